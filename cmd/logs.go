@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gin-admin/internal/app"
+
+	"github.com/spf13/cobra"
+)
+
+func LogsCmd() *cobra.Command {
+
+	// logsCmd represents the logs command
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Manage the logs",
+	}
+
+	// purgeCmd represents the logs purge command
+	purgeCmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Purge the logs created before the time, or expired by the retention rules without --before",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configFile, _ := cmd.Flags().GetString("config")
+			tag, _ := cmd.Flags().GetString("tag")
+			level, _ := cmd.Flags().GetString("level")
+
+			var before time.Time
+			if v, _ := cmd.Flags().GetString("before"); v != "" {
+				var err error
+				if before, err = parseTime(v); err != nil {
+					return fmt.Errorf("invalid --before %q, expected RFC3339 or 2006-01-02", v)
+				}
+			} else if tag != "" || level != "" {
+				return fmt.Errorf("--tag and --level require --before")
+			}
+
+			count, err := app.PurgeLogs(context.Background(), configFile, before, tag, level)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("purged %d logs \n", count)
+			return nil
+		},
+	}
+
+	purgeCmd.Flags().StringP("config", "c", "config.yaml", "Config file")
+	purgeCmd.Flags().String("before", "", "Purge the logs created before the time (RFC3339 or 2006-01-02)")
+	purgeCmd.Flags().String("tag", "", "Only purge the logs of the tag")
+	purgeCmd.Flags().String("level", "", "Only purge the logs of the level")
+
+	cmd.AddCommand(purgeCmd)
+	return cmd
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, v, time.Local)
+}
@@ -14,9 +14,11 @@ func RegisterRouters(app types.AppContext, e *gin.Engine) error {
 		app.Middlewares().I18n(),
 		app.Middlewares().Cors(),
 		app.Middlewares().Trace(),
+		app.Middlewares().Tenant(),
 		app.Middlewares().Logger(),
 		app.Middlewares().CopyBody(),
 		// app.Middlewares().Auth(),
+		// limited by client IP here, and by user in the Auth middleware of the route groups
 		app.Middlewares().RateLimiter(),
 		// app.Middlewares().Casbin(),
 		app.Middlewares().Prometheus(),
@@ -24,10 +26,15 @@ func RegisterRouters(app types.AppContext, e *gin.Engine) error {
 
 	registerRouters(apiV1, e,
 		v1.NewAuth(app),
+		v1.NewAPIKey(app),
+		v1.NewAudit(app),
+		v1.NewOIDC(app),
 		v1.NewCaptcha(app),
+		v1.NewDepartment(app),
 		v1.NewLogger(app),
 		v1.NewMenu(app),
 		v1.NewRole(app),
+		v1.NewTenant(app),
 		v1.NewUser(app),
 	)
 
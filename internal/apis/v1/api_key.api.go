@@ -0,0 +1,124 @@
+package v1
+
+import (
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/services"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/helper"
+	"gin-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Personal API keys of current user
+type APIKey struct {
+	app       types.AppContext
+	APIKeySVC *services.APIKey
+}
+
+func NewAPIKey(app types.AppContext) *APIKey {
+	return &APIKey{
+		app:       app,
+		APIKeySVC: services.NewAPIKey(app),
+	}
+}
+
+func (a *APIKey) RegisterRouter(group *gin.RouterGroup, engine *gin.Engine) {
+
+	g := group.Group("auth/api-keys")
+	g.Use(a.app.Middlewares().Auth())
+
+	g.GET("", a.Query)
+	g.POST("", a.Create)
+	g.PUT(":id", a.Update)
+	g.DELETE(":id", a.Delete)
+}
+
+// @Tags APIKeyAPI
+// @Security ApiKeyAuth
+// @Summary Query API keys of current user
+// @Success 200 {object} dtos.Result[[]models.APIKey]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/api-keys [get]
+func (a *APIKey) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.APIKeySVC.List(ctx, helper.GetUserID(ctx))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, data)
+}
+
+// @Tags APIKeyAPI
+// @Security ApiKeyAuth
+// @Summary Create API key of current user, the plaintext key is only returned once
+// @Param body body dtos.APIKeyCreateReq true "Request body"
+// @Success 200 {object} dtos.Result[dtos.APIKeyCreated]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 403 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/api-keys [post]
+func (a *APIKey) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(dtos.APIKeyCreateReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	result, err := a.APIKeySVC.Create(ctx, helper.GetUserID(ctx), item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, result)
+}
+
+// @Tags APIKeyAPI
+// @Security ApiKeyAuth
+// @Summary Update API key of current user by ID
+// @Param id path string true "unique id"
+// @Param body body dtos.APIKeyUpdateReq true "Request body"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 404 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/api-keys/{id} [put]
+func (a *APIKey) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(dtos.APIKeyUpdateReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	err := a.APIKeySVC.Update(ctx, helper.GetUserID(ctx), c.Param("id"), item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
+
+// @Tags APIKeyAPI
+// @Security ApiKeyAuth
+// @Summary Delete API key of current user by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 404 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/api-keys/{id} [delete]
+func (a *APIKey) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.APIKeySVC.Delete(ctx, helper.GetUserID(ctx), c.Param("id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
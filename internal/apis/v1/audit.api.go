@@ -0,0 +1,57 @@
+package v1
+
+import (
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/services"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audit trail management
+type Audit struct {
+	app      types.AppContext
+	AuditSVC *services.Audit
+}
+
+func NewAudit(app types.AppContext) *Audit {
+	return &Audit{
+		app:      app,
+		AuditSVC: services.NewAudit(app),
+	}
+}
+
+func (a *Audit) RegisterRouter(group *gin.RouterGroup, engine *gin.Engine) {
+	g := group.Group("audits")
+	g.Use(
+		a.app.Middlewares().Auth(),
+		a.app.Middlewares().Casbin(),
+	)
+
+	g.GET("", a.Query)
+}
+
+// @Tags AuditAPI
+// @Security ApiKeyAuth
+// @Summary Query the audit trail of the changes, newest first
+// @Param request query dtos.AuditListReq false "query params"
+// @Success 200 {object} dtos.ResultList[models.Audit]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/audits [get]
+func (a *Audit) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req dtos.AuditListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	result, err := a.AuditSVC.List(ctx, req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.List(c, result.Items, &result.Pager)
+}
@@ -1,6 +1,8 @@
 package v1
 
 import (
+	"net/http"
+
 	"gin-admin/internal/dtos"
 	"gin-admin/internal/errorx"
 	"gin-admin/internal/services"
@@ -14,37 +16,61 @@ import (
 type Auth struct {
 	app     types.AppContext
 	AuthSVC *services.Auth
+	MFASVC  *services.MFA
+	SessSVC *services.Session
+	PassSVC *services.Password
+	PermSVC *services.Permission
 }
 
 func NewAuth(app types.AppContext) *Auth {
 	return &Auth{
 		app:     app,
 		AuthSVC: services.NewAuth(app),
+		MFASVC:  services.NewMFA(app),
+		SessSVC: services.NewSession(app),
+		PassSVC: services.NewPassword(app),
+		PermSVC: services.NewPermission(app),
 	}
 }
 
 func (a *Auth) RegisterRouter(group *gin.RouterGroup, engine *gin.Engine) {
 
+	// the concrete paths to check are resolved against the routes of the engine
+	a.PermSVC.Routes = engine.Routes
+
 	g := group.Group("auth")
 
 	g.POST("login", a.Login)
 	g.POST("refresh-token", a.RefreshToken)
 	g.GET("user", a.app.Middlewares().Auth(), a.GetUserInfo)
 	g.GET("menus", a.app.Middlewares().Auth(), a.QueryMenus)
+	g.GET("codes", a.app.Middlewares().Auth(), a.QueryCodes)
+	g.POST("permissions/check", a.app.Middlewares().Auth(), a.CheckPermissions)
 	g.PUT("password", a.app.Middlewares().Auth(), a.UpdatePassword)
+	g.POST("password/change", a.ChangePassword)
+	g.POST("password/forgot", a.ForgotPassword)
+	g.POST("password/reset", a.ResetPassword)
 	g.PUT("user", a.app.Middlewares().Auth(), a.UpdateUser)
 	g.POST("logout", a.app.Middlewares().Auth(), a.Logout)
+	g.POST("mfa/verify", a.VerifyMFA)
+	g.POST("mfa/enroll", a.app.Middlewares().Auth(), a.EnrollMFA)
+	g.POST("mfa/activate", a.app.Middlewares().Auth(), a.ActivateMFA)
+	g.DELETE("mfa", a.app.Middlewares().Auth(), a.DisableMFA)
+	g.GET("sessions", a.app.Middlewares().Auth(), a.QuerySessions)
+	g.DELETE("sessions/:id", a.app.Middlewares().Auth(), a.RevokeSession)
+
+	engine.GET("/.well-known/jwks.json", a.JWKS)
 }
 
 // @Tags AuthAPI
-// @Summary Login system with username and password
+// @Summary Login system with username and password, a two-factor authentication challenge is returned instead of tokens if required
 // @Param body body dtos.Login true "Request body"
-// @Success 200 {object} dtos.Result[dtos.LoginToken]
+// @Success 200 {object} dtos.Result[dtos.LoginResult]
 // @Failure 400 {object} dtos.Result[any]
 // @Failure 500 {object} dtos.Result[any]
 // @Router /api/v1/auth/login [post]
 func (a *Auth) Login(c *gin.Context) {
-	ctx := c.Request.Context()
+	ctx := helper.WithClient(c)
 	item := new(dtos.Login)
 	if err := c.ShouldBindJSON(item); err != nil {
 		response.Error(c, err)
@@ -83,7 +109,7 @@ func (a *Auth) Logout(c *gin.Context) {
 // @Failure 500 {object} dtos.Result[any]
 // @Router /api/v1/auth/refresh-token [post]
 func (a *Auth) RefreshToken(c *gin.Context) {
-	ctx := c.Request.Context()
+	ctx := helper.WithClient(c)
 
 	refreshToken := helper.GetToken(c)
 	if refreshToken == "" {
@@ -141,6 +167,76 @@ func (a *Auth) UpdatePassword(c *gin.Context) {
 	response.OK(c)
 }
 
+// @Tags AuthAPI
+// @Summary Change the expired or administrator set password with the challenge returned by login, then complete the login
+// @Param body body dtos.PasswordChangeReq true "Request body"
+// @Success 200 {object} dtos.Result[dtos.LoginResult]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/password/change [post]
+func (a *Auth) ChangePassword(c *gin.Context) {
+	ctx := helper.WithClient(c)
+	item := new(dtos.PasswordChangeReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	data, err := a.AuthSVC.ChangePassword(ctx, item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, data)
+}
+
+// @Tags AuthAPI
+// @Summary Mail a password reset code to the account with the email, the response is the same whether the account exists or not
+// @Param body body dtos.PasswordForgotReq true "Request body"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 503 {object} dtos.Result[any]
+// @Router /api/v1/auth/password/forgot [post]
+func (a *Auth) ForgotPassword(c *gin.Context) {
+	ctx := helper.WithClient(c)
+	item := new(dtos.PasswordForgotReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	err := a.PassSVC.Forgot(ctx, item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
+
+// @Tags AuthAPI
+// @Summary Set a new password with the mailed reset code
+// @Param body body dtos.PasswordResetReq true "Request body"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/password/reset [post]
+func (a *Auth) ResetPassword(c *gin.Context) {
+	ctx := helper.WithClient(c)
+	item := new(dtos.PasswordResetReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	err := a.AuthSVC.ResetPassword(ctx, item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
+
 // @Tags AuthAPI
 // @Security ApiKeyAuth
 // @Summary Query current user menus based on the current user role
@@ -158,6 +254,48 @@ func (a *Auth) QueryMenus(c *gin.Context) {
 	response.OkData(c, data)
 }
 
+// @Tags AuthAPI
+// @Security ApiKeyAuth
+// @Summary Query the permission codes of the buttons granted to the current user
+// @Success 200 {object} dtos.Result[[]string]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/codes [get]
+func (a *Auth) QueryCodes(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.PermSVC.QueryCodes(ctx)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, data)
+}
+
+// @Tags AuthAPI
+// @Security ApiKeyAuth
+// @Summary Check whether the current user is allowed the APIs or button permission codes
+// @Param body body dtos.PermissionCheckReq true "Request body"
+// @Success 200 {object} dtos.Result[[]dtos.PermissionCheckResult]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/permissions/check [post]
+func (a *Auth) CheckPermissions(c *gin.Context) {
+	ctx := helper.WithClient(c)
+	item := new(dtos.PermissionCheckReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	data, err := a.PermSVC.Check(ctx, item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, data)
+}
+
 // @Tags AuthAPI
 // @Security ApiKeyAuth
 // @Summary Update current user info
@@ -182,3 +320,142 @@ func (a *Auth) UpdateUser(c *gin.Context) {
 	}
 	response.OK(c)
 }
+
+// @Tags AuthAPI
+// @Summary Complete login with the two-factor authentication code
+// @Param body body dtos.MFAVerifyReq true "Request body"
+// @Success 200 {object} dtos.Result[dtos.LoginToken]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/mfa/verify [post]
+func (a *Auth) VerifyMFA(c *gin.Context) {
+	ctx := helper.WithClient(c)
+	item := new(dtos.MFAVerifyReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	data, err := a.AuthSVC.VerifyMFA(ctx, item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, data)
+}
+
+// @Tags AuthAPI
+// @Security ApiKeyAuth
+// @Summary Start two-factor authentication enrollment of current user
+// @Success 200 {object} dtos.Result[dtos.MFASetup]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 409 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/mfa/enroll [post]
+func (a *Auth) EnrollMFA(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.MFASVC.Enroll(ctx)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, data)
+}
+
+// @Tags AuthAPI
+// @Security ApiKeyAuth
+// @Summary Complete two-factor authentication enrollment of current user
+// @Param body body dtos.MFACodeReq true "Request body"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/mfa/activate [post]
+func (a *Auth) ActivateMFA(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(dtos.MFACodeReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	err := a.MFASVC.Activate(ctx, item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
+
+// @Tags AuthAPI
+// @Security ApiKeyAuth
+// @Summary Disable two-factor authentication of current user
+// @Param body body dtos.MFACodeReq true "Request body"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 403 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/mfa [delete]
+func (a *Auth) DisableMFA(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(dtos.MFACodeReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	err := a.MFASVC.Disable(ctx, item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
+
+// @Tags AuthAPI
+// @Security ApiKeyAuth
+// @Summary Query active sessions of current user
+// @Success 200 {object} dtos.Result[[]dtos.Session]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/sessions [get]
+func (a *Auth) QuerySessions(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.SessSVC.List(ctx, helper.GetUserID(ctx))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, data)
+}
+
+// @Tags AuthAPI
+// @Security ApiKeyAuth
+// @Summary Revoke a session of current user
+// @Param id path string true "session id"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 404 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/sessions/{id} [delete]
+func (a *Auth) RevokeSession(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.SessSVC.Revoke(ctx, helper.GetUserID(ctx), c.Param("id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
+
+// @Tags AuthAPI
+// @Summary Public keys to verify the access tokens (JSON Web Key Set)
+// @Success 200 {object} jwtx.JWKS
+// @Router /.well-known/jwks.json [get]
+func (a *Auth) JWKS(c *gin.Context) {
+	ctx := c.Request.Context()
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, a.AuthSVC.JWKS(ctx))
+}
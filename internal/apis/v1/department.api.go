@@ -0,0 +1,178 @@
+package v1
+
+import (
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/services"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Department management for SYS
+type Department struct {
+	app     types.AppContext
+	DeptSVC *services.Department
+}
+
+func NewDepartment(app types.AppContext) *Department {
+	return &Department{
+		app:     app,
+		DeptSVC: services.NewDepartment(app),
+	}
+}
+
+func (a *Department) RegisterRouter(group *gin.RouterGroup, engine *gin.Engine) {
+
+	g := group.Group("departments")
+	g.Use(
+		a.app.Middlewares().Auth(),
+		a.app.Middlewares().Casbin(),
+	)
+
+	g.GET("", a.Query)
+	g.GET(":id", a.Get)
+	g.POST("", a.Create)
+	g.PUT(":id", a.Update)
+	g.PUT(":id/move", a.Move)
+	g.DELETE(":id", a.Delete)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Query department list, the tree is built by the parent ID
+// @Param request query dtos.DepartmentListReq false "query params"
+// @Success 200 {object} dtos.ResultList[models.Department]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/departments [get]
+func (a *Department) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params dtos.DepartmentListReq
+	if err := c.ShouldBindQuery(&params); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	result, err := a.DeptSVC.List(ctx, params)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.List(c, result.Items, &result.Pager)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Get department record by ID, with its sub departments and leaders
+// @Param id path string true "unique id"
+// @Success 200 {object} dtos.Result[models.Department]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 404 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/departments/{id} [get]
+func (a *Department) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.DeptSVC.Get(ctx, c.Param("id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, item)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Create department record
+// @Param body body dtos.DepartmentCreateReq true "Request body"
+// @Success 200 {object} dtos.Result[models.Department]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/departments [post]
+func (a *Department) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(dtos.DepartmentCreateReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	result, err := a.DeptSVC.Create(ctx, item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, result)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Update department record by ID
+// @Param id path string true "unique id"
+// @Param body body dtos.DepartmentUpdateReq true "Request body"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/departments/{id} [put]
+func (a *Department) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(dtos.DepartmentUpdateReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	err := a.DeptSVC.Update(ctx, c.Param("id"), item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Move department under another parent with its sub departments, or reorder it among the siblings
+// @Param id path string true "unique id"
+// @Param body body dtos.DepartmentMoveReq true "Request body"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/departments/{id}/move [put]
+func (a *Department) Move(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(dtos.DepartmentMoveReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	err := a.DeptSVC.Move(ctx, c.Param("id"), item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Delete department record by ID, it must have no sub departments and members
+// @Param id path string true "unique id"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/departments/{id} [delete]
+func (a *Department) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.DeptSVC.Delete(ctx, c.Param("id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
@@ -1,9 +1,18 @@
 package v1
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
 	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
 	"gin-admin/internal/services"
 	"gin-admin/internal/types"
+	"gin-admin/pkg/logger"
 	"gin-admin/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -30,6 +39,8 @@ func (a *Logger) RegisterRouter(group *gin.RouterGroup, engine *gin.Engine) {
 	)
 
 	g.GET("", a.Query)
+	g.GET("export", a.Export)
+	g.GET("stats", a.Stats)
 }
 
 // @Tags LoggerAPI
@@ -55,3 +66,182 @@ func (a *Logger) Query(c *gin.Context) {
 	}
 	response.List(c, result.Items, &result.Pager)
 }
+
+// @Tags LoggerAPI
+// @Security ApiKeyAuth
+// @Summary Get the statistics of the loggers in a time range
+// @Param request query dtos.LoggerStatsReq false "query params"
+// @Success 200 {object} dtos.Result[dtos.LoggerStats]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/loggers/stats [get]
+func (a *Logger) Stats(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req dtos.LoggerStatsReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	result, err := a.LoggerSVC.Stats(ctx, req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, result)
+}
+
+// @Tags LoggerAPI
+// @Security ApiKeyAuth
+// @Summary Export logger list as a CSV or NDJSON file
+// @Param request query dtos.LoggerExportReq false "query params"
+// @Produce text/csv,application/x-ndjson
+// @Success 200 {file} file
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/loggers/export [get]
+func (a *Logger) Export(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req dtos.LoggerExportReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	var w loggerExportWriter
+	if req.Format == "ndjson" {
+		w = &loggerNDJSONWriter{enc: json.NewEncoder(c.Writer)}
+	} else {
+		req.Format = "csv"
+		w = &loggerCSVWriter{w: csv.NewWriter(c.Writer)}
+	}
+
+	// the headers are written with the first log, so the errors before it are still responded as usual
+	started := false
+	start := func() error {
+		if !started {
+			started = true
+			c.Header("Content-Type", w.ContentType())
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="logs-%s.%s"`, time.Now().Format("20060102150405"), req.Format))
+			c.Status(http.StatusOK)
+			return w.Header()
+		}
+		return nil
+	}
+
+	count := 0
+	err := a.LoggerSVC.Export(ctx, req.LoggerListReq, func(item *models.Logger) error {
+		if err := start(); err != nil {
+			return err
+		}
+		if err := w.Write(item); err != nil {
+			return err
+		}
+
+		// stream the logs to the client instead of buffering the whole file
+		if count++; count%100 == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			response.Error(c, err)
+			return
+		}
+		// the response is already started, the client gets a truncated file
+		logger.Error(ctx, "Failed to export logs", err)
+		c.Abort()
+		return
+	}
+
+	if err := start(); err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		logger.Error(ctx, "Failed to export logs", err)
+	}
+}
+
+type loggerExportWriter interface {
+	ContentType() string
+	Header() error
+	Write(item *models.Logger) error
+	Flush() error
+}
+
+type loggerCSVWriter struct {
+	w *csv.Writer
+}
+
+func (a *loggerCSVWriter) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (a *loggerCSVWriter) Header() error {
+	return a.w.Write([]string{"id", "createdAt", "level", "tag", "traceId", "userId", "username", "nickName", "tenantId", "message", "stack", "meta"})
+}
+
+func (a *loggerCSVWriter) Write(item *models.Logger) error {
+	var meta string
+	if len(item.Meta) > 0 {
+		b, err := json.Marshal(item.Meta)
+		if err != nil {
+			return err
+		}
+		meta = string(b)
+	}
+
+	return a.w.Write([]string{
+		item.ID,
+		item.CreatedAt.Format(time.RFC3339),
+		item.Level,
+		item.Tag,
+		item.TraceID,
+		item.UserID,
+		csvSafe(item.Username),
+		csvSafe(item.NickName),
+		item.TenantID,
+		csvSafe(item.Message),
+		item.Stack,
+		csvSafe(meta),
+	})
+}
+
+// Prefix the field with a quote if it starts like a formula, so the spreadsheets opening the file do not evaluate it.
+func csvSafe(field string) string {
+	if field != "" && strings.ContainsRune("=+-@", rune(field[0])) {
+		return "'" + field
+	}
+	return field
+}
+
+func (a *loggerCSVWriter) Flush() error {
+	a.w.Flush()
+	return a.w.Error()
+}
+
+type loggerNDJSONWriter struct {
+	enc *json.Encoder
+}
+
+func (a *loggerNDJSONWriter) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (a *loggerNDJSONWriter) Header() error {
+	return nil
+}
+
+func (a *loggerNDJSONWriter) Write(item *models.Logger) error {
+	return a.enc.Encode(item)
+}
+
+func (a *loggerNDJSONWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,198 @@
+package v1
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/services"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/helper"
+	"gin-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cookie binding the state of a login to the user agent which started it
+const oidcStateCookie = "oidc_state"
+
+// OpenID Connect single sign-on
+type OIDC struct {
+	app     types.AppContext
+	OIDCSVC *services.OIDC
+}
+
+func NewOIDC(app types.AppContext) *OIDC {
+	return &OIDC{
+		app:     app,
+		OIDCSVC: services.NewOIDC(app),
+	}
+}
+
+func (a *OIDC) RegisterRouter(group *gin.RouterGroup, engine *gin.Engine) {
+
+	g := group.Group("auth/oidc")
+
+	g.GET("providers", a.QueryProviders)
+	g.GET(":provider/login", a.Login)
+	g.GET(":provider/callback", a.Callback)
+	g.POST(":provider/link", a.app.Middlewares().Auth(), a.Link)
+	g.POST(":provider/link/callback", a.app.Middlewares().Auth(), a.LinkCallback)
+
+	group.GET("auth/identities", a.app.Middlewares().Auth(), a.QueryIdentities)
+	group.DELETE("auth/identities/:id", a.app.Middlewares().Auth(), a.Unlink)
+}
+
+// @Tags OIDCAPI
+// @Summary Query identity providers available for single sign-on
+// @Success 200 {object} dtos.Result[[]dtos.OIDCProvider]
+// @Router /api/v1/auth/oidc/providers [get]
+func (a *OIDC) QueryProviders(c *gin.Context) {
+	ctx := c.Request.Context()
+	response.OkData(c, a.OIDCSVC.Providers(ctx))
+}
+
+// @Tags OIDCAPI
+// @Summary Redirect to the identity provider to login
+// @Param provider path string true "provider name"
+// @Success 302
+// @Failure 404 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/oidc/{provider}/login [get]
+func (a *OIDC) Login(c *gin.Context) {
+	ctx := c.Request.Context()
+	authURL, state, err := a.OIDCSVC.LoginURL(ctx, c.Param("provider"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	a.setStateCookie(c, state, configs.C.OIDC.StateExpiration)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// The cookie is sent back with the top-level redirect from the provider only, so it is lax and limited to the OIDC routes.
+func (a *OIDC) setStateCookie(c *gin.Context, state string, maxAge int) {
+	path := c.FullPath()
+	path = path[:strings.Index(path, ":provider")]
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, maxAge, path, "", c.Request.TLS != nil, true)
+}
+
+// @Tags OIDCAPI
+// @Summary Complete the login redirected back from the identity provider, a two-factor authentication challenge is returned instead of tokens if required
+// @Param provider path string true "provider name"
+// @Param request query dtos.OIDCCallbackReq true "query params"
+// @Success 200 {object} dtos.Result[dtos.LoginResult]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 403 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/oidc/{provider}/callback [get]
+func (a *OIDC) Callback(c *gin.Context) {
+	ctx := helper.WithClient(c)
+	item := new(dtos.OIDCCallbackReq)
+	if err := c.ShouldBindQuery(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	// the login must be completed by the user agent which started it
+	state, _ := c.Cookie(oidcStateCookie)
+	a.setStateCookie(c, "", -1)
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(item.State)) != 1 {
+		response.Error(c, errorx.ErrOIDCStateInvalid.New(ctx))
+		return
+	}
+
+	data, err := a.OIDCSVC.Callback(ctx, c.Param("provider"), item, "")
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, data)
+}
+
+// @Tags OIDCAPI
+// @Security ApiKeyAuth
+// @Summary Start linking an identity of the provider to current user
+// @Param provider path string true "provider name"
+// @Success 200 {object} dtos.Result[dtos.OIDCAuthURL]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 404 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/oidc/{provider}/link [post]
+func (a *OIDC) Link(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.OIDCSVC.LinkURL(ctx, c.Param("provider"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, data)
+}
+
+// @Tags OIDCAPI
+// @Security ApiKeyAuth
+// @Summary Complete linking the identity redirected back from the provider to current user
+// @Param provider path string true "provider name"
+// @Param body body dtos.OIDCCallbackReq true "Request body"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 409 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/oidc/{provider}/link/callback [post]
+func (a *OIDC) LinkCallback(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(dtos.OIDCCallbackReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	_, err := a.OIDCSVC.Callback(ctx, c.Param("provider"), item, helper.GetUserID(ctx))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
+
+// @Tags OIDCAPI
+// @Security ApiKeyAuth
+// @Summary Query external identities linked to current user
+// @Success 200 {object} dtos.Result[[]models.UserIdentity]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/identities [get]
+func (a *OIDC) QueryIdentities(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.OIDCSVC.ListIdentities(ctx, helper.GetUserID(ctx))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, data)
+}
+
+// @Tags OIDCAPI
+// @Security ApiKeyAuth
+// @Summary Unlink an external identity from current user
+// @Param id path string true "unique id"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 404 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/auth/identities/{id} [delete]
+func (a *OIDC) Unlink(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.OIDCSVC.Unlink(ctx, helper.GetUserID(ctx), c.Param("id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
@@ -0,0 +1,149 @@
+package v1
+
+import (
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/services"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tenant management, only out of any tenant
+type Tenant struct {
+	app       types.AppContext
+	TenantSVC *services.Tenant
+}
+
+func NewTenant(app types.AppContext) *Tenant {
+	return &Tenant{
+		app:       app,
+		TenantSVC: services.NewTenant(app),
+	}
+}
+
+func (a *Tenant) RegisterRouter(group *gin.RouterGroup, engine *gin.Engine) {
+
+	g := group.Group("tenants")
+	g.Use(
+		a.app.Middlewares().Auth(),
+		a.app.Middlewares().Casbin(),
+	)
+
+	g.GET("", a.Query)
+	g.GET(":id", a.Get)
+	g.POST("", a.Create)
+	g.PUT(":id", a.Update)
+	g.DELETE(":id", a.Delete)
+}
+
+// @Tags TenantAPI
+// @Security ApiKeyAuth
+// @Summary Query tenant list
+// @Param request query dtos.TenantListReq false "query params"
+// @Success 200 {object} dtos.ResultList[models.Tenant]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/tenants [get]
+func (a *Tenant) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params dtos.TenantListReq
+	if err := c.ShouldBindQuery(&params); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	result, err := a.TenantSVC.List(ctx, params)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.List(c, result.Items, &result.Pager)
+}
+
+// @Tags TenantAPI
+// @Security ApiKeyAuth
+// @Summary Get tenant record by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} dtos.Result[models.Tenant]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/tenants/{id} [get]
+func (a *Tenant) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.TenantSVC.Get(ctx, c.Param("id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, item)
+}
+
+// @Tags TenantAPI
+// @Security ApiKeyAuth
+// @Summary Create tenant record with its super admin
+// @Param body body dtos.TenantCreateReq true "Request body"
+// @Success 200 {object} dtos.Result[models.Tenant]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/tenants [post]
+func (a *Tenant) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(dtos.TenantCreateReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	result, err := a.TenantSVC.Create(ctx, item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, result)
+}
+
+// @Tags TenantAPI
+// @Security ApiKeyAuth
+// @Summary Update tenant record by ID
+// @Param id path string true "unique id"
+// @Param body body dtos.TenantUpdateReq true "Request body"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/tenants/{id} [put]
+func (a *Tenant) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(dtos.TenantUpdateReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	err := a.TenantSVC.Update(ctx, c.Param("id"), item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
+
+// @Tags TenantAPI
+// @Security ApiKeyAuth
+// @Summary Delete tenant record by ID with its data
+// @Param id path string true "unique id"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/tenants/{id} [delete]
+func (a *Tenant) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.TenantSVC.Delete(ctx, c.Param("id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
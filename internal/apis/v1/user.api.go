@@ -13,12 +13,16 @@ import (
 type User struct {
 	app     types.AppContext
 	UserSVC *services.User
+	SessSVC *services.Session
+	KeySVC  *services.APIKey
 }
 
 func NewUser(app types.AppContext) *User {
 	return &User{
 		app:     app,
 		UserSVC: services.NewUser(app),
+		SessSVC: services.NewSession(app),
+		KeySVC:  services.NewAPIKey(app),
 	}
 }
 
@@ -36,6 +40,12 @@ func (a *User) RegisterRouter(group *gin.RouterGroup, engine *gin.Engine) {
 	g.PUT(":id", a.Update)
 	g.DELETE(":id", a.Delete)
 	g.PATCH(":id/reset-pwd", a.ResetPassword)
+	g.PATCH(":id/unlock", a.Unlock)
+	g.GET(":id/sessions", a.QuerySessions)
+	g.DELETE(":id/sessions/:sid", a.RevokeSession)
+	g.GET(":id/api-keys", a.QueryAPIKeys)
+	g.POST(":id/api-keys", a.CreateAPIKey)
+	g.DELETE(":id/api-keys/:kid", a.DeleteAPIKey)
 }
 
 // @Tags UserAPI
@@ -166,3 +176,125 @@ func (a *User) ResetPassword(c *gin.Context) {
 	}
 	response.OK(c)
 }
+
+// @Tags UserAPI
+// @Security ApiKeyAuth
+// @Summary Clear the login lockout of user by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 404 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/users/{id}/unlock [patch]
+func (a *User) Unlock(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.UserSVC.Unlock(ctx, c.Param("id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
+
+// @Tags UserAPI
+// @Security ApiKeyAuth
+// @Summary Query active sessions of user by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} dtos.Result[[]dtos.Session]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/users/{id}/sessions [get]
+func (a *User) QuerySessions(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.SessSVC.List(ctx, c.Param("id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, data)
+}
+
+// @Tags UserAPI
+// @Security ApiKeyAuth
+// @Summary Revoke a session of user by ID
+// @Param id path string true "unique id"
+// @Param sid path string true "session id"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 404 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/users/{id}/sessions/{sid} [delete]
+func (a *User) RevokeSession(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.SessSVC.Revoke(ctx, c.Param("id"), c.Param("sid"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
+
+// @Tags UserAPI
+// @Security ApiKeyAuth
+// @Summary Query API keys of user by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} dtos.Result[[]models.APIKey]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/users/{id}/api-keys [get]
+func (a *User) QueryAPIKeys(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.KeySVC.List(ctx, c.Param("id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, data)
+}
+
+// @Tags UserAPI
+// @Security ApiKeyAuth
+// @Summary Create API key of user by ID (e.g. for service accounts), the plaintext key is only returned once
+// @Param id path string true "unique id"
+// @Param body body dtos.APIKeyCreateReq true "Request body"
+// @Success 200 {object} dtos.Result[dtos.APIKeyCreated]
+// @Failure 400 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 404 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/users/{id}/api-keys [post]
+func (a *User) CreateAPIKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(dtos.APIKeyCreateReq)
+	if err := c.ShouldBindJSON(item); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	result, err := a.KeySVC.Create(ctx, c.Param("id"), item)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OkData(c, result)
+}
+
+// @Tags UserAPI
+// @Security ApiKeyAuth
+// @Summary Delete API key of user by ID
+// @Param id path string true "unique id"
+// @Param kid path string true "api key id"
+// @Success 200 {object} dtos.Result[any]
+// @Failure 401 {object} dtos.Result[any]
+// @Failure 404 {object} dtos.Result[any]
+// @Failure 500 {object} dtos.Result[any]
+// @Router /api/v1/users/{id}/api-keys/{kid} [delete]
+func (a *User) DeleteAPIKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.KeySVC.Delete(ctx, c.Param("id"), c.Param("kid"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.OK(c)
+}
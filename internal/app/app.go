@@ -17,6 +17,7 @@ import (
 	"gin-admin/pkg/cachex"
 	"gin-admin/pkg/jwtx"
 	"gin-admin/pkg/logger"
+	"gin-admin/pkg/mail"
 	"gin-admin/pkg/middleware"
 	"gin-admin/pkg/response"
 	"gin-admin/pkg/uploader"
@@ -34,6 +35,7 @@ type App struct {
 	cacher   cachex.Cacher
 	jwt      jwtx.Auther
 	uploader *uploader.Uploader
+	mailer   *mail.SmtpSender
 	casbin   types.Casbinx
 
 	middlewares *modules.Middlewares
@@ -54,8 +56,13 @@ func New(ctx context.Context, c *configs.Config) *App {
 	app.db = util.Must(modules.InitDB(ctx, app))
 	app.jwt = util.Must(modules.InitJWT(ctx, app))
 	app.uploader = util.Must(modules.InitUploader(ctx, app))
+	app.mailer = util.Must(modules.InitMailer(ctx, app))
 	app.casbin = util.Must(modules.InitCasbinx(ctx, app))
 
+	if err := modules.InitCaptcha(ctx, app); err != nil {
+		panic(err)
+	}
+
 	app.middlewares = modules.NewMiddlewares(app)
 
 	return app
@@ -81,6 +88,10 @@ func (a *App) Uploader() *uploader.Uploader {
 	return a.uploader
 }
 
+func (a *App) Mailer() *mail.SmtpSender {
+	return a.mailer
+}
+
 func (a *App) Casbin() types.Casbinx {
 	return a.casbin
 }
@@ -96,11 +107,18 @@ func (a *App) AddCleaner(ctx context.Context, cleaner func()) {
 func (a *App) autoMigrate(_ context.Context) error {
 	return a.db.AutoMigrate(
 		new(models.Logger),
+		new(models.LoggerRollup),
+		new(models.Audit),
 		new(models.MenuRole),
 		new(models.UserRole),
 		new(models.Menu),
 		new(models.Role),
 		new(models.User),
+		new(models.APIKey),
+		new(models.UserIdentity),
+		new(models.Department),
+		new(models.UserDepartment),
+		new(models.Tenant),
 	)
 }
 
@@ -135,6 +153,10 @@ func (a *App) InitHttp(ctx context.Context) error {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	e := gin.New()
+	// the client IP is only taken from the forwarded headers of the trusted proxies, none are trusted by default
+	if err := e.SetTrustedProxies(configs.C.HTTP.TrustedProxies); err != nil {
+		return err
+	}
 	e.GET("/health", func(c *gin.Context) {
 		response.OK(c)
 	})
@@ -152,6 +174,13 @@ func (a *App) InitHttp(ctx context.Context) error {
 		return err
 	}
 
+	// report the drift between the registered routes and the button resources
+	if configs.C.Menu.RouteSync.Enable {
+		if _, err := services.NewRouteSync(a).Sync(ctx, e.Routes()); err != nil {
+			logger.Error(ctx, "Failed to sync routes to button resources", err)
+		}
+	}
+
 	// Register swagger
 	if !configs.C.Swagger.Disable {
 		e.StaticFile("/openapi.json", configs.C.Swagger.StaticFile)
@@ -202,6 +231,32 @@ func (a *App) InitHttp(ctx context.Context) error {
 	return nil
 }
 
+// Start the background jobs, they are stopped by the release of the app.
+func (a *App) InitJobs(ctx context.Context) {
+	if cfg := configs.C.LogRetention; cfg.Enable && cfg.Interval > 0 {
+		ctx, cancel := context.WithCancel(logger.WithTag(ctx, logger.Tag_System))
+		a.AddCleaner(ctx, cancel)
+
+		retention := services.NewLoggerRetention(a)
+		go func() {
+			ticker := time.NewTicker(time.Second * time.Duration(cfg.Interval))
+			defer ticker.Stop()
+
+			for {
+				if _, err := retention.PurgeExpired(ctx); err != nil && ctx.Err() == nil {
+					logger.Error(ctx, "Failed to purge the expired logs", err)
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
 func (a *App) Release(ctx context.Context) error {
 	for _, cleaner := range a.cleaners {
 		cleaner()
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/services"
+	"gin-admin/pkg/logger"
+)
+
+// The PurgeLogs function purges the logs created before the time and matched by the tag and level,
+// the logs expired by the retention rules are purged if the time is zero.
+func PurgeLogs(ctx context.Context, configFile string, before time.Time, tag, level string) (int64, error) {
+	configs.MustLoad(ctx, configFile)
+
+	cleanLoggerFn, err := logger.InitWithConfig(ctx, &configs.C.Logger)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cleanLoggerFn != nil {
+			cleanLoggerFn()
+		}
+	}()
+	ctx = logger.WithTag(ctx, logger.Tag_System)
+
+	app := New(ctx, configs.C)
+	defer func() {
+		if err := app.Release(ctx); err != nil {
+			logger.Error(ctx, "failed to release app context", err)
+		}
+	}()
+
+	// the rollup table may not exist yet if the server has not run since it was added
+	if app.Config().DB.AutoMigrate {
+		if err := app.autoMigrate(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	retention := services.NewLoggerRetention(app)
+	if before.IsZero() {
+		return retention.PurgeExpired(ctx)
+	}
+	return retention.Purge(ctx, before, tag, level)
+}
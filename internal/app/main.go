@@ -62,6 +62,9 @@ func Run(ctx context.Context, configFile string) error {
 
 	return run(ctx, func(ctx context.Context) (func(), error) {
 		err := app.InitHttp(ctx)
+		if err == nil {
+			app.InitJobs(ctx)
+		}
 
 		cleaner := func() {
 
@@ -6,6 +6,7 @@ import (
 
 	"gin-admin/internal/types"
 	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/helper"
 )
 
 // It returns a cachex.Cacher instance, a function to close the cache, and an error
@@ -31,6 +32,14 @@ func InitCacher(ctx context.Context, app types.AppContext) (cachex.Cacher, error
 		}, cachex.WithDelimiter(cfg.Delimiter))
 	}
 
+	if app.Config().Tenant.Enable {
+		// the tenants share the cache, each in its own namespaces
+		cache = cachex.NewScopedCache(cache, func(ctx context.Context) string {
+			tenantID, _ := helper.GetTenantID(ctx)
+			return tenantID
+		}, cachex.WithDelimiter(cfg.Delimiter))
+	}
+
 	app.AddCleaner(ctx, func() {
 		_ = cache.Close(ctx)
 	})
@@ -0,0 +1,59 @@
+package modules
+
+import (
+	"context"
+	"time"
+
+	"gin-admin/internal/types"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/captchax"
+
+	"github.com/LyricTian/captcha"
+)
+
+// Replace the in-process captcha store with a cache-backed one, so that captchas work across multiple instances.
+func InitCaptcha(ctx context.Context, app types.AppContext) error {
+	cfg := app.Config().Captcha
+
+	var (
+		cache cachex.Cacher
+		ns    = "captcha"
+	)
+	switch cfg.CacheType {
+	case "redis":
+		// the dedicated redis falls back to the redis of the cache
+		redisCfg := cachex.RedisConfig{
+			Addr:     cfg.Redis.Addr,
+			DB:       cfg.Redis.DB,
+			Username: cfg.Redis.Username,
+			Password: cfg.Redis.Password,
+		}
+		if redisCfg.Addr == "" {
+			cacheCfg := app.Config().Cache.Redis
+			redisCfg.Addr, redisCfg.Username, redisCfg.Password = cacheCfg.Addr, cacheCfg.Username, cacheCfg.Password
+		}
+		cache = cachex.NewRedisCache(redisCfg, cachex.WithDelimiter(""))
+		ns = cfg.Redis.KeyPrefix
+		app.AddCleaner(ctx, func() {
+			_ = cache.Close(ctx)
+		})
+	case "memory":
+		// only works with a single instance
+		cache = cachex.NewMemoryCache(cachex.MemoryConfig{
+			CleanupInterval: time.Minute,
+		})
+		app.AddCleaner(ctx, func() {
+			_ = cache.Close(ctx)
+		})
+	default:
+		// shared by the instances as the cache of the app is
+		cache = app.Cacher()
+	}
+
+	captcha.SetCustomStore(captchax.NewStoreWithCache(cache,
+		captchax.WithCacheNS(ns),
+		captchax.WithExpiration(time.Second*time.Duration(cfg.Expiration)),
+	))
+
+	return nil
+}
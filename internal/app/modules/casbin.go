@@ -1,49 +1,56 @@
 package modules
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"gin-admin/internal/configs"
-	"gin-admin/internal/dtos"
 	"gin-admin/internal/errorx"
 	"gin-admin/internal/models"
 	"gin-admin/internal/repositories"
-	"gin-admin/internal/services"
 	"gin-admin/internal/types"
 	"gin-admin/pkg/cachex"
-	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/encoding/json"
 	"gin-admin/pkg/logger"
+	"gin-admin/pkg/randx"
 
 	"github.com/casbin/casbin/v2"
 )
 
+// Channel of the role IDs whose policies are changed
+const casbinPolicyChannel = "casbin_policy"
+
+type casbinPolicyMessage struct {
+	Origin  string   `json:"origin"`  // Instance publishing the message
+	RoleIDs []string `json:"roleIds"` // Roles whose policies are changed
+}
+
 // Load rbac permissions to casbin
 type Casbinx struct {
-	enforcer *atomic.Value
-	ticker   *time.Ticker
-	Cache    cachex.Cacher
-	// MenuRepo *repositories.Menu
-	RoleRepo *repositories.Role
-	MenuSvc  *services.Menu
-	RoleSvc  *services.Role
+	id          string // Unique ID of the instance
+	enforcer    *atomic.Value
+	adapter     *casbinAdapter
+	ticker      *time.Ticker
+	unsubscribe func()
+	conditions  sync.Map // Parsed grant conditions by their JSON
+	Cache       cachex.Cacher
 }
 
 var _ types.Casbinx = (*Casbinx)(nil)
 
 func InitCasbinx(ctx context.Context, app types.AppContext) (types.Casbinx, error) {
 	cb := &Casbinx{
+		id:       randx.NewXID(),
+		enforcer: new(atomic.Value),
+		adapter: &casbinAdapter{
+			RoleRepo:     repositories.NewRole(app.DB()),
+			MenuRoleRepo: repositories.NewMenuRole(app.DB()),
+		},
 		Cache: app.Cacher(),
-		// MenuRepo: repositories.NewMenu(app.DB()),
-		RoleRepo: repositories.NewRole(app.DB()),
-		MenuSvc:  services.NewMenu(app),
-		RoleSvc:  services.NewRole(app),
 	}
 
 	app.AddCleaner(ctx, func() {
@@ -53,146 +60,207 @@ func InitCasbinx(ctx context.Context, app types.AppContext) (types.Casbinx, erro
 	return cb, nil
 }
 
-func (a *Casbinx) GetEnforcer() *casbin.Enforcer {
+func (a *Casbinx) GetEnforcer() *casbin.SyncedEnforcer {
 	if v := a.enforcer.Load(); v != nil {
-		return v.(*casbin.Enforcer)
+		return v.(*casbin.SyncedEnforcer)
 	}
 	return nil
 }
 
-type policyQueueItem struct {
-	RoleID string
-	Menus  models.Menus
-}
-
 func (a *Casbinx) Load(ctx context.Context) error {
 	if configs.C.Middleware.Casbin.Disable {
 		return nil
 	}
 
-	a.enforcer = new(atomic.Value)
-	if err := a.load(ctx); err != nil {
+	e, err := casbin.NewSyncedEnforcer(configs.C.Middleware.Casbin.ModelFile, a.adapter)
+	if err != nil {
+		logger.Error(ctx, "Failed to create casbin enforcer", err)
+		return err
+	}
+	// the policies are derived from roles and menus, they are never saved back
+	e.EnableAutoSave(false)
+	e.EnableLog(configs.C.IsDebug())
+	e.AddFunction("grantMatch", a.grantMatch)
+	a.enforcer.Store(e)
+
+	// the changes made by other instances
+	unsubscribe, err := a.Cache.Subscribe(ctx, casbinPolicyChannel, func(ctx context.Context, message string) {
+		var msg casbinPolicyMessage
+		if err := json.Unmarshal([]byte(message), &msg); err != nil {
+			logger.Error(ctx, "Failed to parse casbin policy message", err)
+			return
+		}
+		if msg.Origin == a.id {
+			return
+		}
+		if err := a.reloadRoles(ctx, msg.RoleIDs...); err != nil {
+			logger.Error(ctx, "Failed to reload casbin role policies", err)
+		}
+	})
+	if err != nil {
+		logger.Error(ctx, "Failed to subscribe casbin policy changes", err)
 		return err
 	}
+	a.unsubscribe = unsubscribe
+
+	if interval := configs.C.Middleware.Casbin.AutoLoadInterval; interval > 0 {
+		a.ticker = time.NewTicker(time.Duration(interval) * time.Second)
+		go a.autoLoad(ctx, a.ticker)
+	}
 
-	go a.autoLoad(ctx)
 	return nil
 }
 
-func (a *Casbinx) load(ctx context.Context) error {
-	start := time.Now()
-	roles, err := a.RoleRepo.Find(ctx, gormx.WithWhere("status = ?", models.RoleStatus_Enabled), gormx.WithSelect("id"))
+// Reload all the policies periodically, the changes whose messages are lost are applied in the end.
+func (a *Casbinx) autoLoad(ctx context.Context, ticker *time.Ticker) {
+	for range ticker.C {
+		start := time.Now()
+		if err := a.GetEnforcer().LoadPolicy(); err != nil {
+			logger.Error(ctx, "Failed to reload casbin policies", err)
+			continue
+		}
+		logger.Debug(ctx, "Casbin reload policies", map[string]any{"cost": time.Since(start)})
+	}
+}
+
+// Reload the policies of the roles, the other instances are notified to reload them as well.
+func (a *Casbinx) ReloadRoles(ctx context.Context, roleIDs ...string) error {
+	if a.GetEnforcer() == nil || len(roleIDs) == 0 {
+		return nil
+	}
+
+	if err := a.reloadRoles(ctx, roleIDs...); err != nil {
+		return err
+	}
+
+	byt, err := json.Marshal(casbinPolicyMessage{Origin: a.id, RoleIDs: roleIDs})
 	if err != nil {
-		return errorx.WrapGormError(ctx, err)
+		return errorx.ErrInternal.New(ctx).Wrap(err)
 	}
+	return a.Cache.Publish(ctx, casbinPolicyChannel, string(byt))
+}
 
-	if len(roles) == 0 {
+// Reload the policies of the roles from the database, only the changed policies are added or removed.
+// The policies of the deleted or disabled roles are removed.
+func (a *Casbinx) reloadRoles(ctx context.Context, roleIDs ...string) error {
+	e := a.GetEnforcer()
+	if e == nil || len(roleIDs) == 0 {
 		return nil
 	}
 
-	var resCount int32
-	queue := make(chan *policyQueueItem, len(roles))
-	threadNum := configs.C.Middleware.Casbin.LoadThread
-	lock := new(sync.Mutex)
-	buf := new(bytes.Buffer)
-
-	wg := new(sync.WaitGroup)
-	wg.Add(threadNum)
-	for i := 0; i < threadNum; i++ {
-		go func() {
-			defer wg.Done()
-			ibuf := new(bytes.Buffer)
-			for item := range queue {
-				for _, res := range item.Menus {
-					_, _ = ibuf.WriteString(fmt.Sprintf("p, %s, %s, %s \n", item.RoleID, res.Path, res.Method))
-				}
-			}
-			lock.Lock()
-			_, _ = buf.Write(ibuf.Bytes())
-			lock.Unlock()
-		}()
-	}
-
-	for _, item := range roles {
-		list, err := a.MenuSvc.List(ctx, dtos.MenuListReq{
-			RoleID: item.ID,
-			Type:   models.MenuType_BUTTON,
-			Pager: dtos.Pager{
-				Page: -1,
-			},
-		})
-		if err != nil {
-			logger.Error(ctx, "Failed to query role menus", err)
-			continue
+	policies, groupings, err := a.adapter.rules(ctx, roleIDs...)
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	var currentPolicies, currentGroupings [][]string
+	for _, id := range roleIDs {
+		currentPolicies = append(currentPolicies, e.GetFilteredPolicy(0, id)...)
+		currentGroupings = append(currentGroupings, e.GetFilteredGroupingPolicy(0, id)...)
+		currentGroupings = append(currentGroupings, e.GetFilteredGroupingPolicy(1, id)...)
+	}
+
+	var addedCount, removedCount int
+
+	removed, added := diffRules(currentPolicies, policies)
+	if len(removed) > 0 {
+		if _, err := e.RemovePolicies(removed); err != nil {
+			return err
 		}
-		atomic.AddInt32(&resCount, int32(len(list.Items)))
-		queue <- &policyQueueItem{
-			RoleID: item.ID,
-			Menus:  list.Items,
+	}
+	if len(added) > 0 {
+		if _, err := e.AddPolicies(added); err != nil {
+			return err
 		}
 	}
-	close(queue)
-	wg.Wait()
+	addedCount, removedCount = len(added), len(removed)
 
-	if buf.Len() > 0 {
-		policyFile := configs.C.Middleware.Casbin.GenPolicyFile
-		_ = os.Rename(policyFile, policyFile+".bak")
-		_ = os.MkdirAll(filepath.Dir(policyFile), 0755)
-		if err := os.WriteFile(policyFile, buf.Bytes(), 0666); err != nil {
-			logger.Error(ctx, "Failed to write policy file", err)
+	removed, added = diffRules(currentGroupings, groupings)
+	if len(removed) > 0 {
+		if _, err := e.RemoveGroupingPolicies(removed); err != nil {
 			return err
 		}
-		// set readonly
-		_ = os.Chmod(policyFile, 0444)
-
-		modelFile := configs.C.Middleware.Casbin.ModelFile
-		e, err := casbin.NewEnforcer(modelFile, policyFile)
-		if err != nil {
-			logger.Error(ctx, "Failed to create casbin enforcer", err)
+	}
+	if len(added) > 0 {
+		if _, err := e.AddGroupingPolicies(added); err != nil {
 			return err
 		}
-		e.EnableLog(configs.C.IsDebug())
-		a.enforcer.Store(e)
 	}
+	addedCount, removedCount = addedCount+len(added), removedCount+len(removed)
 
-	logger.Info(ctx, "Casbin load policy",
+	logger.Info(ctx, "Casbin reload role policies",
 		map[string]any{
-			"cost":      time.Since(start),
-			"roles":     len(roles),
-			"resources": resCount,
-			"bytes":     buf.Len(),
+			"roles":   roleIDs,
+			"added":   addedCount,
+			"removed": removedCount,
 		},
 	)
 	return nil
 }
 
-func (a *Casbinx) autoLoad(ctx context.Context) {
-	var lastUpdated int64
-	a.ticker = time.NewTicker(time.Duration(configs.C.Middleware.Casbin.AutoLoadInterval) * time.Second)
-	for range a.ticker.C {
-		updated, err := a.RoleSvc.GetUpdateTime(ctx)
-		if err != nil {
-			logger.Error(ctx, "Failed to get role update time", err)
-
-			if err := a.RoleSvc.RefreshUpdateTime(ctx); err != nil {
-				panic(err)
-			}
-			continue
+// Casbin function checking the request attributes meet the condition of the policy, the policies without condition always match.
+func (a *Casbinx) grantMatch(args ...any) (any, error) {
+	if len(args) != 2 {
+		return false, fmt.Errorf("grantMatch expects 2 arguments, got %d", len(args))
+	}
+
+	cond, _ := args[1].(string)
+	if cond == "" {
+		return true, nil
+	}
+	attrs, ok := args[0].(models.GrantAttributes)
+	if !ok {
+		return false, nil
+	}
+
+	v, ok := a.conditions.Load(cond)
+	if !ok {
+		var condition models.GrantCondition
+		if err := json.Unmarshal([]byte(cond), &condition); err != nil {
+			return false, err
 		}
+		v, _ = a.conditions.LoadOrStore(cond, &condition)
+	}
+	return v.(*models.GrantCondition).Match(attrs), nil
+}
+
+// Rules of the current set missing in the wanted set are removed, and the other way round they are added.
+func diffRules(current, wanted [][]string) (removed, added [][]string) {
+	key := func(rule []string) string {
+		return strings.Join(rule, ",")
+	}
 
-		if lastUpdated < updated {
-			if err := a.load(ctx); err != nil {
-				logger.Error(ctx, "Failed to load casbin policy", err)
-			} else {
-				lastUpdated = updated
-			}
+	currentKeys := make(map[string]struct{}, len(current))
+	for _, rule := range current {
+		currentKeys[key(rule)] = struct{}{}
+	}
+	wantedKeys := make(map[string]struct{}, len(wanted))
+	for _, rule := range wanted {
+		k := key(rule)
+		if _, ok := wantedKeys[k]; ok {
+			continue
+		}
+		wantedKeys[k] = struct{}{}
+		if _, ok := currentKeys[k]; !ok {
+			added = append(added, rule)
 		}
 	}
+	for _, rule := range current {
+		k := key(rule)
+		if _, ok := wantedKeys[k]; !ok {
+			removed = append(removed, rule)
+			wantedKeys[k] = struct{}{} // removed once
+		}
+	}
+	return removed, added
 }
 
 func (a *Casbinx) Release(ctx context.Context) error {
 	if a.ticker != nil {
 		a.ticker.Stop()
 	}
+	if a.unsubscribe != nil {
+		a.unsubscribe()
+	}
 	return nil
 }
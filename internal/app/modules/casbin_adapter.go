@@ -0,0 +1,122 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/pkg/encoding/json"
+	"gin-admin/pkg/helper"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+)
+
+var errPolicyReadOnly = errors.New("casbin policies are derived from roles and menus and can not be saved")
+
+// Casbin adapter reading the policies from the enabled button menus granted to the enabled roles,
+// the policies are changed through roles and menus so nothing is ever written back.
+type casbinAdapter struct {
+	RoleRepo     *repositories.Role
+	MenuRoleRepo *repositories.MenuRole
+}
+
+var _ persist.Adapter = (*casbinAdapter)(nil)
+
+type casbinPolicy struct {
+	RoleID    string
+	Path      string
+	Method    string
+	Condition *models.GrantCondition `gorm:"column:grant_condition;serializer:json"`
+}
+
+// Load all the policies to the model.
+func (a *casbinAdapter) LoadPolicy(m model.Model) error {
+	policies, groupings, err := a.rules(context.Background())
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range policies {
+		if err := persist.LoadPolicyArray(append([]string{"p"}, rule...), m); err != nil {
+			return err
+		}
+	}
+	for _, rule := range groupings {
+		if err := persist.LoadPolicyArray(append([]string{"g"}, rule...), m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *casbinAdapter) SavePolicy(model.Model) error {
+	return errPolicyReadOnly
+}
+
+func (a *casbinAdapter) AddPolicy(string, string, []string) error {
+	return errPolicyReadOnly
+}
+
+func (a *casbinAdapter) RemovePolicy(string, string, []string) error {
+	return errPolicyReadOnly
+}
+
+func (a *casbinAdapter) RemoveFilteredPolicy(string, string, int, ...string) error {
+	return errPolicyReadOnly
+}
+
+// Query the permission policies (role, route template, method, condition) and the inheritance policies (role, parent role) of the roles,
+// the inheritances of the sub roles are included. All the roles are queried if no role ID is given.
+func (a *casbinAdapter) rules(ctx context.Context, roleIDs ...string) (policies, groupings [][]string, err error) {
+	// the enforcer is shared by all the tenants, their role IDs never collide
+	ctx = helper.WithoutTenant(ctx)
+
+	var items []*casbinPolicy
+	db := a.MenuRoleRepo.DB().WithContext(ctx).Table(new(models.MenuRole).TableName()+" a").
+		Select("a.role_id, b.path, b.method, a.grant_condition").
+		Joins(fmt.Sprintf("join %s b on a.menu_id=b.id", new(models.Menu).TableName())).
+		Joins(fmt.Sprintf("join %s c on a.role_id=c.id", new(models.Role).TableName())).
+		Where("b.type = ? AND b.status = ? AND c.status = ?", models.MenuType_BUTTON, models.MenuStatus_ENABLED, models.RoleStatus_Enabled)
+	if len(roleIDs) > 0 {
+		db = db.Where("a.role_id IN (?)", roleIDs)
+	}
+	if err := db.Scan(&items).Error; err != nil {
+		return nil, nil, err
+	}
+
+	for _, item := range items {
+		if item.Path == "" || item.Method == "" {
+			continue
+		}
+
+		var cond string
+		if !item.Condition.IsEmpty() {
+			byt, err := json.Marshal(item.Condition)
+			if err != nil {
+				return nil, nil, err
+			}
+			cond = string(byt)
+		}
+		policies = append(policies, []string{item.RoleID, models.ToRoutePath(item.Path), item.Method, cond})
+	}
+
+	db = a.RoleRepo.DB().WithContext(ctx).Model(new(models.Role)).
+		Select("id", "parent_id").
+		Where("status = ? AND parent_id != ''", models.RoleStatus_Enabled)
+	if len(roleIDs) > 0 {
+		db = db.Where("id IN (?) OR parent_id IN (?)", roleIDs, roleIDs)
+	}
+
+	var roles models.Roles
+	if err := db.Find(&roles).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, role := range roles {
+		groupings = append(groupings, []string{role.ID, role.ParentID})
+	}
+
+	return policies, groupings, nil
+}
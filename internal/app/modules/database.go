@@ -3,8 +3,10 @@ package modules
 import (
 	"context"
 
+	"gin-admin/internal/models"
 	"gin-admin/internal/types"
 	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
 
 	"gorm.io/gorm"
 )
@@ -39,6 +41,19 @@ func InitDB(ctx context.Context, app types.AppContext) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// the models with a tenant are scoped to the tenant of the request
+	if err := db.Use(&gormx.TenantPlugin{GetTenantID: helper.GetTenantID}); err != nil {
+		return nil, err
+	}
+
+	// the menu grants of roles carry their own columns
+	if err := db.SetupJoinTable(new(models.Role), "Menus", new(models.MenuRole)); err != nil {
+		return nil, err
+	}
+	if err := db.SetupJoinTable(new(models.Menu), "Roles", new(models.MenuRole)); err != nil {
+		return nil, err
+	}
+
 	app.AddCleaner(ctx, func() {
 		sqlDB, err := db.DB()
 		if err == nil {
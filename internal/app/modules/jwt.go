@@ -2,6 +2,8 @@ package modules
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"time"
 
 	"gin-admin/internal/types"
@@ -15,19 +17,26 @@ func InitJWT(ctx context.Context, app types.AppContext) (jwtx.Auther, error) {
 	cfg := app.Config().Middleware.Auth
 	var opts []jwtx.Option
 	opts = append(opts, jwtx.SetExpired(cfg.Expired))
-	opts = append(opts, jwtx.SetSigningKey(cfg.SigningKey, cfg.OldSigningKey))
+	opts = append(opts, jwtx.SetRefreshExpired(cfg.RefreshExpired))
 	opts = append(opts, jwtx.SetRefreshKey(cfg.RefreshKey))
 
-	var method jwt.SigningMethod
-	switch cfg.SigningMethod {
-	case "HS256":
-		method = jwt.SigningMethodHS256
-	case "HS384":
-		method = jwt.SigningMethodHS384
-	default:
-		method = jwt.SigningMethodHS512
+	signingKey, err := loadJWTKey(cfg.KeyID, cfg.SigningMethod, cfg.SigningKey, cfg.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if signingKey.Sign == nil {
+		return nil, fmt.Errorf("jwt signing key %q is not a private key", cfg.SigningKeyFile)
+	}
+	opts = append(opts, jwtx.SetSigningKey(signingKey))
+
+	// a configured verify key which is invalid fails the startup, instead of rejecting the tokens signed by it
+	for i, item := range cfg.VerifyKeys {
+		key, err := loadJWTKey(item.KeyID, item.Method, item.Key, item.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwt verify key #%d %q: %w", i, item.KeyID, err)
+		}
+		opts = append(opts, jwtx.AddVerifyKeys(key))
 	}
-	opts = append(opts, jwtx.SetSigningMethod(method))
 
 	var cache cachex.Cacher
 	switch cfg.Store.Type {
@@ -56,3 +65,34 @@ func InitJWT(ctx context.Context, app types.AppContext) (jwtx.Auther, error) {
 
 	return auth, nil
 }
+
+// Load a key from the secret (HS*) or the PEM key file (RS*/ES*/EdDSA).
+func loadJWTKey(kid, methodName, secret, file string) (*jwtx.Key, error) {
+	if methodName == "" {
+		methodName = "HS512"
+	}
+
+	method := jwt.GetSigningMethod(methodName)
+	if method == nil || method == jwt.SigningMethodNone {
+		return nil, fmt.Errorf("unsupported jwt signing method %q", methodName)
+	}
+
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		key, err := jwtx.NewHMACKey(kid, method, []byte(secret))
+		if err != nil {
+			return nil, fmt.Errorf("empty jwt secret key of %s: %w", methodName, err)
+		}
+		return key, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwt key file: %w", err)
+	}
+
+	key, err := jwtx.ParseKeyPEM(kid, method, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse jwt key file %q: %w", file, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,29 @@
+package modules
+
+import (
+	"context"
+
+	"gin-admin/internal/types"
+	"gin-admin/pkg/mail"
+)
+
+// Init the SMTP sender, it returns nil if no SMTP server is configured.
+func InitMailer(ctx context.Context, app types.AppContext) (*mail.SmtpSender, error) {
+
+	cfg := app.Config().Mail
+	if cfg.SmtpHost == "" {
+		return nil, nil
+	}
+
+	sender := &mail.SmtpSender{
+		SmtpHost: cfg.SmtpHost,
+		Port:     cfg.Port,
+		FromName: cfg.FromName,
+		FromMail: cfg.FromMail,
+		UserName: cfg.Username,
+		AuthCode: cfg.Password,
+	}
+	mail.SetSender(sender)
+
+	return sender, nil
+}
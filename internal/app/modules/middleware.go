@@ -1,11 +1,19 @@
 package modules
 
 import (
+	"context"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
 	"gin-admin/internal/services"
 	"gin-admin/internal/types"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/gormx"
 	"gin-admin/pkg/helper"
+	"gin-admin/pkg/logger"
 	"gin-admin/pkg/middleware"
 	"gin-admin/pkg/promx"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/casbin/casbin/v2"
@@ -17,6 +25,7 @@ type Middlewares struct {
 	i18n        gin.HandlerFunc
 	cors        gin.HandlerFunc
 	trace       gin.HandlerFunc
+	tenant      gin.HandlerFunc
 	logger      gin.HandlerFunc
 	copyBody    gin.HandlerFunc
 	auth        gin.HandlerFunc
@@ -55,6 +64,23 @@ func NewMiddlewares(app types.AppContext) *Middlewares {
 		ResponseTraceKey: cfg.Middleware.Trace.ResponseTraceKey,
 	})
 
+	if cfg.Tenant.Enable {
+		tenantSvc := services.NewTenant(app)
+		m.tenant = middleware.TenantWithConfig(middleware.TenantConfig{
+			Header: cfg.Tenant.Header,
+			Domain: cfg.Tenant.Domain,
+			Resolve: func(c *gin.Context, code string) (string, error) {
+				tenant, err := tenantSvc.Resolve(c.Request.Context(), code)
+				if err != nil {
+					return "", err
+				}
+				return tenant.ID, nil
+			},
+		})
+	} else {
+		m.tenant = middleware.Empty()
+	}
+
 	m.logger = middleware.LoggerWithConfig(middleware.LoggerConfig{
 		MaxOutputRequestBodyLen:  cfg.Middleware.Logger.MaxOutputRequestBodyLen,
 		MaxOutputResponseBodyLen: cfg.Middleware.Logger.MaxOutputResponseBodyLen,
@@ -64,28 +90,98 @@ func NewMiddlewares(app types.AppContext) *Middlewares {
 		MaxContentLen: cfg.Middleware.CopyBody.MaxContentLen,
 	})
 
-	m.auth = middleware.AuthWithConfig(middleware.AuthConfig{
-		ParseUserID: services.NewAuth(app).ParseUserID,
-		RootID:      cfg.Super.ID,
+	// role IDs of the users cached in the instance, dropped once they are changed on any instance
+	roleIDsCache := cachex.NewMemoryCache(cachex.MemoryConfig{CleanupInterval: time.Minute})
+	unsubscribe, err := app.Cacher().Subscribe(context.Background(), services.UserRolesChannel, func(ctx context.Context, userID string) {
+		_ = roleIDsCache.Delete(ctx, services.UserRolesChannel, userID)
 	})
+	if err != nil {
+		logger.Error(context.Background(), "Failed to subscribe user role changes", err)
+	} else {
+		app.AddCleaner(context.Background(), unsubscribe)
+	}
 
-	m.rateLimiter = middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
-		Enable:             cfg.Middleware.RateLimiter.Enable,
-		Period:             cfg.Middleware.RateLimiter.Period,
-		MaxRequestsPerIP:   cfg.Middleware.RateLimiter.MaxRequestsPerIP,
-		MaxRequestsPerUser: cfg.Middleware.RateLimiter.MaxRequestsPerUser,
-		StoreType:          cfg.Middleware.RateLimiter.Store.Type,
+	getRoleIDs := func(c *gin.Context) []string {
+		ctx := c.Request.Context()
+		userID := helper.GetUserID(ctx)
+
+		var roleIDs []string
+		if val, err := roleIDsCache.Get(ctx, services.UserRolesChannel, userID); err == nil {
+			roleIDs = strings.Fields(val)
+		} else if roleIDs, err = services.NewUser(app).GetRoleIDsCache(ctx, userID); err == nil {
+			// the subscription may be broken for a while, the local copies expire soon anyway
+			_ = roleIDsCache.Set(ctx, services.UserRolesChannel, userID, strings.Join(roleIDs, " "), time.Minute)
+		}
+
+		// requests with a scoped API key only have the granted roles which are still owned by the user
+		if scoped, ok := helper.GetAPIKeyRoleIDs(ctx); ok {
+			roleIDs = slices.DeleteFunc(roleIDs, func(id string) bool {
+				return !slices.Contains(scoped, id)
+			})
+		}
+		return roleIDs
+	}
+
+	rlCfg := cfg.Middleware.RateLimiter
+	rlRules := make([]middleware.RateLimitRule, len(rlCfg.Rules))
+	for i, rule := range rlCfg.Rules {
+		rlRules[i] = middleware.RateLimitRule{
+			Name:    rule.Name,
+			Path:    rule.Path,
+			Methods: rule.Methods,
+			Roles:   rule.Roles,
+			By:      rule.By,
+			Limit:   rule.Limit,
+			Period:  time.Second * time.Duration(rule.Period),
+		}
+	}
+
+	// the codes of the roles are only changed by the admins, so they are cached in the instance for a while
+	roleCodesCache := cachex.NewMemoryCache(cachex.MemoryConfig{CleanupInterval: time.Minute})
+	roleRepo := repositories.NewRole(app.DB())
+	rateLimiter := middleware.NewRateLimiter(middleware.RateLimiterConfig{
+		Enable:             rlCfg.Enable,
+		Period:             rlCfg.Period,
+		MaxRequestsPerIP:   rlCfg.MaxRequestsPerIP,
+		MaxRequestsPerUser: rlCfg.MaxRequestsPerUser,
+		Rules:              rlRules,
+		GetRoles: func(c *gin.Context) []string {
+			ctx := c.Request.Context()
+			var codes []string
+			for _, roleID := range getRoleIDs(c) {
+				code, err := roleCodesCache.Get(ctx, "role_codes", roleID)
+				if err != nil {
+					role, err := roleRepo.Get(ctx, roleID, gormx.WithSelect("id", "code"))
+					if err != nil {
+						continue
+					}
+					code = role.Code
+					_ = roleCodesCache.Set(ctx, "role_codes", roleID, code, time.Minute)
+				}
+				codes = append(codes, code)
+			}
+			return codes
+		},
+		StoreType: rlCfg.Store.Type,
 		MemoryStoreConfig: middleware.RateLimiterMemoryConfig{
-			Expiration:      time.Second * time.Duration(cfg.Middleware.RateLimiter.Store.Memory.Expiration),
-			CleanupInterval: time.Second * time.Duration(cfg.Middleware.RateLimiter.Store.Memory.CleanupInterval),
+			Expiration:      time.Second * time.Duration(rlCfg.Store.Memory.Expiration),
+			CleanupInterval: time.Second * time.Duration(rlCfg.Store.Memory.CleanupInterval),
 		},
 		RedisStoreConfig: middleware.RateLimiterRedisConfig{
-			Addr:     cfg.Middleware.RateLimiter.Store.Redis.Addr,
-			Password: cfg.Middleware.RateLimiter.Store.Redis.Password,
-			DB:       cfg.Middleware.RateLimiter.Store.Redis.DB,
-			Username: cfg.Middleware.RateLimiter.Store.Redis.Username,
+			Addr:     rlCfg.Store.Redis.Addr,
+			Password: rlCfg.Store.Redis.Password,
+			DB:       rlCfg.Store.Redis.DB,
+			Username: rlCfg.Store.Redis.Username,
 		},
 	})
+	m.rateLimiter = rateLimiter.Handler()
+
+	// the requests are limited by user once authenticated, as the authentication is applied per route group
+	m.auth = middleware.AuthWithConfig(middleware.AuthConfig{
+		ParseUserID: services.NewAuth(app).ParseUserID,
+		RootID:      cfg.Super.ID,
+		Limit:       rateLimiter.LimitUser,
+	})
 
 	m.casbin = middleware.CasbinWithConfig(middleware.CasbinConfig{
 		Skipper: func(c *gin.Context) bool {
@@ -95,13 +191,17 @@ func NewMiddlewares(app types.AppContext) *Middlewares {
 			}
 			return false
 		},
-		GetEnforcer: func(c *gin.Context) *casbin.Enforcer {
+		GetEnforcer: func(c *gin.Context) *casbin.SyncedEnforcer {
 			return app.Casbin().GetEnforcer()
 		},
-		GetSubjects: func(c *gin.Context) []string {
+		GetSubjects: getRoleIDs,
+		GetAttributes: func(c *gin.Context) any {
 			ctx := c.Request.Context()
-			roleIDs, _ := services.NewUser(app).GetRoleIDsCache(ctx, helper.GetUserID(ctx))
-			return roleIDs
+			return models.GrantAttributes{
+				Time:        time.Now(),
+				ClientIP:    c.ClientIP(),
+				MFAVerified: helper.GetMFAVerified(ctx),
+			}
 		},
 	})
 
@@ -130,6 +230,8 @@ func (m *Middlewares) Cors() gin.HandlerFunc { return m.cors }
 
 func (m *Middlewares) Trace() gin.HandlerFunc { return m.trace }
 
+func (m *Middlewares) Tenant() gin.HandlerFunc { return m.tenant }
+
 func (m *Middlewares) Logger() gin.HandlerFunc { return m.logger }
 
 func (m *Middlewares) CopyBody() gin.HandlerFunc { return m.copyBody }
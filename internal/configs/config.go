@@ -30,19 +30,28 @@ type Config struct {
 		IdleTimeout     int    `default:"10"` // seconds
 		CertFile        string
 		KeyFile         string
+		TrustedProxies  []string // IPs or CIDRs of the proxies allowed to forward the client IP, none by default
 	}
 
 	Cache      Cache
 	DB         DB
 	Upload     Upload
 	Captcha    Captcha
+	LoginGuard LoginGuard
+	Password   PasswordPolicy
+	MFA        MFA
+	OIDC       OIDC
+	Mail       Mail
 	Prometheus Prometheus
 	Swagger    Swagger
 	Pprof      Pprof
 	Menu       Menu
+	Tenant     Tenant
 
-	Logger     logger.Config
-	Middleware Middleware
+	Logger       logger.Config
+	LogRetention LogRetention
+	LogExport    LogExport
+	Middleware   Middleware
 }
 
 func (c *Config) IsDebug() bool {
@@ -30,13 +30,21 @@ type Middleware struct {
 		MaxContentLen int64 `default:"33554432"` // max content length (default 32MB)
 	}
 	Auth struct {
-		Disable       bool
-		SigningMethod string `default:"HS512"`    // HS256/HS384/HS512
-		SigningKey    string `default:"XnEsT0S@"` // secret key
-		RefreshKey    string `default:"TfYOj6Tf"` // secret key for refresh token
-		OldSigningKey string // old secret key (for migration)
-		Expired       int    `default:"86400"` // seconds
-		Store         struct {
+		Disable        bool
+		SigningMethod  string `default:"HS512"`    // HS256/HS384/HS512/RS256/RS384/RS512/ES256/ES384/ES512/EdDSA
+		SigningKey     string `default:"XnEsT0S@"` // secret key (HS*)
+		SigningKeyFile string // PEM private key file (RS*/ES*/EdDSA)
+		KeyID          string // kid of the signing key, if empty, then use the thumbprint of public key (RS*/ES*/EdDSA)
+		VerifyKeys     []struct {
+			KeyID   string // kid of the key
+			Method  string // signing method of the key
+			Key     string // secret key (HS*)
+			KeyFile string // PEM public or private key file (RS*/ES*/EdDSA)
+		} // keys which only verify tokens (for rotation)
+		RefreshKey     string `default:"TfYOj6Tf"` // secret key for refresh token
+		Expired        int    `default:"86400"`    // seconds
+		RefreshExpired int    `default:"2592000"`  // seconds
+		Store          struct {
 			Type      string `default:"memory"` // memory/badger/redis
 			Delimiter string `default:":"`      // delimiter for key
 			Memory    struct {
@@ -58,7 +66,16 @@ type Middleware struct {
 		Period             int // seconds
 		MaxRequestsPerIP   int
 		MaxRequestsPerUser int
-		Store              struct {
+		Rules              []struct {
+			Name    string   // counted per rule
+			Path    string   // route template, e.g. /api/v1/users/:id or /api/v1/*
+			Methods []string // all methods if empty
+			Roles   []string // role codes, the rule with roles is by user
+			By      string   `default:"ip"` // ip/user
+			Limit   int      // maximum requests in the period
+			Period  int      // seconds
+		}
+		Store struct {
 			Type   string // memory/redis
 			Memory struct {
 				Expiration      int `default:"3600"` // seconds
@@ -74,10 +91,8 @@ type Middleware struct {
 	}
 	Casbin struct {
 		Disable          bool
-		LoadThread       int    `default:"2"`
-		AutoLoadInterval int    `default:"3"` // seconds
+		AutoLoadInterval int    `default:"300"` // seconds, full reload in case a change message is missed, 0 to disable
 		ModelFile        string `default:"rbac_model.conf"`
-		GenPolicyFile    string `default:"data/gen_rbac_policy.csv"`
 	}
 	Static struct {
 		ExcludedPathPrefixes []string // excluded path prefixes
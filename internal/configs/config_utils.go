@@ -1,11 +1,13 @@
 package configs
 
 type Captcha struct {
-	Length    int    `default:"4"`
-	Width     int    `default:"400"`
-	Height    int    `default:"160"`
-	CacheType string `default:"memory"` // memory/redis
-	Redis     struct {
+	Enable     bool   // Require captcha on login
+	Length     int    `default:"4"`
+	Width      int    `default:"400"`
+	Height     int    `default:"160"`
+	Expiration int    `default:"600"`   // seconds
+	CacheType  string `default:"cache"` // cache/redis/memory, cache shares the cache of the app
+	Redis      struct {
 		Addr      string
 		Username  string
 		Password  string
@@ -14,6 +16,67 @@ type Captcha struct {
 	}
 }
 
+type LoginGuard struct {
+	Enable               bool
+	MaxFailuresPerUser   int `default:"5"`    // lock the username after n failures, 0 means unlimited
+	MaxFailuresPerIP     int `default:"20"`   // lock the client IP after n failures, 0 means unlimited
+	MaxFailuresPerUserIP int `default:"5"`    // lock the username from the client IP after n failures, 0 means unlimited
+	FailureWindow        int `default:"900"`  // seconds, failures older than this are forgotten
+	DelayAfter           int `default:"3"`    // start delaying the next attempt after n failures
+	DelayBase            int `default:"1"`    // seconds, the delay doubles on each further failure
+	MaxDelay             int `default:"30"`   // seconds
+	LockoutDuration      int `default:"1800"` // seconds
+}
+
+type PasswordPolicy struct {
+	MinLength           int    `default:"8"` // minimum length of the plaintext password
+	MinCharClasses      int    `default:"2"` // minimum number of character classes (uppercase, lowercase, digit, symbol)
+	RejectUsername      bool   // reject passwords containing the username
+	History             int    `default:"5"` // reject the last n passwords, 0 disables the history
+	MaxAge              int    // days before the password expires, 0 means never
+	ChangeOnFirstLogin  bool   // force users created by an administrator to change the password on first login
+	ChangeOnReset       bool   // force users to change the password after an administrator reset it
+	ChallengeExpiration int    `default:"600"`  // seconds to change the password after login
+	ResetExpiration     int    `default:"1800"` // seconds before the emailed reset code expires
+	ResetURL            string // page to reset the password, the code is appended as `token` query, e.g. https://admin.example.com/#/reset-password
+}
+
+type Mail struct {
+	SmtpHost string // SMTP server host, sending mails is disabled if empty
+	Port     int    `default:"25"`
+	FromName string
+	FromMail string
+	Username string
+	Password string // password or authorization code of the account
+}
+
+type MFA struct {
+	Issuer              string // issuer shown in authenticator apps (default: AppName)
+	SecretKey           string // AES key (16/24/32 bytes) to encrypt TOTP secrets (default: aes.SecretKey)
+	Skew                int    `default:"1"`   // number of 30s periods allowed before and after the current one
+	ChallengeExpiration int    `default:"300"` // seconds
+	ChallengeAttempts   int    `default:"5"`   // max verify attempts per challenge
+	RecoveryCodes       int    `default:"10"`  // number of recovery codes
+}
+
+type OIDC struct {
+	StateExpiration int `default:"600"` // seconds to complete the sign-on at the provider
+	Providers       []OIDCProvider
+}
+
+type OIDCProvider struct {
+	Name          string   // unique name used in routes, e.g. /api/v1/auth/oidc/{Name}/login
+	Title         string   // display name on the login page
+	Issuer        string   // issuer URL of the provider
+	ClientID      string   // client ID registered at the provider
+	ClientSecret  string   // client secret, empty for public clients
+	RedirectURL   string   // callback URL registered at the provider, e.g. https://admin.example.com/api/v1/auth/oidc/{Name}/callback
+	Scopes        []string // requested scopes (default: openid, profile, email)
+	UsernameClaim string   // claim used as the username of provisioned users (default: preferred_username)
+	AutoCreate    bool     // provision users just in time on their first sign-on
+	DefaultRoles  []string // codes of the roles granted to provisioned users
+}
+
 type Prometheus struct {
 	Enable         bool
 	Port           int    `default:"9100"`
@@ -36,4 +99,45 @@ type Pprof struct {
 type Menu struct {
 	File        string // Data to restore model.Menus (JSON/YAML)
 	DenyOperate bool   // Deny operate menu
+	RouteSync   struct {
+		Enable               bool     // Check the registered routes against the button resources at startup
+		CreateMissing        bool     // Create the button resources of the routes without one
+		DisableStale         bool     // Disable the button resources pointing to non-existent routes
+		Catalog              string   `default:"api_routes"`                               // Name of the root catalog holding the created buttons
+		PathPrefixes         []string `default:"[\"/api/\"]"`                              // Path prefixes of the routes to sync
+		ExcludedPathPrefixes []string `default:"[\"/api/v1/auth/\",\"/api/v1/captcha/\"]"` // Path prefixes of the routes not authorized by casbin
+	}
+}
+
+type Tenant struct {
+	Enable          bool   // Serve several tenants, the data of the requests are scoped to their tenant
+	Header          string `default:"X-Tenant"` // Request header carrying the code of the tenant
+	Domain          string // Base domain of the tenant subdomains, e.g. admin.example.com resolves acme.admin.example.com to tenant acme
+	CacheExpiration int    `default:"600"` // seconds to cache the resolved tenants
+}
+
+type LogRetention struct {
+	Enable     bool               // Purge the expired logs of the logger table in the background, enable it on one instance only
+	Days       int                `default:"90"` // Days to keep the logs not matched by any rule, 0 to keep them forever
+	Rules      []LogRetentionRule // Rules matched in order, the first rule matching the tag and level of a log applies
+	Interval   int                `default:"3600"` // seconds between the purges
+	BatchSize  int                `default:"1000"` // logs deleted per batch, the batches are small to avoid long locks
+	BatchPause int                `default:"100"`  // milliseconds to pause between the batches
+	Rollup     bool               // Add up the daily counts of the purged logs by tenant, tag and level
+	Archive    struct {
+		Enable bool   // Archive the purged logs to gzip compressed NDJSON files before they are deleted
+		Path   string `default:"data/archive/logger"` // Directory of the archive files
+	}
+}
+
+type LogRetentionRule struct {
+	Tag   string // Log tag, any tag if empty
+	Level string // Log level, any level if empty
+	Days  int    // Days to keep the matched logs, 0 to keep them forever
+}
+
+type LogExport struct {
+	MaxRows     int            `default:"10000"` // Logs exported at most per request
+	RoleMaxRows map[string]int // Logs exported at most per request by role code, the largest of the roles of the user applies instead of MaxRows
+	BatchSize   int            `default:"500"` // Logs read per query, no database cursor is held while the client downloads
 }
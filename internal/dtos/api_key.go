@@ -0,0 +1,26 @@
+package dtos
+
+import "time"
+
+// Defining the data structure for creating an `APIKey` struct.
+type APIKeyCreateReq struct {
+	Name      string     `json:"name" binding:"required,max=128"` // Display name of the key
+	RoleIDs   []string   `json:"roleIds"`                         // Roles granted to the key (subset of owner's roles, empty for all)
+	ExpiresAt *time.Time `json:"expiresAt"`                       // Expire time, empty for never
+}
+
+type APIKeyUpdateReq struct {
+	Name    *string   `json:"name" binding:"omitempty,max=128"` // Display name of the key
+	RoleIDs *[]string `json:"roleIds"`                          // Roles granted to the key (subset of owner's roles, empty for all)
+}
+
+// The created API key, the plaintext key is only returned once.
+type APIKeyCreated struct {
+	ID        string     `json:"id"`        // Unique ID
+	Name      string     `json:"name"`      // Display name of the key
+	Prefix    string     `json:"prefix"`    // Public prefix to identify the key
+	Key       string     `json:"key"`       // Plaintext key, send it with the `X-API-Key` header
+	RoleIDs   []string   `json:"roleIds"`   // Roles granted to the key
+	ExpiresAt *time.Time `json:"expiresAt"` // Expire time
+	CreatedAt time.Time  `json:"createdAt"` // Create time
+}
@@ -0,0 +1,14 @@
+package dtos
+
+// Defining the query parameters for the `Audit` struct.
+type AuditListReq struct {
+	Pager
+	Action     string `form:"action"`     // action of the change (create, update, delete)
+	EntityType string `form:"entityType"` // type of the changed entity
+	EntityID   string `form:"entityId"`   // ID of the changed entity
+	UserID     string `form:"userId"`     // actor of the change
+	UserName   string `form:"username"`   // user name of the actor
+	TraceID    string `form:"traceID"`    // trace ID
+	StartTime  string `form:"startTime"`  // start time
+	EndTime    string `form:"endTime"`    // end time
+}
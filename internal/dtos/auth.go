@@ -3,15 +3,15 @@ package dtos
 import "strings"
 
 type Login struct {
-	Username string `json:"username" binding:"required"` // Login name
-	Password string `json:"password" binding:"required"` // Login password (md5 hash)
-	// CaptchaID   string `json:"captchaId" binding:"required"`   // Captcha verify id
-	// CaptchaCode string `json:"captchaCode" binding:"required"` // Captcha verify code
+	Username    string `json:"username" binding:"required"` // Login name
+	Password    string `json:"password" binding:"required"` // Login password (md5 hash of the plaintext password set by the user and password APIs)
+	CaptchaID   string `json:"captchaId"`                   // Captcha verify id (required if captcha is enabled)
+	CaptchaCode string `json:"captchaCode"`                 // Captcha verify code (required if captcha is enabled)
 }
 
 func (a *Login) Trim() *Login {
 	a.Username = strings.TrimSpace(a.Username)
-	// a.CaptchaCode = strings.TrimSpace(a.CaptchaCode)
+	a.CaptchaCode = strings.TrimSpace(a.CaptchaCode)
 	return a
 }
 
@@ -22,9 +22,62 @@ type LoginToken struct {
 	RefreshToken string `json:"refreshToken"` // Refresh token (JWT)
 }
 
+// Result of login, either the tokens, a password change or a two-factor authentication challenge
+type LoginResult struct {
+	*LoginToken
+	PasswordChange *PasswordChallenge `json:"passwordChange,omitempty"` // The password must be changed before login
+	MFA            *MFAChallenge      `json:"mfa,omitempty"`            // Two-factor authentication challenge
+}
+
+const (
+	PasswordChangeReason_Expired  = "expired"  // The password is older than the max age
+	PasswordChangeReason_Required = "required" // The password was set by an administrator
+)
+
+type PasswordChallenge struct {
+	Token   string `json:"token"`   // Challenge token for /auth/password/change
+	Expires int64  `json:"expires"` // Expired time (seconds)
+	Reason  string `json:"reason"`  // Why the password must be changed (expired, required)
+}
+
+type PasswordChangeReq struct {
+	Token       string `json:"token" binding:"required"`              // Challenge token
+	NewPassword string `json:"newPassword" binding:"required,max=64"` // New password (plaintext, checked against the password policy)
+}
+
+type MFAChallenge struct {
+	Token   string    `json:"token"`           // Challenge token for /auth/mfa/verify
+	Expires int64     `json:"expires"`         // Expired time (seconds)
+	Setup   *MFASetup `json:"setup,omitempty"` // Enrollment data if the user has not enrolled yet
+}
+
+type MFASetup struct {
+	Secret        string   `json:"secret"`        // TOTP secret (base32)
+	URI           string   `json:"uri"`           // Provisioning URI (otpauth://), rendered as QR code
+	RecoveryCodes []string `json:"recoveryCodes"` // One-time recovery codes, shown only once
+}
+
+type MFAVerifyReq struct {
+	Token string `json:"token" binding:"required"` // Challenge token
+	Code  string `json:"code" binding:"required"`  // TOTP code or recovery code
+}
+
+type MFACodeReq struct {
+	Code string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+type PasswordForgotReq struct {
+	Email string `json:"email" binding:"required,email,max=128"` // Email of the account
+}
+
+type PasswordResetReq struct {
+	Token       string `json:"token" binding:"required"`              // Reset code from the mail
+	NewPassword string `json:"newPassword" binding:"required,max=64"` // New password (plaintext, checked against the password policy)
+}
+
 type AuthUpdatePasswordReq struct {
-	OldPassword string `json:"oldPassword" binding:"required"` // Old password (md5 hash)
-	NewPassword string `json:"newPassword" binding:"required"` // New password (md5 hash)
+	OldPassword string `json:"oldPassword" binding:"required"`        // Old password (md5 hash, the same as on login)
+	NewPassword string `json:"newPassword" binding:"required,max=64"` // New password (plaintext, checked against the password policy)
 }
 
 type AuthUpdateUserReq struct {
@@ -43,3 +96,20 @@ type CaptchaImageReq struct {
 	ID     string `from:"id"`     // Captcha ID
 	Reload bool   `from:"reload"` // Reload captcha image (reload=1)
 }
+
+// Batch of permissions to check for the current user
+type PermissionCheckReq struct {
+	Items []*PermissionCheckItem `json:"items" binding:"required,max=100,dive"` // Permissions to check (max 100)
+}
+
+// Either an API (method and path) or a permission code of button
+type PermissionCheckItem struct {
+	Method string `json:"method,omitempty" binding:"required_without=Code"` // Http method of API
+	Path   string `json:"path,omitempty" binding:"required_without=Code"`   // Path of API, either concrete or a template like /api/v1/users/{id}
+	Code   string `json:"code,omitempty"`                                   // Permission code of button
+}
+
+type PermissionCheckResult struct {
+	PermissionCheckItem
+	Allowed bool `json:"allowed"` // Whether the current user is allowed
+}
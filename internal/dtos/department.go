@@ -0,0 +1,35 @@
+package dtos
+
+// Defining the query parameters for the `Department` struct.
+type DepartmentListReq struct {
+	Pager
+	LikeName string `form:"name"`                                              // Display name of department
+	Status   string `form:"status" binding:"omitempty,oneof=disabled enabled"` // Status of department (disabled, enabled)
+	ParentID string `form:"parentId"`                                          // Parent ID (From Department.ID)
+}
+
+// Defining the data structure for creating a `Department` struct.
+type DepartmentCreateReq struct {
+	Code        string   `json:"code" binding:"max=32"`                            // Code of department
+	Name        string   `json:"name" binding:"required,max=128"`                  // Display name of department
+	Description string   `json:"description" binding:"max=1024"`                   // Details about department
+	Status      string   `json:"status" binding:"required,oneof=disabled enabled"` // Status of department (enabled, disabled)
+	ParentID    string   `json:"parentId"`                                         // Parent ID (From Department.ID)
+	Rank        int      `json:"rank"`                                             // Rank for sorting (Order by desc)
+	LeaderIDs   []string `json:"leaderIds"`                                        // Leaders of department, they are added as members if not yet
+}
+
+type DepartmentUpdateReq struct {
+	Code        *string   `json:"code" binding:"omitempty,max=32"`                   // Code of department
+	Name        *string   `json:"name" binding:"omitempty,max=128"`                  // Display name of department
+	Description *string   `json:"description" binding:"omitempty,max=1024"`          // Details about department
+	Status      *string   `json:"status" binding:"omitempty,oneof=disabled enabled"` // Status of department (enabled, disabled)
+	Rank        *int      `json:"rank"`                                              // Rank for sorting (Order by desc)
+	LeaderIDs   *[]string `json:"leaderIds"`                                         // Leaders of department, they are added as members if not yet
+}
+
+// Move the department under another parent, or reorder it among the siblings.
+type DepartmentMoveReq struct {
+	ParentID string `json:"parentId"` // New parent ID, empty for the top level
+	Rank     *int   `json:"rank"`     // New rank among the siblings, the current one is kept if empty
+}
@@ -1,5 +1,7 @@
 package dtos
 
+import "time"
+
 // Defining the query parameters for the `Logger` struct.
 type LoggerListReq struct {
 	Pager
@@ -11,3 +13,59 @@ type LoggerListReq struct {
 	StartTime string `form:"startTime"` // start time
 	EndTime   string `form:"endTime"`   // end time
 }
+
+// Defining the query parameters to export the loggers, the page is ignored.
+type LoggerExportReq struct {
+	LoggerListReq
+	Format string `form:"format" binding:"omitempty,oneof=csv ndjson"` // export format (csv, ndjson), csv by default
+}
+
+// Defining the query parameters for the statistics of the loggers.
+type LoggerStatsReq struct {
+	Level     string `form:"level"`                                            // log level
+	Tag       string `form:"tag"`                                              // log tags separated by commas
+	UserName  string `form:"username"`                                         // user name
+	StartTime string `form:"startTime"`                                        // start time (RFC3339 or 2006-01-02 15:04:05), 24 hours before the end time by default
+	EndTime   string `form:"endTime"`                                          // end time (RFC3339 or 2006-01-02 15:04:05), now by default
+	Bucket    string `form:"bucket" binding:"omitempty,oneof=minute hour day"` // time bucket of the histogram (minute, hour, day), hour by default
+	Top       int    `form:"top" binding:"omitempty,min=1,max=100"`            // number of the top users and slow paths, 10 by default
+}
+
+// Statistics of the loggers in a time range
+type LoggerStats struct {
+	StartTime time.Time            `json:"startTime"` // start time of the range
+	EndTime   time.Time            `json:"endTime"`   // end time of the range
+	Bucket    string               `json:"bucket"`    // time bucket of the histogram
+	Total     int64                `json:"total"`     // number of the logs
+	Levels    []*LoggerStatsCount  `json:"levels"`    // counts by level
+	Tags      []*LoggerStatsCount  `json:"tags"`      // counts by tag
+	Statuses  []*LoggerStatsCount  `json:"statuses"`  // counts of the requests by response status code
+	Users     []*LoggerStatsUser   `json:"users"`     // top users by count
+	Histogram []*LoggerStatsBucket `json:"histogram"` // counts by time bucket, the empty buckets included
+	SlowPaths []*LoggerStatsPath   `json:"slowPaths"` // top request paths by average cost
+}
+
+type LoggerStatsCount struct {
+	Key   string `json:"key"`   // level, tag or status code
+	Count int64  `json:"count"` // number of the logs
+}
+
+type LoggerStatsUser struct {
+	UserID   string `json:"userId"`   // user ID, empty for the anonymous logs
+	Username string `json:"username"` // user name
+	Count    int64  `json:"count"`    // number of the logs
+}
+
+type LoggerStatsBucket struct {
+	Time   time.Time `json:"time"`   // start time of the bucket
+	Count  int64     `json:"count"`  // number of the logs
+	Errors int64     `json:"errors"` // number of the logs of the error levels
+}
+
+type LoggerStatsPath struct {
+	Method  string  `json:"method"`  // request method
+	Path    string  `json:"path"`    // route of the request path, like /api/v1/users/:id
+	Count   int64   `json:"count"`   // number of the requests
+	AvgCost float64 `json:"avgCost"` // average cost in milliseconds
+	MaxCost int64   `json:"maxCost"` // maximum cost in milliseconds
+}
@@ -1,5 +1,7 @@
 package dtos
 
+import "gin-admin/internal/models"
+
 // Defining the query parameters for the `Menu` struct.
 type MenuListReq struct {
 	Pager
@@ -19,6 +21,7 @@ type MenuCreateReq struct {
 	Name      string         `json:"name" binding:"required_unless=Type button,max=128"` // Display name of menu
 	Type      string         `json:"type" binding:"required,oneof=catalog menu button"`  // Type of menu (catalog menu, button)
 	Method    string         `json:"method"`                                             // Http method of resource
+	Code      string         `json:"code" binding:"max=128"`                             // Permission code of button for frontend
 	Path      string         `json:"path"`                                               // Access path of menu
 	Component string         `json:"component"`                                          // Component path of view
 	Status    string         `json:"status" binding:"required,oneof=disabled enabled"`   // Status of menu (enabled, disabled)
@@ -37,7 +40,22 @@ type MenuUpdateReq struct {
 	Status    *string        `json:"status" binding:"omitempty,oneof=disabled enabled"`  // Status of menu (enabled, disabled)
 	ParentID  *string        `json:"parentId"`                                           // Parent ID (From Menu.ID)
 	Method    *string        `json:"method"`                                             // Http method of resource
+	Code      *string        `json:"code" binding:"omitempty,max=128"`                   // Permission code of button for frontend
 	Rank      *int           `json:"rank"`                                               // Rank for sorting (Order by desc)
 	Title     *string        `json:"title"`                                              // Menu title
 	Extra     map[string]any `json:"extra"`                                              // Meta of menu (JSON)
 }
+
+// Route registered in the http engine
+type Route struct {
+	Method string `json:"method"` // Http method of route
+	Path   string `json:"path"`   // Route template, e.g. /api/v1/users/:id
+}
+
+// Differences between the registered routes and the button resources
+type RouteSyncResult struct {
+	Missing  []*Route     `json:"missing"`  // Routes without a button resource
+	Stale    models.Menus `json:"stale"`    // Button resources pointing to non-existent routes
+	Created  int          `json:"created"`  // Count of the button resources created for the missing routes
+	Disabled int          `json:"disabled"` // Count of the stale button resources disabled
+}
@@ -0,0 +1,20 @@
+package dtos
+
+// OpenID Connect provider available for single sign-on
+type OIDCProvider struct {
+	Name  string `json:"name"`  // Name used in routes
+	Title string `json:"title"` // Display name
+}
+
+// Parameters of the redirection from the provider
+type OIDCCallbackReq struct {
+	Code             string `form:"code" json:"code"`                           // Authorization code
+	State            string `form:"state" json:"state"`                         // State of the sign-on
+	Error            string `form:"error" json:"error"`                         // Error code if the sign-on failed at the provider
+	ErrorDescription string `form:"error_description" json:"error_description"` // Error details
+}
+
+// Authorization URL of the provider
+type OIDCAuthURL struct {
+	URL string `json:"url"` // Redirect the user agent to the URL
+}
@@ -1,5 +1,7 @@
 package dtos
 
+import "gin-admin/internal/models"
+
 // Defining the query parameters for the `Role` struct.
 type RoleListReq struct {
 	Pager
@@ -10,19 +12,31 @@ type RoleListReq struct {
 
 // Defining the data structure for creating a `Role` struct.
 type RoleCreateReq struct {
-	Code        string   `json:"code" binding:"required,max=32"`                   // Code of role (unique)
-	Name        string   `json:"name" binding:"required,max=128"`                  // Display name of role
-	Description string   `json:"description"`                                      // Details about role
-	Rank        int      `json:"rank"`                                             // Rank for sorting
-	Status      string   `json:"status" binding:"required,oneof=disabled enabled"` // Status of role (enabled, disabled)
-	MenuIDs     []string `json:"menuIds"`                                          // Menu ids
+	Code        string   `json:"code" binding:"required,max=32"`                                          // Code of role (unique)
+	Name        string   `json:"name" binding:"required,max=128"`                                         // Display name of role
+	Description string   `json:"description"`                                                             // Details about role
+	Rank        int      `json:"rank"`                                                                    // Rank for sorting
+	Status      string   `json:"status" binding:"required,oneof=disabled enabled"`                        // Status of role (enabled, disabled)
+	ParentID    string   `json:"parentId"`                                                                // Parent role ID, the permissions of the parent are inherited
+	MFARequired bool     `json:"mfaRequired"`                                                             // Users of the role must pass two-factor authentication
+	DataScope   string   `json:"dataScope" binding:"omitempty,oneof=all custom dept dept_and_below self"` // Rows visible to users of the role, defaults to all
+	DataDeptIDs []string `json:"dataDeptIds"`                                                             // Departments visible with the custom data scope
+	MenuIDs     []string `json:"menuIds"`                                                                 // Menu ids
+	// Conditions of the granted menus by menu ID, the menus without condition are always granted
+	MenuConditions map[string]*models.GrantCondition `json:"menuConditions"`
 }
 
 type RoleUpdateReq struct {
-	Code        *string   `json:"code" binding:"omitempty,max=32"`                   // Code of role (unique)
-	Name        *string   `json:"name" binding:"omitempty,max=128"`                  // Display name of role
-	Description *string   `json:"description"`                                       // Details about role
-	Rank        *int      `json:"rank"`                                              // Rank for sorting
-	Status      *string   `json:"status" binding:"omitempty,oneof=disabled enabled"` // Status of role (enabled, disabled)
-	MenuIDs     *[]string `json:"menuIds"`                                           // Menu ids
+	Code        *string   `json:"code" binding:"omitempty,max=32"`                                         // Code of role (unique)
+	Name        *string   `json:"name" binding:"omitempty,max=128"`                                        // Display name of role
+	Description *string   `json:"description"`                                                             // Details about role
+	Rank        *int      `json:"rank"`                                                                    // Rank for sorting
+	Status      *string   `json:"status" binding:"omitempty,oneof=disabled enabled"`                       // Status of role (enabled, disabled)
+	ParentID    *string   `json:"parentId"`                                                                // Parent role ID, empty to stop inheriting
+	MFARequired *bool     `json:"mfaRequired"`                                                             // Users of the role must pass two-factor authentication
+	DataScope   *string   `json:"dataScope" binding:"omitempty,oneof=all custom dept dept_and_below self"` // Rows visible to users of the role
+	DataDeptIDs *[]string `json:"dataDeptIds"`                                                             // Departments visible with the custom data scope
+	MenuIDs     *[]string `json:"menuIds"`                                                                 // Menu ids
+	// Conditions of the granted menus by menu ID replacing the current ones, kept for the granted menus if null
+	MenuConditions map[string]*models.GrantCondition `json:"menuConditions"`
 }
@@ -0,0 +1,15 @@
+package dtos
+
+import "time"
+
+// Login session of user
+type Session struct {
+	ID         string    `json:"id"`         // Session ID (claim ID of token)
+	ClientIP   string    `json:"clientIp"`   // Client IP when logged in
+	UserAgent  string    `json:"userAgent"`  // User agent when logged in
+	Location   string    `json:"location"`   // Geo location of client IP
+	IssuedAt   time.Time `json:"issuedAt"`   // Login time
+	LastSeenAt time.Time `json:"lastSeenAt"` // Last active time
+	ExpiresAt  time.Time `json:"expiresAt"`  // Expire time
+	Current    bool      `json:"current"`    // Whether it is the session of current request
+}
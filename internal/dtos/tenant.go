@@ -0,0 +1,26 @@
+package dtos
+
+// Defining the query parameters for the `Tenant` struct.
+type TenantListReq struct {
+	Pager
+	Code   string `form:"code"`                                              // Code of tenant
+	Name   string `form:"name"`                                              // Display name of tenant
+	Status string `form:"status" binding:"omitempty,oneof=disabled enabled"` // Status of tenant (disabled, enabled)
+}
+
+// Defining the data structure for creating a `Tenant` struct, the super admin of the tenant is created with it.
+type TenantCreateReq struct {
+	Code          string `json:"code" binding:"required,max=32,alphanum"`          // Code of tenant (unique), resolved from the subdomain or request header
+	Name          string `json:"name" binding:"required,max=128"`                  // Display name of tenant
+	Description   string `json:"description" binding:"max=1024"`                   // Details about tenant
+	Status        string `json:"status" binding:"required,oneof=disabled enabled"` // Status of tenant (enabled, disabled)
+	AdminUsername string `json:"adminUsername" binding:"required,max=64"`          // Username of the super admin of tenant
+	AdminNickName string `json:"adminNickName" binding:"max=64"`                   // Name of the super admin of tenant, the username if empty
+	AdminPassword string `json:"adminPassword" binding:"max=64"`                   // Password of the super admin (plaintext, the admin logs in with its md5 hash), the default password if empty
+}
+
+type TenantUpdateReq struct {
+	Name        *string `json:"name" binding:"omitempty,max=128"`                  // Display name of tenant
+	Description *string `json:"description" binding:"omitempty,max=1024"`          // Details about tenant
+	Status      *string `json:"status" binding:"omitempty,oneof=disabled enabled"` // Status of tenant (enabled, disabled)
+}
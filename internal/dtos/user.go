@@ -6,6 +6,8 @@ type UserListReq struct {
 	LikeUsername string `form:"username"`                                           // Username for login
 	LikeName     string `form:"name"`                                               // Name of user
 	Status       string `form:"status" binding:"omitempty,oneof=activated freezed"` // Status of user (activated, freezed)
+	Type         string `form:"type" binding:"omitempty,oneof=user service"`        // Type of user (user, service)
+	DeptID       string `form:"deptId"`                                             // Department of user, the sub departments are included
 	WithRoles    bool   `form:"withRoles"`                                          // Whether to include role IDs
 }
 
@@ -14,12 +16,15 @@ type UserCreateReq struct {
 	Username    string   `json:"username" binding:"required,max=64"`                // Username for login
 	NickName    string   `json:"nickName" binding:"required,max=64"`                // Name of user
 	RealName    string   `json:"realName" binding:"max=64"`                         // Real name of user
-	Password    string   `json:"password" binding:"max=64"`                         // Password for login (md5 hash)
+	Password    string   `json:"password" binding:"max=64"`                         // Password for login (plaintext, checked against the password policy, the user logs in with its md5 hash)
 	Wechat      string   `json:"wechat" binding:"max=64"`                           // Wechat account
 	Phone       string   `json:"phone" binding:"max=32"`                            // Phone number of user
 	Email       string   `json:"email" binding:"omitempty,max=128,email"`           // Email of user
 	Description string   `json:"description" binding:"max=1024"`                    // Description of user
 	Status      string   `json:"status" binding:"required,oneof=activated freezed"` // Status of user (activated, freezed)
+	Type        string   `json:"type" binding:"omitempty,oneof=user service"`       // Type of user (user, service), service accounts can not login interactively
+	DeptID      string   `json:"deptId" binding:"max=20"`                           // Primary department of user
+	DeptIDs     []string `json:"deptIds"`                                           // Secondary departments of user
 	RoleIDs     []string `json:"roleIds" binding:"required"`                        // Roles of user
 }
 
@@ -27,11 +32,13 @@ type UserUpdateReq struct {
 	Username    *string   `json:"username" binding:"omitempty,max=64"`                // Username for login
 	NickName    *string   `json:"nickName" binding:"omitempty,max=64"`                // Name of user
 	RealName    *string   `json:"realName" binding:"omitempty,max=64"`                // Real name of user
-	Password    *string   `json:"password" binding:"omitempty,max=64"`                // Password for login (md5 hash)
+	Password    *string   `json:"password" binding:"omitempty,max=64"`                // Password for login (plaintext, checked against the password policy, the user logs in with its md5 hash)
 	Wechat      *string   `json:"wechat" binding:"omitempty,max=64"`                  // Wechat account
 	Phone       *string   `json:"phone" binding:"omitempty,max=32"`                   // Phone number of user
 	Email       *string   `json:"email" binding:"omitempty,email,max=128"`            // Email of user
 	Description *string   `json:"description" binding:"omitempty,max=1024"`           // Description of user
 	Status      *string   `json:"status" binding:"omitempty,oneof=activated freezed"` // Status of user (activated, freezed)
+	DeptID      *string   `json:"deptId" binding:"omitempty,max=20"`                  // Primary department of user
+	DeptIDs     *[]string `json:"deptIds"`                                            // Secondary departments of user
 	RoleIDs     *[]string `json:"roleIds" binding:"omitempty"`                        // Roles of user
 }
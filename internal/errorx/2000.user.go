@@ -34,4 +34,51 @@ var (
 	ErrUser                    = Define(userI18n, 2018, "incorrect user", http.StatusBadRequest)                                      // 用户信息错误
 	ErrOldPassword             = Define(userI18n, 2019, "old password incorrect", http.StatusBadRequest)                              // 旧密码错误
 	ErrCaptchaIDNotFound       = Define(userI18n, 2020, "captcha id not found", http.StatusBadRequest)                                // 验证码ID不存在
+	ErrCaptchaIncorrect        = Define(userI18n, 2021, "incorrect captcha", http.StatusBadRequest)                                   // 验证码错误
+
+	ErrLoginLocked      = Definef[struct{ UnlockAt string }](userI18n, 2022, "too many failed logins, locked until {{.UnlockAt}}", http.StatusLocked)                // 登录失败次数过多，已锁定至 {{.UnlockAt}}
+	ErrLoginTooFrequent = Definef[struct{ Seconds int }](userI18n, 2023, "too many failed logins, please retry in {{.Seconds}} seconds", http.StatusTooManyRequests) // 登录失败次数过多，请 {{.Seconds}} 秒后重试
+
+	ErrMFACodeIncorrect    = Define(userI18n, 2024, "incorrect verification code", http.StatusBadRequest)                // 验证码错误
+	ErrMFAChallengeInvalid = Define(userI18n, 2025, "invalid or expired verification", http.StatusUnauthorized)          // 验证无效或已过期
+	ErrMFAAlreadyEnabled   = Define(userI18n, 2026, "two-factor authentication is already enabled", http.StatusConflict) // 已启用双因素认证
+	ErrMFANotEnrolled      = Define(userI18n, 2027, "two-factor authentication is not enrolled", http.StatusBadRequest)  // 未绑定双因素认证
+	ErrMFARequired         = Define(userI18n, 2028, "two-factor authentication is required", http.StatusForbidden)       // 必须启用双因素认证
+	ErrSessionNotFound     = Define(userI18n, 2029, "session not found", http.StatusNotFound)                            // 会话不存在
+
+	ErrAPIKeyNotFound      = Define(userI18n, 2030, "api key not found", http.StatusNotFound)                                // API 密钥不存在
+	ErrInvalidAPIKey       = Define(userI18n, 2031, "invalid or expired api key", http.StatusUnauthorized)                   // API 密钥无效或已过期
+	ErrAPIKeyRoleNotOwned  = Define(userI18n, 2032, "api key can only be granted roles of its owner", http.StatusBadRequest) // API 密钥只能授予所属用户拥有的角色
+	ErrAPIKeyNotAllowed    = Define(userI18n, 2033, "the operation is not allowed with an api key", http.StatusForbidden)    // 不允许使用 API 密钥执行该操作
+	ErrServiceAccountLogin = Define(userI18n, 2034, "service accounts can not login interactively", http.StatusForbidden)    // 服务账号不能交互式登录
+
+	ErrOIDCProviderNotFound  = Define(userI18n, 2035, "identity provider not found", http.StatusNotFound)                       // 身份提供方不存在
+	ErrOIDCStateInvalid      = Define(userI18n, 2036, "invalid or expired sign-on", http.StatusBadRequest)                      // 单点登录无效或已过期
+	ErrOIDCAuthFailed        = Define(userI18n, 2037, "single sign-on failed", http.StatusUnauthorized)                         // 单点登录失败
+	ErrOIDCIdentityNotLinked = Define(userI18n, 2038, "no user is linked to the external identity", http.StatusForbidden)       // 外部身份未关联用户
+	ErrOIDCIdentityLinked    = Define(userI18n, 2039, "the external identity is already linked to a user", http.StatusConflict) // 外部身份已关联其他用户
+	ErrIdentityNotFound      = Define(userI18n, 2040, "identity not found", http.StatusNotFound)                                // 身份不存在
+
+	ErrPasswordTooShort         = Definef[struct{ Length int }](userI18n, 2041, "password must be at least {{.Length}} characters", http.StatusBadRequest)                                                         // 密码长度至少为 {{.Length}} 个字符
+	ErrPasswordTooWeak          = Definef[struct{ Classes int }](userI18n, 2042, "password must contain at least {{.Classes}} of uppercase letters, lowercase letters, digits and symbols", http.StatusBadRequest) // 密码至少需要包含大写字母、小写字母、数字和符号中的 {{.Classes}} 种
+	ErrPasswordContainsUsername = Define(userI18n, 2043, "password must not contain the username", http.StatusBadRequest)                                                                                          // 密码不能包含用户名
+	ErrPasswordReused           = Definef[struct{ Count int }](userI18n, 2044, "password must differ from the last {{.Count}} passwords", http.StatusBadRequest)                                                   // 密码不能与最近 {{.Count}} 次使用的密码相同
+	ErrPasswordChangeInvalid    = Define(userI18n, 2045, "invalid or expired password change", http.StatusUnauthorized)                                                                                            // 修改密码请求无效或已过期
+	ErrPasswordResetInvalid     = Define(userI18n, 2046, "invalid or expired password reset code", http.StatusBadRequest)                                                                                          // 重置密码验证码无效或已过期
+	ErrPasswordResetUnavailable = Define(userI18n, 2047, "password reset by mail is not available", http.StatusServiceUnavailable)                                                                                 // 未开启邮件重置密码
+
+	ErrDepartmentNotFound    = Define(userI18n, 2048, "department not found", http.StatusNotFound)                                             // 部门不存在
+	ErrDepartmentHasChildren = Define(userI18n, 2049, "department has sub departments", http.StatusBadRequest)                                 // 部门下存在子部门
+	ErrDepartmentHasMembers  = Define(userI18n, 2050, "department has members", http.StatusBadRequest)                                         // 部门下存在成员
+	ErrDepartmentMoveInvalid = Define(userI18n, 2051, "department can not be moved into itself or its sub departments", http.StatusBadRequest) // 部门不能移动到自身或其子部门下
+
+	ErrRoleInheritCycle      = Define(userI18n, 2052, "role can not inherit from itself or its sub roles", http.StatusBadRequest) // 角色不能继承自身或其子角色
+	ErrGrantConditionInvalid = Define(userI18n, 2053, "invalid menu grant condition", http.StatusBadRequest)                      // 菜单授权条件无效
+
+	ErrTenantNotFound     = Define(userI18n, 2054, "tenant not found", http.StatusNotFound)                                      // 租户不存在
+	ErrTenantDisabled     = Definef[struct{ Name string }](userI18n, 2055, "tenant {{.Name}} is disabled", http.StatusForbidden) // 租户 {{.Name}} 已被禁用
+	ErrTenantCodeExists   = Define(userI18n, 2056, "tenant code already exists", http.StatusBadRequest)                          // 租户编码已存在
+	ErrTenantPlatformOnly = Define(userI18n, 2057, "operation is only allowed out of tenants", http.StatusForbidden)             // 该操作不允许在租户内执行
+
+	ErrLogExportTooLarge = Definef[struct{ Max int }](userI18n, 2058, "at most {{.Max}} logs can be exported, please narrow the filters", http.StatusBadRequest) // 最多导出 {{.Max}} 条日志，请缩小筛选范围
 )
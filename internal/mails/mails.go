@@ -0,0 +1,36 @@
+// Package mails renders the mails sent to users, the texts are localized with the `mail` catalog.
+package mails
+
+import (
+	"html"
+	"strings"
+
+	"github.com/epkgs/i18n"
+)
+
+var mailI18n = i18n.NewCatalog("mail")
+
+// A rendered mail
+type Mail struct {
+	Subject string
+	Body    string // HTML
+}
+
+// Render paragraphs of localized texts into the HTML body.
+func render(paragraphs ...string) string {
+	var b strings.Builder
+	for _, p := range paragraphs {
+		if p == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(p)
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
+
+// Escape the arguments of a text, the localized texts are trusted.
+func escape(s string) string {
+	return html.EscapeString(s)
+}
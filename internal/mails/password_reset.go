@@ -0,0 +1,54 @@
+package mails
+
+import (
+	"context"
+	"fmt"
+)
+
+var (
+	passwordResetSubject = mailI18n.New("Reset your password")
+	passwordResetGreet   = mailI18n.New("Hello {{.Name}},")
+	passwordResetIntro   = mailI18n.New("We received a request to reset the password of your account {{.Username}}.")
+	passwordResetLink    = mailI18n.New("Open the link below to set a new password, it expires in {{.Minutes}} minutes:")
+	passwordResetCode    = mailI18n.New("Use the code below to set a new password, it expires in {{.Minutes}} minutes:")
+	passwordResetIgnore  = mailI18n.New("If you did not request a password reset, you can ignore this mail.")
+)
+
+func init() {
+	mailI18n.LoadTranslations()
+}
+
+type PasswordResetData struct {
+	Name     string // Display name of the user
+	Username string
+	Code     string // Single-use reset code
+	URL      string // Reset link, empty to send the code only
+	Minutes  int    // Minutes before the code expires
+}
+
+// Mail with the code or link to reset the password.
+func PasswordReset(ctx context.Context, data PasswordResetData) *Mail {
+	args := struct {
+		Name     string
+		Username string
+		Minutes  int
+	}{escape(data.Name), escape(data.Username), data.Minutes}
+
+	action := fmt.Sprintf("<strong>%s</strong>", escape(data.Code))
+	instruction := passwordResetCode.T(ctx, args)
+	if data.URL != "" {
+		action = fmt.Sprintf(`<a href="%[1]s">%[1]s</a>`, escape(data.URL))
+		instruction = passwordResetLink.T(ctx, args)
+	}
+
+	return &Mail{
+		Subject: passwordResetSubject.T(ctx),
+		Body: render(
+			passwordResetGreet.T(ctx, args),
+			passwordResetIntro.T(ctx, args),
+			instruction,
+			action,
+			passwordResetIgnore.T(ctx),
+		),
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gin-admin/internal/configs"
+)
+
+// Personal API key of user
+type APIKey struct {
+	ID         string     `json:"id" gorm:"size:20;primarykey;"`              // Unique ID
+	TenantID   string     `json:"-" gorm:"size:20;index;not null;default:''"` // Tenant of the owner (From Tenant.ID), empty for the platform
+	UserID     string     `json:"userId" gorm:"size:20;index"`                // Owner of the key, from User.ID
+	Name       string     `json:"name" gorm:"size:128"`                       // Display name of the key
+	Prefix     string     `json:"prefix" gorm:"size:32;uniqueIndex"`          // Public prefix to identify the key
+	Hash       string     `json:"-" gorm:"size:64"`                           // SHA256 hash of the key
+	RoleIDs    []string   `json:"roleIds" gorm:"type:text;serializer:json"`   // Roles granted to the key (subset of owner's roles, empty for all)
+	ExpiresAt  *time.Time `json:"expiresAt"`                                  // Expire time, nil for never
+	LastUsedAt *time.Time `json:"lastUsedAt"`                                 // Last time the key was used
+	LastUsedIP string     `json:"lastUsedIp" gorm:"size:64"`                  // Client IP of the last use
+	CreatedAt  time.Time  `json:"createdAt" gorm:"index;"`                    // Create time
+	UpdatedAt  time.Time  `json:"updatedAt" gorm:"index;"`                    // Update time
+}
+
+func (a APIKey) TableName() string {
+	return configs.C.FormatTableName("api_key")
+}
+
+// Check whether the key is expired at the time.
+func (a *APIKey) Expired(now time.Time) bool {
+	return a.ExpiresAt != nil && !a.ExpiresAt.After(now)
+}
+
+// Defining the slice of `APIKey` struct.
+type APIKeys []*APIKey
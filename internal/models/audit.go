@@ -0,0 +1,119 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gin-admin/internal/configs"
+)
+
+const (
+	AuditAction_Create       = "create"
+	AuditAction_Update       = "update"
+	AuditAction_Delete       = "delete"
+	AuditAction_Unlock       = "unlock"
+	AuditAction_RevokeTokens = "revoke_tokens"
+
+	AuditEntity_User       = "user"
+	AuditEntity_Role       = "role"
+	AuditEntity_Menu       = "menu"
+	AuditEntity_Department = "department"
+	AuditEntity_Tenant     = "tenant"
+	AuditEntity_APIKey     = "api_key"
+
+	auditMask = "******"
+)
+
+// Audit trail of the changes made to the managed entities
+type Audit struct {
+	ID         string       `json:"id" gorm:"size:20;primaryKey;"`                     // Unique ID
+	TenantID   string       `json:"tenantId" gorm:"size:20;index;not null;default:''"` // Tenant of the change (From Tenant.ID), empty for the platform
+	UserID     string       `json:"userId" gorm:"size:20;index;"`                      // Actor of the change (From User.ID)
+	Action     string       `json:"action" gorm:"size:20;index;"`                      // Action of the change (create, update, delete, unlock, revoke_tokens)
+	EntityType string       `json:"entityType" gorm:"size:32;index;"`                  // Type of the changed entity (user, role, menu, department, tenant, api_key)
+	EntityID   string       `json:"entityId" gorm:"size:20;index;"`                    // ID of the changed entity
+	Changes    AuditChanges `json:"changes" gorm:"type:text;serializer:json;"`         // Changed fields with the old and new values
+	TraceID    string       `json:"traceId" gorm:"size:64;index;"`                     // Trace ID of the request
+	ClientIP   string       `json:"clientIp" gorm:"size:64;"`                          // Client IP of the request
+	CreatedAt  time.Time    `json:"createdAt" gorm:"index;"`                           // Create time
+
+	NickName string `json:"nickName" gorm:"<-:false;-:migration;"` // From User.NickName
+	Username string `json:"username" gorm:"<-:false;-:migration;"` // From User.Username
+}
+
+func (a Audit) TableName() string {
+	return configs.C.FormatTableName("audit")
+}
+
+// Defining the slice of `Audit` struct.
+type Audits []*Audit
+
+// Changed field of the audited entity
+type AuditChange struct {
+	Field string `json:"field"` // JSON name of the field
+	Old   any    `json:"old"`   // Value before the change, null for the created entity
+	New   any    `json:"new"`   // Value after the change, null for the deleted entity
+}
+
+type AuditChanges []*AuditChange
+
+// Compare the fields of two copies of the model by their struct field names, like the keys assigned by object.Assign.
+// The unchanged fields are skipped, nil stands for the missing copy of the created or deleted entity,
+// and the values of the fields hidden from JSON are masked.
+func DiffFields(before, after any, fields ...string) AuditChanges {
+	bv, av := reflect.Indirect(reflect.ValueOf(before)), reflect.Indirect(reflect.ValueOf(after))
+	var typ reflect.Type
+	switch {
+	case bv.IsValid():
+		typ = bv.Type()
+	case av.IsValid():
+		typ = av.Type()
+	default:
+		return nil
+	}
+
+	var changes AuditChanges
+	for _, name := range fields {
+		sf, ok := typ.FieldByName(name)
+		if !ok || !sf.IsExported() {
+			continue
+		}
+
+		// the zero values of the created or deleted entity are not changes
+		var old, new any
+		if bv.IsValid() {
+			if fv := bv.FieldByIndex(sf.Index); av.IsValid() || !fv.IsZero() {
+				old = fv.Interface()
+			}
+		}
+		if av.IsValid() {
+			if fv := av.FieldByIndex(sf.Index); bv.IsValid() || !fv.IsZero() {
+				new = fv.Interface()
+			}
+		}
+		if reflect.DeepEqual(old, new) {
+			continue
+		}
+
+		field, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if field == "-" {
+			old, new = mask(old), mask(new)
+		}
+		if field == "" || field == "-" {
+			r, size := utf8.DecodeRuneInString(sf.Name)
+			field = string(unicode.ToLower(r)) + sf.Name[size:]
+		}
+		changes = append(changes, &AuditChange{Field: field, Old: old, New: new})
+	}
+	return changes
+}
+
+func mask(v any) any {
+	if v == nil {
+		return nil
+	}
+	return auditMask
+}
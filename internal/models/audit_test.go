@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffFields(t *testing.T) {
+	before := &User{Username: "alice", NickName: "Alice", Password: "hash1", Status: UserStatus_Activated}
+	after := *before
+	after.NickName = "Alice Liddell"
+	after.Password = "hash2"
+
+	// the unchanged and unknown fields are skipped, the hidden ones are masked
+	changes := DiffFields(before, &after, "Username", "NickName", "Password", "Unknown")
+	assert.Equal(t, AuditChanges{
+		{Field: "nickName", Old: "Alice", New: "Alice Liddell"},
+		{Field: "password", Old: "******", New: "******"},
+	}, changes)
+
+	// the missing copy of the created or deleted entity is nil
+	changes = DiffFields(nil, before, "Username")
+	assert.Equal(t, AuditChanges{{Field: "username", Old: nil, New: "alice"}}, changes)
+	changes = DiffFields(before, nil, "Status")
+	assert.Equal(t, AuditChanges{{Field: "status", Old: UserStatus_Activated, New: nil}}, changes)
+
+	assert.Nil(t, DiffFields(nil, nil, "Username"))
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gin-admin/internal/configs"
+)
+
+const (
+	DepartmentStatus_Enabled  = "enabled"  // Enabled
+	DepartmentStatus_Disabled = "disabled" // Disabled
+)
+
+// Department of the organization
+type Department struct {
+	ID          string    `json:"id" gorm:"size:20;primarykey;"`                     // Unique ID
+	TenantID    string    `json:"tenantId" gorm:"size:20;index;not null;default:''"` // Tenant of department (From Tenant.ID), empty for the platform
+	Code        string    `json:"code" gorm:"size:32;index;"`                        // Code of department
+	Name        string    `json:"name" gorm:"size:128;index"`                        // Display name of department
+	Description string    `json:"description" gorm:"size:1024"`                      // Details about department
+	Status      string    `json:"status" gorm:"size:20;index"`                       // Status of department (enabled, disabled)
+	ParentID    string    `json:"parentId" gorm:"size:20;index;"`                    // Parent ID (From Department.ID)
+	ParentPath  string    `json:"-" gorm:"size:255;index;"`                          // Parent path (split by .)
+	Rank        int       `json:"rank" gorm:"index;"`                                // Rank for sorting (Order by desc)
+	CreatedAt   time.Time `json:"createdAt" gorm:"index;"`                           // Create time
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"index;"`                           // Update time
+
+	Children *Departments `json:"children,omitempty" gorm:"-"` // Child departments
+	Leaders  Users        `json:"leaders,omitempty" gorm:"-"`  // Leaders of department
+}
+
+func (a Department) TableName() string {
+	return configs.C.FormatTableName("department")
+}
+
+// Path of the department itself, the prefix of the parent path of all its sub departments.
+func (a *Department) Path() string {
+	return a.ParentPath + a.ID + "."
+}
+
+// Defining the slice of `Department` struct.
+type Departments []*Department
+
+func (a Departments) ToIDMapper() map[string]*Department {
+	m := make(map[string]*Department)
+	for _, item := range a {
+		m[item.ID] = item
+	}
+	return m
+}
+
+func (a Departments) ToTree() Departments {
+	var list Departments
+	m := a.ToIDMapper()
+	for _, item := range a {
+		parent, ok := m[item.ParentID]
+		if !ok {
+			list = append(list, item)
+			continue
+		}
+		if parent.Children == nil {
+			parent.Children = &Departments{}
+		}
+		*parent.Children = append(*parent.Children, item)
+	}
+	return list
+}
+
+func (m Departments) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("[]"), nil
+	}
+
+	type M Departments
+	copy := M(m)
+
+	return json.Marshal(copy)
+}
@@ -19,3 +19,17 @@ func (a Logger) TableName() string {
 
 // Defining the slice of `Logger` struct.
 type Loggers []*Logger
+
+// Daily counts of the purged logs, kept after the logs are deleted by the retention
+type LoggerRollup struct {
+	ID       string `json:"id" gorm:"size:20;primaryKey;"`                                                 // Unique ID
+	Day      string `json:"day" gorm:"size:10;uniqueIndex:idx_logger_rollup_key;"`                         // Day of the logs (2006-01-02)
+	TenantID string `json:"tenantId" gorm:"size:20;uniqueIndex:idx_logger_rollup_key;not null;default:''"` // Tenant of the logs
+	Tag      string `json:"tag" gorm:"size:32;uniqueIndex:idx_logger_rollup_key;"`                         // Log tag
+	Level    string `json:"level" gorm:"size:20;uniqueIndex:idx_logger_rollup_key;"`                       // Log level
+	Total    int64  `json:"total" gorm:"not null;default:0"`                                               // Number of the logs
+}
+
+func (a LoggerRollup) TableName() string {
+	return configs.C.FormatTableName("logger_rollup")
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"regexp"
 	"strings"
 	"time"
 
@@ -23,6 +24,7 @@ type Menu struct {
 	Name       string    `json:"name" gorm:"size:128;index"`                   // Display name of menu
 	Type       string    `json:"type" gorm:"size:20;index"`                    // Type of menu (catalog, menu, button)
 	Method     string    `json:"method" gorm:"size:20;index;"`                 // Http method of resource
+	Code       string    `json:"code" gorm:"size:128;index;"`                  // Permission code of button for frontend
 	Path       string    `json:"path" gorm:"size:255;"`                        // Access path of menu
 	Component  string    `json:"component" gorm:"size:255;"`                   // Component path of view
 	Status     string    `json:"status" gorm:"size:20;index"`                  // Status of menu (enabled, disabled)
@@ -44,6 +46,14 @@ func (a Menu) TableName() string {
 	return configs.C.FormatTableName("menu")
 }
 
+// Path parameters written as `{id}`
+var pathParamRegexp = regexp.MustCompile(`\{([^/{}]+)\}`)
+
+// Convert the path parameters of the resource path to the gin route template style, e.g. `/users/{id}` to `/users/:id`.
+func ToRoutePath(path string) string {
+	return pathParamRegexp.ReplaceAllString(path, ":$1")
+}
+
 // Defining the slice of `Menu` struct.
 type Menus []*Menu
 
@@ -1,23 +1,150 @@
 package models
 
 import (
+	"net"
+	"slices"
 	"time"
 
 	"gin-admin/internal/configs"
+	"gin-admin/pkg/randx"
+
+	"gorm.io/gorm"
 )
 
-// Role permissions for SYS
+// Role permissions for SYS, the join table of roles and menus
 type MenuRole struct {
-	ID        string    `json:"id" gorm:"size:20;primarykey"`                          // Unique ID
-	RoleID    string    `json:"roleId" gorm:"size:20;uniqueIndex:idx_role_menu_index"` // From Role.ID
-	MenuID    string    `json:"menuId" gorm:"size:20;uniqueIndex:idx_role_menu_index"` // From Menu.ID
-	CreatedAt time.Time `json:"createdAt" gorm:"index;"`                               // Create time
-	UpdatedAt time.Time `json:"updatedAt" gorm:"index;"`                               // Update time
+	ID        string          `json:"id" gorm:"size:20;primarykey"`                                      // Unique ID
+	RoleID    string          `json:"roleId" gorm:"size:20;uniqueIndex:idx_role_menu_index"`             // From Role.ID
+	MenuID    string          `json:"menuId" gorm:"size:20;uniqueIndex:idx_role_menu_index"`             // From Menu.ID
+	TenantID  string          `json:"tenantId" gorm:"size:20;index;not null;default:''"`                 // Tenant of the role (From Tenant.ID), empty for the platform
+	Condition *GrantCondition `json:"condition" gorm:"column:grant_condition;type:text;serializer:json"` // Conditions of the grant, the grant always applies if empty
+	CreatedAt time.Time       `json:"createdAt" gorm:"index;"`                                           // Create time
+	UpdatedAt time.Time       `json:"updatedAt" gorm:"index;"`                                           // Update time
 }
 
 func (a MenuRole) TableName() string {
 	return configs.C.FormatTableName("role_menus")
 }
 
+// The rows inserted by the role and menu associations are given an ID as well.
+func (a *MenuRole) BeforeCreate(*gorm.DB) error {
+	if a.ID == "" {
+		a.ID = randx.NewXID()
+	}
+	return nil
+}
+
 // Defining the slice of `MenuRole` struct.
 type MenuRoles []*MenuRole
+
+// Conditions of a menu grant evaluated from the request, all the given conditions must be met.
+type GrantCondition struct {
+	TimeWindows []*GrantTimeWindow `json:"timeWindows,omitempty"` // Allowed time windows in server local time, any of them
+	CIDRs       []string           `json:"cidrs,omitempty"`       // Allowed client IP ranges, any of them
+	MFA         bool               `json:"mfa,omitempty"`         // The user must pass two-factor authentication at login
+}
+
+// Daily time window of a grant.
+type GrantTimeWindow struct {
+	Weekdays []time.Weekday `json:"weekdays,omitempty"` // Days of the week (0 is Sunday), every day if empty
+	Start    string         `json:"start"`              // Start time (HH:MM), inclusive
+	End      string         `json:"end"`                // End time (HH:MM), exclusive, a window before the start crosses midnight
+}
+
+// Attributes of the request the grant conditions are evaluated against.
+type GrantAttributes struct {
+	Time        time.Time
+	ClientIP    string
+	MFAVerified bool
+}
+
+const grantTimeLayout = "15:04"
+
+func (a *GrantCondition) IsEmpty() bool {
+	return a == nil || (len(a.TimeWindows) == 0 && len(a.CIDRs) == 0 && !a.MFA)
+}
+
+// Check the time windows and CIDRs are well formed.
+func (a *GrantCondition) Validate() bool {
+	if a == nil {
+		return true
+	}
+	for _, w := range a.TimeWindows {
+		if w == nil {
+			return false
+		}
+		if _, err := time.Parse(grantTimeLayout, w.Start); err != nil {
+			return false
+		}
+		if _, err := time.Parse(grantTimeLayout, w.End); err != nil {
+			return false
+		}
+		for _, d := range w.Weekdays {
+			if d < time.Sunday || d > time.Saturday {
+				return false
+			}
+		}
+	}
+	for _, cidr := range a.CIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Check the request attributes meet the conditions.
+func (a *GrantCondition) Match(attrs GrantAttributes) bool {
+	if a.IsEmpty() {
+		return true
+	}
+	if a.MFA && !attrs.MFAVerified {
+		return false
+	}
+
+	if len(a.CIDRs) > 0 {
+		ip := net.ParseIP(attrs.ClientIP)
+		if ip == nil || !slices.ContainsFunc(a.CIDRs, func(cidr string) bool {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			return err == nil && ipNet.Contains(ip)
+		}) {
+			return false
+		}
+	}
+
+	if len(a.TimeWindows) > 0 && !slices.ContainsFunc(a.TimeWindows, func(w *GrantTimeWindow) bool {
+		return w.contains(attrs.Time)
+	}) {
+		return false
+	}
+	return true
+}
+
+func (a *GrantTimeWindow) contains(t time.Time) bool {
+	start, err := time.Parse(grantTimeLayout, a.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(grantTimeLayout, a.End)
+	if err != nil {
+		return false
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	weekday := t.Weekday()
+	if endMinute <= startMinute && minute < endMinute {
+		// the window crossing midnight started on the day before
+		weekday = (weekday + 6) % 7
+	}
+	if len(a.Weekdays) > 0 && !slices.Contains(a.Weekdays, weekday) {
+		return false
+	}
+
+	if endMinute > startMinute {
+		return minute >= startMinute && minute < endMinute
+	}
+	return minute >= startMinute || minute < endMinute
+}
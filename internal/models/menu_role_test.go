@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantConditionMatch(t *testing.T) {
+	// Monday 10:30
+	monday := time.Date(2024, 1, 1, 10, 30, 0, 0, time.Local)
+
+	var empty *GrantCondition
+	assert.True(t, empty.Match(GrantAttributes{Time: monday}))
+
+	workHours := &GrantCondition{TimeWindows: []*GrantTimeWindow{
+		{Weekdays: []time.Weekday{time.Monday, time.Friday}, Start: "09:00", End: "18:00"},
+	}}
+	assert.True(t, workHours.Match(GrantAttributes{Time: monday}))
+	assert.False(t, workHours.Match(GrantAttributes{Time: monday.Add(8 * time.Hour)}))
+	assert.False(t, workHours.Match(GrantAttributes{Time: monday.AddDate(0, 0, 1)}))
+
+	// the window crossing midnight belongs to the day it starts
+	night := &GrantCondition{TimeWindows: []*GrantTimeWindow{
+		{Weekdays: []time.Weekday{time.Monday}, Start: "22:00", End: "06:00"},
+	}}
+	assert.True(t, night.Match(GrantAttributes{Time: monday.Add(12 * time.Hour)}))
+	assert.True(t, night.Match(GrantAttributes{Time: monday.Add(18 * time.Hour)}))
+	assert.False(t, night.Match(GrantAttributes{Time: monday.Add(-8 * time.Hour)}))
+	assert.False(t, night.Match(GrantAttributes{Time: monday}))
+
+	office := &GrantCondition{CIDRs: []string{"10.0.0.0/8", "192.168.1.0/24"}, MFA: true}
+	assert.True(t, office.Match(GrantAttributes{ClientIP: "192.168.1.20", MFAVerified: true}))
+	assert.False(t, office.Match(GrantAttributes{ClientIP: "192.168.1.20"}))
+	assert.False(t, office.Match(GrantAttributes{ClientIP: "172.16.0.1", MFAVerified: true}))
+	assert.False(t, office.Match(GrantAttributes{ClientIP: "", MFAVerified: true}))
+}
+
+func TestGrantConditionValidate(t *testing.T) {
+	assert.True(t, (&GrantCondition{
+		TimeWindows: []*GrantTimeWindow{{Start: "08:00", End: "20:00"}},
+		CIDRs:       []string{"::1/128"},
+	}).Validate())
+	assert.False(t, (&GrantCondition{TimeWindows: []*GrantTimeWindow{{Start: "8am", End: "20:00"}}}).Validate())
+	assert.False(t, (&GrantCondition{TimeWindows: []*GrantTimeWindow{{Weekdays: []time.Weekday{7}, Start: "08:00", End: "20:00"}}}).Validate())
+	assert.False(t, (&GrantCondition{CIDRs: []string{"10.0.0.1"}}).Validate())
+}
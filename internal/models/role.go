@@ -12,21 +12,35 @@ const (
 	RoleStatus_Disabled = "disabled" // Disabled
 
 	RoleResultType_Select = "select" // Select
+
+	RoleDataScope_All          = "all"            // All data
+	RoleDataScope_Custom       = "custom"         // Data of the selected departments
+	RoleDataScope_Dept         = "dept"           // Data of the own department
+	RoleDataScope_DeptAndBelow = "dept_and_below" // Data of the own department and its sub departments
+	RoleDataScope_Self         = "self"           // Data of the user itself
 )
 
 // Role management
 type Role struct {
-	ID          string    `json:"id" gorm:"size:20;primarykey;"` // Unique ID
-	Code        string    `json:"code" gorm:"size:32;index;"`    // Code of role (unique)
-	Name        string    `json:"name" gorm:"size:128;index"`    // Display name of role
-	Description string    `json:"description" gorm:"size:1024"`  // Details about role
-	Rank        int       `json:"rank" gorm:"index"`             // Rank for sorting
-	Status      string    `json:"status" gorm:"size:20;index"`   // Status of role (disabled, enabled)
-	CreatedAt   time.Time `json:"createdAt" gorm:"index;"`       // Create time
-	UpdatedAt   time.Time `json:"updatedAt" gorm:"index;"`       // Update time
+	ID          string    `json:"id" gorm:"size:20;primarykey;"`                     // Unique ID
+	TenantID    string    `json:"tenantId" gorm:"size:20;index;not null;default:''"` // Tenant of role (From Tenant.ID), empty for the platform
+	Code        string    `json:"code" gorm:"size:32;index;"`                        // Code of role (unique)
+	Name        string    `json:"name" gorm:"size:128;index"`                        // Display name of role
+	Description string    `json:"description" gorm:"size:1024"`                      // Details about role
+	Rank        int       `json:"rank" gorm:"index"`                                 // Rank for sorting
+	Status      string    `json:"status" gorm:"size:20;index"`                       // Status of role (disabled, enabled)
+	ParentID    string    `json:"parentId" gorm:"size:20;index;"`                    // Parent role ID (From Role.ID), the permissions of the parent are inherited
+	MFARequired bool      `json:"mfaRequired" gorm:"not null;default:false"`         // Users of the role must pass two-factor authentication
+	DataScope   string    `json:"dataScope" gorm:"size:20;not null;default:'all'"`   // Rows visible to users of the role (all, custom, dept, dept_and_below, self)
+	DataDeptIDs []string  `json:"dataDeptIds" gorm:"type:text;serializer:json"`      // Departments visible with the custom data scope
+	CreatedAt   time.Time `json:"createdAt" gorm:"index;"`                           // Create time
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"index;"`                           // Update time
 
 	Menus Menus `json:"menus" gorm:"many2many:role_menus;"`
 	Users Users `json:"users" gorm:"many2many:user_roles;"`
+
+	EffectiveMenus Menus                      `json:"effectiveMenus,omitempty" gorm:"-"` // Own and inherited menus of role
+	MenuConditions map[string]*GrantCondition `json:"menuConditions,omitempty" gorm:"-"` // Conditions of the own menu grants by menu ID
 }
 
 func (a Role) TableName() string {
@@ -36,6 +50,14 @@ func (a Role) TableName() string {
 // Defining the slice of `Role` struct.
 type Roles []*Role
 
+func (a Roles) ToIDs() []string {
+	var ids []string
+	for _, item := range a {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
 func (m Roles) MarshalJSON() ([]byte, error) {
 	if m == nil {
 		return []byte("[]"), nil
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gin-admin/internal/configs"
+)
+
+const (
+	TenantStatus_Enabled  = "enabled"  // Enabled
+	TenantStatus_Disabled = "disabled" // Disabled
+)
+
+// Tenant served by the application, the users, roles, departments and logs are owned by one tenant
+type Tenant struct {
+	ID          string    `json:"id" gorm:"size:20;primarykey;"`    // Unique ID
+	Code        string    `json:"code" gorm:"size:32;uniqueIndex;"` // Code of tenant, resolved from the subdomain or request header
+	Name        string    `json:"name" gorm:"size:128;index"`       // Display name of tenant
+	Description string    `json:"description" gorm:"size:1024"`     // Details about tenant
+	Status      string    `json:"status" gorm:"size:20;index"`      // Status of tenant (enabled, disabled)
+	AdminID     string    `json:"adminId" gorm:"size:20;"`          // Super admin of tenant (From User.ID)
+	CreatedAt   time.Time `json:"createdAt" gorm:"index;"`          // Create time
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"index;"`          // Update time
+}
+
+func (a Tenant) TableName() string {
+	return configs.C.FormatTableName("tenant")
+}
+
+// Defining the slice of `Tenant` struct.
+type Tenants []*Tenant
@@ -12,32 +12,61 @@ import (
 const (
 	UserStatus_Activated = "activated"
 	UserStatus_Freezed   = "freezed"
+
+	UserType_User    = "user"    // Interactive user
+	UserType_Service = "service" // Service account, only accesses with API keys
 )
 
 // User management for SYS
 type User struct {
-	ID          string    `json:"id" gorm:"size:20;primarykey;"`                                                       // Unique ID
-	Username    string    `json:"username" gorm:"size:64;index"`                                                       // Username for login
-	Password    string    `json:"-" gorm:"size:64;"`                                                                   // Password for login (encrypted)
-	NickName    string    `json:"nickName" gorm:"size:64;index"`                                                       // Name of user
-	RealName    string    `json:"realName" gorm:"size:64;"`                                                            // Real name of user
-	Wechat      string    `json:"wechat" gorm:"size:64;"`                                                              // Wechat account
-	Phone       string    `json:"phone" gorm:"size:32;"`                                                               // Phone number of user
-	Email       string    `json:"email" gorm:"size:128;"`                                                              // Email of user
-	Status      string    `json:"status" gorm:"size:20;index"`                                                         // Status of user (activated, freezed)
-	Description string    `json:"description" gorm:"size:1024"`                                                        // Details about user
-	Avatar      string    `json:"avatar" gorm:"not null;default:'';comment:Avatar URL"`                                // Avatar URL
-	Fingers     Fingers   `json:"-" gorm:"type:string;serializer:json;not null;default:'[]';comment:Fingerprint list"` // Frontend fingerprints
-	CreatedAt   time.Time `json:"createdAt" gorm:"index;"`                                                             // Create time
-	UpdatedAt   time.Time `json:"updatedAt" gorm:"index;"`                                                             // Update time
-
-	Roles Roles `json:"roles" gorm:"many2many:user_roles;"` // Roles of user
+	ID                 string     `json:"id" gorm:"size:20;primarykey;"`                                                       // Unique ID
+	TenantID           string     `json:"tenantId" gorm:"size:20;index;not null;default:''"`                                   // Tenant of user (From Tenant.ID), empty for the platform
+	Username           string     `json:"username" gorm:"size:64;index"`                                                       // Username for login
+	Password           string     `json:"-" gorm:"size:64;"`                                                                   // Password for login (encrypted)
+	NickName           string     `json:"nickName" gorm:"size:64;index"`                                                       // Name of user
+	RealName           string     `json:"realName" gorm:"size:64;"`                                                            // Real name of user
+	Wechat             string     `json:"wechat" gorm:"size:64;"`                                                              // Wechat account
+	Phone              string     `json:"phone" gorm:"size:32;"`                                                               // Phone number of user
+	Email              string     `json:"email" gorm:"size:128;"`                                                              // Email of user
+	Status             string     `json:"status" gorm:"size:20;index"`                                                         // Status of user (activated, freezed)
+	Type               string     `json:"type" gorm:"size:20;index;not null;default:'user'"`                                   // Type of user (user, service)
+	Description        string     `json:"description" gorm:"size:1024"`                                                        // Details about user
+	Avatar             string     `json:"avatar" gorm:"not null;default:'';comment:Avatar URL"`                                // Avatar URL
+	Fingers            Fingers    `json:"-" gorm:"type:string;serializer:json;not null;default:'[]';comment:Fingerprint list"` // Frontend fingerprints
+	MFAEnabled         bool       `json:"mfaEnabled" gorm:"not null;default:false"`                                            // Whether TOTP two-factor authentication is enabled
+	MFASecret          string     `json:"-" gorm:"size:256"`                                                                   // TOTP secret (AES encrypted)
+	MFACodes           []string   `json:"-" gorm:"type:text;serializer:json"`                                                  // Unused recovery codes (hashed)
+	PasswordChangedAt  *time.Time `json:"passwordChangedAt"`                                                                   // Time of the last password change
+	MustChangePassword bool       `json:"mustChangePassword" gorm:"not null;default:false"`                                    // Whether the password must be changed on next login
+	PasswordHistory    []string   `json:"-" gorm:"type:text;serializer:json"`                                                  // Hashes of the previous passwords, newest first
+	TokenGeneration    int64      `json:"-" gorm:"not null;default:0"`                                                         // Generation of the issued tokens, changed to invalidate all of them
+	DeptID             string     `json:"deptId" gorm:"size:20;index"`                                                         // Primary department of user (From Department.ID)
+	CreatedBy          string     `json:"createdBy" gorm:"size:20;index"`                                                      // From User.ID, the creator of user
+	CreatedAt          time.Time  `json:"createdAt" gorm:"index;"`                                                             // Create time
+	UpdatedAt          time.Time  `json:"updatedAt" gorm:"index;"`                                                             // Update time
+
+	Roles       Roles           `json:"roles" gorm:"many2many:user_roles;"`   // Roles of user
+	Departments UserDepartments `json:"departments" gorm:"foreignKey:UserID"` // Departments of user, including the primary one
 }
 
 func (a User) TableName() string {
 	return configs.C.FormatTableName("user")
 }
 
+// Check whether the password is older than the max age of the password policy.
+func (a *User) PasswordExpired(now time.Time) bool {
+	maxAge := configs.C.Password.MaxAge
+	if maxAge <= 0 || a.Password == "" {
+		return false
+	}
+
+	changedAt := a.CreatedAt
+	if a.PasswordChangedAt != nil {
+		changedAt = *a.PasswordChangedAt
+	}
+	return now.After(changedAt.AddDate(0, 0, maxAge))
+}
+
 // Defining the slice of `User` struct.
 type Users []*User
 
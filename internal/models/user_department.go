@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gin-admin/internal/configs"
+)
+
+// Department membership of user
+type UserDepartment struct {
+	ID        string    `json:"id" gorm:"size:20;primarykey"`          // Unique ID
+	UserID    string    `json:"userId" gorm:"size:20;index"`           // From User.ID
+	DeptID    string    `json:"deptId" gorm:"size:20;index"`           // From Department.ID
+	Primary   bool      `json:"primary" gorm:"not null;default:false"` // Whether it is the primary department of user (same as User.DeptID)
+	Leader    bool      `json:"leader" gorm:"not null;default:false"`  // Whether the user leads the department
+	CreatedAt time.Time `json:"createdAt" gorm:"index;"`               // Create time
+}
+
+func (a UserDepartment) TableName() string {
+	return configs.C.FormatTableName("user_departments")
+}
+
+// Defining the slice of `UserDepartment` struct.
+type UserDepartments []*UserDepartment
+
+func (a UserDepartments) ToDeptIDs() []string {
+	var ids []string
+	for _, item := range a {
+		ids = append(ids, item.DeptID)
+	}
+	return ids
+}
+
+func (a UserDepartments) ToUserIDs() []string {
+	var ids []string
+	for _, item := range a {
+		ids = append(ids, item.UserID)
+	}
+	return ids
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"gin-admin/internal/configs"
+)
+
+// External identity (e.g. OpenID Connect) linked to user
+type UserIdentity struct {
+	ID          string     `json:"id" gorm:"size:20;primarykey;"`                            // Unique ID
+	UserID      string     `json:"userId" gorm:"size:20;index"`                              // From User.ID
+	Provider    string     `json:"provider" gorm:"size:64;uniqueIndex:idx_identity_subject"` // Name of the identity provider
+	Subject     string     `json:"subject" gorm:"size:255;uniqueIndex:idx_identity_subject"` // Subject of the user at the provider
+	Email       string     `json:"email" gorm:"size:128"`                                    // Email claimed by the provider
+	LastLoginAt *time.Time `json:"lastLoginAt"`                                              // Last sign-on time with the identity
+	CreatedAt   time.Time  `json:"createdAt" gorm:"index;"`                                  // Create time
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"index;"`                                  // Update time
+}
+
+func (a UserIdentity) TableName() string {
+	return configs.C.FormatTableName("user_identity")
+}
+
+// Defining the slice of `UserIdentity` struct.
+type UserIdentities []*UserIdentity
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"context"
+
+	"gin-admin/internal/models"
+	"gin-admin/pkg/gormx"
+
+	"gorm.io/gorm"
+)
+
+// Personal API keys of user
+type APIKey struct {
+	gormx.Repository[models.APIKey]
+}
+
+func NewAPIKey(db *gorm.DB) *APIKey {
+	return &APIKey{
+		Repository: gormx.NewGenericRepo[models.APIKey](db),
+	}
+}
+
+func (a *APIKey) GetByPrefix(ctx context.Context, prefix string, opts ...gormx.Option) (*models.APIKey, error) {
+	return a.First(ctx, func(db *gorm.DB) *gorm.DB {
+		db = db.Where("prefix = ?", prefix)
+		return gormx.Apply(db, opts...)
+	})
+}
+
+func (a *APIKey) DeleteByUserID(ctx context.Context, userID ...string) error {
+	return a.Repository.DeleteBatch(ctx, gormx.WithWhere("user_id IN (?)", userID))
+}
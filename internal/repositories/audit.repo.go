@@ -0,0 +1,19 @@
+package repositories
+
+import (
+	"gin-admin/internal/models"
+	"gin-admin/pkg/gormx"
+
+	"gorm.io/gorm"
+)
+
+// Audit trail management
+type Audit struct {
+	gormx.Repository[models.Audit]
+}
+
+func NewAudit(db *gorm.DB) *Audit {
+	return &Audit{
+		Repository: gormx.NewGenericRepo[models.Audit](db),
+	}
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"slices"
+
+	"gin-admin/internal/models"
+	"gin-admin/pkg/gormx"
+
+	"gorm.io/gorm"
+)
+
+// Department of the organization
+type Department struct {
+	gormx.Repository[models.Department]
+}
+
+func NewDepartment(db *gorm.DB) *Department {
+	return &Department{
+		Repository: gormx.NewGenericRepo[models.Department](db),
+	}
+}
+
+// Find all sub departments under the path of a department.
+func (a *Department) FindDescendants(ctx context.Context, path string, opts ...gormx.Option) ([]*models.Department, error) {
+	return a.Repository.Find(ctx, gormx.WithWhere("parent_path LIKE ?", path+"%"), func(db *gorm.DB) *gorm.DB {
+		return gormx.Apply(db, opts...)
+	})
+}
+
+// Find the IDs of the departments and all their sub departments.
+func (a *Department) FindSubtreeIDs(ctx context.Context, ids ...string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	depts, err := a.Repository.Find(ctx, gormx.WithWhere("id IN (?)", ids), gormx.WithSelect("id", "parent_path"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := slices.Clone(ids)
+	for _, dept := range depts {
+		descendants, err := a.FindDescendants(ctx, dept.Path(), gormx.WithSelect("id"))
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range descendants {
+			result = append(result, child.ID)
+		}
+	}
+
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
+
+// Updates the parent path of the specified department.
+func (a *Department) UpdateParentPath(ctx context.Context, id, parentPath string) error {
+	dept := &models.Department{
+		ParentPath: parentPath,
+	}
+	return a.Repository.Update(ctx, dept, gormx.WithWhere("id=?", id))
+}
@@ -1,10 +1,13 @@
 package repositories
 
 import (
+	"context"
+
 	"gin-admin/internal/models"
 	"gin-admin/pkg/gormx"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Logger management
@@ -17,3 +20,29 @@ func NewLogger(db *gorm.DB) *Logger {
 		Repository: gormx.NewGenericRepo[models.Logger](db),
 	}
 }
+
+// Daily counts of the purged logs
+type LoggerRollup struct {
+	gormx.Repository[models.LoggerRollup]
+}
+
+func NewLoggerRollup(db *gorm.DB) *LoggerRollup {
+	return &LoggerRollup{
+		Repository: gormx.NewGenericRepo[models.LoggerRollup](db),
+	}
+}
+
+// Add the count to the rollup of the day, tenant, tag and level, the rollup is created if not exists.
+func (a *LoggerRollup) Add(ctx context.Context, item *models.LoggerRollup) error {
+	return gormx.GetDB(ctx, a.DB()).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "day"}, {Name: "tenant_id"}, {Name: "tag"}, {Name: "level"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"total": gorm.Expr("? + ?", clause.Column{Table: clause.CurrentTable, Name: "total"}, item.Total),
+		}),
+	}).Create(item).Error
+}
+
+// Scan the aggregates of the loggers matched by the options, which select and group the aggregates as well.
+func (a *Logger) Aggregate(ctx context.Context, dest any, opts ...gormx.Option) error {
+	return gormx.Apply(gormx.GetDB(ctx, a.DB()).Model(new(models.Logger)), opts...).Scan(dest).Error
+}
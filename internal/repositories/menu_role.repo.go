@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"gin-admin/internal/models"
 	"gin-admin/pkg/gormx"
@@ -25,6 +26,19 @@ func (a *MenuRole) DeleteByMenuID(ctx context.Context, menuID ...string) error {
 	return a.Repository.DeleteBatch(ctx, gormx.WithWhere("menu_id IN (?)", menuID))
 }
 
+// Deletes role menus by role id.
+func (a *MenuRole) DeleteByRoleID(ctx context.Context, roleID ...string) error {
+	return a.Repository.DeleteBatch(ctx, gormx.WithWhere("role_id IN (?)", roleID))
+}
+
+// Updates the condition of the role menu.
+func (a *MenuRole) UpdateCondition(ctx context.Context, roleID, menuID string, condition *models.GrantCondition) error {
+	return gormx.GetDB(ctx, a.Repository.DB()).Model(new(models.MenuRole)).
+		Where("role_id = ? AND menu_id = ?", roleID, menuID).
+		Select("Condition", "UpdatedAt").
+		Updates(&models.MenuRole{Condition: condition, UpdatedAt: time.Now()}).Error
+}
+
 // // Query role menus from the database based on the provided parameters and options.
 // func (a *MenuRole) Query(ctx context.Context, params models.RoleMenuQueryParam, opts ...models.RoleMenuQueryOptions) (*models.RoleMenuQueryResult, error) {
 // 	var opt models.RoleMenuQueryOptions
@@ -24,6 +24,11 @@ func (a *Role) ExistsCode(ctx context.Context, code string) (bool, error) {
 	return a.Repository.Exists(ctx, gormx.WithWhere("code=?", code))
 }
 
+// Updates the parent ID of the sub roles of the specified role.
+func (a *Role) UpdateParentID(ctx context.Context, parentID, newParentID string) error {
+	return gormx.GetDB(ctx, a.Repository.DB()).Model(new(models.Role)).Where("parent_id = ?", parentID).Update("parent_id", newParentID).Error
+}
+
 // // List roles from the database based on the provided parameters and options.
 // func (a *Role) List(ctx context.Context, params models.RoleQueryParam, opts ...models.RoleQueryOptions) (*models.RoleQueryResult, error) {
 // 	var opt models.RoleQueryOptions
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"context"
+
+	"gin-admin/internal/models"
+	"gin-admin/pkg/gormx"
+
+	"gorm.io/gorm"
+)
+
+// Tenant management
+type Tenant struct {
+	gormx.Repository[models.Tenant]
+}
+
+func NewTenant(db *gorm.DB) *Tenant {
+	return &Tenant{
+		Repository: gormx.NewGenericRepo[models.Tenant](db),
+	}
+}
+
+func (a *Tenant) GetByCode(ctx context.Context, code string, opts ...gormx.Option) (*models.Tenant, error) {
+	return a.First(ctx, func(db *gorm.DB) *gorm.DB {
+		db = db.Where("code = ?", code)
+		return gormx.Apply(db, opts...)
+	})
+}
+
+func (a *Tenant) ExistsCode(ctx context.Context, code string) (bool, error) {
+	return a.Repository.Exists(ctx, gormx.WithWhere("code = ?", code))
+}
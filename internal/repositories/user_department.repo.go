@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"context"
+
+	"gin-admin/internal/models"
+	"gin-admin/pkg/gormx"
+
+	"gorm.io/gorm"
+)
+
+// Department membership of user
+type UserDepartment struct {
+	gormx.Repository[models.UserDepartment]
+}
+
+func NewUserDepartment(db *gorm.DB) *UserDepartment {
+	return &UserDepartment{
+		Repository: gormx.NewGenericRepo[models.UserDepartment](db),
+	}
+}
+
+func (a *UserDepartment) DeleteByUserID(ctx context.Context, userID ...string) error {
+	return a.Repository.DeleteBatch(ctx, gormx.WithWhere("user_id IN (?)", userID))
+}
+
+func (a *UserDepartment) DeleteByDeptID(ctx context.Context, deptID ...string) error {
+	return a.Repository.DeleteBatch(ctx, gormx.WithWhere("dept_id IN (?)", deptID))
+}
+
+// Updates the leader flag of the department members.
+func (a *UserDepartment) UpdateLeader(ctx context.Context, deptID string, leader bool, opts ...gormx.Option) error {
+	db := gormx.Apply(gormx.GetDB(ctx, a.Repository.DB()), opts...)
+	return db.Model(new(models.UserDepartment)).Where("dept_id = ?", deptID).Update("leader", leader).Error
+}
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"context"
+
+	"gin-admin/internal/models"
+	"gin-admin/pkg/gormx"
+
+	"gorm.io/gorm"
+)
+
+// External identities of user
+type UserIdentity struct {
+	gormx.Repository[models.UserIdentity]
+}
+
+func NewUserIdentity(db *gorm.DB) *UserIdentity {
+	return &UserIdentity{
+		Repository: gormx.NewGenericRepo[models.UserIdentity](db),
+	}
+}
+
+func (a *UserIdentity) GetBySubject(ctx context.Context, provider, subject string, opts ...gormx.Option) (*models.UserIdentity, error) {
+	return a.First(ctx, func(db *gorm.DB) *gorm.DB {
+		db = db.Where("provider = ? AND subject = ?", provider, subject)
+		return gormx.Apply(db, opts...)
+	})
+}
+
+func (a *UserIdentity) DeleteByUserID(ctx context.Context, userID ...string) error {
+	return a.Repository.DeleteBatch(ctx, gormx.WithWhere("user_id IN (?)", userID))
+}
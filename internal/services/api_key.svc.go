@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/crypto/hash"
+	"gin-admin/pkg/crypto/rand"
+	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
+	"gin-admin/pkg/logger"
+	"gin-admin/pkg/randx"
+
+	"github.com/epkgs/object"
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyScheme = "ga_"
+
+	// The last used time is refreshed at most once per interval to avoid a write on every request
+	apiKeyTouchInterval = time.Minute
+)
+
+// Fields of the API key recorded by the audit trail
+var apiKeyAuditFields = []string{"UserID", "Name", "Prefix", "RoleIDs", "ExpiresAt"}
+
+// Personal API keys of user
+type APIKey struct {
+	APIKeyRepo *repositories.APIKey
+	UserRepo   *repositories.User
+	UserSvc    *User
+	AuditSvc   *Audit
+}
+
+func NewAPIKey(app types.AppContext) *APIKey {
+	return &APIKey{
+		APIKeyRepo: repositories.NewAPIKey(app.DB()),
+		UserRepo:   repositories.NewUser(app.DB()),
+		UserSvc:    NewUser(app),
+		AuditSvc:   NewAudit(app),
+	}
+}
+
+// Generate a key in the form of `ga_<prefix>_<secret>`, the prefix part identifies the key.
+func (a *APIKey) generate() (prefix, key string, err error) {
+	id, err := rand.Random(8, rand.LdigitAndLowerCase)
+	if err != nil {
+		return "", "", err
+	}
+	secret, err := rand.Random(40, rand.LdigitAndLetter)
+	if err != nil {
+		return "", "", err
+	}
+	prefix = apiKeyScheme + id
+	return prefix, prefix + "_" + secret, nil
+}
+
+func (a *APIKey) parsePrefix(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyScheme)
+	if !ok {
+		return "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", false
+	}
+	return apiKeyScheme + id, true
+}
+
+// Check the roles granted to a key are owned by the user.
+func (a *APIKey) checkRoles(ctx context.Context, userID string, roleIDs []string) error {
+	if len(roleIDs) == 0 {
+		return nil
+	}
+
+	owned, err := a.UserSvc.GetRoleIDs(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, roleID := range roleIDs {
+		if !slices.Contains(owned, roleID) {
+			return errorx.ErrAPIKeyRoleNotOwned.New(ctx)
+		}
+	}
+	return nil
+}
+
+func (a *APIKey) get(ctx context.Context, userID, id string) (*models.APIKey, error) {
+	item, err := a.APIKeyRepo.First(ctx, gormx.WithWhere("id = ? AND user_id = ?", id, userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorx.ErrAPIKeyNotFound.New(ctx)
+		}
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+	return item, nil
+}
+
+// List API keys of the user, the latest created first.
+func (a *APIKey) List(ctx context.Context, userID string) (models.APIKeys, error) {
+	list, err := a.APIKeyRepo.Find(ctx,
+		gormx.WithWhere("user_id = ?", userID),
+		gormx.WithOrder("created_at", "desc"),
+	)
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+	return list, nil
+}
+
+// Create an API key for the user, the plaintext key is only returned here and never stored.
+func (a *APIKey) Create(ctx context.Context, userID string, req *dtos.APIKeyCreateReq) (*dtos.APIKeyCreated, error) {
+	// a leaked key must not be able to issue itself new keys
+	if helper.GetAPIKeyID(ctx) != "" {
+		return nil, errorx.ErrAPIKeyNotAllowed.New(ctx)
+	}
+
+	if userID != configs.C.Super.ID {
+		exists, err := a.UserRepo.Exists(ctx, gormx.WithWhere("id = ?", userID))
+		if err != nil {
+			return nil, errorx.WrapGormError(ctx, err)
+		} else if !exists {
+			return nil, errorx.ErrUserNotFound.New(ctx)
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errorx.ErrInvalidParams.New(ctx, struct{ Params string }{"expiresAt"})
+	}
+
+	if err := a.checkRoles(ctx, userID, req.RoleIDs); err != nil {
+		return nil, err
+	}
+
+	prefix, key, err := a.generate()
+	if err != nil {
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	item := &models.APIKey{
+		ID:        randx.NewXID(),
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		Hash:      hash.SHA256String(key),
+		RoleIDs:   req.RoleIDs,
+		ExpiresAt: req.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	if item.RoleIDs == nil {
+		item.RoleIDs = []string{}
+	}
+
+	err = a.APIKeyRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.APIKeyRepo.Create(ctx, item); err != nil {
+			return err
+		}
+		return a.AuditSvc.Record(ctx, models.AuditAction_Create, models.AuditEntity_APIKey, item.ID, models.DiffFields(nil, item, apiKeyAuditFields...))
+	})
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	logger.Info(logger.WithTag(ctx, logger.Tag_Operate), "API key created", map[string]any{
+		"apiKeyId": item.ID,
+		"prefix":   item.Prefix,
+		"owner":    userID,
+	})
+
+	return &dtos.APIKeyCreated{
+		ID:        item.ID,
+		Name:      item.Name,
+		Prefix:    item.Prefix,
+		Key:       key,
+		RoleIDs:   item.RoleIDs,
+		ExpiresAt: item.ExpiresAt,
+		CreatedAt: item.CreatedAt,
+	}, nil
+}
+
+// Update the name or roles of the API key of the user.
+func (a *APIKey) Update(ctx context.Context, userID, id string, req *dtos.APIKeyUpdateReq) error {
+	if helper.GetAPIKeyID(ctx) != "" {
+		return errorx.ErrAPIKeyNotAllowed.New(ctx)
+	}
+
+	item, err := a.get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	if req.RoleIDs != nil {
+		if err := a.checkRoles(ctx, userID, *req.RoleIDs); err != nil {
+			return err
+		}
+	}
+
+	before := *item
+	var md object.Metadata
+	if err := object.Assign(item, req, func(c *object.AssignConfig) {
+		c.Metadata = &md
+	}); err != nil {
+		return errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	if item.RoleIDs == nil {
+		item.RoleIDs = []string{}
+	}
+	item.UpdatedAt = time.Now()
+
+	err = a.APIKeyRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.APIKeyRepo.Update(ctx, item, gormx.WithSelect(append(md.Keys, "UpdatedAt"))); err != nil {
+			return err
+		}
+		return a.AuditSvc.Record(ctx, models.AuditAction_Update, models.AuditEntity_APIKey, item.ID, models.DiffFields(&before, item, md.Keys...))
+	})
+	return errorx.WrapGormError(ctx, err)
+}
+
+// Delete the API key of the user, requests with it are rejected immediately.
+func (a *APIKey) Delete(ctx context.Context, userID, id string) error {
+	item, err := a.get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	err = a.APIKeyRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.APIKeyRepo.Delete(ctx, item.ID); err != nil {
+			return err
+		}
+		return a.AuditSvc.Record(ctx, models.AuditAction_Delete, models.AuditEntity_APIKey, item.ID, models.DiffFields(item, nil, apiKeyAuditFields...))
+	})
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	logger.Info(logger.WithTag(ctx, logger.Tag_Operate), "API key deleted", map[string]any{
+		"apiKeyId": item.ID,
+		"prefix":   item.Prefix,
+		"owner":    userID,
+	})
+	return nil
+}
+
+// Authenticate the plaintext key, it returns the matched key which is neither expired nor revoked.
+func (a *APIKey) Authenticate(ctx context.Context, key string) (*models.APIKey, error) {
+	prefix, ok := a.parsePrefix(key)
+	if !ok {
+		return nil, errorx.ErrInvalidAPIKey.New(ctx)
+	}
+
+	item, err := a.APIKeyRepo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorx.ErrInvalidAPIKey.New(ctx)
+		}
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(item.Hash), []byte(hash.SHA256String(key))) != 1 {
+		return nil, errorx.ErrInvalidAPIKey.New(ctx)
+	}
+
+	now := time.Now()
+	if item.Expired(now) {
+		return nil, errorx.ErrInvalidAPIKey.New(ctx)
+	}
+
+	if item.LastUsedAt == nil || now.Sub(*item.LastUsedAt) >= apiKeyTouchInterval || item.LastUsedIP != helper.GetClientIP(ctx) {
+		item.LastUsedAt = &now
+		item.LastUsedIP = helper.GetClientIP(ctx)
+		if err := a.APIKeyRepo.Update(ctx, item, gormx.WithSelect("LastUsedAt", "LastUsedIP")); err != nil {
+			logger.Error(ctx, "Failed to update last used time of api key", err)
+		}
+	}
+
+	return item, nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
+	"gin-admin/pkg/logger"
+	"gin-admin/pkg/randx"
+
+	"gorm.io/gorm"
+)
+
+// Audit trail of the changes made to the managed entities
+type Audit struct {
+	AuditRepo    *repositories.Audit
+	DataScopeSvc *DataScope
+}
+
+func NewAudit(app types.AppContext) *Audit {
+	return &Audit{
+		AuditRepo:    repositories.NewAudit(app.DB()),
+		DataScopeSvc: NewDataScope(app),
+	}
+}
+
+// Record the change of the entity made by the user of the context, the update without any change is not recorded.
+func (a *Audit) Record(ctx context.Context, action, entityType, entityID string, changes models.AuditChanges) error {
+	if action == models.AuditAction_Update && len(changes) == 0 {
+		return nil
+	}
+
+	audit := &models.Audit{
+		ID:         randx.NewXID(),
+		UserID:     helper.GetUserID(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Changes:    changes,
+		TraceID:    helper.GetTraceID(ctx),
+		ClientIP:   helper.GetClientIP(ctx),
+		CreatedAt:  time.Now(),
+	}
+	if err := a.AuditRepo.Create(ctx, audit); err != nil {
+		return err
+	}
+
+	logger.Info(logger.WithTag(ctx, logger.Tag_Operate), fmt.Sprintf("%s %s", action, entityType), map[string]any{
+		"entityId": entityID,
+		"changes":  changes,
+	})
+	return nil
+}
+
+// List audits from the data access object based on the provided parameters and options.
+func (a *Audit) List(ctx context.Context, req dtos.AuditListReq) (*dtos.List[*models.Audit], error) {
+	option := func(d *gorm.DB) *gorm.DB {
+		db := d.Table(fmt.Sprintf("%s AS a", new(models.Audit).TableName()))
+		db = db.Joins(fmt.Sprintf("left join %s b on a.user_id=b.id", new(models.User).TableName()))
+		db = db.Select("a.*, b.nick_name as nick_name, b.username as username") // 对应 models.Audit 的 NickName 和 UserName
+
+		if v := req.Action; v != "" {
+			db = db.Where("a.action = ?", v)
+		}
+		if v := req.EntityType; v != "" {
+			db = db.Where("a.entity_type = ?", v)
+		}
+		if v := req.EntityID; v != "" {
+			db = db.Where("a.entity_id = ?", v)
+		}
+		if v := req.UserID; v != "" {
+			db = db.Where("a.user_id = ?", v)
+		}
+		if v := req.UserName; v != "" {
+			db = db.Where("b.username LIKE ?", "%"+v+"%")
+		}
+		if v := req.TraceID; v != "" {
+			db = db.Where("a.trace_id = ?", v)
+		}
+		if start := req.StartTime; start != "" {
+			if end := req.EndTime; end != "" {
+				db = db.Where("a.created_at BETWEEN ? AND ?", start, end)
+			} else {
+				db = db.Where("a.created_at >= ?", start)
+			}
+		} else if end := req.EndTime; end != "" {
+			db = db.Where("a.created_at <= ?", end)
+		}
+
+		return db
+	}
+
+	// audits belong to the department of the user who made the changes
+	scope, err := a.DataScopeSvc.Option(ctx, DataScopeColumns{Dept: "b.dept_id", Owners: []string{"a.user_id"}})
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := a.AuditRepo.Find(ctx, option, scope, gormx.WithOrder("a.created_at", "desc"), gormx.WithPage(req.Page, req.Limit))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	count, err := a.AuditRepo.Count(ctx, option, scope)
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	return dtos.NewList(list, req.Page, req.Limit, count), nil
+}
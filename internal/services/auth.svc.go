@@ -14,6 +14,7 @@ import (
 	"gin-admin/internal/types"
 	"gin-admin/pkg/cachex"
 	"gin-admin/pkg/crypto/hash"
+	"gin-admin/pkg/geo"
 	"gin-admin/pkg/gormx"
 	"gin-admin/pkg/helper"
 	"gin-admin/pkg/jwtx"
@@ -33,6 +34,12 @@ type Auth struct {
 	MenuRepo     *repositories.Menu
 	UserSvc      *User
 	MenuSvc      *Menu
+	CaptchaSvc   *Captcha
+	GuardSvc     *LoginGuard
+	MFASvc       *MFA
+	APIKeySvc    *APIKey
+	PasswordSvc  *Password
+	TenantSvc    *Tenant
 }
 
 func NewAuth(app types.AppContext) *Auth {
@@ -44,6 +51,12 @@ func NewAuth(app types.AppContext) *Auth {
 		MenuRepo:     repositories.NewMenu(app.DB()),
 		UserSvc:      NewUser(app),
 		MenuSvc:      NewMenu(app),
+		CaptchaSvc:   NewCaptcha(app),
+		GuardSvc:     NewLoginGuard(app),
+		MFASvc:       NewMFA(app),
+		APIKeySvc:    NewAPIKey(app),
+		PasswordSvc:  NewPassword(app),
+		TenantSvc:    NewTenant(app),
 	}
 }
 
@@ -55,6 +68,10 @@ func (a *Auth) ParseUserID(c *gin.Context) (string, error) {
 		return rootID, nil
 	}
 
+	if key := helper.GetAPIKey(c); key != "" {
+		return a.parseAPIKey(c, key)
+	}
+
 	token := helper.GetToken(c)
 	if token == "" {
 		return "", errorx.ErrInvalidToken.New(ctx)
@@ -70,59 +87,156 @@ func (a *Auth) ParseUserID(c *gin.Context) (string, error) {
 		return "", errorx.ErrInternal.New(ctx).Wrap(err)
 	}
 
+	ctx = helper.WithSessionID(ctx, jwtx.GetClaimID(claims))
+	if jwtx.GetClaimMFA(claims) {
+		ctx = helper.WithMFAVerified(ctx)
+	}
+	if ctx, err = a.withTenant(ctx, jwtx.GetClaimTenant(claims)); err != nil {
+		return "", err
+	}
+	c.Request = c.Request.WithContext(ctx)
+
 	userID, _ := claims.GetSubject()
 
-	if userID == rootID {
+	// tokens issued before the user was frozen, deleted or its password and roles changed are rejected
+	if userID != rootID {
+		generation, err := a.UserSvc.GetTokenGeneration(ctx, userID)
+		if err != nil {
+			if errors.Is(err, errorx.ErrRecordNotFound.New(ctx)) {
+				return "", errorx.ErrInvalidToken.New(ctx)
+			}
+			return "", err
+		}
+		if generation != jwtx.GetClaimGeneration(claims) {
+			return "", errorx.ErrInvalidToken.New(ctx)
+		}
+	}
+
+	if err := a.checkUser(c, userID); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// Authenticate the request with the API key, the roles of the request are limited to the scope of the key.
+func (a *Auth) parseAPIKey(c *gin.Context, key string) (string, error) {
+	ctx := helper.WithClientIP(c.Request.Context(), c.ClientIP())
+
+	// the key is looked up out of any tenant, the request is scoped to the tenant of its owner
+	item, err := a.APIKeySvc.Authenticate(helper.WithoutTenant(ctx), key)
+	if err != nil {
+		return "", err
+	}
+	if ctx, err = a.withTenant(ctx, item.TenantID); err != nil {
+		return "", err
+	}
+
+	ctx = helper.WithAPIKeyID(ctx, item.ID)
+	if len(item.RoleIDs) > 0 {
+		ctx = helper.WithAPIKeyRoleIDs(ctx, item.RoleIDs)
+	}
+	c.Request = c.Request.WithContext(ctx)
+
+	if err := a.checkUser(c, item.UserID); err != nil {
+		return "", err
+	}
+	return item.UserID, nil
+}
+
+// Scope the context to the tenant of the token or API key, which must match the tenant resolved from the request.
+// The request out of any tenant is scoped to the tenant of the subject.
+func (a *Auth) withTenant(ctx context.Context, tenantID string) (context.Context, error) {
+	resolved, ok := helper.GetTenantID(ctx)
+	if !ok || resolved == tenantID {
+		return ctx, nil
+	}
+	if resolved != "" {
+		return ctx, errorx.ErrInvalidToken.New(ctx)
+	}
+
+	if _, err := a.TenantSvc.GetEnabled(ctx, tenantID); err != nil {
+		return ctx, err
+	}
+	ctx = helper.WithTenantID(ctx, tenantID)
+	return logger.WithTenantID(ctx, tenantID), nil
+}
+
+// Check the status of the authenticated user and load its roles into cache.
+func (a *Auth) checkUser(c *gin.Context, userID string) error {
+	ctx := c.Request.Context()
+
+	// the request with a role-scoped API key is limited to the roles of the key, even for the root user
+	_, scoped := helper.GetAPIKeyRoleIDs(ctx)
+
+	if userID == configs.C.Super.ID {
+		if scoped {
+			return errorx.ErrInvalidAPIKey.New(ctx)
+		}
 		c.Request = c.Request.WithContext(helper.WithIsRootUser(ctx))
-		return userID, nil
+		return nil
+	}
+
+	// the super admin of the tenant is granted everything in the tenant
+	if isAdmin, err := a.TenantSvc.IsAdmin(ctx, userID); err != nil {
+		return err
+	} else if isAdmin && !scoped {
+		ctx = helper.WithIsRootUser(ctx)
+		c.Request = c.Request.WithContext(ctx)
 	}
 
-	_, err = a.UserSvc.GetRoleIDsCache(ctx, userID)
+	_, err := a.UserSvc.GetRoleIDsCache(ctx, userID)
 	if err != nil {
-		if errors.Is(err, cachex.ErrNotFound) {
+		if errors.Is(err, errorx.ErrRecordNotFound.New(ctx)) {
 
 			// Check user status, if not activated, force to logout
 			user, err := a.UserRepo.Get(ctx, userID, gormx.WithSelect("status"))
 			if err != nil {
 				if errors.Is(err, gorm.ErrRecordNotFound) {
-					return "", errorx.ErrInvalidToken.New(ctx)
+					return errorx.ErrInvalidToken.New(ctx)
 				}
-				return "", errorx.WrapGormError(ctx, err)
+				return errorx.WrapGormError(ctx, err)
 			}
 
 			if user == nil || user.Status != models.UserStatus_Activated {
-				return "", errorx.ErrInvalidToken.New(ctx)
+				return errorx.ErrInvalidToken.New(ctx)
 			}
 
 			roleIDs, err := a.UserSvc.GetRoleIDs(ctx, userID)
 			if err != nil {
-				return "", err
+				return err
 			}
 
-			err = a.UserSvc.SetRoleIDsCache(ctx, userID, roleIDs)
-			if err != nil {
-				return "", err
-			}
-			return userID, nil
+			return a.UserSvc.SetRoleIDsCache(ctx, userID, roleIDs)
 		}
-		return "", err
+		return err
 	}
 
-	return userID, nil
+	return nil
 }
 
-func (a *Auth) Login(ctx context.Context, req *dtos.Login) (*dtos.LoginToken, error) {
+func (a *Auth) Login(ctx context.Context, req *dtos.Login) (*dtos.LoginResult, error) {
+	ctx = logger.WithTag(ctx, logger.Tag_Login)
+
 	// verify captcha
-	// if !captcha.VerifyString(req.CaptchaID, req.CaptchaCode) {
-	// 	return nil, errors.BadRequest("Incorrect captcha")
-	// }
+	if configs.C.Captcha.Enable {
+		if err := a.CaptchaSvc.Verify(ctx, req.CaptchaID, req.CaptchaCode); err != nil {
+			return nil, err
+		}
+	}
 
-	ctx = logger.WithTag(ctx, logger.Tag_Login)
+	// check brute-force lockout
+	clientIP := helper.GetClientIP(ctx)
+	if err := a.GuardSvc.Check(ctx, req.Username, clientIP); err != nil {
+		return nil, err
+	}
 
 	// get user info
-	user, err := a.UserRepo.GetByUsername(ctx, req.Username, gormx.WithSelect("id", "password", "status"))
+	user, err := a.UserRepo.GetByUsername(ctx, req.Username, gormx.WithSelect("id", "username", "password", "status", "type", "mfa_enabled", "must_change_password", "password_changed_at", "created_at"))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := a.GuardSvc.Fail(ctx, req.Username, clientIP); err != nil {
+				logger.Error(ctx, "Failed to record login failure", err)
+			}
 			return nil, errorx.ErrUsernamePassword.New(ctx)
 		}
 		return nil, errorx.WrapGormError(ctx, err)
@@ -134,12 +248,115 @@ func (a *Auth) Login(ctx context.Context, req *dtos.Login) (*dtos.LoginToken, er
 
 	// check password
 	if err := hash.CompareHashAndPassword(user.Password, req.Password); err != nil {
+		if err := a.GuardSvc.Fail(ctx, req.Username, clientIP); err != nil {
+			logger.Error(ctx, "Failed to record login failure", err)
+		}
 		return nil, errorx.ErrUsernamePassword.New(ctx)
 	}
 
-	userID := user.ID
+	if user.Type == models.UserType_Service {
+		return nil, errorx.ErrServiceAccountLogin.New(ctx)
+	}
+
+	// the password must be changed before completing the login
+	if reason := a.PasswordSvc.ChangeReason(user); reason != "" {
+		challenge, err := a.PasswordSvc.Challenge(ctx, user.ID, reason)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Info(logger.WithUserID(ctx, user.ID), "Login requires password change", map[string]any{
+			"username": user.Username,
+			"reason":   reason,
+		})
+		return &dtos.LoginResult{PasswordChange: challenge}, nil
+	}
+
+	return a.completeLogin(ctx, user)
+}
+
+// Change the password with the challenge returned by login, then complete the login.
+func (a *Auth) ChangePassword(ctx context.Context, req *dtos.PasswordChangeReq) (*dtos.LoginResult, error) {
+	ctx = logger.WithTag(ctx, logger.Tag_Login)
+
+	user, err := a.PasswordSvc.Change(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Status != models.UserStatus_Activated {
+		return nil, errorx.ErrUserDisabled.New(ctx, struct{ Name string }{user.Username})
+	}
+
+	return a.completeLogin(ctx, user)
+}
+
+// Complete the login of the authenticated user, a two-factor authentication challenge is returned instead of tokens if required.
+func (a *Auth) completeLogin(ctx context.Context, user *models.User) (*dtos.LoginResult, error) {
+	ctx = logger.WithUserID(ctx, user.ID)
+
+	// two-factor authentication
+	mfaRequired := user.MFAEnabled
+	if !mfaRequired {
+		var err error
+		mfaRequired, err = a.MFASvc.Required(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if mfaRequired {
+		challenge, err := a.MFASvc.Challenge(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Info(ctx, "Login requires two-factor authentication", map[string]any{
+			"username": user.Username,
+		})
+		return &dtos.LoginResult{MFA: challenge}, nil
+	}
+
+	// the failures are cleared only once the login is completed, the failed codes of the challenge are counted with them
+	if err := a.GuardSvc.Succeed(ctx, user.Username, helper.GetClientIP(ctx)); err != nil {
+		logger.Error(ctx, "Failed to clear login failures", err)
+	}
+
+	loginToken, err := a.issueToken(ctx, user.ID, user.Username, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.LoginResult{LoginToken: loginToken}, nil
+}
+
+// Complete the login with the code of two-factor authentication challenge.
+func (a *Auth) VerifyMFA(ctx context.Context, req *dtos.MFAVerifyReq) (*dtos.LoginToken, error) {
+	ctx = logger.WithTag(ctx, logger.Tag_Login)
+
+	userID, err := a.MFASvc.VerifyChallenge(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.UserRepo.Get(ctx, userID, gormx.WithSelect("username", "status"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorx.ErrUser.New(ctx)
+		}
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	if user.Status != models.UserStatus_Activated {
+		return nil, errorx.ErrUserDisabled.New(ctx, struct{ Name string }{user.Username})
+	}
+
 	ctx = logger.WithUserID(ctx, userID)
 
+	return a.issueToken(ctx, userID, user.Username, true)
+}
+
+func (a *Auth) issueToken(ctx context.Context, userID, username string, mfa bool) (*dtos.LoginToken, error) {
 	// set user cache with role ids
 	roleIDs, err := a.UserSvc.GetRoleIDs(ctx, userID)
 	if err != nil {
@@ -151,8 +368,20 @@ func (a *Auth) Login(ctx context.Context, req *dtos.Login) (*dtos.LoginToken, er
 		logger.Error(ctx, "Failed to set cache", err)
 	}
 
-	// generate token
-	token, err := a.Jwt.GenerateToken(ctx, userID)
+	generation, err := a.UserSvc.GetTokenGeneration(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// generate token, the tenant of the login is kept in the token
+	tenantID, _ := helper.GetTenantID(ctx)
+	clientIP := helper.GetClientIP(ctx)
+	token, err := a.Jwt.GenerateToken(ctx, userID,
+		jwtx.WithClient(clientIP, helper.GetUserAgent(ctx), geo.GetCityName(clientIP, "zh-CN")),
+		jwtx.WithGeneration(generation),
+		jwtx.WithMFA(mfa),
+		jwtx.WithTenant(tenantID),
+	)
 	if err != nil {
 		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
 	}
@@ -167,7 +396,7 @@ func (a *Auth) Login(ctx context.Context, req *dtos.Login) (*dtos.LoginToken, er
 	logger.Info(ctx, "Login success",
 
 		map[string]any{
-			"username":     req.Username,
+			"username":     username,
 			"accessToken":  loginToken.AccessToken,
 			"refreshToken": loginToken.RefreshToken,
 			"tokenType":    loginToken.TokenType,
@@ -182,32 +411,49 @@ func (a *Auth) RefreshToken(ctx context.Context, refreshToken string) (*dtos.Log
 
 	ctx = logger.WithTag(ctx, logger.Tag_Login)
 
-	claims, err := a.Jwt.ParseRefreshToken(ctx, refreshToken)
+	// rotate the refresh token, it can only be used once
+	clientIP := helper.GetClientIP(ctx)
+	token, claims, err := a.Jwt.RefreshToken(ctx, refreshToken, jwtx.WithClient(clientIP, helper.GetUserAgent(ctx), geo.GetCityName(clientIP, "zh-CN")))
 	if err != nil {
+		if err == jwtx.ErrTokenReused {
+			userID, _ := claims.GetSubject()
+			logger.Warn(logger.WithUserID(ctx, userID), "Refresh token reused, token family revoked", map[string]any{
+				"sessionId": jwtx.GetClaimID(claims),
+				"clientIP":  clientIP,
+				"userAgent": helper.GetUserAgent(ctx),
+			})
+			return nil, errorx.ErrInvalidToken.New(ctx).Wrap(err)
+		}
 		if err == jwtx.ErrInvalidToken {
 			return nil, errorx.ErrInvalidToken.New(ctx).Wrap(err)
 		}
-		return nil, err
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
 	}
 
 	userID, _ := claims.GetSubject()
-
-	user, err := a.UserRepo.Get(ctx, userID, gormx.WithSelect("status", "username"))
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errorx.ErrUser.New(ctx)
-		}
-		return nil, errorx.WrapGormError(ctx, err)
-	}
-
-	if user.Status != models.UserStatus_Activated {
-		return nil, errorx.ErrUserDisabled.New(ctx, struct{ Name string }{user.NickName})
-	}
-
 	ctx = logger.WithUserID(ctx, userID)
 
-	token, err := a.Jwt.GenerateToken(ctx, userID)
+	var user *models.User
+	ctx, err = a.withTenant(ctx, jwtx.GetClaimTenant(claims))
+	if err == nil {
+		user, err = a.UserRepo.Get(ctx, userID, gormx.WithSelect("status", "username", "nick_name", "password", "must_change_password", "password_changed_at", "created_at", "token_generation"))
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = errorx.ErrUser.New(ctx)
+	} else if err != nil {
+		err = errorx.WrapGormError(ctx, err)
+	} else if user.Status != models.UserStatus_Activated {
+		err = errorx.ErrUserDisabled.New(ctx, struct{ Name string }{user.NickName})
+	} else if a.PasswordSvc.ChangeReason(user) != "" {
+		err = errorx.ErrPasswordExpired.New(ctx)
+	} else if user.TokenGeneration != jwtx.GetClaimGeneration(claims) {
+		err = errorx.ErrInvalidToken.New(ctx)
+	}
 	if err != nil {
+		// drop the session just issued
+		if claims, perr := a.Jwt.ParseToken(ctx, token.GetAccessToken()); perr == nil {
+			_ = a.Jwt.RevokeSession(ctx, jwtx.GetClaimID(claims))
+		}
 		return nil, err
 	}
 
@@ -231,6 +477,11 @@ func (a *Auth) RefreshToken(ctx context.Context, refreshToken string) (*dtos.Log
 	return loginToken, nil
 }
 
+// Public keys to verify the access tokens, HMAC keys are never exposed.
+func (a *Auth) JWKS(ctx context.Context) *jwtx.JWKS {
+	return a.Jwt.JWKS()
+}
+
 func (a *Auth) Logout(ctx context.Context) error {
 	userToken := helper.GetUserToken(ctx)
 	if userToken == "" {
@@ -274,7 +525,7 @@ func (a *Auth) UpdatePassword(ctx context.Context, req *dtos.AuthUpdatePasswordR
 	}
 
 	userID := helper.GetUserID(ctx)
-	user, err := a.UserRepo.Get(ctx, userID, gormx.WithSelect("password"))
+	user, err := a.UserRepo.Get(ctx, userID, gormx.WithSelect("id", "username", "password", "password_history"))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errorx.ErrUserNotLogin.New(ctx)
@@ -288,11 +539,52 @@ func (a *Auth) UpdatePassword(ctx context.Context, req *dtos.AuthUpdatePasswordR
 	}
 
 	// update password
-	newPassword, err := hash.GeneratePassword(req.NewPassword)
+	fields, err := a.PasswordSvc.Set(ctx, user, req.NewPassword, false)
 	if err != nil {
-		return errorx.ErrInternal.New(ctx).Wrap(err)
+		return err
+	}
+	if err := a.UserRepo.Update(ctx, user, gormx.WithSelect(fields)); err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	// tokens issued with the old password are invalidated
+	return a.UserSvc.RevokeTokens(ctx, userID)
+}
+
+// Set the new password with the mailed reset code, the tokens issued before are invalidated.
+func (a *Auth) ResetPassword(ctx context.Context, req *dtos.PasswordResetReq) error {
+	user, fields, err := a.PasswordSvc.ResetWithCode(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	ctx = logger.WithUserID(ctx, user.ID)
+
+	var generation int64
+	err = a.UserRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.UserRepo.Update(ctx, user, gormx.WithSelect(fields)); err != nil {
+			return err
+		}
+		var err error
+		generation, err = a.UserSvc.startTokenGeneration(ctx, user.ID)
+		return err
+	})
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	if err := a.UserSvc.cacheTokenGeneration(ctx, user.ID, generation); err != nil {
+		return err
+	}
+
+	// the owner proved the access to the mailbox, the login lockout is lifted
+	if err := a.GuardSvc.Unlock(ctx, user.Username); err != nil {
+		logger.Error(ctx, "Failed to clear login lockout", err)
 	}
-	return a.UserRepo.UpdatePassword(ctx, userID, newPassword)
+
+	logger.Info(logger.WithTag(ctx, logger.Tag_Operate), "Password reset with mailed code")
+	return nil
 }
 
 // Query menus based on user permissions
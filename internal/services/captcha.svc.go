@@ -48,3 +48,11 @@ func (a *Captcha) ResponseCaptcha(ctx context.Context, w http.ResponseWriter, id
 	w.Header().Set("Content-Type", "image/png")
 	return nil
 }
+
+// Verify the captcha code, the captcha is consumed whether it matches or not.
+func (a *Captcha) Verify(ctx context.Context, id, code string) error {
+	if id == "" || code == "" || !captcha.VerifyString(id, code) {
+		return errorx.ErrCaptchaIncorrect.New(ctx)
+	}
+	return nil
+}
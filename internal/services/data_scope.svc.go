@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
+
+	"gorm.io/gorm"
+)
+
+// Columns of a table the data scope is applied on.
+type DataScopeColumns struct {
+	Dept   string   // Column of the department ID, rows without it are only limited by the owners
+	Owners []string // Columns of the user IDs owning the row, used by the self scope
+}
+
+// Row-level data scope granted by the roles of the current user
+type DataScope struct {
+	RoleRepo     *repositories.Role
+	UserRoleRepo *repositories.UserRole
+	UserDeptRepo *repositories.UserDepartment
+	DeptRepo     *repositories.Department
+}
+
+func NewDataScope(app types.AppContext) *DataScope {
+	return &DataScope{
+		RoleRepo:     repositories.NewRole(app.DB()),
+		UserRoleRepo: repositories.NewUserRole(app.DB()),
+		UserDeptRepo: repositories.NewUserDepartment(app.DB()),
+		DeptRepo:     repositories.NewDepartment(app.DB()),
+	}
+}
+
+// Build the query option limiting the rows to the data scope of the current user,
+// the scopes of multiple roles are merged. The root user and internal calls without user are not limited.
+func (a *DataScope) Option(ctx context.Context, columns DataScopeColumns) (gormx.Option, error) {
+	userID := helper.GetUserID(ctx)
+	if userID == "" || helper.GetIsRootUser(ctx) {
+		return noScope, nil
+	}
+
+	roles, err := a.roles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		self     bool
+		ownDept  bool
+		subDepts bool
+		deptIDs  []string
+	)
+	for _, role := range roles {
+		switch role.DataScope {
+		case models.RoleDataScope_All, "":
+			return noScope, nil
+		case models.RoleDataScope_Custom:
+			deptIDs = append(deptIDs, role.DataDeptIDs...)
+		case models.RoleDataScope_Dept:
+			ownDept = true
+		case models.RoleDataScope_DeptAndBelow:
+			ownDept, subDepts = true, true
+		default:
+			self = true
+		}
+	}
+
+	// users without roles can only see their own data
+	if len(roles) == 0 {
+		self = true
+	}
+
+	// all the departments the user belongs to
+	if ownDept {
+		members, err := a.UserDeptRepo.Find(ctx, gormx.WithSelect("dept_id"), gormx.WithWhere("user_id = ?", userID))
+		if err != nil {
+			return nil, errorx.WrapGormError(ctx, err)
+		}
+
+		ownDeptIDs := models.UserDepartments(members).ToDeptIDs()
+		if subDepts {
+			ownDeptIDs, err = a.DeptRepo.FindSubtreeIDs(ctx, ownDeptIDs...)
+			if err != nil {
+				return nil, errorx.WrapGormError(ctx, err)
+			}
+		}
+		deptIDs = append(deptIDs, ownDeptIDs...)
+	}
+
+	var (
+		conds []string
+		args  []any
+	)
+	if columns.Dept != "" && len(deptIDs) > 0 {
+		slices.Sort(deptIDs)
+		conds = append(conds, columns.Dept+" IN (?)")
+		args = append(args, slices.Compact(deptIDs))
+	}
+	if self || len(conds) == 0 {
+		for _, column := range columns.Owners {
+			conds = append(conds, column+" = ?")
+			args = append(args, userID)
+		}
+	}
+
+	// nothing can be matched
+	if len(conds) == 0 {
+		return gormx.WithWhere("1 = 0"), nil
+	}
+
+	return gormx.WithWhere("("+strings.Join(conds, " OR ")+")", args...), nil
+}
+
+// Enabled roles of the user, limited to the roles granted to the API key of the request.
+func (a *DataScope) roles(ctx context.Context, userID string) (models.Roles, error) {
+	userRoles, err := a.UserRoleRepo.Find(ctx, gormx.WithSelect("role_id"), gormx.WithWhere("user_id = ?", userID))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	roleIDs := models.UserRoles(userRoles).ToRoleIDs()
+	if scoped, ok := helper.GetAPIKeyRoleIDs(ctx); ok {
+		roleIDs = slices.DeleteFunc(roleIDs, func(id string) bool {
+			return !slices.Contains(scoped, id)
+		})
+	}
+	if len(roleIDs) == 0 {
+		return nil, nil
+	}
+
+	roles, err := a.RoleRepo.Find(ctx,
+		gormx.WithSelect("id", "data_scope", "data_dept_ids"),
+		gormx.WithWhere("id IN (?) AND status = ?", roleIDs, models.RoleStatus_Enabled),
+	)
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	return roles, nil
+}
+
+func noScope(db *gorm.DB) *gorm.DB {
+	return db
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
+	"gin-admin/pkg/randx"
+
+	"github.com/epkgs/object"
+	"gorm.io/gorm"
+)
+
+// Fields of the department recorded by the audit trail
+var deptAuditFields = []string{"Code", "Name", "Description", "Status", "ParentID", "Rank"}
+
+// Department tree of the organization
+type Department struct {
+	DeptRepo     *repositories.Department
+	UserDeptRepo *repositories.UserDepartment
+	UserRepo     *repositories.User
+	AuditSvc     *Audit
+}
+
+func NewDepartment(app types.AppContext) *Department {
+	return &Department{
+		DeptRepo:     repositories.NewDepartment(app.DB()),
+		UserDeptRepo: repositories.NewUserDepartment(app.DB()),
+		UserRepo:     repositories.NewUser(app.DB()),
+		AuditSvc:     NewAudit(app),
+	}
+}
+
+// List departments from the data access object based on the provided parameters and options.
+func (a *Department) List(ctx context.Context, req dtos.DepartmentListReq) (*dtos.List[*models.Department], error) {
+	option := func(db *gorm.DB) *gorm.DB {
+		if v := req.LikeName; len(v) > 0 {
+			db = db.Where("name LIKE ?", "%"+v+"%")
+		}
+		if v := req.Status; len(v) > 0 {
+			db = db.Where("status = ?", v)
+		}
+		if v := req.ParentID; len(v) > 0 {
+			db = db.Where("parent_id = ?", v)
+		}
+		return db
+	}
+
+	list, err := a.DeptRepo.Find(ctx, option, gormx.WithPage(req.Page, req.Limit), gormx.WithOrder("rank", "desc"), gormx.WithOrder("created_at", "desc"))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	count, err := a.DeptRepo.Count(ctx, option)
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	return dtos.NewList(list, req.Page, req.Limit, count), nil
+}
+
+// Get the specified department with its direct sub departments and leaders.
+func (a *Department) Get(ctx context.Context, id string) (*models.Department, error) {
+	dept, err := a.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := a.DeptRepo.Find(ctx, gormx.WithWhere("parent_id = ?", id), gormx.WithOrder("rank", "desc"), gormx.WithOrder("created_at", "desc"))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+	var depts models.Departments = children
+	dept.Children = &depts
+
+	leaderQuery := a.UserDeptRepo.DB().Model(new(models.UserDepartment)).Where("dept_id = ? AND leader = ?", id, true).Select("user_id")
+	leaders, err := a.UserRepo.Find(ctx, gormx.WithWhere("id IN (?)", leaderQuery))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+	dept.Leaders = leaders
+
+	return dept, nil
+}
+
+// Create a new department in the data access object.
+func (a *Department) Create(ctx context.Context, req *dtos.DepartmentCreateReq) (*models.Department, error) {
+	dept := &models.Department{
+		ID:        randx.NewXID(),
+		CreatedAt: time.Now(),
+	}
+
+	if parentID := req.ParentID; parentID != "" {
+		parent, err := a.get(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		dept.ParentPath = parent.Path()
+	}
+
+	if err := object.Assign(dept, req); err != nil {
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	err := a.DeptRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.DeptRepo.Create(ctx, dept); err != nil {
+			return err
+		}
+		if err := a.setLeaders(ctx, dept.ID, req.LeaderIDs); err != nil {
+			return err
+		}
+
+		changes := models.DiffFields(nil, dept, deptAuditFields...)
+		if len(req.LeaderIDs) > 0 {
+			changes = append(changes, &models.AuditChange{Field: "leaderIds", New: req.LeaderIDs})
+		}
+		return a.AuditSvc.Record(ctx, models.AuditAction_Create, models.AuditEntity_Department, dept.ID, changes)
+	})
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	return dept, nil
+}
+
+// Update the specified department in the data access object, disabling a department disables its sub departments as well.
+func (a *Department) Update(ctx context.Context, id string, req *dtos.DepartmentUpdateReq) error {
+	dept, err := a.get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	before := *dept
+
+	var md object.Metadata
+	if err := object.Assign(dept, req, func(c *object.AssignConfig) {
+		c.Metadata = &md
+	}); err != nil {
+		return errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	dept.UpdatedAt = time.Now()
+
+	err = a.DeptRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.DeptRepo.Update(ctx, dept, gormx.WithSelect(append(md.Keys, "UpdatedAt"))); err != nil {
+			return err
+		}
+
+		if dept.Status == models.DepartmentStatus_Disabled && before.Status != dept.Status {
+			err := a.DeptRepo.Update(ctx, &models.Department{Status: dept.Status}, gormx.WithWhere("parent_path LIKE ?", dept.Path()+"%"))
+			if err != nil {
+				return err
+			}
+		}
+
+		changes := models.DiffFields(&before, dept, md.Keys...)
+		if req.LeaderIDs != nil {
+			if err := a.setLeaders(ctx, id, *req.LeaderIDs); err != nil {
+				return err
+			}
+			changes = append(changes, &models.AuditChange{Field: "leaderIds", New: *req.LeaderIDs})
+		}
+		return a.AuditSvc.Record(ctx, models.AuditAction_Update, models.AuditEntity_Department, id, changes)
+	})
+
+	return errorx.WrapGormError(ctx, err)
+}
+
+// Move the department under another parent with all its sub departments, or reorder it among the siblings.
+func (a *Department) Move(ctx context.Context, id string, req *dtos.DepartmentMoveReq) error {
+	dept, err := a.get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	before := *dept
+	oldPath := dept.Path()
+	if req.ParentID != dept.ParentID {
+		if parentID := req.ParentID; parentID != "" {
+			parent, err := a.get(ctx, parentID)
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(parent.Path(), oldPath) {
+				return errorx.ErrDepartmentMoveInvalid.New(ctx)
+			}
+			dept.ParentPath = parent.Path()
+		} else {
+			dept.ParentPath = ""
+		}
+		dept.ParentID = req.ParentID
+	}
+	if req.Rank != nil {
+		dept.Rank = *req.Rank
+	}
+	dept.UpdatedAt = time.Now()
+
+	var descendants []*models.Department
+	if newPath := dept.Path(); newPath != oldPath {
+		descendants, err = a.DeptRepo.FindDescendants(ctx, oldPath, gormx.WithSelect("id", "parent_path"))
+		if err != nil {
+			return errorx.WrapGormError(ctx, err)
+		}
+	}
+
+	err = a.DeptRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.DeptRepo.Update(ctx, dept, gormx.WithSelect("ParentID", "ParentPath", "Rank", "UpdatedAt")); err != nil {
+			return err
+		}
+
+		for _, child := range descendants {
+			err := a.DeptRepo.UpdateParentPath(ctx, child.ID, strings.Replace(child.ParentPath, oldPath, dept.Path(), 1))
+			if err != nil {
+				return err
+			}
+		}
+
+		changes := models.DiffFields(&before, dept, "ParentID", "Rank")
+		return a.AuditSvc.Record(ctx, models.AuditAction_Update, models.AuditEntity_Department, id, changes)
+	})
+
+	return errorx.WrapGormError(ctx, err)
+}
+
+// Delete the specified department, only empty departments without sub departments can be deleted.
+func (a *Department) Delete(ctx context.Context, id string) error {
+	dept, err := a.get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if exists, err := a.DeptRepo.Exists(ctx, gormx.WithWhere("parent_id = ?", id)); err != nil {
+		return errorx.WrapGormError(ctx, err)
+	} else if exists {
+		return errorx.ErrDepartmentHasChildren.New(ctx)
+	}
+
+	if exists, err := a.UserDeptRepo.Exists(ctx, gormx.WithWhere("dept_id = ?", id)); err != nil {
+		return errorx.WrapGormError(ctx, err)
+	} else if exists {
+		return errorx.ErrDepartmentHasMembers.New(ctx)
+	}
+
+	err = a.DeptRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.DeptRepo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return a.AuditSvc.Record(ctx, models.AuditAction_Delete, models.AuditEntity_Department, id, models.DiffFields(dept, nil, deptAuditFields...))
+	})
+
+	return errorx.WrapGormError(ctx, err)
+}
+
+// Get the IDs of the departments and all their sub departments.
+func (a *Department) SubtreeIDs(ctx context.Context, ids ...string) ([]string, error) {
+	result, err := a.DeptRepo.FindSubtreeIDs(ctx, ids...)
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+	return result, nil
+}
+
+// Check all the departments exist.
+func (a *Department) CheckExists(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	count, err := a.DeptRepo.Count(ctx, gormx.WithWhere("id IN (?)", ids))
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	if int(count) != len(slices.Compact(ids)) {
+		return errorx.ErrDepartmentNotFound.New(ctx)
+	}
+	return nil
+}
+
+func (a *Department) get(ctx context.Context, id string) (*models.Department, error) {
+	dept, err := a.DeptRepo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorx.ErrDepartmentNotFound.New(ctx)
+		}
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+	return dept, nil
+}
+
+// Replace the leaders of the department, the users not in the department are added as secondary members.
+func (a *Department) setLeaders(ctx context.Context, deptID string, userIDs []string) error {
+	if err := a.UserDeptRepo.UpdateLeader(ctx, deptID, false); err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if exists, err := a.UserDeptRepo.Exists(ctx, gormx.WithWhere("dept_id = ? AND user_id = ?", deptID, userID)); err != nil {
+			return err
+		} else if exists {
+			if err := a.UserDeptRepo.UpdateLeader(ctx, deptID, true, gormx.WithWhere("user_id = ?", userID)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if exists, err := a.UserRepo.Exists(ctx, gormx.WithWhere("id = ?", userID)); err != nil {
+			return err
+		} else if !exists {
+			return errorx.ErrUserNotFound.New(ctx)
+		}
+
+		err := a.UserDeptRepo.Create(ctx, &models.UserDepartment{
+			ID:        randx.NewXID(),
+			UserID:    userID,
+			DeptID:    deptID,
+			Leader:    true,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
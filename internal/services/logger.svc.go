@@ -1,33 +1,169 @@
 package services
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
+	"gin-admin/internal/configs"
 	"gin-admin/internal/dtos"
+	"gin-admin/internal/errorx"
 	"gin-admin/internal/models"
 	"gin-admin/internal/repositories"
 	"gin-admin/internal/types"
 	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Logger management
 type Logger struct {
-	LoggerRepo *repositories.Logger
+	LoggerRepo   *repositories.Logger
+	RoleRepo     *repositories.Role
+	UserRoleRepo *repositories.UserRole
+	DataScopeSvc *DataScope
 }
 
 func NewLogger(app types.AppContext) *Logger {
 	return &Logger{
-		LoggerRepo: repositories.NewLogger(app.DB()),
+		LoggerRepo:   repositories.NewLogger(app.DB()),
+		RoleRepo:     repositories.NewRole(app.DB()),
+		UserRoleRepo: repositories.NewUserRole(app.DB()),
+		DataScopeSvc: NewDataScope(app),
 	}
 }
 
 // List loggers from the data access object based on the provided parameters and options.
 func (a *Logger) List(ctx context.Context, req dtos.LoggerListReq) (*dtos.List[*models.Logger], error) {
-	option := func(d *gorm.DB) *gorm.DB {
+	option := a.filter(req)
+
+	// logs belong to the department of the user who made them
+	scope, err := a.DataScopeSvc.Option(ctx, DataScopeColumns{Dept: "b.dept_id", Owners: []string{"a.user_id"}})
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := a.LoggerRepo.Find(ctx, option, scope, gormx.WithPage(req.Page, req.Limit))
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := a.LoggerRepo.Count(ctx, option, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return dtos.NewList(list, req.Page, req.Limit, count), nil
+}
+
+// Export the loggers matched by the filters of the list in the order of creation, each of them is passed to the function
+// as it is read from the database. The export is refused before anything is passed if more logs than the cap are matched.
+func (a *Logger) Export(ctx context.Context, req dtos.LoggerListReq, fn func(item *models.Logger) error) error {
+	option := a.filter(req)
+
+	scope, err := a.DataScopeSvc.Option(ctx, DataScopeColumns{Dept: "b.dept_id", Owners: []string{"a.user_id"}})
+	if err != nil {
+		return err
+	}
+
+	maxRows, err := a.exportMaxRows(ctx)
+	if err != nil {
+		return err
+	}
+
+	count, err := a.LoggerRepo.Count(ctx, option, scope)
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	} else if count > int64(maxRows) {
+		return errorx.ErrLogExportTooLarge.New(ctx, struct{ Max int }{maxRows})
+	}
+
+	// the logs are read in batches after the last read one, the logs made after the count are still capped
+	batchSize := max(configs.C.LogExport.BatchSize, 1)
+	var last *models.Logger
+	for exported := 0; exported < maxRows; {
+		opts := []gormx.Option{option, scope,
+			gormx.WithOrder("a.created_at", "asc"),
+			gormx.WithOrder("a.id", "asc"),
+			func(db *gorm.DB) *gorm.DB { return db.Limit(min(batchSize, maxRows-exported)) },
+		}
+		if last != nil {
+			opts = append(opts, gormx.WithWhere("(a.created_at > ? OR (a.created_at = ? AND a.id > ?))", last.CreatedAt, last.CreatedAt, last.ID))
+		}
+
+		items, err := a.LoggerRepo.Find(ctx, opts...)
+		if err != nil {
+			return errorx.WrapGormError(ctx, err)
+		}
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+
+		if len(items) < batchSize {
+			break
+		}
+		last, exported = items[len(items)-1], exported+len(items)
+	}
+	return nil
+}
+
+// Get the cap of the logs exported by the current user, the largest cap of the roles of the user applies
+// and the root user has the largest of all the caps.
+func (a *Logger) exportMaxRows(ctx context.Context) (int, error) {
+	cfg := configs.C.LogExport
+	maxRows := cfg.MaxRows
+
+	if helper.GetIsRootUser(ctx) {
+		for _, v := range cfg.RoleMaxRows {
+			maxRows = max(maxRows, v)
+		}
+		return maxRows, nil
+	}
+
+	userRoles, err := a.UserRoleRepo.Find(ctx, gormx.WithSelect("role_id"), gormx.WithWhere("user_id = ?", helper.GetUserID(ctx)))
+	if err != nil {
+		return 0, errorx.WrapGormError(ctx, err)
+	}
+
+	roleIDs := models.UserRoles(userRoles).ToRoleIDs()
+	if scoped, ok := helper.GetAPIKeyRoleIDs(ctx); ok {
+		roleIDs = slices.DeleteFunc(roleIDs, func(id string) bool {
+			return !slices.Contains(scoped, id)
+		})
+	}
+	if len(roleIDs) == 0 || len(cfg.RoleMaxRows) == 0 {
+		return maxRows, nil
+	}
+
+	roles, err := a.RoleRepo.Find(ctx,
+		gormx.WithSelect("id", "code"),
+		gormx.WithWhere("id IN (?) AND status = ?", roleIDs, models.RoleStatus_Enabled),
+	)
+	if err != nil {
+		return 0, errorx.WrapGormError(ctx, err)
+	}
+
+	found := false
+	for _, role := range roles {
+		if v, ok := cfg.RoleMaxRows[role.Code]; ok {
+			if !found || v > maxRows {
+				maxRows, found = v, true
+			}
+		}
+	}
+	return maxRows, nil
+}
+
+// Build the query option of the loggers matched by the filters.
+func (a *Logger) filter(req dtos.LoggerListReq) gormx.Option {
+	return func(d *gorm.DB) *gorm.DB {
 
 		db := d.Table(fmt.Sprintf("%s AS a", new(models.Logger).TableName()))
 		db = db.Joins(fmt.Sprintf("left join %s b on a.user_id=b.id", new(models.User).TableName()))
@@ -61,16 +197,239 @@ func (a *Logger) List(ctx context.Context, req dtos.LoggerListReq) (*dtos.List[*
 
 		return db
 	}
+}
 
-	list, err := a.LoggerRepo.Find(ctx, option, gormx.WithPage(req.Page, req.Limit))
+// Get the statistics of the loggers matched by the filters in the time range, the logs are counted by the database
+// and only the aggregates are read, the range is limited to keep the scanned logs bounded.
+func (a *Logger) Stats(ctx context.Context, req dtos.LoggerStatsReq) (*dtos.LoggerStats, error) {
+	end, start := time.Now(), time.Time{}
+	if v := req.EndTime; v != "" {
+		t, err := parseStatsTime(v)
+		if err != nil {
+			return nil, errorx.ErrInvalidParams.New(ctx, struct{ Params string }{"endTime"})
+		}
+		end = t
+	}
+	if v := req.StartTime; v != "" {
+		t, err := parseStatsTime(v)
+		if err != nil || !t.Before(end) || end.Sub(t) > maxStatsRange {
+			return nil, errorx.ErrInvalidParams.New(ctx, struct{ Params string }{"startTime"})
+		}
+		start = t
+	} else {
+		start = end.Add(-24 * time.Hour)
+	}
+
+	bucket := req.Bucket
+	if bucket == "" {
+		bucket = "hour"
+	}
+	truncate := statsTruncate(bucket)
+
+	// the histogram has every bucket of the range, so the range is limited by the bucket
+	var histogram []*dtos.LoggerStatsBucket
+	buckets := make(map[time.Time]*dtos.LoggerStatsBucket)
+	for t := truncate(start); !t.After(end); t = statsNext(bucket, t) {
+		if len(histogram) >= maxStatsBuckets {
+			return nil, errorx.ErrInvalidParams.New(ctx, struct{ Params string }{"bucket"})
+		}
+		b := &dtos.LoggerStatsBucket{Time: t}
+		histogram = append(histogram, b)
+		buckets[t] = b
+	}
+
+	top := req.Top
+	if top <= 0 {
+		top = 10
+	}
+
+	scope, err := a.DataScopeSvc.Option(ctx, DataScopeColumns{Dept: "b.dept_id", Owners: []string{"a.user_id"}})
+	if err != nil {
+		return nil, err
+	}
+	opts := []gormx.Option{
+		a.filter(dtos.LoggerListReq{Level: req.Level, Tag: req.Tag, UserName: req.UserName}),
+		scope,
+		gormx.WithWhere("a.created_at BETWEEN ? AND ?", start, end),
+	}
+	aggregate := func(dest any, extra ...gormx.Option) error {
+		if err := a.LoggerRepo.Aggregate(ctx, dest, append(slices.Clip(opts), extra...)...); err != nil {
+			return errorx.WrapGormError(ctx, err)
+		}
+		return nil
+	}
+
+	stats := &dtos.LoggerStats{
+		StartTime: start,
+		EndTime:   end,
+		Bucket:    bucket,
+		Histogram: histogram,
+	}
+
+	// key is reserved by some databases
+	key := clause.Column{Name: "key"}
+	if err := aggregate(&stats.Levels, gormx.WithSelect("a.level AS ?, COUNT(*) AS count", key), gormx.WithGroup("a.level")); err != nil {
+		return nil, err
+	}
+	for _, item := range stats.Levels {
+		stats.Total += item.Count
+	}
+	if err := aggregate(&stats.Tags, gormx.WithSelect("a.tag AS ?, COUNT(*) AS count", key), gormx.WithGroup("a.tag")); err != nil {
+		return nil, err
+	}
+	sortStatsCounts(stats.Levels)
+	sortStatsCounts(stats.Tags)
+
+	err = aggregate(&stats.Users,
+		gormx.WithSelect("a.user_id AS user_id, MAX(b.username) AS username, COUNT(*) AS count"), gormx.WithGroup("a.user_id"),
+		gormx.WithOrder("count", "desc"), gormx.WithOrder("a.user_id", "asc"),
+		func(db *gorm.DB) *gorm.DB { return db.Limit(top) },
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	count, err := a.LoggerRepo.Count(ctx, option)
+	// the buckets are counted by the units of the epoch time, which are folded into the buckets of the local time,
+	// every time zone is offset from UTC by a multiple of 15 minutes
+	dialect := a.LoggerRepo.DB().Dialector.Name()
+	unit := int64(15 * 60)
+	if bucket == "minute" {
+		unit = 60
+	}
+	var units []struct {
+		Unit   int64
+		Count  int64
+		Errors int64
+	}
+	unitExpr := statsEpochUnit(dialect, "a.created_at", unit)
+	err = aggregate(&units,
+		gormx.WithSelect(unitExpr+" AS unit, COUNT(*) AS count, SUM(CASE WHEN a.level IN (?) THEN 1 ELSE 0 END) AS errors", errorLevels),
+		gormx.WithGroup(unitExpr),
+	)
 	if err != nil {
 		return nil, err
 	}
+	for _, u := range units {
+		if b, ok := buckets[truncate(time.Unix(u.Unit*unit, 0))]; ok {
+			b.Count += u.Count
+			b.Errors += u.Errors
+		}
+	}
 
-	return dtos.NewList(list, req.Page, req.Limit, count), nil
+	// the request logs of middleware.LoggerWithConfig have the status, cost and route in the meta
+	status := statsJSONText(dialect, "a.meta", "status")
+	err = aggregate(&stats.Statuses,
+		gormx.WithSelect(status+" AS ?, COUNT(*) AS count", key), gormx.WithGroup(status),
+		gormx.WithWhere(status+" IS NOT NULL"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	sortStatsCounts(stats.Statuses)
+
+	method, route := statsJSONText(dialect, "a.meta", "method"), statsJSONText(dialect, "a.meta", "route")
+	cost := fmt.Sprintf("CAST(%s AS DECIMAL(20,3))", statsJSONText(dialect, "a.meta", "cost"))
+	var paths []struct {
+		Method  string
+		Path    string
+		Count   int64
+		AvgCost float64
+		MaxCost float64
+	}
+	err = aggregate(&paths,
+		gormx.WithSelect(fmt.Sprintf("%s AS method, %s AS path, COUNT(*) AS count, AVG(%s) AS avg_cost, MAX(%s) AS max_cost", method, route, cost, cost)),
+		gormx.WithGroup(method+", "+route),
+		// the requests of no route have no template to be grouped by
+		gormx.WithWhere(route+" != '' AND "+cost+" IS NOT NULL"),
+		gormx.WithOrder("avg_cost", "desc"), gormx.WithOrder("path", "asc"), gormx.WithOrder("method", "asc"),
+		func(db *gorm.DB) *gorm.DB { return db.Limit(top) },
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		stats.SlowPaths = append(stats.SlowPaths, &dtos.LoggerStatsPath{
+			Method:  p.Method,
+			Path:    p.Path,
+			Count:   p.Count,
+			AvgCost: p.AvgCost,
+			MaxCost: int64(p.MaxCost),
+		})
+	}
+
+	return stats, nil
+}
+
+// Longest time range of the statistics
+const maxStatsRange = 31 * 24 * time.Hour
+
+// Buckets of the histogram at most, a day of minutes or two months of hours
+const maxStatsBuckets = 1500
+
+// Levels of the logs counted as errors
+var errorLevels = []string{"error", "dpanic", "panic", "fatal"}
+
+func parseStatsTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateTime, v, time.Local)
+}
+
+// Get the function truncating the time to the start of its bucket in the local time.
+func statsTruncate(bucket string) func(t time.Time) time.Time {
+	return func(t time.Time) time.Time {
+		t = t.In(time.Local)
+		switch bucket {
+		case "minute":
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.Local)
+		case "day":
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+		default:
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local)
+		}
+	}
+}
+
+// Get the start of the bucket next to the bucket starting at the time.
+func statsNext(bucket string, t time.Time) time.Time {
+	switch bucket {
+	case "minute":
+		return t.Add(time.Minute)
+	case "day":
+		return t.AddDate(0, 0, 1)
+	default:
+		return t.Add(time.Hour)
+	}
+}
+
+// Sort the counts by count in descending order and then by key.
+func sortStatsCounts(items []*dtos.LoggerStatsCount) {
+	slices.SortFunc(items, func(x, y *dtos.LoggerStatsCount) int {
+		return cmp.Or(cmp.Compare(y.Count, x.Count), cmp.Compare(x.Key, y.Key))
+	})
+}
+
+// Expression of the units of the epoch seconds of the time column, the time functions differ by database.
+func statsEpochUnit(dialect, column string, unit int64) string {
+	switch dialect {
+	case "postgres":
+		return fmt.Sprintf("CAST(FLOOR(EXTRACT(EPOCH FROM %s) / %d) AS BIGINT)", column, unit)
+	case "mysql":
+		return fmt.Sprintf("FLOOR(UNIX_TIMESTAMP(%s) / %d)", column, unit)
+	default:
+		return fmt.Sprintf("CAST(strftime('%%s', %s) AS INTEGER) / %d", column, unit)
+	}
+}
+
+// Expression of the text value of the key of the JSON column, the JSON functions differ by database.
+func statsJSONText(dialect, column, key string) string {
+	switch dialect {
+	case "postgres":
+		return fmt.Sprintf("(%s::json ->> '%s')", column, key)
+	case "mysql":
+		return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, '$.%s'))", column, key)
+	default:
+		return fmt.Sprintf("json_extract(%s, '$.%s')", column, key)
+	}
 }
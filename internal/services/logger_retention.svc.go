@@ -0,0 +1,235 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
+	"gin-admin/pkg/logger"
+	"gin-admin/pkg/randx"
+
+	"gorm.io/gorm"
+)
+
+// Retention of the logger table, the purged logs are rolled up and archived first if enabled
+type LoggerRetention struct {
+	LoggerRepo *repositories.Logger
+	RollupRepo *repositories.LoggerRollup
+}
+
+func NewLoggerRetention(app types.AppContext) *LoggerRetention {
+	return &LoggerRetention{
+		LoggerRepo: repositories.NewLogger(app.DB()),
+		RollupRepo: repositories.NewLoggerRollup(app.DB()),
+	}
+}
+
+// Logs created before the time and matched by the condition
+type logPurgeScope struct {
+	before time.Time
+	query  string
+	args   []any
+}
+
+// Condition matching the logs of the tag and level, any tag or level if empty.
+func logMatch(tag, level string) (string, []any) {
+	conds, args := []string{"1 = 1"}, []any{}
+	if tag != "" {
+		conds, args = append(conds, "tag = ?"), append(args, tag)
+	}
+	if level != "" {
+		conds, args = append(conds, "level = ?"), append(args, level)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// Purge the logs created before the time and matched by the tag and level if not empty.
+func (a *LoggerRetention) Purge(ctx context.Context, before time.Time, tag, level string) (int64, error) {
+	query, args := logMatch(tag, level)
+	return a.purge(ctx, logPurgeScope{before: before, query: query, args: args})
+}
+
+// Purge the logs expired by the retention rules, the first rule matching the tag and level of a log applies
+// and the logs matched by no rule are kept for the default days.
+func (a *LoggerRetention) PurgeExpired(ctx context.Context) (int64, error) {
+	cfg := configs.C.LogRetention
+	now := time.Now()
+
+	var (
+		scopes   []logPurgeScope
+		previous []string
+		prevArgs []any
+	)
+	add := func(days int, query string, args []any) {
+		if days > 0 {
+			if len(previous) > 0 {
+				query = fmt.Sprintf("(%s) AND NOT (%s)", query, strings.Join(previous, " OR "))
+				args = append(append([]any{}, args...), prevArgs...)
+			}
+			scopes = append(scopes, logPurgeScope{before: now.AddDate(0, 0, -days), query: query, args: args})
+		}
+	}
+
+	for _, rule := range cfg.Rules {
+		query, args := logMatch(rule.Tag, rule.Level)
+		add(rule.Days, query, args)
+		previous, prevArgs = append(previous, "("+query+")"), append(prevArgs, args...)
+	}
+	add(cfg.Days, "1 = 1", nil)
+
+	return a.purge(ctx, scopes...)
+}
+
+// Delete the logs of the scopes in batches, every batch is archived and rolled up before it is deleted.
+func (a *LoggerRetention) purge(ctx context.Context, scopes ...logPurgeScope) (int64, error) {
+	cfg := configs.C.LogRetention
+	// the logs of all the tenants are purged
+	ctx = helper.WithoutTenant(ctx)
+
+	var archive *logArchive
+	if cfg.Archive.Enable {
+		var err error
+		if archive, err = openLogArchive(cfg.Archive.Path); err != nil {
+			return 0, err
+		}
+		defer archive.Close()
+	}
+
+	batchSize := max(cfg.BatchSize, 1)
+	var total int64
+	for _, scope := range scopes {
+		for {
+			items, err := a.LoggerRepo.Find(ctx,
+				gormx.WithWhere("created_at < ?", scope.before),
+				gormx.WithWhere(scope.query, scope.args...),
+				gormx.WithOrder("created_at", "asc"),
+				func(db *gorm.DB) *gorm.DB { return db.Limit(batchSize) },
+			)
+			if err != nil {
+				return total, err
+			}
+			if len(items) == 0 {
+				break
+			}
+
+			if archive != nil {
+				if err := archive.Write(items); err != nil {
+					return total, err
+				}
+			}
+			// the rollup and the deletion commit together, so a failed batch is neither counted twice nor lost
+			err = a.LoggerRepo.Transaction(ctx, func(tx *gorm.DB) error {
+				ctx := helper.WithTrans(ctx, tx)
+				if cfg.Rollup {
+					if err := a.rollup(ctx, items); err != nil {
+						return err
+					}
+				}
+
+				ids := make([]string, len(items))
+				for i, item := range items {
+					ids[i] = item.ID
+				}
+				return a.LoggerRepo.DeleteBatch(ctx, gormx.WithWhere("id IN ?", ids))
+			})
+			if err != nil {
+				return total, err
+			}
+			total += int64(len(items))
+
+			if len(items) < batchSize {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return total, ctx.Err()
+			case <-time.After(time.Duration(cfg.BatchPause) * time.Millisecond):
+			}
+		}
+	}
+
+	if total > 0 {
+		logger.Info(logger.WithTag(ctx, logger.Tag_System), "Purged the expired logs", map[string]any{"count": total})
+	}
+	return total, nil
+}
+
+// Add up the daily counts of the logs by tenant, tag and level.
+func (a *LoggerRetention) rollup(ctx context.Context, items []*models.Logger) error {
+	var keys []models.LoggerRollup
+	counts := make(map[models.LoggerRollup]int64)
+	for _, item := range items {
+		key := models.LoggerRollup{
+			Day:      item.CreatedAt.Local().Format(time.DateOnly),
+			TenantID: item.TenantID,
+			Tag:      item.Tag,
+			Level:    item.Level,
+		}
+		if _, ok := counts[key]; !ok {
+			keys = append(keys, key)
+		}
+		counts[key]++
+	}
+
+	for _, key := range keys {
+		rollup := key
+		rollup.ID = randx.NewXID()
+		rollup.Total = counts[key]
+		if err := a.RollupRepo.Add(ctx, &rollup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Gzip compressed NDJSON file of the purged logs
+type logArchive struct {
+	file *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+// Open the archive file of the purge, the file of the same second is appended as another gzip member.
+func openLogArchive(dir string) (*logArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	name := filepath.Join(dir, fmt.Sprintf("logger-%s.ndjson.gz", time.Now().Format("20060102150405")))
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(file)
+	return &logArchive{file: file, gz: gz, enc: json.NewEncoder(gz)}, nil
+}
+
+// Write the logs and flush them to the disk, so they are archived before being deleted.
+func (a *logArchive) Write(items []*models.Logger) error {
+	for _, item := range items {
+		if err := a.enc.Encode(item.Logger); err != nil {
+			return err
+		}
+	}
+	if err := a.gz.Flush(); err != nil {
+		return err
+	}
+	return a.file.Sync()
+}
+
+func (a *logArchive) Close() {
+	_ = a.gz.Close()
+	_ = a.file.Close()
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/logger"
+)
+
+const (
+	gCacheNSForLoginAttempts = "login_attempts"     // failures in the window
+	gCacheNSForLoginLastFail = "login_last_failure" // unix seconds of the last failure
+	gCacheNSForLoginLocks    = "login_locks"        // unix seconds until the lockout ends
+)
+
+type loginCounter struct {
+	key         string
+	maxFailures int
+}
+
+// Brute-force protection for login
+type LoginGuard struct {
+	Cacher cachex.Cacher
+}
+
+func NewLoginGuard(app types.AppContext) *LoginGuard {
+	return &LoginGuard{
+		Cacher: app.Cacher(),
+	}
+}
+
+func (a *LoginGuard) counters(username, clientIP string) []loginCounter {
+	cfg := configs.C.LoginGuard
+
+	counters := []loginCounter{
+		{key: "user:" + username, maxFailures: cfg.MaxFailuresPerUser},
+	}
+	if clientIP != "" {
+		counters = append(counters,
+			loginCounter{key: "ip:" + clientIP, maxFailures: cfg.MaxFailuresPerIP},
+			loginCounter{key: "user_ip:" + username + "@" + clientIP, maxFailures: cfg.MaxFailuresPerUserIP},
+		)
+	}
+	return counters
+}
+
+// Get the integer of the key in the namespace, zero if not exists.
+func (a *LoginGuard) getInt(ctx context.Context, ns, key string) (int64, error) {
+	val, err := a.Cacher.Get(ctx, ns, key)
+	if err != nil {
+		if err == cachex.ErrNotFound {
+			return 0, nil
+		}
+		return 0, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	n, _ := strconv.ParseInt(val, 10, 64)
+	return n, nil
+}
+
+// The delay before the next attempt is allowed, it doubles on each failure after `DelayAfter`.
+func (a *LoginGuard) delay(failures int) time.Duration {
+	cfg := configs.C.LoginGuard
+	if cfg.DelayBase <= 0 || failures < cfg.DelayAfter {
+		return 0
+	}
+
+	delay := cfg.DelayBase
+	for i := cfg.DelayAfter; i < failures && delay < cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if cfg.MaxDelay > 0 && delay > cfg.MaxDelay {
+		delay = cfg.MaxDelay
+	}
+	return time.Duration(delay) * time.Second
+}
+
+// Check whether the username is allowed to login from the client IP.
+func (a *LoginGuard) Check(ctx context.Context, username, clientIP string) error {
+	if !configs.C.LoginGuard.Enable {
+		return nil
+	}
+
+	now := time.Now()
+	for _, counter := range a.counters(username, clientIP) {
+		if counter.maxFailures <= 0 {
+			continue
+		}
+
+		lockedUntil, err := a.getInt(ctx, gCacheNSForLoginLocks, counter.key)
+		if err != nil {
+			return err
+		}
+		if lockedUntil > now.Unix() {
+			unlockAt := time.Unix(lockedUntil, 0).Format(time.DateTime)
+			return errorx.ErrLoginLocked.New(ctx, struct{ UnlockAt string }{unlockAt})
+		}
+
+		failures, err := a.getInt(ctx, gCacheNSForLoginAttempts, counter.key)
+		if err != nil {
+			return err
+		}
+		if delay := a.delay(int(failures)); delay > 0 {
+			lastFailure, err := a.getInt(ctx, gCacheNSForLoginLastFail, counter.key)
+			if err != nil {
+				return err
+			}
+			if wait := time.Unix(lastFailure, 0).Add(delay).Sub(now); wait > 0 {
+				return errorx.ErrLoginTooFrequent.New(ctx, struct{ Seconds int }{int(wait.Seconds()) + 1})
+			}
+		}
+	}
+
+	return nil
+}
+
+// Record a failed login, the counters which reach the limit will be locked.
+func (a *LoginGuard) Fail(ctx context.Context, username, clientIP string) error {
+	cfg := configs.C.LoginGuard
+	if !cfg.Enable {
+		return nil
+	}
+
+	ctx = logger.WithTag(ctx, logger.Tag_Login)
+
+	now := time.Now()
+	window := time.Duration(cfg.FailureWindow) * time.Second
+	lockout := time.Duration(cfg.LockoutDuration) * time.Second
+
+	for _, counter := range a.counters(username, clientIP) {
+		if counter.maxFailures <= 0 {
+			continue
+		}
+
+		// the failures are counted atomically, so the concurrent attempts can not exceed the limit
+		failures, err := a.Cacher.Incr(ctx, gCacheNSForLoginAttempts, counter.key, window)
+		if err != nil {
+			return errorx.ErrInternal.New(ctx).Wrap(err)
+		}
+		if err := a.Cacher.Set(ctx, gCacheNSForLoginLastFail, counter.key, strconv.FormatInt(now.Unix(), 10), window); err != nil {
+			return errorx.ErrInternal.New(ctx).Wrap(err)
+		}
+
+		if failures >= int64(counter.maxFailures) {
+			lockedUntil := now.Add(lockout).Unix()
+			if err := a.Cacher.Set(ctx, gCacheNSForLoginLocks, counter.key, strconv.FormatInt(lockedUntil, 10), lockout); err != nil {
+				return errorx.ErrInternal.New(ctx).Wrap(err)
+			}
+			// the failures are counted again after the lockout
+			if err := a.Cacher.Delete(ctx, gCacheNSForLoginAttempts, counter.key); err != nil {
+				return errorx.ErrInternal.New(ctx).Wrap(err)
+			}
+
+			logger.Warn(ctx, "Login locked", map[string]any{
+				"key":      counter.key,
+				"username": username,
+				"clientIP": clientIP,
+				"unlockAt": time.Unix(lockedUntil, 0).Format(time.DateTime),
+			})
+		}
+	}
+
+	return nil
+}
+
+// Clear the failures of the username after a successful login, the client IP counter is kept.
+func (a *LoginGuard) Succeed(ctx context.Context, username, clientIP string) error {
+	if !configs.C.LoginGuard.Enable {
+		return nil
+	}
+
+	keys := []string{"user:" + username}
+	if clientIP != "" {
+		keys = append(keys, "user_ip:"+username+"@"+clientIP)
+	}
+	return a.clear(ctx, keys...)
+}
+
+// Clear the failures and lockouts of the keys.
+func (a *LoginGuard) clear(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		for _, ns := range []string{gCacheNSForLoginAttempts, gCacheNSForLoginLastFail, gCacheNSForLoginLocks} {
+			if err := a.Cacher.Delete(ctx, ns, key); err != nil {
+				return errorx.ErrInternal.New(ctx).Wrap(err)
+			}
+		}
+	}
+	return nil
+}
+
+// Clear the lockout of the username from all client IPs.
+func (a *LoginGuard) Unlock(ctx context.Context, username string) error {
+	keys := []string{"user:" + username}
+
+	prefix := "user_ip:" + username + "@"
+	for _, ns := range []string{gCacheNSForLoginAttempts, gCacheNSForLoginLocks} {
+		err := a.Cacher.Iterator(ctx, ns, func(ctx context.Context, key, value string) bool {
+			if strings.HasPrefix(key, prefix) && !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+			return true
+		})
+		if err != nil {
+			return errorx.ErrInternal.New(ctx).Wrap(err)
+		}
+	}
+
+	if err := a.clear(ctx, keys...); err != nil {
+		return err
+	}
+
+	ctx = logger.WithTag(ctx, logger.Tag_Login)
+	logger.Info(ctx, "Login unlocked", map[string]any{
+		"username": username,
+	})
+
+	return nil
+}
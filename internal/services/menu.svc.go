@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -18,6 +19,7 @@ import (
 	"gin-admin/pkg/encoding/json"
 	"gin-admin/pkg/encoding/yaml"
 	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
 	"gin-admin/pkg/logger"
 	"gin-admin/pkg/randx"
 
@@ -30,6 +32,9 @@ const (
 	gTreePathDelimiter = "."
 )
 
+// Fields of the menu recorded by the audit trail of the deleted menu
+var menuAuditFields = []string{"Name", "Type", "Method", "Code", "Path", "Component", "Status", "Redirect", "ParentID", "Rank", "Title", "Extra"}
+
 // Menu management for SYS
 type Menu struct {
 	Cacher       cachex.Cacher
@@ -37,6 +42,7 @@ type Menu struct {
 	MenuRoleRepo *repositories.MenuRole
 	UserRoleRepo *repositories.UserRole
 	RoleSvc      *Role
+	AuditSvc     *Audit
 }
 
 func NewMenu(app types.AppContext) *Menu {
@@ -46,6 +52,7 @@ func NewMenu(app types.AppContext) *Menu {
 		MenuRoleRepo: repositories.NewMenuRole(app.DB()),
 		UserRoleRepo: repositories.NewUserRole(app.DB()),
 		RoleSvc:      NewRole(app),
+		AuditSvc:     NewAudit(app),
 	}
 }
 
@@ -68,7 +75,7 @@ func (a *Menu) InitIfNeed(ctx context.Context) error {
 		logger.Error(ctx, "failed to init menu data", err, map[string]any{"file": configs.C.Menu.File})
 	}
 
-	return a.RoleSvc.RefreshUpdateTime(ctx)
+	return nil
 }
 
 func (a *Menu) initFromFile(ctx context.Context, menuFile string) error {
@@ -172,6 +179,19 @@ func (a *Menu) upsert(ctx context.Context, items models.Menus, parent *models.Me
 // List menus from the data access object based on the provided parameters and options.
 func (a *Menu) List(ctx context.Context, req dtos.MenuListReq) (*dtos.List[*models.Menu], error) {
 
+	// the menus of the user include the ones inherited by its roles
+	var userRoleIDs []string
+	if v := req.UserID; len(v) > 0 {
+		userRoles, err := a.UserRoleRepo.Find(ctx, gormx.WithSelect("role_id"), gormx.WithWhere("user_id = ?", v))
+		if err != nil {
+			return nil, errorx.WrapGormError(ctx, err)
+		}
+		userRoleIDs, err = a.RoleSvc.WithAncestors(ctx, models.UserRoles(userRoles).ToRoleIDs()...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	option := func(db *gorm.DB) *gorm.DB {
 		if v := req.InIDs; len(v) > 0 {
 			db = db.Where("id IN ?", v)
@@ -189,8 +209,7 @@ func (a *Menu) List(ctx context.Context, req dtos.MenuListReq) (*dtos.List[*mode
 			db = db.Where("parent_path LIKE ?", v+"%")
 		}
 		if v := req.UserID; len(v) > 0 {
-			userRoleQuery := a.UserRoleRepo.DB().Model(new(models.UserRole)).Where("user_id = ?", v).Select("role_id")
-			menuRoleQuery := a.MenuRoleRepo.DB().Model(new(models.MenuRole)).Where("role_id IN (?)", userRoleQuery).Select("menu_id")
+			menuRoleQuery := a.MenuRoleRepo.DB().Model(new(models.MenuRole)).Where("role_id IN (?)", userRoleIDs).Select("menu_id")
 			db = db.Where("id IN (?)", menuRoleQuery)
 		}
 		if v := req.RoleID; len(v) > 0 {
@@ -295,6 +314,9 @@ func (a *Menu) Create(ctx context.Context, req *dtos.MenuCreateReq) (*models.Men
 	if configs.C.Menu.DenyOperate {
 		return nil, errorx.ErrBadRequest.New(ctx)
 	}
+	if err := checkPlatform(ctx); err != nil {
+		return nil, err
+	}
 
 	menu := &models.Menu{
 		ID:        randx.NewXID(),
@@ -310,13 +332,23 @@ func (a *Menu) Create(ctx context.Context, req *dtos.MenuCreateReq) (*models.Men
 		menu.ParentPath = parent.ParentPath + parent.ID + gTreePathDelimiter
 	}
 
+	var md object.Metadata
 	if err := object.Assign(menu, req, func(c *object.AssignConfig) {
 		c.IncludeIgnoreFields = true
+		c.Metadata = &md
 	}); err != nil {
 		return nil, err
 	}
 
-	if err := a.MenuRepo.Create(ctx, menu); err != nil {
+	err := a.MenuRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.MenuRepo.Create(ctx, menu); err != nil {
+			return err
+		}
+		changes := models.DiffFields(nil, menu, md.Keys...)
+		return a.AuditSvc.Record(ctx, models.AuditAction_Create, models.AuditEntity_Menu, menu.ID, changes)
+	})
+	if err != nil {
 		return nil, errorx.WrapGormError(ctx, err)
 	}
 
@@ -328,6 +360,9 @@ func (a *Menu) Update(ctx context.Context, id string, req *dtos.MenuUpdateReq) e
 	if configs.C.Menu.DenyOperate {
 		return errorx.ErrBadRequest.New(ctx)
 	}
+	if err := checkPlatform(ctx); err != nil {
+		return err
+	}
 
 	menu, err := a.MenuRepo.Get(ctx, id)
 	if err != nil {
@@ -355,11 +390,21 @@ func (a *Menu) Update(ctx context.Context, id string, req *dtos.MenuUpdateReq) e
 		childData = res
 	}
 
-	if err := object.Assign(menu, req); err != nil {
+	before := *menu
+	var md object.Metadata
+	if err := object.Assign(menu, req, func(c *object.AssignConfig) {
+		c.Metadata = &md
+	}); err != nil {
 		return errorx.ErrInternal.New(ctx).Wrap(err)
 	}
 
+	roleIDs, err := a.grantedRoleIDs(ctx, oldParentPath+menu.ID+gTreePathDelimiter, menu.ID)
+	if err != nil {
+		return err
+	}
+
 	err = a.MenuRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
 		if req.Status != nil && oldStatus != *req.Status {
 			oldPath := oldParentPath + menu.ID + gTreePathDelimiter
 			if err := a.MenuRepo.UpdateStatusByParentPath(ctx, oldPath, *req.Status); err != nil {
@@ -379,16 +424,23 @@ func (a *Menu) Update(ctx context.Context, id string, req *dtos.MenuUpdateReq) e
 		if err := a.MenuRepo.Update(ctx, menu); err != nil {
 			return err
 		}
+		changes := models.DiffFields(&before, menu, md.Keys...)
+		if err := a.AuditSvc.Record(ctx, models.AuditAction_Update, models.AuditEntity_Menu, menu.ID, changes); err != nil {
+			return err
+		}
 
 		if menu.Type != models.MenuType_MENU {
 			if err := a.MenuRepo.DeleteChildrenOfButton(ctx, menu.ParentID); err != nil {
 				return err
 			}
 		}
-		return a.RoleSvc.RefreshUpdateTime(ctx)
+		return nil
 	})
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
 
-	return errorx.WrapGormError(ctx, err)
+	return a.RoleSvc.SyncPolicies(ctx, roleIDs...)
 }
 
 // Delete the specified menu from the data access object.
@@ -396,40 +448,71 @@ func (a *Menu) Delete(ctx context.Context, id string) error {
 	if configs.C.Menu.DenyOperate {
 		return errorx.ErrBadRequest.New(ctx)
 	}
+	if err := checkPlatform(ctx); err != nil {
+		return err
+	}
 
 	menu, err := a.MenuRepo.Get(ctx, id)
 	if err != nil {
 		return errorx.WrapGormError(ctx, err)
 	}
 
-	children, err := a.MenuRepo.Find(ctx, gormx.WithWhere("parent_path LIKE ?", menu.ParentPath+menu.ID+gTreePathDelimiter+"%"), gormx.WithSelect("id"))
+	children, err := a.MenuRepo.Find(ctx, gormx.WithWhere("parent_path LIKE ?", menu.ParentPath+menu.ID+gTreePathDelimiter+"%"))
 	if err != nil {
 		return errorx.WrapGormError(ctx, err)
 	}
 
+	// the roles must be collected before their grants are deleted
+	roleIDs, err := a.grantedRoleIDs(ctx, menu.ParentPath+menu.ID+gTreePathDelimiter, menu.ID)
+	if err != nil {
+		return err
+	}
+
 	err = a.MenuRepo.Transaction(ctx, func(tx *gorm.DB) error {
-		if err := a.delete(ctx, id); err != nil {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.delete(ctx, menu); err != nil {
 			return err
 		}
 
 		for _, child := range children {
-			if err := a.delete(ctx, child.ID); err != nil {
+			if err := a.delete(ctx, child); err != nil {
 				return err
 			}
 		}
-
-		return a.RoleSvc.RefreshUpdateTime(ctx)
+		return nil
 	})
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	return a.RoleSvc.SyncPolicies(ctx, roleIDs...)
+}
+
+// Get the IDs of the roles granted the menu or any menu under its path.
+func (a *Menu) grantedRoleIDs(ctx context.Context, path, id string) ([]string, error) {
+	// the menus are shared by all the tenants, so are the roles granted them
+	ctx = helper.WithoutTenant(ctx)
+	menuQuery := a.MenuRepo.DB().Model(new(models.Menu)).Where("id = ? OR parent_path LIKE ?", id, path+"%").Select("id")
+	menuRoles, err := a.MenuRoleRepo.Find(ctx, gormx.WithSelect("role_id"), gormx.WithWhere("menu_id IN (?)", menuQuery))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
 
-	return errorx.WrapGormError(ctx, err)
+	var roleIDs []string
+	for _, menuRole := range menuRoles {
+		if !slices.Contains(roleIDs, menuRole.RoleID) {
+			roleIDs = append(roleIDs, menuRole.RoleID)
+		}
+	}
+	return roleIDs, nil
 }
 
-func (a *Menu) delete(ctx context.Context, id string) error {
-	if err := a.MenuRepo.Delete(ctx, id); err != nil {
+func (a *Menu) delete(ctx context.Context, menu *models.Menu) error {
+	if err := a.MenuRepo.Delete(ctx, menu.ID); err != nil {
 		return err
 	}
-	if err := a.MenuRoleRepo.DeleteByMenuID(ctx, id); err != nil {
+	if err := a.MenuRoleRepo.DeleteByMenuID(helper.WithoutTenant(ctx), menu.ID); err != nil {
 		return err
 	}
-	return nil
+	return a.AuditSvc.Record(ctx, models.AuditAction_Delete, models.AuditEntity_Menu, menu.ID, models.DiffFields(menu, nil, menuAuditFields...))
 }
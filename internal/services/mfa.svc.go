@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/crypto/aes"
+	"gin-admin/pkg/crypto/hash"
+	"gin-admin/pkg/crypto/rand"
+	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
+	"gin-admin/pkg/logger"
+	"gin-admin/pkg/totp"
+
+	"gorm.io/gorm"
+)
+
+const (
+	gCacheNSForMFAChallenge         = "mfa_challenge"
+	gCacheNSForMFAChallengeAttempts = "mfa_challenge_attempts" // failed attempts of the challenge
+	gCacheNSForMFAUsedCodes         = "mfa_used_codes"         // used TOTP time steps and recovery codes
+)
+
+type mfaChallenge struct {
+	UserID    string `json:"userId"`
+	ExpiresAt int64  `json:"expiresAt"` // unix seconds
+}
+
+// TOTP two-factor authentication
+type MFA struct {
+	Cacher   cachex.Cacher
+	UserRepo *repositories.User
+	RoleRepo *repositories.Role
+	GuardSvc *LoginGuard
+}
+
+func NewMFA(app types.AppContext) *MFA {
+	return &MFA{
+		Cacher:   app.Cacher(),
+		UserRepo: repositories.NewUser(app.DB()),
+		RoleRepo: repositories.NewRole(app.DB()),
+		GuardSvc: NewLoginGuard(app),
+	}
+}
+
+func (a *MFA) secretKey() []byte {
+	if key := configs.C.MFA.SecretKey; key != "" {
+		return []byte(key)
+	}
+	return aes.SecretKey
+}
+
+func (a *MFA) totpOptions() []totp.Option {
+	return []totp.Option{totp.WithSkew(configs.C.MFA.Skew)}
+}
+
+func (a *MFA) issuer() string {
+	if issuer := configs.C.MFA.Issuer; issuer != "" {
+		return issuer
+	}
+	return configs.C.AppName
+}
+
+func (a *MFA) normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// Match the recovery code against the stored hashes, the unsalted SHA1 hashes of the earlier versions are still accepted.
+func (a *MFA) matchRecoveryCode(hashedCodes []string, code string) int {
+	code = a.normalizeRecoveryCode(code)
+	if code == "" {
+		return -1
+	}
+
+	for i, hashed := range hashedCodes {
+		if strings.HasPrefix(hashed, "$2") {
+			if hash.CompareHashAndPassword(hashed, code) == nil {
+				return i
+			}
+		} else if hashed == hash.SHA1String(code) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Mark the code as used, it returns false if the code has been used already.
+func (a *MFA) useCode(ctx context.Context, userID, code string, expiration time.Duration) (bool, error) {
+	ok, err := a.Cacher.SetNX(ctx, gCacheNSForMFAUsedCodes, userID+":"+code, "1", expiration)
+	if err != nil {
+		return false, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+	return ok, nil
+}
+
+// Check whether any enabled role of the user requires two-factor authentication.
+func (a *MFA) Required(ctx context.Context, userID string) (bool, error) {
+	exists, err := a.RoleRepo.Exists(ctx, func(db *gorm.DB) *gorm.DB {
+		userRoleTable := new(models.UserRole).TableName()
+		return db.Where("mfa_required = ? AND status = ?", true, models.RoleStatus_Enabled).
+			Where("id IN (?)", db.Session(&gorm.Session{NewDB: true}).Table(userRoleTable).Select("role_id").Where("user_id = ?", userID))
+	})
+	if err != nil {
+		return false, errorx.WrapGormError(ctx, err)
+	}
+	return exists, nil
+}
+
+// Generate a new TOTP secret and recovery codes for the user, it takes effect after the first code is verified.
+func (a *MFA) setup(ctx context.Context, user *models.User) (*dtos.MFASetup, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	encrypted, err := aes.EncryptToBase64([]byte(secret), a.secretKey())
+	if err != nil {
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	codes := make([]string, configs.C.MFA.RecoveryCodes)
+	hashedCodes := make([]string, len(codes))
+	for i := range codes {
+		code, err := rand.Random(10, rand.LdigitAndLowerCase)
+		if err != nil {
+			return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+		}
+		codes[i] = code[:5] + "-" + code[5:]
+		if hashedCodes[i], err = hash.GeneratePassword(code); err != nil {
+			return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+		}
+	}
+
+	user.MFAEnabled = false
+	user.MFASecret = encrypted
+	user.MFACodes = hashedCodes
+	if err := a.UserRepo.Update(ctx, user, gormx.WithSelect("MFAEnabled", "MFASecret", "MFACodes")); err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	return &dtos.MFASetup{
+		Secret:        secret,
+		URI:           totp.ProvisioningURI(secret, a.issuer(), user.Username, a.totpOptions()...),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// Verify the TOTP code or consume a recovery code of the user.
+func (a *MFA) verify(ctx context.Context, user *models.User, code string) error {
+	if user.MFASecret == "" {
+		return errorx.ErrMFANotEnrolled.New(ctx)
+	}
+
+	secret, err := aes.DecryptFromBase64(user.MFASecret, a.secretKey())
+	if err != nil {
+		return errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	if step, ok := totp.Match(string(secret), code, time.Now(), a.totpOptions()...); ok {
+		// the code of a time step is accepted only once, it is remembered until the step is out of the skew
+		period := 30 * time.Second
+		expiration := time.Duration(2*configs.C.MFA.Skew+2) * period
+		if ok, err := a.useCode(ctx, user.ID, fmt.Sprintf("totp:%d", step), expiration); err != nil {
+			return err
+		} else if !ok {
+			return errorx.ErrMFACodeIncorrect.New(ctx)
+		}
+		return nil
+	}
+
+	// recovery codes are only accepted once the enrollment is completed
+	if user.MFAEnabled {
+		if idx := a.matchRecoveryCode(user.MFACodes, code); idx >= 0 {
+			// the concurrent requests with the same code can not both consume it
+			if ok, err := a.useCode(ctx, user.ID, "recovery:"+user.MFACodes[idx], time.Minute); err != nil {
+				return err
+			} else if !ok {
+				return errorx.ErrMFACodeIncorrect.New(ctx)
+			}
+
+			user.MFACodes = slices.Delete(user.MFACodes, idx, idx+1)
+			if err := a.UserRepo.Update(ctx, user, gormx.WithSelect("MFACodes")); err != nil {
+				return errorx.WrapGormError(ctx, err)
+			}
+			logger.Warn(ctx, "Recovery code used", map[string]any{
+				"remaining": len(user.MFACodes),
+			})
+			return nil
+		}
+	}
+
+	return errorx.ErrMFACodeIncorrect.New(ctx)
+}
+
+func (a *MFA) getUser(ctx context.Context, userID string) (*models.User, error) {
+	user, err := a.UserRepo.Get(ctx, userID, gormx.WithSelect("id", "username", "mfa_enabled", "mfa_secret", "mfa_codes"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorx.ErrUserNotFound.New(ctx)
+		}
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+	return user, nil
+}
+
+// Start enrollment for the current user.
+func (a *MFA) Enroll(ctx context.Context) (*dtos.MFASetup, error) {
+	user, err := a.getUser(ctx, helper.GetUserID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if user.MFAEnabled {
+		return nil, errorx.ErrMFAAlreadyEnabled.New(ctx)
+	}
+
+	return a.setup(ctx, user)
+}
+
+// Complete enrollment of the current user with the first TOTP code.
+func (a *MFA) Activate(ctx context.Context, req *dtos.MFACodeReq) error {
+	user, err := a.getUser(ctx, helper.GetUserID(ctx))
+	if err != nil {
+		return err
+	}
+
+	if user.MFAEnabled {
+		return errorx.ErrMFAAlreadyEnabled.New(ctx)
+	}
+
+	if err := a.verify(ctx, user, req.Code); err != nil {
+		return err
+	}
+
+	user.MFAEnabled = true
+	if err := a.UserRepo.Update(ctx, user, gormx.WithSelect("MFAEnabled")); err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	logger.Info(logger.WithTag(ctx, logger.Tag_Operate), "Two-factor authentication enabled")
+	return nil
+}
+
+// Disable two-factor authentication of the current user, it is not allowed if required by any role.
+func (a *MFA) Disable(ctx context.Context, req *dtos.MFACodeReq) error {
+	userID := helper.GetUserID(ctx)
+
+	required, err := a.Required(ctx, userID)
+	if err != nil {
+		return err
+	} else if required {
+		return errorx.ErrMFARequired.New(ctx)
+	}
+
+	user, err := a.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.MFAEnabled {
+		return errorx.ErrMFANotEnrolled.New(ctx)
+	}
+
+	if err := a.verify(ctx, user, req.Code); err != nil {
+		return err
+	}
+
+	user.MFAEnabled = false
+	user.MFASecret = ""
+	user.MFACodes = []string{}
+	if err := a.UserRepo.Update(ctx, user, gormx.WithSelect("MFAEnabled", "MFASecret", "MFACodes")); err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	logger.Info(logger.WithTag(ctx, logger.Tag_Operate), "Two-factor authentication disabled")
+	return nil
+}
+
+// Issue a short-lived challenge for the user who passed the password check.
+// If the user has not enrolled yet, the enrollment data is returned within the challenge.
+func (a *MFA) Challenge(ctx context.Context, user *models.User) (*dtos.MFAChallenge, error) {
+	token, err := rand.Random(32, rand.LdigitAndLetter)
+	if err != nil {
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	expiration := time.Duration(configs.C.MFA.ChallengeExpiration) * time.Second
+
+	byt, err := json.Marshal(mfaChallenge{UserID: user.ID, ExpiresAt: time.Now().Add(expiration).Unix()})
+	if err != nil {
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	if err := a.Cacher.Set(ctx, gCacheNSForMFAChallenge, token, string(byt), expiration); err != nil {
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	challenge := &dtos.MFAChallenge{
+		Token:   token,
+		Expires: int64(configs.C.MFA.ChallengeExpiration),
+	}
+
+	if !user.MFAEnabled {
+		setup, err := a.setup(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		challenge.Setup = setup
+	}
+
+	return challenge, nil
+}
+
+// Verify the code of the challenge, it returns the user ID if passed.
+// The challenge is dropped once passed or after too many attempts, and the failures are counted by the login guard.
+func (a *MFA) VerifyChallenge(ctx context.Context, req *dtos.MFAVerifyReq) (string, error) {
+	val, err := a.Cacher.Get(ctx, gCacheNSForMFAChallenge, req.Token)
+	if err != nil {
+		if err == cachex.ErrNotFound {
+			return "", errorx.ErrMFAChallengeInvalid.New(ctx)
+		}
+		return "", errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	var challenge mfaChallenge
+	if err := json.Unmarshal([]byte(val), &challenge); err != nil {
+		return "", errorx.ErrMFAChallengeInvalid.New(ctx)
+	}
+
+	ctx = logger.WithUserID(ctx, challenge.UserID)
+
+	user, err := a.getUser(ctx, challenge.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	clientIP := helper.GetClientIP(ctx)
+	if err := a.GuardSvc.Check(ctx, user.Username, clientIP); err != nil {
+		return "", err
+	}
+
+	if err := a.verify(ctx, user, req.Code); err != nil {
+		if gerr := a.GuardSvc.Fail(ctx, user.Username, clientIP); gerr != nil {
+			return "", gerr
+		}
+
+		// the attempts are counted atomically, so the concurrent guesses can not exceed the limit
+		remaining := time.Until(time.Unix(challenge.ExpiresAt, 0))
+		attempts, ierr := a.Cacher.Incr(ctx, gCacheNSForMFAChallengeAttempts, req.Token, max(remaining, time.Second))
+		if ierr != nil {
+			return "", errorx.ErrInternal.New(ctx).Wrap(ierr)
+		}
+		if attempts >= int64(configs.C.MFA.ChallengeAttempts) || remaining <= 0 {
+			_ = a.Cacher.Delete(ctx, gCacheNSForMFAChallenge, req.Token)
+			_ = a.Cacher.Delete(ctx, gCacheNSForMFAChallengeAttempts, req.Token)
+		}
+		return "", err
+	}
+
+	// the challenge is consumed only once, even if it is verified concurrently
+	if _, err := a.Cacher.GetAndDelete(ctx, gCacheNSForMFAChallenge, req.Token); err != nil {
+		if err == cachex.ErrNotFound {
+			return "", errorx.ErrMFAChallengeInvalid.New(ctx)
+		}
+		return "", errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+	_ = a.Cacher.Delete(ctx, gCacheNSForMFAChallengeAttempts, req.Token)
+
+	if err := a.GuardSvc.Succeed(ctx, user.Username, clientIP); err != nil {
+		return "", err
+	}
+
+	// the first verified code completes the enrollment
+	if !user.MFAEnabled {
+		user.MFAEnabled = true
+		if err := a.UserRepo.Update(ctx, user, gormx.WithSelect("MFAEnabled")); err != nil {
+			return "", errorx.WrapGormError(ctx, err)
+		}
+	}
+
+	return user.ID, nil
+}
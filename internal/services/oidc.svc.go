@@ -0,0 +1,417 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/crypto/rand"
+	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
+	"gin-admin/pkg/logger"
+	"gin-admin/pkg/oidcx"
+	"gin-admin/pkg/randx"
+
+	"gorm.io/gorm"
+)
+
+const (
+	gCacheNSForOIDCState = "oidc_state"
+)
+
+// Pending sign-on at the provider, kept until the callback
+type oidcState struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`         // PKCE code verifier
+	Nonce    string `json:"nonce"`            // Nonce of the ID token
+	UserID   string `json:"userId,omitempty"` // The user to link the identity to, empty for login
+}
+
+// OpenID Connect single sign-on
+type OIDC struct {
+	Cacher       cachex.Cacher
+	UserRepo     *repositories.User
+	RoleRepo     *repositories.Role
+	IdentityRepo *repositories.UserIdentity
+	AuthSvc      *Auth
+	AuditSvc     *Audit
+
+	mu        sync.Mutex
+	providers map[string]*oidcx.Provider
+}
+
+func NewOIDC(app types.AppContext) *OIDC {
+	return &OIDC{
+		Cacher:       app.Cacher(),
+		UserRepo:     repositories.NewUser(app.DB()),
+		RoleRepo:     repositories.NewRole(app.DB()),
+		IdentityRepo: repositories.NewUserIdentity(app.DB()),
+		AuthSvc:      NewAuth(app),
+		AuditSvc:     NewAudit(app),
+		providers:    make(map[string]*oidcx.Provider),
+	}
+}
+
+func (a *OIDC) config(ctx context.Context, name string) (*configs.OIDCProvider, error) {
+	for i, item := range configs.C.OIDC.Providers {
+		if item.Name == name {
+			return &configs.C.OIDC.Providers[i], nil
+		}
+	}
+	return nil, errorx.ErrOIDCProviderNotFound.New(ctx)
+}
+
+func (a *OIDC) provider(ctx context.Context, name string) (*configs.OIDCProvider, *oidcx.Provider, error) {
+	cfg, err := a.config(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if p, ok := a.providers[name]; ok {
+		return cfg, p, nil
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+
+	p := oidcx.New(oidcx.Config{
+		Issuer:       cfg.Issuer,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       scopes,
+	})
+	a.providers[name] = p
+	return cfg, p, nil
+}
+
+// List the providers available for single sign-on.
+func (a *OIDC) Providers(ctx context.Context) []*dtos.OIDCProvider {
+	list := make([]*dtos.OIDCProvider, 0, len(configs.C.OIDC.Providers))
+	for _, item := range configs.C.OIDC.Providers {
+		title := item.Title
+		if title == "" {
+			title = item.Name
+		}
+		list = append(list, &dtos.OIDCProvider{Name: item.Name, Title: title})
+	}
+	return list
+}
+
+// Start a sign-on at the provider, it returns the authorization URL to redirect the user agent to and the state of it.
+func (a *OIDC) authURL(ctx context.Context, name, userID string) (string, string, error) {
+	_, provider, err := a.provider(ctx, name)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := rand.Random(32, rand.LdigitAndLetter)
+	if err != nil {
+		return "", "", errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+	nonce, err := rand.Random(32, rand.LdigitAndLetter)
+	if err != nil {
+		return "", "", errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+	verifier, err := oidcx.GenerateVerifier()
+	if err != nil {
+		return "", "", errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", errorx.ErrOIDCAuthFailed.New(ctx).Wrap(err)
+	}
+
+	byt, err := json.Marshal(oidcState{Provider: name, Verifier: verifier, Nonce: nonce, UserID: userID})
+	if err != nil {
+		return "", "", errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	expiration := time.Duration(configs.C.OIDC.StateExpiration) * time.Second
+	if err := a.Cacher.Set(ctx, gCacheNSForOIDCState, state, string(byt), expiration); err != nil {
+		return "", "", errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	return authURL, state, nil
+}
+
+// Start a login with the provider, the state must be bound to the user agent (e.g. by a cookie)
+// and compared with the one of the callback, so a login started by another one can not be completed.
+func (a *OIDC) LoginURL(ctx context.Context, name string) (string, string, error) {
+	return a.authURL(ctx, name, "")
+}
+
+// Start linking an identity of the provider to the current user.
+func (a *OIDC) LinkURL(ctx context.Context, name string) (*dtos.OIDCAuthURL, error) {
+	authURL, _, err := a.authURL(ctx, name, helper.GetUserID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.OIDCAuthURL{URL: authURL}, nil
+}
+
+// Complete the sign-on redirected back from the provider, the user ID is empty for a login.
+// A login returns the same result as the password login, while linking an identity returns nil.
+func (a *OIDC) Callback(ctx context.Context, name string, req *dtos.OIDCCallbackReq, userID string) (*dtos.LoginResult, error) {
+	ctx = logger.WithTag(ctx, logger.Tag_Login)
+
+	if req.State == "" {
+		return nil, errorx.ErrOIDCStateInvalid.New(ctx)
+	}
+
+	// the state can only be used once
+	val, err := a.Cacher.GetAndDelete(ctx, gCacheNSForOIDCState, req.State)
+	if err != nil {
+		if err == cachex.ErrNotFound {
+			return nil, errorx.ErrOIDCStateInvalid.New(ctx)
+		}
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	var state oidcState
+	if err := json.Unmarshal([]byte(val), &state); err != nil || state.Provider != name {
+		return nil, errorx.ErrOIDCStateInvalid.New(ctx)
+	}
+
+	// a linking must be completed by the user who started it, otherwise a victim could be
+	// tricked into linking its identity to the account of an attacker
+	if state.UserID != userID {
+		return nil, errorx.ErrOIDCStateInvalid.New(ctx)
+	}
+
+	cfg, provider, err := a.provider(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Error != "" || req.Code == "" {
+		logger.Warn(ctx, "Single sign-on rejected by provider", map[string]any{
+			"provider":    name,
+			"error":       req.Error,
+			"description": req.ErrorDescription,
+		})
+		return nil, errorx.ErrOIDCAuthFailed.New(ctx)
+	}
+
+	token, err := provider.Exchange(ctx, req.Code, state.Verifier)
+	if err != nil {
+		return nil, errorx.ErrOIDCAuthFailed.New(ctx).Wrap(err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, state.Nonce)
+	if err != nil {
+		return nil, errorx.ErrOIDCAuthFailed.New(ctx).Wrap(err)
+	}
+
+	if state.UserID != "" {
+		return nil, a.link(logger.WithUserID(ctx, state.UserID), cfg, state.UserID, claims)
+	}
+	return a.login(ctx, cfg, claims)
+}
+
+func (a *OIDC) link(ctx context.Context, cfg *configs.OIDCProvider, userID string, claims *oidcx.Claims) error {
+	identity, err := a.IdentityRepo.GetBySubject(ctx, cfg.Name, claims.Subject)
+	if err == nil {
+		if identity.UserID != userID {
+			return errorx.ErrOIDCIdentityLinked.New(ctx)
+		}
+		return nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	identity = &models.UserIdentity{
+		ID:        randx.NewXID(),
+		UserID:    userID,
+		Provider:  cfg.Name,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: time.Now(),
+	}
+	if err := a.IdentityRepo.Create(ctx, identity); err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	logger.Info(ctx, "External identity linked", map[string]any{
+		"provider": cfg.Name,
+		"subject":  claims.Subject,
+	})
+	return nil
+}
+
+func (a *OIDC) login(ctx context.Context, cfg *configs.OIDCProvider, claims *oidcx.Claims) (*dtos.LoginResult, error) {
+	var user *models.User
+
+	identity, err := a.IdentityRepo.GetBySubject(ctx, cfg.Name, claims.Subject)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorx.WrapGormError(ctx, err)
+		}
+		if !cfg.AutoCreate {
+			logger.Warn(ctx, "Single sign-on with unlinked identity", map[string]any{
+				"provider": cfg.Name,
+				"subject":  claims.Subject,
+				"email":    claims.Email,
+			})
+			return nil, errorx.ErrOIDCIdentityNotLinked.New(ctx)
+		}
+
+		user, identity, err = a.provision(ctx, cfg, claims)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		user, err = a.UserRepo.Get(ctx, identity.UserID, gormx.WithSelect("id", "username", "status", "type", "mfa_enabled"))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errorx.ErrOIDCIdentityNotLinked.New(ctx)
+			}
+			return nil, errorx.WrapGormError(ctx, err)
+		}
+	}
+
+	if user.Status != models.UserStatus_Activated {
+		return nil, errorx.ErrUserDisabled.New(ctx, struct{ Name string }{user.Username})
+	}
+	if user.Type == models.UserType_Service {
+		return nil, errorx.ErrServiceAccountLogin.New(ctx)
+	}
+
+	now := time.Now()
+	identity.LastLoginAt = &now
+	identity.Email = claims.Email
+	if err := a.IdentityRepo.Update(ctx, identity, gormx.WithSelect("LastLoginAt", "Email")); err != nil {
+		logger.Error(ctx, "Failed to update last login time of identity", err)
+	}
+
+	return a.AuthSvc.completeLogin(ctx, user)
+}
+
+// Create the user of the identity on its first sign-on, with the default roles of the provider.
+func (a *OIDC) provision(ctx context.Context, cfg *configs.OIDCProvider, claims *oidcx.Claims) (*models.User, *models.UserIdentity, error) {
+	usernameClaim := cfg.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = "preferred_username"
+	}
+
+	username := claims.String(usernameClaim)
+	if username == "" {
+		username = claims.Email
+	}
+	if username == "" {
+		username = cfg.Name + "_" + claims.Subject
+	}
+
+	// an existing account is never taken over by a new identity, it must be linked by its owner
+	exists, err := a.UserRepo.ExistsUsername(ctx, username)
+	if err != nil {
+		return nil, nil, errorx.WrapGormError(ctx, err)
+	} else if exists || username == configs.C.Super.Username {
+		return nil, nil, errorx.ErrUserExists.New(ctx, struct{ Name string }{Name: username})
+	}
+
+	var roles models.Roles
+	if len(cfg.DefaultRoles) > 0 {
+		roles, err = a.RoleRepo.Find(ctx, gormx.WithWhere("code IN ?", cfg.DefaultRoles))
+		if err != nil {
+			return nil, nil, errorx.WrapGormError(ctx, err)
+		}
+	}
+
+	nickName := claims.Name
+	if nickName == "" {
+		nickName = username
+	}
+
+	now := time.Now()
+	user := &models.User{
+		ID:        randx.NewXID(),
+		Username:  username,
+		NickName:  nickName,
+		Email:     claims.Email,
+		Status:    models.UserStatus_Activated,
+		Type:      models.UserType_User,
+		Roles:     roles,
+		CreatedAt: now,
+	}
+
+	identity := &models.UserIdentity{
+		ID:        randx.NewXID(),
+		UserID:    user.ID,
+		Provider:  cfg.Name,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: now,
+	}
+
+	err = a.UserRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.UserRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		if err := a.IdentityRepo.Create(ctx, identity); err != nil {
+			return err
+		}
+
+		changes := models.DiffFields(nil, user, userAuditFields...)
+		changes = append(changes, &models.AuditChange{Field: "roleIds", New: roles.ToIDs()})
+		return a.AuditSvc.Record(ctx, models.AuditAction_Create, models.AuditEntity_User, user.ID, changes)
+	})
+	if err != nil {
+		return nil, nil, errorx.WrapGormError(ctx, err)
+	}
+
+	logger.Info(logger.WithUserID(ctx, user.ID), "User provisioned by single sign-on", map[string]any{
+		"provider": cfg.Name,
+		"subject":  claims.Subject,
+		"username": username,
+		"roles":    cfg.DefaultRoles,
+	})
+
+	return user, identity, nil
+}
+
+// List external identities linked to the user.
+func (a *OIDC) ListIdentities(ctx context.Context, userID string) (models.UserIdentities, error) {
+	list, err := a.IdentityRepo.Find(ctx, gormx.WithWhere("user_id = ?", userID), gormx.WithOrder("created_at", "asc"))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+	return list, nil
+}
+
+// Unlink the external identity from the user.
+func (a *OIDC) Unlink(ctx context.Context, userID, id string) error {
+	identity, err := a.IdentityRepo.First(ctx, gormx.WithWhere("id = ? AND user_id = ?", id, userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errorx.ErrIdentityNotFound.New(ctx)
+		}
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	if err := a.IdentityRepo.Delete(ctx, identity.ID); err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	logger.Info(logger.WithTag(ctx, logger.Tag_Operate), "External identity unlinked", map[string]any{
+		"provider": identity.Provider,
+		"subject":  identity.Subject,
+	})
+	return nil
+}
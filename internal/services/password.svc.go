@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/mails"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/crypto/hash"
+	"gin-admin/pkg/crypto/rand"
+	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/logger"
+	"gin-admin/pkg/mail"
+
+	"gorm.io/gorm"
+)
+
+const (
+	gCacheNSForPasswordChange = "password_change"
+	gCacheNSForPasswordReset  = "password_reset"
+)
+
+// Interval between two reset mails to the same user.
+const passwordResetInterval = time.Minute
+
+// Fields of the user written when the password is changed.
+var passwordFields = []string{"Password", "PasswordHistory", "PasswordChangedAt", "MustChangePassword"}
+
+// Password policy
+type Password struct {
+	Cacher   cachex.Cacher
+	Mailer   *mail.SmtpSender
+	UserRepo *repositories.User
+	GuardSvc *LoginGuard
+}
+
+func NewPassword(app types.AppContext) *Password {
+	return &Password{
+		Cacher:   app.Cacher(),
+		Mailer:   app.Mailer(),
+		UserRepo: repositories.NewUser(app.DB()),
+		GuardSvc: NewLoginGuard(app),
+	}
+}
+
+// Check the plaintext password against the length and complexity rules of the policy.
+func (a *Password) Validate(ctx context.Context, username, password string) error {
+	policy := configs.C.Password
+
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		return errorx.ErrPasswordTooShort.New(ctx, struct{ Length int }{policy.MinLength})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, ok := range []bool{upper, lower, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < policy.MinCharClasses {
+		return errorx.ErrPasswordTooWeak.New(ctx, struct{ Classes int }{policy.MinCharClasses})
+	}
+
+	if policy.RejectUsername && username != "" &&
+		strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return errorx.ErrPasswordContainsUsername.New(ctx)
+	}
+
+	return nil
+}
+
+// Check the policy and set the plaintext password to the user, the current password is moved into the history.
+// The user must be loaded with its password and history, the changed fields are returned for the update.
+func (a *Password) Set(ctx context.Context, user *models.User, password string, mustChange bool) ([]string, error) {
+	if err := a.Validate(ctx, user.Username, password); err != nil {
+		return nil, err
+	}
+
+	// the password is stored in the same form as the client sends on login
+	password = hash.MD5String(password)
+
+	if count := configs.C.Password.History; count > 0 {
+		for _, hashed := range a.recent(user, count) {
+			if hash.CompareHashAndPassword(hashed, password) == nil {
+				return nil, errorx.ErrPasswordReused.New(ctx, struct{ Count int }{count})
+			}
+		}
+	}
+
+	return a.set(ctx, user, password, mustChange)
+}
+
+// Reset the password of the user to the default login password, the policy is not applied.
+func (a *Password) Reset(ctx context.Context, user *models.User, mustChange bool) ([]string, error) {
+	return a.set(ctx, user, configs.C.DefaultLoginPwd, mustChange)
+}
+
+// The current and previous password hashes, newest first.
+func (a *Password) recent(user *models.User, count int) []string {
+	var hashes []string
+	if user.Password != "" {
+		hashes = append(hashes, user.Password)
+	}
+	for _, hashed := range user.PasswordHistory {
+		if len(hashes) >= count {
+			break
+		}
+		hashes = append(hashes, hashed)
+	}
+	return hashes
+}
+
+func (a *Password) set(ctx context.Context, user *models.User, password string, mustChange bool) ([]string, error) {
+	hashPass, err := hash.GeneratePassword(password)
+	if err != nil {
+		return nil, errorx.ErrPasswordEncrypt.New(ctx).Wrap(err)
+	}
+
+	// the current password counts as one of the history
+	history := []string{}
+	if count := configs.C.Password.History - 1; count > 0 {
+		history = a.recent(user, count)
+	}
+
+	now := time.Now()
+	user.Password = hashPass
+	user.PasswordHistory = history
+	user.PasswordChangedAt = &now
+	user.MustChangePassword = mustChange
+
+	return passwordFields, nil
+}
+
+// The reason why the user must change the password before login, empty if not required.
+func (a *Password) ChangeReason(user *models.User) string {
+	if user.MustChangePassword {
+		return dtos.PasswordChangeReason_Required
+	}
+	if user.PasswordExpired(time.Now()) {
+		return dtos.PasswordChangeReason_Expired
+	}
+	return ""
+}
+
+// Issue a short-lived challenge for the user who passed the password check but must change the password.
+func (a *Password) Challenge(ctx context.Context, userID, reason string) (*dtos.PasswordChallenge, error) {
+	token, err := rand.Random(32, rand.LdigitAndLetter)
+	if err != nil {
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	expiration := configs.C.Password.ChallengeExpiration
+	if err := a.Cacher.Set(ctx, gCacheNSForPasswordChange, token, userID, time.Duration(expiration)*time.Second); err != nil {
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	return &dtos.PasswordChallenge{
+		Token:   token,
+		Expires: int64(expiration),
+		Reason:  reason,
+	}, nil
+}
+
+// Change the password with the challenge, the challenge is kept until the new password satisfies the policy.
+func (a *Password) Change(ctx context.Context, req *dtos.PasswordChangeReq) (*models.User, error) {
+	userID, err := a.Cacher.Get(ctx, gCacheNSForPasswordChange, req.Token)
+	if err != nil {
+		if errors.Is(err, cachex.ErrNotFound) {
+			return nil, errorx.ErrPasswordChangeInvalid.New(ctx)
+		}
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	ctx = logger.WithUserID(ctx, userID)
+
+	user, err := a.UserRepo.Get(ctx, userID, gormx.WithSelect("id", "username", "password", "password_history", "status", "type", "mfa_enabled"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorx.ErrPasswordChangeInvalid.New(ctx)
+		}
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	fields, err := a.Set(ctx, user, req.NewPassword, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// the challenge is consumed atomically, so the concurrent changes with it can not both pass
+	if _, err := a.Cacher.GetAndDelete(ctx, gCacheNSForPasswordChange, req.Token); err != nil {
+		if errors.Is(err, cachex.ErrNotFound) {
+			return nil, errorx.ErrPasswordChangeInvalid.New(ctx)
+		}
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	if err := a.UserRepo.Update(ctx, user, gormx.WithSelect(fields)); err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	logger.Info(logger.WithTag(ctx, logger.Tag_Operate), "Password changed")
+	return user, nil
+}
+
+// Mail a reset code to the users with the email.
+// The result does not tell whether any user matches, the mails are sent in background to keep the response time alike.
+func (a *Password) Forgot(ctx context.Context, req *dtos.PasswordForgotReq) error {
+	if a.Mailer == nil {
+		return errorx.ErrPasswordResetUnavailable.New(ctx)
+	}
+
+	users, err := a.UserRepo.Find(ctx,
+		gormx.WithWhere("email = ? AND status = ? AND type = ? AND id <> ?", req.Email, models.UserStatus_Activated, models.UserType_User, configs.C.Super.ID),
+		gormx.WithSelect("id", "username", "nick_name", "email"),
+	)
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		for _, user := range users {
+			if err := a.sendResetCode(ctx, user); err != nil {
+				logger.Error(logger.WithUserID(ctx, user.ID), "Failed to send password reset mail", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (a *Password) sendResetCode(ctx context.Context, user *models.User) error {
+	// one mail per interval to the same user, further requests (even concurrent ones) are ignored
+	sentKey := "sent:" + user.ID
+	if ok, err := a.Cacher.SetNX(ctx, gCacheNSForPasswordReset, sentKey, "1", passwordResetInterval); err != nil || !ok {
+		return err
+	}
+
+	code, err := rand.Random(32, rand.LdigitAndLetter)
+	if err != nil {
+		return err
+	}
+
+	// only the hash of the code is stored
+	expiration := configs.C.Password.ResetExpiration
+	if err := a.Cacher.Set(ctx, gCacheNSForPasswordReset, hash.SHA256String(code), user.ID, time.Duration(expiration)*time.Second); err != nil {
+		return err
+	}
+
+	data := mails.PasswordResetData{
+		Name:     user.NickName,
+		Username: user.Username,
+		Code:     code,
+		Minutes:  expiration / 60,
+	}
+	if data.Name == "" {
+		data.Name = user.Username
+	}
+	if base := configs.C.Password.ResetURL; base != "" {
+		sep := "?"
+		if strings.Contains(base, "?") {
+			sep = "&"
+		}
+		data.URL = base + sep + "token=" + url.QueryEscape(code)
+	}
+
+	m := mails.PasswordReset(ctx, data)
+	if err := a.Mailer.SendTo(ctx, []string{user.Email}, m.Subject, m.Body); err != nil {
+		return err
+	}
+
+	logger.Info(logger.WithTag(logger.WithUserID(ctx, user.ID), logger.Tag_Operate), "Password reset mail sent")
+	return nil
+}
+
+// Set the new password on the user of the mailed code, the code can only be used once.
+// The returned fields are not saved yet, Auth.ResetPassword saves them together with the token revocation.
+func (a *Password) ResetWithCode(ctx context.Context, req *dtos.PasswordResetReq) (*models.User, []string, error) {
+	key := hash.SHA256String(req.Token)
+
+	userID, err := a.Cacher.Get(ctx, gCacheNSForPasswordReset, key)
+	if err != nil {
+		if errors.Is(err, cachex.ErrNotFound) {
+			return nil, nil, errorx.ErrPasswordResetInvalid.New(ctx)
+		}
+		return nil, nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	user, err := a.UserRepo.Get(ctx, userID, gormx.WithSelect("id", "username", "password", "password_history", "status"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errorx.ErrPasswordResetInvalid.New(ctx)
+		}
+		return nil, nil, errorx.WrapGormError(ctx, err)
+	}
+
+	if user.Status != models.UserStatus_Activated {
+		return nil, nil, errorx.ErrPasswordResetInvalid.New(ctx)
+	}
+
+	// the code is kept until the new password satisfies the policy
+	fields, err := a.Set(ctx, user, req.NewPassword, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := a.Cacher.GetAndDelete(ctx, gCacheNSForPasswordReset, key); err != nil {
+		if errors.Is(err, cachex.ErrNotFound) {
+			return nil, nil, errorx.ErrPasswordResetInvalid.New(ctx)
+		}
+		return nil, nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+	return user, fields, nil
+}
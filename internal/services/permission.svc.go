@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permission checks of the current user
+type Permission struct {
+	Casbin       types.Casbinx
+	MenuRepo     *repositories.Menu
+	MenuRoleRepo *repositories.MenuRole
+	UserSvc      *User
+	RoleSvc      *Role
+	Routes       func() gin.RoutesInfo // Registered routes, the policies are granted on their templates
+}
+
+func NewPermission(app types.AppContext) *Permission {
+	return &Permission{
+		Casbin:       app.Casbin(),
+		MenuRepo:     repositories.NewMenu(app.DB()),
+		MenuRoleRepo: repositories.NewMenuRole(app.DB()),
+		UserSvc:      NewUser(app),
+		RoleSvc:      NewRole(app),
+	}
+}
+
+// Check the APIs and button permission codes against the live policies, the results keep the order of the items.
+// A code is allowed if any enabled button with the code is granted and its API, if any, passes the policies.
+func (a *Permission) Check(ctx context.Context, req *dtos.PermissionCheckReq) ([]*dtos.PermissionCheckResult, error) {
+	results := make([]*dtos.PermissionCheckResult, len(req.Items))
+	for i, item := range req.Items {
+		results[i] = &dtos.PermissionCheckResult{PermissionCheckItem: *item}
+	}
+
+	if helper.GetIsRootUser(ctx) {
+		codes, err := a.buttonCodes(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range results {
+			result.Allowed = result.Code == "" || slices.Contains(codes, result.Code)
+		}
+		return results, nil
+	}
+
+	enforce, err := a.enforcer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		buttons models.Menus
+		loaded  bool
+	)
+	for _, result := range results {
+		if result.Code == "" {
+			if result.Allowed, err = enforce(result.Path, result.Method); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !loaded {
+			if buttons, err = a.grantedButtons(ctx); err != nil {
+				return nil, err
+			}
+			loaded = true
+		}
+		for _, button := range buttons {
+			if button.Code != result.Code {
+				continue
+			}
+			if button.Path == "" || button.Method == "" {
+				result.Allowed = true
+			} else if result.Allowed, err = enforce(button.Path, button.Method); err != nil {
+				return nil, err
+			}
+			if result.Allowed {
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+// Query the permission codes of the enabled buttons granted to the current user, the inherited ones included
+// and the ones with unmet grant conditions left out.
+func (a *Permission) QueryCodes(ctx context.Context) ([]string, error) {
+	if helper.GetIsRootUser(ctx) {
+		return a.buttonCodes(ctx, nil)
+	}
+
+	buttons, err := a.grantedButtons(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return a.buttonCodes(ctx, buttons)
+}
+
+// Get the sorted and distinct codes of the buttons, all the enabled buttons are queried if nil.
+func (a *Permission) buttonCodes(ctx context.Context, buttons models.Menus) ([]string, error) {
+	if buttons == nil {
+		var err error
+		buttons, err = a.MenuRepo.Find(ctx, gormx.WithSelect("code"),
+			gormx.WithWhere("type = ? AND status = ? AND code != ''", models.MenuType_BUTTON, models.MenuStatus_ENABLED))
+		if err != nil {
+			return nil, errorx.WrapGormError(ctx, err)
+		}
+	}
+
+	codes := []string{}
+	for _, button := range buttons {
+		if button.Code != "" {
+			codes = append(codes, button.Code)
+		}
+	}
+	slices.Sort(codes)
+	return slices.Compact(codes), nil
+}
+
+// Get the enabled buttons granted to the same roles the policies are enforced for, that is the enabled roles
+// of the user limited by the API key and their enabled parent roles. The grants with unmet conditions are left out.
+func (a *Permission) grantedButtons(ctx context.Context) (models.Menus, error) {
+	roleIDs, err := a.roleIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if roleIDs, err = a.RoleSvc.WithAncestors(ctx, roleIDs...); err != nil {
+		return nil, err
+	} else if len(roleIDs) == 0 {
+		return models.Menus{}, nil
+	}
+
+	// the same as the policies, the grants are loaded regardless of the tenant
+	grants, err := a.MenuRoleRepo.Find(helper.WithoutTenant(ctx), gormx.WithSelect("menu_id", "grant_condition"),
+		gormx.WithWhere("role_id IN (?)", roleIDs))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	attrs := grantAttributes(ctx)
+	var menuIDs []string
+	for _, grant := range grants {
+		if grant.Condition.Match(attrs) {
+			menuIDs = append(menuIDs, grant.MenuID)
+		}
+	}
+	if len(menuIDs) == 0 {
+		return models.Menus{}, nil
+	}
+
+	buttons, err := a.MenuRepo.Find(ctx, gormx.WithSelect("code", "path", "method"),
+		gormx.WithWhere("id IN (?) AND type = ? AND status = ?", menuIDs, models.MenuType_BUTTON, models.MenuStatus_ENABLED))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+	return buttons, nil
+}
+
+// Get the attributes of the request the grant conditions are evaluated against.
+func grantAttributes(ctx context.Context) models.GrantAttributes {
+	return models.GrantAttributes{
+		Time:        time.Now(),
+		ClientIP:    helper.GetClientIP(ctx),
+		MFAVerified: helper.GetMFAVerified(ctx),
+	}
+}
+
+// Get a function enforcing the APIs for the roles of the current user with the request attributes.
+func (a *Permission) enforcer(ctx context.Context) (func(path, method string) (bool, error), error) {
+	if configs.C.Middleware.Casbin.Disable {
+		return func(string, string) (bool, error) { return true, nil }, nil
+	}
+
+	e := a.Casbin.GetEnforcer()
+	if e == nil {
+		return func(string, string) (bool, error) { return false, nil }, nil
+	}
+
+	roleIDs, err := a.roleIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := grantAttributes(ctx)
+	var routes gin.RoutesInfo
+	if a.Routes != nil {
+		routes = a.Routes()
+	}
+
+	return func(path, method string) (bool, error) {
+		path = routeTemplate(routes, method, models.ToRoutePath(path))
+		for _, roleID := range roleIDs {
+			if ok, err := e.Enforce(roleID, path, method, attrs); err != nil {
+				return false, errorx.ErrInternal.New(ctx).Wrap(err)
+			} else if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	}, nil
+}
+
+// Get the roles of the current user, limited to the granted roles of the API key if authenticated by one.
+func (a *Permission) roleIDs(ctx context.Context) ([]string, error) {
+	userID := helper.GetUserID(ctx)
+	roleIDs, err := a.UserSvc.GetRoleIDsCache(ctx, userID)
+	if err != nil {
+		if roleIDs, err = a.UserSvc.GetRoleIDs(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if scoped, ok := helper.GetAPIKeyRoleIDs(ctx); ok {
+		roleIDs = slices.DeleteFunc(roleIDs, func(id string) bool {
+			return !slices.Contains(scoped, id)
+		})
+	}
+	return roleIDs, nil
+}
+
+// Resolve the path to the template of the registered route serving it, the path is kept if no route matches.
+// Static segments take precedence over the parameters, and the parameters over the wildcards, the same as gin.
+func routeTemplate(routes gin.RoutesInfo, method, path string) string {
+	segments := strings.Split(path, "/")
+
+	template, best := path, []int(nil)
+	for _, route := range routes {
+		if route.Method != method {
+			continue
+		}
+		if route.Path == path {
+			return path
+		}
+		if rank, ok := matchRoute(strings.Split(route.Path, "/"), segments); ok && (best == nil || slices.Compare(rank, best) < 0) {
+			template, best = route.Path, rank
+		}
+	}
+	return template
+}
+
+// Match the path segments against the segments of the route template, the lower rank the more specific the match.
+func matchRoute(pattern, segments []string) ([]int, bool) {
+	rank := make([]int, 0, len(pattern))
+	for i, p := range pattern {
+		switch {
+		case strings.HasPrefix(p, "*"):
+			return append(rank, 2), i <= len(segments)
+		case i >= len(segments):
+			return nil, false
+		case strings.HasPrefix(p, ":"):
+			if segments[i] == "" {
+				return nil, false
+			}
+			rank = append(rank, 1)
+		case p != segments[i]:
+			return nil, false
+		default:
+			rank = append(rank, 0)
+		}
+	}
+	return rank, len(pattern) == len(segments)
+}
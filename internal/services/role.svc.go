@@ -2,8 +2,9 @@ package services
 
 import (
 	"context"
-	"fmt"
-	"strconv"
+	"errors"
+	"reflect"
+	"slices"
 	"time"
 
 	"gin-admin/internal/dtos"
@@ -13,31 +14,36 @@ import (
 	"gin-admin/internal/types"
 	"gin-admin/pkg/cachex"
 	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
 	"gin-admin/pkg/randx"
 
 	"github.com/epkgs/object"
 	"gorm.io/gorm"
 )
 
-const (
-	gCacheKeyForCasbin = "sync:casbin"
-	gCacheNSForRole    = "role"
-)
+// Fields of the role recorded by the audit trail of the deleted role
+var roleAuditFields = []string{"Code", "Name", "Description", "Rank", "Status", "ParentID", "MFARequired", "DataScope", "DataDeptIDs"}
 
 // Role management for SYS
 type Role struct {
 	Cacher       cachex.Cacher
+	Casbin       types.Casbinx
 	RoleRepo     *repositories.Role
 	MenuRepo     *repositories.Menu
+	MenuRoleRepo *repositories.MenuRole
 	UserRoleRepo *repositories.UserRole
+	AuditSvc     *Audit
 }
 
 func NewRole(app types.AppContext) *Role {
 	return &Role{
 		Cacher:       app.Cacher(),
+		Casbin:       app.Casbin(),
 		RoleRepo:     repositories.NewRole(app.DB()),
 		MenuRepo:     repositories.NewMenu(app.DB()),
+		MenuRoleRepo: repositories.NewMenuRole(app.DB()),
 		UserRoleRepo: repositories.NewUserRole(app.DB()),
+		AuditSvc:     NewAudit(app),
 	}
 }
 
@@ -73,13 +79,30 @@ func (a *Role) List(ctx context.Context, req dtos.RoleListReq) (*dtos.List[*mode
 	return result, nil
 }
 
-// Get the specified role from the data access object.s
+// Get the specified role from the data access object, with the menus inherited from the parent roles.
 func (a *Role) Get(ctx context.Context, id string) (*models.Role, error) {
 	role, err := a.RoleRepo.Get(ctx, id, gormx.WithPreload("Menus"))
 	if err != nil {
 		return nil, errorx.WrapGormError(ctx, err)
 	}
 
+	roleIDs, err := a.WithAncestors(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	menuRoleQuery := a.MenuRoleRepo.DB().Model(new(models.MenuRole)).Where("role_id IN (?)", roleIDs).Select("menu_id")
+	menus, err := a.MenuRepo.Find(ctx, gormx.WithWhere("id IN (?)", menuRoleQuery), gormx.WithOrder("rank", "desc"), gormx.WithOrder("created_at", "desc"))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+	role.EffectiveMenus = menus
+
+	role.MenuConditions, err = a.menuConditions(ctx, id)
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
 	return role, nil
 }
 
@@ -91,13 +114,31 @@ func (a *Role) Create(ctx context.Context, req dtos.RoleCreateReq) (*models.Role
 		return nil, errorx.ErrRoleCodeExists.New(ctx)
 	}
 
+	if req.ParentID != "" {
+		if exists, err := a.RoleRepo.Exists(ctx, gormx.WithWhere("id = ?", req.ParentID)); err != nil {
+			return nil, errorx.WrapGormError(ctx, err)
+		} else if !exists {
+			return nil, errorx.ErrRoleNotFount.New(ctx)
+		}
+	}
+
+	if err := a.checkMenuConditions(ctx, req.MenuConditions); err != nil {
+		return nil, err
+	}
+
 	role := &models.Role{
 		ID:        randx.NewXID(),
 		CreatedAt: time.Now(),
 	}
 
+	if req.DataScope == "" {
+		req.DataScope = models.RoleDataScope_All
+	}
+
+	var md object.Metadata
 	if err := object.Assign(role, req, func(c *object.AssignConfig) {
-		c.SkipKeys = []string{"Menus"}
+		c.SkipKeys = []string{"Menus", "MenuConditions"}
+		c.Metadata = &md
 	}); err != nil {
 		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
 	}
@@ -111,10 +152,37 @@ func (a *Role) Create(ctx context.Context, req dtos.RoleCreateReq) (*models.Role
 		role.Menus = menus
 	}
 
-	if err := a.RoleRepo.Create(ctx, role); err != nil {
+	err := a.RoleRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.RoleRepo.Create(ctx, role); err != nil {
+			return err
+		}
+
+		if len(req.MenuConditions) > 0 {
+			conditions, err := a.setMenuConditions(ctx, role.ID, req.MenuConditions)
+			if err != nil {
+				return err
+			}
+			role.MenuConditions = conditions
+		}
+
+		changes := models.DiffFields(nil, role, md.Keys...)
+		changes = append(changes,
+			&models.AuditChange{Field: "menuIds", New: req.MenuIDs},
+			&models.AuditChange{Field: "menuConditions", New: role.MenuConditions},
+		)
+		return a.AuditSvc.Record(ctx, models.AuditAction_Create, models.AuditEntity_Role, role.ID, changes)
+	})
+	if err != nil {
 		return nil, errorx.WrapGormError(ctx, err)
 	}
 
+	if len(role.Menus) > 0 || role.ParentID != "" {
+		if err := a.SyncPolicies(ctx, role.ID); err != nil {
+			return nil, err
+		}
+	}
+
 	return role, nil
 }
 
@@ -133,9 +201,39 @@ func (a *Role) Update(ctx context.Context, id string, req *dtos.RoleUpdateReq) e
 		}
 	}
 
+	if req.ParentID != nil && *req.ParentID != role.ParentID {
+		if err := a.checkParent(ctx, id, *req.ParentID); err != nil {
+			return err
+		}
+	}
+
+	if err := a.checkMenuConditions(ctx, req.MenuConditions); err != nil {
+		return err
+	}
+
+	var oldMenuIDs []string
+	var oldConditions map[string]*models.GrantCondition
+	if req.MenuIDs != nil || req.MenuConditions != nil {
+		if oldMenuIDs, err = a.menuIDs(ctx, id); err != nil {
+			return errorx.WrapGormError(ctx, err)
+		}
+		if oldConditions, err = a.menuConditions(ctx, id); err != nil {
+			return errorx.WrapGormError(ctx, err)
+		}
+	}
+
+	conditions := req.MenuConditions
+	if conditions == nil && req.MenuIDs != nil {
+		// the conditions of the menus still granted are kept
+		conditions = oldConditions
+	}
+
+	statusChanged := req.Status != nil && *req.Status != role.Status
+
+	before := *role
 	var md object.Metadata
 	if err := object.Assign(role, req, func(c *object.AssignConfig) {
-		c.SkipKeys = []string{"menus"}
+		c.SkipKeys = []string{"menus", "MenuConditions"}
 		c.Metadata = &md
 	}); err != nil {
 		return err
@@ -155,52 +253,242 @@ func (a *Role) Update(ctx context.Context, id string, req *dtos.RoleUpdateReq) e
 	role.UpdatedAt = time.Now()
 
 	err = a.RoleRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		// the menus are replaced, saving the association only appends the new ones
+		if req.MenuIDs != nil {
+			if err := a.MenuRoleRepo.DeleteByRoleID(ctx, id); err != nil {
+				return err
+			}
+		}
+
 		if err := a.RoleRepo.Update(ctx, role, gormx.WithOmit("Menus.*"), gormx.WithSelect(selected)); err != nil {
 			return err
 		}
 
-		return a.RefreshUpdateTime(ctx)
+		changes := models.DiffFields(&before, role, md.Keys...)
+		if req.MenuIDs != nil && !sameIDs(oldMenuIDs, *req.MenuIDs) {
+			changes = append(changes, &models.AuditChange{Field: "menuIds", Old: oldMenuIDs, New: *req.MenuIDs})
+		}
+
+		if conditions != nil {
+			newConditions, err := a.setMenuConditions(ctx, id, conditions)
+			if err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(oldConditions, newConditions) {
+				changes = append(changes, &models.AuditChange{Field: "menuConditions", Old: oldConditions, New: newConditions})
+			}
+		}
+
+		if err := a.AuditSvc.Record(ctx, models.AuditAction_Update, models.AuditEntity_Role, id, changes); err != nil {
+			return err
+		}
+
+		if statusChanged {
+			if err := a.deleteUserRolesCache(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		return nil
 	})
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
 
-	return errorx.WrapGormError(ctx, err)
+	return a.SyncPolicies(ctx, id)
 }
 
 // Delete the specified role from the data access object.
 func (a *Role) Delete(ctx context.Context, id string) error {
-	exists, err := a.RoleRepo.Exists(ctx, gormx.WithWhere("id = ?", id))
+	role, err := a.RoleRepo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errorx.ErrRoleNotFount.New(ctx)
+		}
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	menuIDs, err := a.menuIDs(ctx, id)
 	if err != nil {
 		return errorx.WrapGormError(ctx, err)
-	} else if !exists {
-		return errorx.ErrRoleNotFount.New(ctx)
+	}
+
+	changes := models.DiffFields(role, nil, roleAuditFields...)
+	if len(menuIDs) > 0 {
+		changes = append(changes, &models.AuditChange{Field: "menuIds", Old: menuIDs})
 	}
 
 	err = a.RoleRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		// the users must be collected before their relations are deleted
+		if err := a.deleteUserRolesCache(ctx, id); err != nil {
+			return err
+		}
 		if err := a.RoleRepo.Delete(ctx, id, gormx.WithSelect("Menus", "Users")); err != nil {
 			return err
 		}
-		return a.RefreshUpdateTime(ctx)
+		// the sub roles stop inheriting from the deleted role
+		if err := a.RoleRepo.UpdateParentID(ctx, id, ""); err != nil {
+			return err
+		}
+		return a.AuditSvc.Record(ctx, models.AuditAction_Delete, models.AuditEntity_Role, id, changes)
 	})
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	return a.SyncPolicies(ctx, id)
+}
+
+// Get the enabled roles together with all their enabled parent roles, the inheritance stops at a disabled role.
+func (a *Role) WithAncestors(ctx context.Context, roleIDs ...string) ([]string, error) {
+	result := []string{}
+	if len(roleIDs) == 0 {
+		return result, nil
+	}
+
+	roles, err := a.RoleRepo.Find(ctx, gormx.WithSelect("id", "parent_id"), gormx.WithWhere("id IN (?) AND status = ?", roleIDs, models.RoleStatus_Enabled))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+	for _, role := range roles {
+		result = append(result, role.ID)
+	}
 
-	return errorx.WrapGormError(ctx, err)
+	for len(roles) > 0 {
+		var parentIDs []string
+		for _, role := range roles {
+			if role.ParentID != "" && !slices.Contains(result, role.ParentID) && !slices.Contains(parentIDs, role.ParentID) {
+				parentIDs = append(parentIDs, role.ParentID)
+			}
+		}
+		if len(parentIDs) == 0 {
+			break
+		}
+
+		roles, err = a.RoleRepo.Find(ctx, gormx.WithSelect("id", "parent_id"), gormx.WithWhere("id IN (?) AND status = ?", parentIDs, models.RoleStatus_Enabled))
+		if err != nil {
+			return nil, errorx.WrapGormError(ctx, err)
+		}
+		for _, role := range roles {
+			result = append(result, role.ID)
+		}
+	}
+
+	return result, nil
 }
 
-func (a *Role) RefreshUpdateTime(ctx context.Context) error {
-	return a.Cacher.Set(ctx, gCacheNSForRole, gCacheKeyForCasbin, fmt.Sprintf("%d", time.Now().Unix()))
+// Check the parent role exists and is not the role itself or one of its sub roles.
+func (a *Role) checkParent(ctx context.Context, id, parentID string) error {
+	var visited []string
+	for parentID != "" && !slices.Contains(visited, parentID) {
+		if parentID == id {
+			return errorx.ErrRoleInheritCycle.New(ctx)
+		}
+		visited = append(visited, parentID)
+
+		parent, err := a.RoleRepo.Get(ctx, parentID, gormx.WithSelect("id", "parent_id"))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errorx.ErrRoleNotFount.New(ctx)
+			}
+			return errorx.WrapGormError(ctx, err)
+		}
+		parentID = parent.ParentID
+	}
+	return nil
 }
 
-func (a *Role) GetUpdateTime(ctx context.Context) (int64, error) {
-	val, err := a.Cacher.Get(ctx, gCacheNSForRole, gCacheKeyForCasbin)
+// Check the time windows and IP ranges of the menu grant conditions are well formed.
+func (a *Role) checkMenuConditions(ctx context.Context, conditions map[string]*models.GrantCondition) error {
+	for _, condition := range conditions {
+		if !condition.Validate() {
+			return errorx.ErrGrantConditionInvalid.New(ctx)
+		}
+	}
+	return nil
+}
+
+// Get the IDs of the menus granted to the role.
+func (a *Role) menuIDs(ctx context.Context, roleID string) ([]string, error) {
+	menuRoles, err := a.MenuRoleRepo.Find(ctx, gormx.WithSelect("menu_id"), gormx.WithWhere("role_id = ?", roleID))
 	if err != nil {
-		if err == cachex.ErrNotFound {
-			return 0, errorx.ErrRecordNotFound.New(ctx).Wrap(err)
+		return nil, err
+	}
+
+	ids := make([]string, len(menuRoles))
+	for i, item := range menuRoles {
+		ids[i] = item.MenuID
+	}
+	return ids, nil
+}
+
+// Get the conditions of the menu grants of the role by menu ID, the grants without condition are excluded.
+func (a *Role) menuConditions(ctx context.Context, roleID string) (map[string]*models.GrantCondition, error) {
+	menuRoles, err := a.MenuRoleRepo.Find(ctx, gormx.WithSelect("menu_id", "grant_condition"), gormx.WithWhere("role_id = ?", roleID))
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := make(map[string]*models.GrantCondition)
+	for _, item := range menuRoles {
+		if !item.Condition.IsEmpty() {
+			conditions[item.MenuID] = item.Condition
 		}
-		return 0, err
 	}
+	return conditions, nil
+}
 
-	updated, err := strconv.ParseInt(val, 10, 64)
+// Set the conditions of the menu grants of the role, the grants missing in the conditions are unconditional.
+// The conditions of the granted menus are returned.
+func (a *Role) setMenuConditions(ctx context.Context, roleID string, conditions map[string]*models.GrantCondition) (map[string]*models.GrantCondition, error) {
+	menuRoles, err := a.MenuRoleRepo.Find(ctx, gormx.WithWhere("role_id = ?", roleID))
 	if err != nil {
-		return 0, errorx.ErrInternal.New(ctx).Wrap(err)
+		return nil, err
 	}
 
-	return updated, nil
+	result := make(map[string]*models.GrantCondition)
+	for _, item := range menuRoles {
+		condition := conditions[item.MenuID]
+		if condition.IsEmpty() {
+			condition = nil
+		} else {
+			result[item.MenuID] = condition
+		}
+
+		if condition == nil && item.Condition.IsEmpty() {
+			continue
+		}
+		if err := a.MenuRoleRepo.UpdateCondition(ctx, roleID, item.MenuID, condition); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Drop the cached role IDs of the users with the role, they are reloaded on the next request.
+func (a *Role) deleteUserRolesCache(ctx context.Context, roleID string) error {
+	userRoles, err := a.UserRoleRepo.Find(ctx, gormx.WithSelect("user_id"), gormx.WithWhere("role_id = ?", roleID))
+	if err != nil {
+		return err
+	}
+
+	for _, userRole := range userRoles {
+		if err := a.Cacher.Delete(ctx, gCacheNSForUserRoles, userRole.UserID); err != nil {
+			return err
+		}
+		if err := a.Cacher.Publish(ctx, UserRolesChannel, userRole.UserID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply the permission changes of the roles to the enforcers of all the instances.
+func (a *Role) SyncPolicies(ctx context.Context, roleIDs ...string) error {
+	if a.Casbin == nil {
+		return nil
+	}
+	return a.Casbin.ReloadRoles(ctx, roleIDs...)
 }
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
+	"gin-admin/pkg/logger"
+	"gin-admin/pkg/randx"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const gCacheNSForRouteSync = "route_sync"
+
+// Time the sync is locked for, the lock is released when the sync ends.
+const routeSyncLockTTL = time.Minute
+
+// Sync of the registered API routes into the button resources of menus
+type RouteSync struct {
+	Cacher       cachex.Cacher
+	MenuRepo     *repositories.Menu
+	MenuRoleRepo *repositories.MenuRole
+	RoleSvc      *Role
+	AuditSvc     *Audit
+}
+
+func NewRouteSync(app types.AppContext) *RouteSync {
+	return &RouteSync{
+		Cacher:       app.Cacher(),
+		MenuRepo:     repositories.NewMenu(app.DB()),
+		MenuRoleRepo: repositories.NewMenuRole(app.DB()),
+		RoleSvc:      NewRole(app),
+		AuditSvc:     NewAudit(app),
+	}
+}
+
+// Compare the routes with the button resources, the routes without a resource are created under the configured
+// catalog and the resources pointing to non-existent routes are disabled if enabled by the config.
+// The instances starting at the same time would create the same resources, only one of them syncs and the others skip it.
+func (a *RouteSync) Sync(ctx context.Context, routes gin.RoutesInfo) (*dtos.RouteSyncResult, error) {
+	cfg := configs.C.Menu.RouteSync
+
+	// the lock is only released by its holder, it may have expired and been taken by another instance
+	token := randx.NewXID()
+	locked, err := a.Cacher.SetNX(ctx, gCacheNSForRouteSync, "lock", token, routeSyncLockTTL)
+	if err != nil {
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	} else if !locked {
+		logger.Info(ctx, "Routes are being synced by another instance, skipped")
+		return new(dtos.RouteSyncResult), nil
+	}
+	defer func() {
+		if _, err := a.Cacher.DeleteIfEqual(ctx, gCacheNSForRouteSync, "lock", token); err != nil {
+			logger.Error(ctx, "Failed to release route sync lock", err)
+		}
+	}()
+
+	var registered []*dtos.Route
+	for _, route := range routes {
+		if a.inScope(route.Path) {
+			registered = append(registered, &dtos.Route{Method: route.Method, Path: route.Path})
+		}
+	}
+
+	buttons, err := a.MenuRepo.Find(ctx, gormx.WithWhere("type = ? AND path != '' AND method != ''", models.MenuType_BUTTON))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	// the resources are matched the same way as the policies are enforced
+	match := func(button *models.Menu, route *dtos.Route) bool {
+		return button.Method == route.Method && route.Path == models.ToRoutePath(button.Path)
+	}
+
+	result := new(dtos.RouteSyncResult)
+	for _, route := range registered {
+		if !slices.ContainsFunc(buttons, func(button *models.Menu) bool { return match(button, route) }) {
+			result.Missing = append(result.Missing, route)
+			logger.Warn(ctx, "Route has no button resource", map[string]any{"method": route.Method, "path": route.Path})
+		}
+	}
+	for _, button := range buttons {
+		if !a.inScope(models.ToRoutePath(button.Path)) {
+			continue
+		}
+		if !slices.ContainsFunc(registered, func(route *dtos.Route) bool { return match(button, route) }) {
+			result.Stale = append(result.Stale, button)
+			logger.Warn(ctx, "Button resource points to non-existent route", map[string]any{"id": button.ID, "method": button.Method, "path": button.Path})
+		}
+	}
+
+	if cfg.CreateMissing && len(result.Missing) > 0 {
+		err := a.MenuRepo.Transaction(ctx, func(tx *gorm.DB) (err error) {
+			result.Created, err = a.createButtons(helper.WithTrans(ctx, tx), result.Missing)
+			return err
+		})
+		if err != nil {
+			return nil, errorx.WrapGormError(ctx, err)
+		}
+	}
+
+	if cfg.DisableStale {
+		var disabledIDs []string
+		err := a.MenuRepo.Transaction(ctx, func(tx *gorm.DB) error {
+			ctx := helper.WithTrans(ctx, tx)
+			for _, button := range result.Stale {
+				if button.Status == models.MenuStatus_DISABLED {
+					continue
+				}
+				before := *button
+				button.Status = models.MenuStatus_DISABLED
+				button.UpdatedAt = time.Now()
+				if err := a.MenuRepo.Update(ctx, button, gormx.WithSelect("status", "updated_at")); err != nil {
+					return err
+				}
+				changes := models.DiffFields(&before, button, "Status")
+				if err := a.AuditSvc.Record(ctx, models.AuditAction_Update, models.AuditEntity_Menu, button.ID, changes); err != nil {
+					return err
+				}
+				disabledIDs = append(disabledIDs, button.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errorx.WrapGormError(ctx, err)
+		}
+		result.Disabled = len(disabledIDs)
+
+		// the policies of the disabled resources are removed
+		if len(disabledIDs) > 0 {
+			if err := a.syncPolicies(ctx, disabledIDs...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	logger.Info(ctx, "Sync routes to button resources", map[string]any{
+		"missing":  len(result.Missing),
+		"stale":    len(result.Stale),
+		"created":  result.Created,
+		"disabled": result.Disabled,
+	})
+	return result, nil
+}
+
+// Reload the policies of the roles granted the menus.
+func (a *RouteSync) syncPolicies(ctx context.Context, menuIDs ...string) error {
+	// the menus are shared by all the tenants, so are the roles granted them
+	menuRoles, err := a.MenuRoleRepo.Find(helper.WithoutTenant(ctx), gormx.WithSelect("role_id"), gormx.WithWhere("menu_id IN (?)", menuIDs))
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	var roleIDs []string
+	for _, menuRole := range menuRoles {
+		if !slices.Contains(roleIDs, menuRole.RoleID) {
+			roleIDs = append(roleIDs, menuRole.RoleID)
+		}
+	}
+	return a.RoleSvc.SyncPolicies(ctx, roleIDs...)
+}
+
+func (a *RouteSync) inScope(path string) bool {
+	cfg := configs.C.Menu.RouteSync
+	hasPrefix := func(prefix string) bool {
+		return strings.HasPrefix(path, prefix)
+	}
+	return slices.ContainsFunc(cfg.PathPrefixes, hasPrefix) && !slices.ContainsFunc(cfg.ExcludedPathPrefixes, hasPrefix)
+}
+
+// Create the button resources of the routes under the catalog, which is created if not exists, the created menus are audited.
+func (a *RouteSync) createButtons(ctx context.Context, routes []*dtos.Route) (int, error) {
+	name := configs.C.Menu.RouteSync.Catalog
+	catalog, err := a.MenuRepo.GetChildByName(ctx, "", name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		catalog = &models.Menu{
+			ID:        randx.NewXID(),
+			Name:      name,
+			Type:      models.MenuType_CATALOG,
+			Status:    models.MenuStatus_ENABLED,
+			Title:     name,
+			CreatedAt: time.Now(),
+		}
+		if err = a.MenuRepo.Create(ctx, catalog); err == nil {
+			err = a.AuditSvc.Record(ctx, models.AuditAction_Create, models.AuditEntity_Menu, catalog.ID, models.DiffFields(nil, catalog, menuAuditFields...))
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for _, route := range routes {
+		button := &models.Menu{
+			ID:         randx.NewXID(),
+			Type:       models.MenuType_BUTTON,
+			Method:     route.Method,
+			Path:       route.Path,
+			Status:     models.MenuStatus_ENABLED,
+			Title:      route.Method + " " + route.Path,
+			ParentID:   catalog.ID,
+			ParentPath: catalog.ParentPath + catalog.ID + gTreePathDelimiter,
+			CreatedAt:  time.Now(),
+		}
+		if err := a.MenuRepo.Create(ctx, button); err != nil {
+			return 0, err
+		}
+		if err := a.AuditSvc.Record(ctx, models.AuditAction_Create, models.AuditEntity_Menu, button.ID, models.DiffFields(nil, button, menuAuditFields...)); err != nil {
+			return 0, err
+		}
+	}
+	return len(routes), nil
+}
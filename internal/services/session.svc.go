@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/helper"
+	"gin-admin/pkg/jwtx"
+	"gin-admin/pkg/logger"
+)
+
+// Login session management
+type Session struct {
+	Jwt jwtx.Auther
+}
+
+func NewSession(app types.AppContext) *Session {
+	return &Session{
+		Jwt: app.Jwt(),
+	}
+}
+
+// List active sessions of the user, the latest active first.
+func (a *Session) List(ctx context.Context, userID string) ([]*dtos.Session, error) {
+	sessions, err := a.Jwt.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	currentID := helper.GetSessionID(ctx)
+	list := make([]*dtos.Session, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, &dtos.Session{
+			ID:         s.ID,
+			ClientIP:   s.ClientIP,
+			UserAgent:  s.UserAgent,
+			Location:   s.Location,
+			IssuedAt:   s.IssuedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+			Current:    s.ID == currentID,
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastSeenAt.After(list[j].LastSeenAt)
+	})
+
+	return list, nil
+}
+
+// Revoke the session of the user, both the access and refresh token of it become invalid.
+func (a *Session) Revoke(ctx context.Context, userID, id string) error {
+	session, err := a.Jwt.GetSession(ctx, id)
+	if err != nil {
+		if errors.Is(err, jwtx.ErrSessionNotFound) {
+			return errorx.ErrSessionNotFound.New(ctx)
+		}
+		return errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	if session.Subject != userID {
+		return errorx.ErrSessionNotFound.New(ctx)
+	}
+
+	if err := a.Jwt.RevokeSession(ctx, id); err != nil {
+		return errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	ctx = logger.WithTag(ctx, logger.Tag_Logout)
+	logger.Info(ctx, "Session revoked", map[string]any{
+		"sessionId": id,
+		"owner":     userID,
+		"clientIP":  session.ClientIP,
+	})
+
+	return nil
+}
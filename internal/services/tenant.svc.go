@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/errorx"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/cachex"
+	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
+	"gin-admin/pkg/randx"
+
+	"gorm.io/gorm"
+)
+
+const (
+	gCacheNSForTenant = "tenant"
+)
+
+// Fields of the tenant recorded by the audit trail
+var tenantAuditFields = []string{"Code", "Name", "Description", "Status", "AdminID"}
+
+// Tenant management, the tenants are only managed out of any tenant
+type Tenant struct {
+	Cacher       cachex.Cacher
+	TenantRepo   *repositories.Tenant
+	UserRepo     *repositories.User
+	RoleRepo     *repositories.Role
+	DeptRepo     *repositories.Department
+	MenuRoleRepo *repositories.MenuRole
+	UserRoleRepo *repositories.UserRole
+	UserDeptRepo *repositories.UserDepartment
+	APIKeyRepo   *repositories.APIKey
+	UserSvc      *User
+	RoleSvc      *Role
+	AuditSvc     *Audit
+}
+
+func NewTenant(app types.AppContext) *Tenant {
+	return &Tenant{
+		Cacher:       app.Cacher(),
+		TenantRepo:   repositories.NewTenant(app.DB()),
+		UserRepo:     repositories.NewUser(app.DB()),
+		RoleRepo:     repositories.NewRole(app.DB()),
+		DeptRepo:     repositories.NewDepartment(app.DB()),
+		MenuRoleRepo: repositories.NewMenuRole(app.DB()),
+		UserRoleRepo: repositories.NewUserRole(app.DB()),
+		UserDeptRepo: repositories.NewUserDepartment(app.DB()),
+		APIKeyRepo:   repositories.NewAPIKey(app.DB()),
+		UserSvc:      NewUser(app),
+		RoleSvc:      NewRole(app),
+		AuditSvc:     NewAudit(app),
+	}
+}
+
+// Check the request is out of any tenant, the shared data like menus and tenants can not be changed in a tenant.
+func checkPlatform(ctx context.Context) error {
+	if tenantID, _ := helper.GetTenantID(ctx); tenantID != "" {
+		return errorx.ErrTenantPlatformOnly.New(ctx)
+	}
+	return nil
+}
+
+// List tenants from the data access object based on the provided parameters and options.
+func (a *Tenant) List(ctx context.Context, req dtos.TenantListReq) (*dtos.List[*models.Tenant], error) {
+	if err := checkPlatform(ctx); err != nil {
+		return nil, err
+	}
+
+	option := func(db *gorm.DB) *gorm.DB {
+		if v := req.Code; len(v) > 0 {
+			db = db.Where("code LIKE ?", "%"+v+"%")
+		}
+		if v := req.Name; len(v) > 0 {
+			db = db.Where("name LIKE ?", "%"+v+"%")
+		}
+		if v := req.Status; len(v) > 0 {
+			db = db.Where("status = ?", v)
+		}
+		return db
+	}
+
+	list, err := a.TenantRepo.Find(ctx, option, gormx.WithOrder("created_at", "desc"), gormx.WithPage(req.Page, req.Limit))
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	count, err := a.TenantRepo.Count(ctx, option)
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	return dtos.NewList(list, req.Page, req.Limit, count), nil
+}
+
+// Get the specified tenant from the data access object.
+func (a *Tenant) Get(ctx context.Context, id string) (*models.Tenant, error) {
+	if err := checkPlatform(ctx); err != nil {
+		return nil, err
+	}
+
+	tenant, err := a.TenantRepo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorx.ErrTenantNotFound.New(ctx)
+		}
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+	return tenant, nil
+}
+
+// Create a new tenant together with its super admin.
+func (a *Tenant) Create(ctx context.Context, req *dtos.TenantCreateReq) (*models.Tenant, error) {
+	if err := checkPlatform(ctx); err != nil {
+		return nil, err
+	}
+
+	if exists, err := a.TenantRepo.ExistsCode(ctx, req.Code); err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	} else if exists {
+		return nil, errorx.ErrTenantCodeExists.New(ctx)
+	}
+
+	tenant := &models.Tenant{
+		ID:          randx.NewXID(),
+		Code:        req.Code,
+		Name:        req.Name,
+		Description: req.Description,
+		Status:      req.Status,
+		CreatedAt:   time.Now(),
+	}
+
+	nickName := req.AdminNickName
+	if nickName == "" {
+		nickName = req.AdminUsername
+	}
+
+	err := a.TenantRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		// the admin is the first user of the tenant
+		admin, err := a.UserSvc.Create(helper.WithTenantID(ctx, tenant.ID), &dtos.UserCreateReq{
+			Username: req.AdminUsername,
+			NickName: nickName,
+			Password: req.AdminPassword,
+			Status:   models.UserStatus_Activated,
+		})
+		if err != nil {
+			return err
+		}
+
+		tenant.AdminID = admin.ID
+		if err := a.TenantRepo.Create(ctx, tenant); err != nil {
+			return err
+		}
+		return a.AuditSvc.Record(ctx, models.AuditAction_Create, models.AuditEntity_Tenant, tenant.ID, models.DiffFields(nil, tenant, tenantAuditFields...))
+	})
+	if err != nil {
+		return nil, errorx.WrapGormError(ctx, err)
+	}
+
+	return tenant, nil
+}
+
+// Update the specified tenant in the data access object, the requests of a disabled tenant are rejected.
+func (a *Tenant) Update(ctx context.Context, id string, req *dtos.TenantUpdateReq) error {
+	tenant, err := a.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	before := *tenant
+	if req.Name != nil {
+		tenant.Name = *req.Name
+	}
+	if req.Description != nil {
+		tenant.Description = *req.Description
+	}
+	if req.Status != nil {
+		tenant.Status = *req.Status
+	}
+	tenant.UpdatedAt = time.Now()
+
+	err = a.TenantRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.TenantRepo.Update(ctx, tenant, gormx.WithSelect("name", "description", "status", "updated_at")); err != nil {
+			return err
+		}
+		changes := models.DiffFields(&before, tenant, "Name", "Description", "Status")
+		return a.AuditSvc.Record(ctx, models.AuditAction_Update, models.AuditEntity_Tenant, id, changes)
+	})
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	return a.dropCache(ctx, tenant)
+}
+
+// Delete the specified tenant with its users, roles and departments, the logs of the tenant are kept.
+func (a *Tenant) Delete(ctx context.Context, id string) error {
+	tenant, err := a.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	tctx := helper.WithTenantID(ctx, tenant.ID)
+	users, err := a.UserRepo.Find(tctx, gormx.WithSelect("id"))
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+	roles, err := a.RoleRepo.Find(tctx, gormx.WithSelect("id"))
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	userIDs := make([]string, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+	roleIDs := make([]string, len(roles))
+	for i, role := range roles {
+		roleIDs[i] = role.ID
+	}
+
+	err = a.TenantRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		tctx := helper.WithTrans(tctx, tx)
+		if len(userIDs) > 0 {
+			if err := a.UserRoleRepo.DeleteByUserID(tctx, userIDs...); err != nil {
+				return err
+			}
+			if err := a.UserDeptRepo.DeleteByUserID(tctx, userIDs...); err != nil {
+				return err
+			}
+			if err := a.APIKeyRepo.DeleteByUserID(tctx, userIDs...); err != nil {
+				return err
+			}
+		}
+
+		byTenant := gormx.WithWhere("tenant_id = ?", tenant.ID)
+		if err := a.MenuRoleRepo.DeleteBatch(tctx, byTenant); err != nil {
+			return err
+		}
+		if err := a.RoleRepo.DeleteBatch(tctx, byTenant); err != nil {
+			return err
+		}
+		if err := a.DeptRepo.DeleteBatch(tctx, byTenant); err != nil {
+			return err
+		}
+		if err := a.UserRepo.DeleteBatch(tctx, byTenant); err != nil {
+			return err
+		}
+		if err := a.TenantRepo.Delete(ctx, tenant.ID); err != nil {
+			return err
+		}
+		return a.AuditSvc.Record(ctx, models.AuditAction_Delete, models.AuditEntity_Tenant, tenant.ID, models.DiffFields(tenant, nil, tenantAuditFields...))
+	})
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	if err := a.dropCache(ctx, tenant); err != nil {
+		return err
+	}
+	return a.RoleSvc.SyncPolicies(ctx, roleIDs...)
+}
+
+// Resolve the enabled tenant by its code.
+func (a *Tenant) Resolve(ctx context.Context, code string) (*models.Tenant, error) {
+	return a.getEnabled(ctx, "code:"+code, func() (*models.Tenant, error) {
+		return a.TenantRepo.GetByCode(ctx, code)
+	})
+}
+
+// Get the enabled tenant by its ID.
+func (a *Tenant) GetEnabled(ctx context.Context, id string) (*models.Tenant, error) {
+	return a.getEnabled(ctx, "id:"+id, func() (*models.Tenant, error) {
+		return a.TenantRepo.Get(ctx, id)
+	})
+}
+
+// Whether the user is the super admin of the tenant of the context.
+func (a *Tenant) IsAdmin(ctx context.Context, userID string) (bool, error) {
+	tenantID, _ := helper.GetTenantID(ctx)
+	if tenantID == "" {
+		return false, nil
+	}
+
+	tenant, err := a.GetEnabled(ctx, tenantID)
+	if err != nil {
+		return false, err
+	}
+	return tenant.AdminID == userID, nil
+}
+
+// Get the tenant from the cache, or the database if not cached, the disabled tenants are rejected.
+func (a *Tenant) getEnabled(ctx context.Context, key string, get func() (*models.Tenant, error)) (*models.Tenant, error) {
+	// the tenants are cached out of any tenant
+	cctx := helper.WithoutTenant(ctx)
+
+	tenant := new(models.Tenant)
+	val, err := a.Cacher.Get(cctx, gCacheNSForTenant, key)
+	if err == nil {
+		err = json.Unmarshal([]byte(val), tenant)
+	}
+	if err != nil {
+		if tenant, err = get(); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errorx.ErrTenantNotFound.New(ctx)
+			}
+			return nil, errorx.WrapGormError(ctx, err)
+		}
+
+		byt, err := json.Marshal(tenant)
+		if err != nil {
+			return nil, errorx.ErrInternal.New(ctx).Wrap(err)
+		}
+		expiration := time.Duration(configs.C.Tenant.CacheExpiration) * time.Second
+		if err := a.Cacher.Set(cctx, gCacheNSForTenant, key, string(byt), expiration); err != nil {
+			return nil, err
+		}
+	}
+
+	if tenant.Status != models.TenantStatus_Enabled {
+		return nil, errorx.ErrTenantDisabled.New(ctx, struct{ Name string }{tenant.Name})
+	}
+	return tenant, nil
+}
+
+func (a *Tenant) dropCache(ctx context.Context, tenant *models.Tenant) error {
+	cctx := helper.WithoutTenant(ctx)
+	if err := a.Cacher.Delete(cctx, gCacheNSForTenant, "code:"+tenant.Code); err != nil {
+		return err
+	}
+	return a.Cacher.Delete(cctx, gCacheNSForTenant, "id:"+tenant.ID)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"slices"
+	"strconv"
 	"time"
 
 	"gin-admin/internal/configs"
@@ -15,6 +17,7 @@ import (
 	"gin-admin/pkg/cachex"
 	"gin-admin/pkg/crypto/hash"
 	"gin-admin/pkg/gormx"
+	"gin-admin/pkg/helper"
 	"gin-admin/pkg/randx"
 
 	"github.com/epkgs/object"
@@ -22,7 +25,11 @@ import (
 )
 
 const (
-	gCacheNSForUserRoles = "user_roles"
+	gCacheNSForUserRoles       = "user_roles"
+	gCacheNSForTokenGeneration = "user_token_gen"
+
+	// Channel of the user IDs whose cached role IDs are changed
+	UserRolesChannel = "user_roles"
 )
 
 // User management for SYS
@@ -31,6 +38,14 @@ type User struct {
 	UserRepo     *repositories.User
 	RoleRepo     *repositories.Role
 	UserRoleRepo *repositories.UserRole
+	APIKeyRepo   *repositories.APIKey
+	IdentityRepo *repositories.UserIdentity
+	UserDeptRepo *repositories.UserDepartment
+	GuardSvc     *LoginGuard
+	PasswordSvc  *Password
+	DataScopeSvc *DataScope
+	DeptSvc      *Department
+	AuditSvc     *Audit
 }
 
 func NewUser(app types.AppContext) *User {
@@ -39,11 +54,34 @@ func NewUser(app types.AppContext) *User {
 		UserRepo:     repositories.NewUser(app.DB()),
 		RoleRepo:     repositories.NewRole(app.DB()),
 		UserRoleRepo: repositories.NewUserRole(app.DB()),
+		APIKeyRepo:   repositories.NewAPIKey(app.DB()),
+		IdentityRepo: repositories.NewUserIdentity(app.DB()),
+		UserDeptRepo: repositories.NewUserDepartment(app.DB()),
+		GuardSvc:     NewLoginGuard(app),
+		PasswordSvc:  NewPassword(app),
+		DataScopeSvc: NewDataScope(app),
+		DeptSvc:      NewDepartment(app),
+		AuditSvc:     NewAudit(app),
 	}
 }
 
+// Fields of the user recorded by the audit trail of the deleted user
+var userAuditFields = []string{"Username", "NickName", "RealName", "Phone", "Email", "Status", "Type", "DeptID"}
+
+// Users are owned by themselves and their creators.
+var userDataScope = DataScopeColumns{Dept: "dept_id", Owners: []string{"id", "created_by"}}
+
 // List users from the data access object based on the provided parameters and options.
 func (a *User) List(ctx context.Context, req dtos.UserListReq) (*dtos.List[*models.User], error) {
+	var deptIDs []string
+	if req.DeptID != "" {
+		ids, err := a.DeptSvc.SubtreeIDs(ctx, req.DeptID)
+		if err != nil {
+			return nil, err
+		}
+		deptIDs = ids
+	}
+
 	option := func(db *gorm.DB) *gorm.DB {
 
 		if v := req.LikeUsername; len(v) > 0 {
@@ -55,18 +93,30 @@ func (a *User) List(ctx context.Context, req dtos.UserListReq) (*dtos.List[*mode
 		if v := req.Status; len(v) > 0 {
 			db = db.Where("status = ?", v)
 		}
+		if v := req.Type; len(v) > 0 {
+			db = db.Where("type = ?", v)
+		}
+		if len(deptIDs) > 0 {
+			userDeptQuery := a.UserDeptRepo.DB().Model(new(models.UserDepartment)).Where("dept_id IN (?)", deptIDs).Select("user_id")
+			db = db.Where("id IN (?)", userDeptQuery)
+		}
 		if req.WithRoles {
 			db = db.Preload("Roles")
 		}
 		return db
 	}
 
-	list, err := a.UserRepo.Find(ctx, option, gormx.WithPage(req.Page, req.Limit))
+	scope, err := a.DataScopeSvc.Option(ctx, userDataScope)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := a.UserRepo.Find(ctx, option, scope, gormx.WithPage(req.Page, req.Limit))
 	if err != nil {
 		return nil, errorx.WrapGormError(ctx, err)
 	}
 
-	count, err := a.UserRepo.Count(ctx, option)
+	count, err := a.UserRepo.Count(ctx, option, scope)
 	if err != nil {
 		return nil, errorx.WrapGormError(ctx, err)
 	}
@@ -76,7 +126,12 @@ func (a *User) List(ctx context.Context, req dtos.UserListReq) (*dtos.List[*mode
 
 // Get the specified user from the data access object.
 func (a *User) Get(ctx context.Context, id string) (*models.User, error) {
-	user, err := a.UserRepo.Get(ctx, id, gormx.WithPreload("Roles"))
+	scope, err := a.DataScopeSvc.Option(ctx, userDataScope)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.UserRepo.Get(ctx, id, scope, gormx.WithPreload("Roles"), gormx.WithPreload("Departments"))
 	if err != nil {
 		return nil, errorx.WrapGormError(ctx, err)
 	}
@@ -100,23 +155,33 @@ func (a *User) Create(ctx context.Context, req *dtos.UserCreateReq) (*models.Use
 
 	user := &models.User{
 		ID:        randx.NewXID(),
+		CreatedBy: helper.GetUserID(ctx),
 		CreatedAt: time.Now(),
 	}
 
-	if req.Password == "" {
-		req.Password = configs.C.DefaultLoginPwd
+	if req.Type == "" {
+		req.Type = models.UserType_User
 	}
 
-	if err := object.Assign(user, req); err != nil {
+	var md object.Metadata
+	if err := object.Assign(user, req, func(c *object.AssignConfig) {
+		c.Metadata = &md
+	}); err != nil {
 		return nil, errorx.ErrInternal.New(ctx).Wrap(err)
 	}
-
-	if pass := req.Password; pass != "" {
-		hashPass, err := hash.GeneratePassword(pass)
+	user.Password = ""
+
+	// service accounts have no password to login
+	if req.Type != models.UserType_Service {
+		mustChange := configs.C.Password.ChangeOnFirstLogin
+		if req.Password == "" {
+			_, err = a.PasswordSvc.Reset(ctx, user, mustChange)
+		} else {
+			_, err = a.PasswordSvc.Set(ctx, user, req.Password, mustChange)
+		}
 		if err != nil {
-			return nil, errorx.ErrPasswordEncrypt.New(ctx).Wrap(err)
+			return nil, err
 		}
-		user.Password = hashPass
 	}
 
 	roles, err := a.RoleRepo.Find(ctx, gormx.WithWhere("id IN ?", req.RoleIDs))
@@ -124,8 +189,26 @@ func (a *User) Create(ctx context.Context, req *dtos.UserCreateReq) (*models.Use
 		return nil, errorx.WrapGormError(ctx, err)
 	}
 
+	user.Departments = userDepartments(user.ID, req.DeptID, req.DeptIDs, nil)
+	if err := a.DeptSvc.CheckExists(ctx, user.Departments.ToDeptIDs()...); err != nil {
+		return nil, err
+	}
+
 	user.Roles = roles
-	if err := a.UserRepo.Create(ctx, user); err != nil {
+	err = a.UserRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.UserRepo.Create(ctx, user); err != nil {
+			return err
+		}
+
+		changes := models.DiffFields(nil, user, md.Keys...)
+		changes = append(changes,
+			&models.AuditChange{Field: "roleIds", New: req.RoleIDs},
+			&models.AuditChange{Field: "deptIds", New: user.Departments.ToDeptIDs()},
+		)
+		return a.AuditSvc.Record(ctx, models.AuditAction_Create, models.AuditEntity_User, user.ID, changes)
+	})
+	if err != nil {
 		return nil, errorx.WrapGormError(ctx, err)
 	}
 
@@ -139,7 +222,13 @@ func (a *User) Update(ctx context.Context, id string, req *dtos.UserUpdateReq) e
 		return errorx.ErrModifySuperUser.New(ctx) // 超级管理员不允许修改
 	}
 
-	user, err := a.UserRepo.Get(ctx, id)
+	scope, err := a.DataScopeSvc.Option(ctx, userDataScope)
+	if err != nil {
+		return err
+	}
+
+	// users out of the data scope are not found
+	user, err := a.UserRepo.Get(ctx, id, scope)
 	if err != nil {
 		return errorx.WrapGormError(ctx, err)
 	}
@@ -153,6 +242,28 @@ func (a *User) Update(ctx context.Context, id string, req *dtos.UserUpdateReq) e
 		}
 	}
 
+	// tokens issued before are invalidated if the user is frozen, renamed, or its password or roles are changed
+	revoke := (req.Status != nil && *req.Status != user.Status) ||
+		(req.Username != nil && *req.Username != user.Username) ||
+		req.Password != nil
+
+	var changes models.AuditChanges
+	if req.RoleIDs != nil {
+		roleIDs, err := a.GetRoleIDs(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !sameIDs(roleIDs, *req.RoleIDs) {
+			revoke = true
+			changes = append(changes, &models.AuditChange{Field: "roleIds", Old: roleIDs, New: *req.RoleIDs})
+		}
+	}
+
+	// the password is set apart from other fields to keep the current hash for the history check
+	password := req.Password
+	req.Password = nil
+
+	before := *user
 	var md object.Metadata
 	if err := object.Assign(user, req, func(c *object.AssignConfig) {
 		c.Metadata = &md
@@ -161,18 +272,19 @@ func (a *User) Update(ctx context.Context, id string, req *dtos.UserUpdateReq) e
 	}
 
 	selected := md.Keys
+	changes = append(models.DiffFields(&before, user, md.Keys...), changes...)
 
-	if req.Password != nil {
-		pass := *req.Password
-		hashPass, err := hash.GeneratePassword(pass)
+	if password != nil && user.Type != models.UserType_Service {
+		fields, err := a.PasswordSvc.Set(ctx, user, *password, configs.C.Password.ChangeOnReset)
 		if err != nil {
-			return errorx.ErrPasswordEncrypt.New(ctx).Wrap(err)
+			return err
 		}
-		user.Password = hashPass
+		selected = append(selected, fields...)
+		changes = append(changes, models.DiffFields(&before, user, "Password")...)
 	}
 
 	if req.RoleIDs != nil {
-		roles, err := a.RoleRepo.Find(ctx, gormx.WithWhere("id IN ?", req.RoleIDs))
+		roles, err := a.RoleRepo.Find(ctx, gormx.WithWhere("id IN ?", *req.RoleIDs))
 		if err != nil {
 			return errorx.WrapGormError(ctx, err)
 		}
@@ -180,15 +292,113 @@ func (a *User) Update(ctx context.Context, id string, req *dtos.UserUpdateReq) e
 		selected = append(selected, "Roles")
 	}
 
+	var departments models.UserDepartments
+	if req.DeptID != nil || req.DeptIDs != nil {
+		current, err := a.UserDeptRepo.Find(ctx, gormx.WithWhere("user_id = ?", id))
+		if err != nil {
+			return errorx.WrapGormError(ctx, err)
+		}
+
+		secondary := []string{}
+		if req.DeptIDs != nil {
+			secondary = *req.DeptIDs
+		} else {
+			for _, item := range current {
+				if !item.Primary {
+					secondary = append(secondary, item.DeptID)
+				}
+			}
+		}
+
+		departments = userDepartments(id, user.DeptID, secondary, current)
+		if err := a.DeptSvc.CheckExists(ctx, departments.ToDeptIDs()...); err != nil {
+			return err
+		}
+		if oldIDs := models.UserDepartments(current).ToDeptIDs(); !sameIDs(oldIDs, departments.ToDeptIDs()) {
+			changes = append(changes, &models.AuditChange{Field: "deptIds", Old: oldIDs, New: departments.ToDeptIDs()})
+		}
+	}
+
 	user.UpdatedAt = time.Now()
 
-	if err := a.UserRepo.Update(ctx, user, gormx.WithSelect(selected)); err != nil {
+	var generation int64
+	err = a.UserRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
+		// the roles are replaced, updating the association only adds the new ones
+		if req.RoleIDs != nil {
+			if err := a.UserRoleRepo.DeleteByUserID(ctx, id); err != nil {
+				return err
+			}
+		}
+		if departments != nil {
+			if err := a.UserDeptRepo.DeleteByUserID(ctx, id); err != nil {
+				return err
+			}
+			if len(departments) > 0 {
+				if err := a.UserDeptRepo.CreateBatch(ctx, departments, len(departments)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := a.UserRepo.Update(ctx, user, gormx.WithSelect(selected)); err != nil {
+			return err
+		}
+		if err := a.AuditSvc.Record(ctx, models.AuditAction_Update, models.AuditEntity_User, id, changes); err != nil {
+			return err
+		}
+		if revoke {
+			var err error
+			generation, err = a.startTokenGeneration(ctx, id)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return errorx.WrapGormError(ctx, err)
 	}
 
+	if revoke {
+		return a.cacheTokenGeneration(ctx, id, generation)
+	}
 	return nil
 }
 
+// Build the department memberships of the user, the leaderships of the kept departments are retained.
+func userDepartments(userID, primary string, secondary []string, current models.UserDepartments) models.UserDepartments {
+	leaders := make(map[string]bool)
+	for _, item := range current {
+		leaders[item.DeptID] = item.Leader
+	}
+
+	list := models.UserDepartments{}
+	add := func(deptID string, primary bool) {
+		if deptID == "" || slices.Contains(list.ToDeptIDs(), deptID) {
+			return
+		}
+		list = append(list, &models.UserDepartment{
+			ID:        randx.NewXID(),
+			UserID:    userID,
+			DeptID:    deptID,
+			Primary:   primary,
+			Leader:    leaders[deptID],
+			CreatedAt: time.Now(),
+		})
+	}
+
+	add(primary, true)
+	for _, deptID := range secondary {
+		add(deptID, false)
+	}
+	return list
+}
+
+func sameIDs(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
 // Delete the specified user from the data access object.
 func (a *User) Delete(ctx context.Context, id string) error {
 
@@ -196,24 +406,66 @@ func (a *User) Delete(ctx context.Context, id string) error {
 		return errorx.ErrModifySuperUser.New(ctx) // 超级管理员不允许修改
 	}
 
-	exists, err := a.UserRepo.Exists(ctx, gormx.WithWhere("id = ?", id))
+	scope, err := a.DataScopeSvc.Option(ctx, userDataScope)
+	if err != nil {
+		return err
+	}
+
+	user, err := a.UserRepo.Get(ctx, id, scope)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errorx.ErrUserNotFound.New(ctx)
+		}
 		return errorx.WrapGormError(ctx, err)
-	} else if !exists {
-		return errorx.ErrUserNotFound.New(ctx)
 	}
 
+	roleIDs, err := a.GetRoleIDs(ctx, id)
+	if err != nil {
+		return err
+	}
+	departments, err := a.UserDeptRepo.Find(ctx, gormx.WithWhere("user_id = ?", id))
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	changes := models.DiffFields(user, nil, userAuditFields...)
+	if len(roleIDs) > 0 {
+		changes = append(changes, &models.AuditChange{Field: "roleIds", Old: roleIDs})
+	}
+	if len(departments) > 0 {
+		changes = append(changes, &models.AuditChange{Field: "deptIds", Old: models.UserDepartments(departments).ToDeptIDs()})
+	}
+
+	var generation int64
 	err = a.UserRepo.Transaction(ctx, func(tx *gorm.DB) error {
+		ctx := helper.WithTrans(ctx, tx)
 		if err := a.UserRepo.Delete(ctx, id); err != nil {
 			return err
 		}
 		if err := a.UserRoleRepo.DeleteByUserID(ctx, id); err != nil {
 			return err
 		}
-		return a.DeleteRoleIDsCache(ctx, id)
+		if err := a.APIKeyRepo.DeleteByUserID(ctx, id); err != nil {
+			return err
+		}
+		if err := a.IdentityRepo.DeleteByUserID(ctx, id); err != nil {
+			return err
+		}
+		if err := a.UserDeptRepo.DeleteByUserID(ctx, id); err != nil {
+			return err
+		}
+		if err := a.AuditSvc.Record(ctx, models.AuditAction_Delete, models.AuditEntity_User, id, changes); err != nil {
+			return err
+		}
+		var err error
+		generation, err = a.startTokenGeneration(ctx, id)
+		return err
 	})
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
 
-	return errorx.WrapGormError(ctx, err)
+	return a.cacheTokenGeneration(ctx, id, generation)
 }
 
 func (a *User) ResetPassword(ctx context.Context, id string) error {
@@ -221,26 +473,69 @@ func (a *User) ResetPassword(ctx context.Context, id string) error {
 		return errorx.ErrModifySuperUser.New(ctx) // 超级管理员不允许修改
 	}
 
-	exists, err := a.UserRepo.Exists(ctx, gormx.WithWhere("id=?", id))
+	scope, err := a.DataScopeSvc.Option(ctx, userDataScope)
 	if err != nil {
+		return err
+	}
+
+	user, err := a.UserRepo.Get(ctx, id, scope, gormx.WithSelect("id", "password", "password_history", "type"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errorx.ErrUserNotFound.New(ctx)
+		}
 		return errorx.WrapGormError(ctx, err)
-	} else if !exists {
-		return errorx.ErrUserNotFound.New(ctx)
 	}
 
-	hashPass, err := hash.GeneratePassword(configs.C.DefaultLoginPwd)
+	if user.Type == models.UserType_Service {
+		return errorx.ErrServiceAccountLogin.New(ctx)
+	}
+
+	before := *user
+	fields, err := a.PasswordSvc.Reset(ctx, user, configs.C.Password.ChangeOnReset)
 	if err != nil {
-		return errorx.ErrPasswordEncrypt.New(ctx).Wrap(err)
+		return err
 	}
 
+	changes := models.DiffFields(&before, user, "Password", "MustChangePassword")
+	var generation int64
 	err = a.UserRepo.Transaction(ctx, func(tx *gorm.DB) error {
-		if err := a.UserRepo.UpdatePassword(ctx, id, hashPass); err != nil {
+		ctx := helper.WithTrans(ctx, tx)
+		if err := a.UserRepo.Update(ctx, user, gormx.WithSelect(fields)); err != nil {
 			return err
 		}
-		return nil
+		if err := a.AuditSvc.Record(ctx, models.AuditAction_Update, models.AuditEntity_User, id, changes); err != nil {
+			return err
+		}
+		var err error
+		generation, err = a.startTokenGeneration(ctx, id)
+		return err
 	})
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
 
-	return errorx.WrapGormError(ctx, err)
+	return a.cacheTokenGeneration(ctx, id, generation)
+}
+
+// Clear the login lockout of the specified user.
+func (a *User) Unlock(ctx context.Context, id string) error {
+	scope, err := a.DataScopeSvc.Option(ctx, userDataScope)
+	if err != nil {
+		return err
+	}
+
+	user, err := a.UserRepo.Get(ctx, id, scope, gormx.WithSelect("username"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errorx.ErrUserNotFound.New(ctx)
+		}
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	if err := a.GuardSvc.Unlock(ctx, user.Username); err != nil {
+		return err
+	}
+	return errorx.WrapGormError(ctx, a.AuditSvc.Record(ctx, models.AuditAction_Unlock, models.AuditEntity_User, id, nil))
 }
 
 func (a *User) GetRoleIDs(ctx context.Context, id string) ([]string, error) {
@@ -259,11 +554,80 @@ func (a *User) SetRoleIDsCache(ctx context.Context, userID string, roleIDs []str
 	if err != nil {
 		return errorx.ErrInternal.New(ctx).Wrap(err)
 	}
+	// filling the cache changes nothing, the other instances are only notified by DeleteRoleIDsCache on writes
 	return a.Cacher.Set(ctx, gCacheNSForUserRoles, userID, string(byt), expiration...)
 }
 
 func (a *User) DeleteRoleIDsCache(ctx context.Context, userID string) error {
-	return a.Cacher.Delete(ctx, gCacheNSForUserRoles, userID)
+	if err := a.Cacher.Delete(ctx, gCacheNSForUserRoles, userID); err != nil {
+		return err
+	}
+	return a.Cacher.Publish(ctx, UserRolesChannel, userID)
+}
+
+// Get the token generation of the user, tokens of another generation are invalid.
+func (a *User) GetTokenGeneration(ctx context.Context, userID string) (int64, error) {
+	val, err := a.Cacher.Get(ctx, gCacheNSForTokenGeneration, userID)
+	if err == nil {
+		if generation, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return generation, nil
+		}
+	} else if !errors.Is(err, cachex.ErrNotFound) {
+		return 0, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	user, err := a.UserRepo.Get(ctx, userID, gormx.WithSelect("token_generation"))
+	if err != nil {
+		return 0, errorx.WrapGormError(ctx, err)
+	}
+
+	// only filled when absent, a generation written by RevokeTokens meanwhile is not overwritten with the stale one
+	_, err = a.Cacher.SetNX(ctx, gCacheNSForTokenGeneration, userID, strconv.FormatInt(user.TokenGeneration, 10),
+		time.Duration(configs.C.Cache.Expiration.User)*time.Hour)
+	if err != nil {
+		return 0, errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	return user.TokenGeneration, nil
+}
+
+// Invalidate all issued tokens of the user by starting a new token generation, the cached role IDs are dropped as well.
+// The changes made in a transaction use startTokenGeneration in it and cacheTokenGeneration after the commit instead.
+func (a *User) RevokeTokens(ctx context.Context, userID string) error {
+	var generation int64
+	err := a.UserRepo.Transaction(ctx, func(tx *gorm.DB) (err error) {
+		generation, err = a.startTokenGeneration(helper.WithTrans(ctx, tx), userID)
+		return err
+	})
+	if err != nil {
+		return errorx.WrapGormError(ctx, err)
+	}
+
+	return a.cacheTokenGeneration(ctx, userID, generation)
+}
+
+// Store and audit a new token generation of the user, the issued tokens are still accepted until it is cached.
+func (a *User) startTokenGeneration(ctx context.Context, userID string) (int64, error) {
+	user := &models.User{ID: userID, TokenGeneration: time.Now().UnixNano()}
+	if err := a.UserRepo.Update(ctx, user, gormx.WithSelect("TokenGeneration")); err != nil {
+		return 0, err
+	}
+
+	if err := a.AuditSvc.Record(ctx, models.AuditAction_RevokeTokens, models.AuditEntity_User, userID, nil); err != nil {
+		return 0, err
+	}
+	return user.TokenGeneration, nil
+}
+
+// Cache the committed token generation of the user and drop the cached role IDs, a rolled back generation is never cached.
+func (a *User) cacheTokenGeneration(ctx context.Context, userID string, generation int64) error {
+	err := a.Cacher.Set(ctx, gCacheNSForTokenGeneration, userID, strconv.FormatInt(generation, 10),
+		time.Duration(configs.C.Cache.Expiration.User)*time.Hour)
+	if err != nil {
+		return errorx.ErrInternal.New(ctx).Wrap(err)
+	}
+
+	return a.DeleteRoleIDsCache(ctx, userID)
 }
 
 func (a *User) GetRoleIDsCache(ctx context.Context, userID string) ([]string, error) {
@@ -286,7 +650,7 @@ func (a *User) GetRoleIDsCache(ctx context.Context, userID string) ([]string, er
 func (a *User) InitSuperUserIfNeed(ctx context.Context) error {
 
 	err := a.UserRepo.Transaction(ctx, func(tx *gorm.DB) error {
-
+		ctx := helper.WithTrans(ctx, tx)
 		user, err := a.UserRepo.Get(ctx, configs.C.Super.ID)
 		if user == nil || errors.Is(err, gorm.ErrRecordNotFound) {
 			// 如果没有 root 账户，则插入数据库
@@ -300,6 +664,7 @@ func (a *User) InitSuperUserIfNeed(ctx context.Context) error {
 				NickName: configs.C.Super.NickName,
 				Password: hashedPass,
 				Status:   models.UserStatus_Activated,
+				Type:     models.UserType_User,
 			}
 			return a.UserRepo.Create(ctx, user)
 		}
@@ -6,6 +6,7 @@ import (
 	"gin-admin/internal/configs"
 	"gin-admin/pkg/cachex"
 	"gin-admin/pkg/jwtx"
+	"gin-admin/pkg/mail"
 	"gin-admin/pkg/uploader"
 
 	"github.com/casbin/casbin/v2"
@@ -20,6 +21,7 @@ type AppContext interface {
 	Jwt() jwtx.Auther
 	Casbin() Casbinx
 	Uploader() *uploader.Uploader
+	Mailer() *mail.SmtpSender // nil if sending mails is disabled
 
 	Middlewares() Middlewares
 
@@ -27,8 +29,9 @@ type AppContext interface {
 }
 
 type Casbinx interface {
-	GetEnforcer() *casbin.Enforcer
+	GetEnforcer() *casbin.SyncedEnforcer
 	Load(ctx context.Context) error
+	ReloadRoles(ctx context.Context, roleIDs ...string) error // Apply the permission changes of the roles to the enforcer
 	Release(ctx context.Context) error
 }
 
@@ -36,6 +39,7 @@ type Middlewares interface {
 	I18n() gin.HandlerFunc
 	Cors() gin.HandlerFunc
 	Trace() gin.HandlerFunc
+	Tenant() gin.HandlerFunc
 	Logger() gin.HandlerFunc
 	CopyBody() gin.HandlerFunc
 	Auth() gin.HandlerFunc
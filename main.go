@@ -27,6 +27,7 @@ func main() {
 	rootCmd.AddCommand(cmd.StartCmd())
 	rootCmd.AddCommand(cmd.StopCmd())
 	rootCmd.AddCommand(cmd.VersionCmd())
+	rootCmd.AddCommand(cmd.LogsCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		panic(err)
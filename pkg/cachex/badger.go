@@ -2,7 +2,9 @@ package cachex
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 	"unsafe"
@@ -38,6 +40,7 @@ func NewBadgerCache(cfg BadgerConfig, opts ...Option) Cacher {
 }
 
 type badgerCache struct {
+	broker
 	opts *options
 	db   *badger.DB
 }
@@ -121,20 +124,125 @@ func (a *badgerCache) Delete(ctx context.Context, ns, key string) error {
 	})
 }
 
+// Run the read-modify-write transaction, it is retried if it conflicts with another one.
+func (a *badgerCache) update(fn func(txn *badger.Txn) error) error {
+	for {
+		err := a.db.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+}
+
+func (a *badgerCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	var count int64
+	k := a.strToBytes(a.getKey(ns, key))
+	err := a.update(func(txn *badger.Txn) error {
+		count = 0
+		var ttl time.Duration
+		item, err := txn.Get(k)
+		switch {
+		case err == nil:
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if count, err = strconv.ParseInt(string(val), 10, 64); err != nil {
+				return err
+			}
+			// the counter keeps its expiration
+			if expiresAt := item.ExpiresAt(); expiresAt > 0 {
+				ttl = max(time.Until(time.Unix(int64(expiresAt), 0)), time.Second)
+			}
+		case errors.Is(err, badger.ErrKeyNotFound):
+			if len(expiration) > 0 {
+				ttl = expiration[0]
+			}
+		default:
+			return err
+		}
+
+		count++
+		entry := badger.NewEntry(k, []byte(strconv.FormatInt(count, 10)))
+		if ttl > 0 {
+			entry = entry.WithTTL(ttl)
+		}
+		return txn.SetEntry(entry)
+	})
+	return count, err
+}
+
 func (a *badgerCache) GetAndDelete(ctx context.Context, ns, key string) (string, error) {
-	value, err := a.Get(ctx, ns, key)
+	value := ""
+	k := a.strToBytes(a.getKey(ns, key))
+	err := a.update(func(txn *badger.Txn) error {
+		item, err := txn.Get(k)
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		value = a.bytesToStr(val)
+		return txn.Delete(k)
+	})
 	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return "", ErrNotFound
+		}
 		return "", err
 	}
+	return value, nil
+}
 
-	err = a.db.Update(func(txn *badger.Txn) error {
-		return txn.Delete(a.strToBytes(a.getKey(ns, key)))
+func (a *badgerCache) DeleteIfEqual(ctx context.Context, ns, key, value string) (bool, error) {
+	deleted := false
+	k := a.strToBytes(a.getKey(ns, key))
+	err := a.update(func(txn *badger.Txn) error {
+		item, err := txn.Get(k)
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		// set on every attempt, the transaction may be retried after the value is changed
+		deleted = a.bytesToStr(val) == value
+		if !deleted {
+			return nil
+		}
+		return txn.Delete(k)
 	})
 	if err != nil {
-		return "", err
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, err
 	}
+	return deleted, nil
+}
 
-	return value, nil
+func (a *badgerCache) SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error) {
+	set := false
+	k := a.strToBytes(a.getKey(ns, key))
+	err := a.update(func(txn *badger.Txn) error {
+		set = false
+		if _, err := txn.Get(k); err == nil {
+			return nil
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		entry := badger.NewEntry(k, []byte(value))
+		if len(expiration) > 0 && expiration[0] > 0 {
+			entry = entry.WithTTL(expiration[0])
+		}
+		set = true
+		return txn.SetEntry(entry)
+	})
+	return set, err
 }
 
 func (a *badgerCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
@@ -3,7 +3,9 @@ package cachex
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -30,6 +32,18 @@ func TestBadgerCache(t *testing.T) {
 	assert.Equal(ErrNotFound, err)
 	assert.Equal("", val)
 
+	// the key is only deleted with the value it holds
+	assert.Nil(cache.Set(ctx, "tt", "lock", "owner"))
+	deleted, err := cache.DeleteIfEqual(ctx, "tt", "lock", "other")
+	assert.Nil(err)
+	assert.False(deleted)
+	deleted, err = cache.DeleteIfEqual(ctx, "tt", "lock", "owner")
+	assert.Nil(err)
+	assert.True(deleted)
+	deleted, err = cache.DeleteIfEqual(ctx, "tt", "lock", "owner")
+	assert.Nil(err)
+	assert.False(deleted)
+
 	tmap := make(map[string]bool)
 	for i := 0; i < 10; i++ {
 		key := fmt.Sprintf("foo%d", i)
@@ -52,3 +66,30 @@ func TestBadgerCache(t *testing.T) {
 	err = cache.Close(ctx)
 	assert.Nil(err)
 }
+
+func TestBadgerCacheIncr(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := NewBadgerCache(BadgerConfig{
+		Path: "./tmp/badger_incr",
+	})
+	defer cache.Close(context.Background())
+
+	ctx := context.Background()
+	_ = cache.Delete(ctx, "tt", "counter")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.Incr(ctx, "tt", "counter", time.Minute)
+			assert.Nil(err)
+		}()
+	}
+	wg.Wait()
+
+	val, err := cache.Get(ctx, "tt", "counter")
+	assert.Nil(err)
+	assert.Equal("20", val)
+}
@@ -12,10 +12,21 @@ var ErrNotFound = errors.New("cache: not found")
 type Cacher interface {
 	Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error
 	Get(ctx context.Context, ns, key string) (string, error)
+	// Get the value and delete it atomically, so the value is only got once.
 	GetAndDelete(ctx context.Context, ns, key string) (string, error)
+	// Set the value only if the key does not exist, it returns whether the value is set.
+	SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error)
+	// Delete the key only if it holds the value atomically, it returns whether the key is deleted.
+	DeleteIfEqual(ctx context.Context, ns, key, value string) (bool, error)
 	Exists(ctx context.Context, ns, key string) (bool, error)
 	Delete(ctx context.Context, ns, key string) error
+	// Increase the counter by one atomically and return the new count, the expiration only applies to the new counter.
+	Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error)
 	Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error
+	// Publish the message to all the subscribers of the channel.
+	Publish(ctx context.Context, channel, message string) error
+	// Subscribe the channel until the returned function is called.
+	Subscribe(ctx context.Context, channel string, fn MessageHandler) (unsubscribe func(), err error)
 	Close(ctx context.Context) error
 }
 
@@ -2,8 +2,11 @@ package cachex
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -29,6 +32,8 @@ func NewMemoryCache(cfg MemoryConfig, opts ...Option) Cacher {
 }
 
 type memCache struct {
+	broker
+	mu    sync.Mutex // guards the read-modify-write operations
 	opts  *options
 	cache *cache.Cache
 }
@@ -65,7 +70,38 @@ func (a *memCache) Delete(ctx context.Context, ns, key string) error {
 	return nil
 }
 
+func (a *memCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := a.getKey(ns, key)
+	var (
+		count int64
+		exp   time.Duration
+	)
+	if val, expiresAt, ok := a.cache.GetWithExpiration(k); ok {
+		n, err := strconv.ParseInt(val.(string), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		count = n
+		// the counter keeps its expiration
+		if !expiresAt.IsZero() {
+			exp = max(time.Until(expiresAt), time.Nanosecond)
+		}
+	} else if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	count++
+	a.cache.Set(k, strconv.FormatInt(count, 10), exp)
+	return count, nil
+}
+
 func (a *memCache) GetAndDelete(ctx context.Context, ns, key string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	value, err := a.Get(ctx, ns, key)
 	if err != nil {
 		return "", err
@@ -75,6 +111,34 @@ func (a *memCache) GetAndDelete(ctx context.Context, ns, key string) (string, er
 	return value, nil
 }
 
+func (a *memCache) SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error) {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	// Add fails if the key exists
+	return a.cache.Add(a.getKey(ns, key), value, exp) == nil, nil
+}
+
+func (a *memCache) DeleteIfEqual(ctx context.Context, ns, key, value string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	val, err := a.Get(ctx, ns, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	} else if val != value {
+		return false, nil
+	}
+
+	a.cache.Delete(a.getKey(ns, key))
+	return true, nil
+}
+
 func (a *memCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
 	for k, v := range a.cache.Items() {
 		if strings.HasPrefix(k, a.getKey(ns, "")) {
@@ -3,6 +3,7 @@ package cachex
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -31,6 +32,18 @@ func TestMemoryCache(t *testing.T) {
 	assert.Equal(ErrNotFound, err)
 	assert.Equal("", val)
 
+	// the key is only deleted with the value it holds
+	assert.Nil(cache.Set(ctx, "tt", "lock", "owner"))
+	deleted, err := cache.DeleteIfEqual(ctx, "tt", "lock", "other")
+	assert.Nil(err)
+	assert.False(deleted)
+	deleted, err = cache.DeleteIfEqual(ctx, "tt", "lock", "owner")
+	assert.Nil(err)
+	assert.True(deleted)
+	deleted, err = cache.DeleteIfEqual(ctx, "tt", "lock", "owner")
+	assert.Nil(err)
+	assert.False(deleted)
+
 	tmap := make(map[string]bool)
 	for i := 0; i < 10; i++ {
 		key := fmt.Sprintf("foo%d", i)
@@ -53,3 +66,102 @@ func TestMemoryCache(t *testing.T) {
 	err = cache.Close(ctx)
 	assert.Nil(err)
 }
+
+func TestMemoryCachePubSub(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := NewMemoryCache(MemoryConfig{
+		CleanupInterval: time.Second * 30,
+	})
+
+	ctx := context.Background()
+	var received []string
+	unsubscribe, err := cache.Subscribe(ctx, "foo", func(ctx context.Context, message string) {
+		received = append(received, message)
+	})
+	assert.Nil(err)
+
+	err = cache.Publish(ctx, "foo", "bar")
+	assert.Nil(err)
+	err = cache.Publish(ctx, "other", "bar")
+	assert.Nil(err)
+	assert.Equal([]string{"bar"}, received)
+
+	unsubscribe()
+	err = cache.Publish(ctx, "foo", "baz")
+	assert.Nil(err)
+	assert.Equal([]string{"bar"}, received)
+
+	err = cache.Close(ctx)
+	assert.Nil(err)
+}
+
+func TestMemoryCacheIncr(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := NewMemoryCache(MemoryConfig{
+		CleanupInterval: time.Second * 30,
+	})
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.Incr(ctx, "tt", "counter", time.Millisecond*100)
+			assert.Nil(err)
+		}()
+	}
+	wg.Wait()
+
+	count, err := cache.Incr(ctx, "tt", "counter", time.Hour)
+	assert.Nil(err)
+	assert.Equal(int64(51), count)
+
+	// the counter keeps the expiration of its creation
+	time.Sleep(time.Millisecond * 150)
+	count, err = cache.Incr(ctx, "tt", "counter")
+	assert.Nil(err)
+	assert.Equal(int64(1), count)
+}
+
+func TestMemoryCacheOnce(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := NewMemoryCache(MemoryConfig{
+		CleanupInterval: time.Second * 30,
+	})
+
+	ctx := context.Background()
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		set int
+		got int
+	)
+	assert.Nil(cache.Set(ctx, "tt", "once", "bar"))
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := cache.SetNX(ctx, "tt", "nx", "bar", time.Minute)
+			assert.Nil(err)
+			_, err = cache.GetAndDelete(ctx, "tt", "once")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				set++
+			}
+			if err == nil {
+				got++
+			}
+		}()
+	}
+	wg.Wait()
+
+	// only one of the concurrent calls sets or gets the value
+	assert.Equal(1, set)
+	assert.Equal(1, got)
+}
@@ -0,0 +1,53 @@
+package cachex
+
+import (
+	"context"
+	"sync"
+)
+
+// Handler of the messages published to a channel.
+type MessageHandler func(ctx context.Context, message string)
+
+// In-process broadcast of the messages, used by the caches which are not shared between processes.
+// The handlers are called synchronously by Publish.
+type broker struct {
+	lock     sync.RWMutex
+	seq      int
+	channels map[string]map[int]MessageHandler
+}
+
+func (a *broker) Publish(ctx context.Context, channel, message string) error {
+	a.lock.RLock()
+	handlers := make([]MessageHandler, 0, len(a.channels[channel]))
+	for _, fn := range a.channels[channel] {
+		handlers = append(handlers, fn)
+	}
+	a.lock.RUnlock()
+
+	for _, fn := range handlers {
+		fn(ctx, message)
+	}
+	return nil
+}
+
+func (a *broker) Subscribe(ctx context.Context, channel string, fn MessageHandler) (func(), error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.channels == nil {
+		a.channels = make(map[string]map[int]MessageHandler)
+	}
+	if a.channels[channel] == nil {
+		a.channels[channel] = make(map[int]MessageHandler)
+	}
+
+	a.seq++
+	id := a.seq
+	a.channels[channel][id] = fn
+
+	return func() {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		delete(a.channels[channel], id)
+	}, nil
+}
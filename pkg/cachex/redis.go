@@ -56,9 +56,14 @@ func newRedisCache(cli redisClienter, opts ...Option) Cacher {
 type redisClienter interface {
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
 	Get(ctx context.Context, key string) *redis.StringCmd
+	GetDel(ctx context.Context, key string) *redis.StringCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
 	Exists(ctx context.Context, keys ...string) *redis.IntCmd
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd
 	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
 	Close() error
 }
 
@@ -115,17 +120,58 @@ func (a *redisCache) Delete(ctx context.Context, ns, key string) error {
 	return nil
 }
 
+// Increase the counter and set its expiration only when it is created, in one step.
+var incrScript = `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 and tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`
+
+func (a *redisCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	return a.cli.Eval(ctx, incrScript, []string{a.getKey(ns, key)}, exp.Milliseconds()).Int64()
+}
+
 func (a *redisCache) GetAndDelete(ctx context.Context, ns, key string) (string, error) {
-	value, err := a.Get(ctx, ns, key)
-	if err != nil {
+	cmd := a.cli.GetDel(ctx, a.getKey(ns, key))
+	if err := cmd.Err(); err != nil {
+		if err == redis.Nil {
+			return "", ErrNotFound
+		}
 		return "", err
 	}
+	return cmd.Val(), nil
+}
 
-	cmd := a.cli.Del(ctx, a.getKey(ns, key))
-	if err := cmd.Err(); err != nil && err != redis.Nil {
-		return "", err
+func (a *redisCache) SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error) {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	return a.cli.SetNX(ctx, a.getKey(ns, key), value, exp).Result()
+}
+
+// Delete the key only if it holds the value, in one step.
+var deleteIfEqualScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+func (a *redisCache) DeleteIfEqual(ctx context.Context, ns, key, value string) (bool, error) {
+	n, err := a.cli.Eval(ctx, deleteIfEqualScript, []string{a.getKey(ns, key)}, value).Int64()
+	if err != nil {
+		return false, err
 	}
-	return value, nil
+	return n > 0, nil
 }
 
 func (a *redisCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
@@ -165,6 +211,29 @@ LB_LOOP:
 	return nil
 }
 
+func (a *redisCache) Publish(ctx context.Context, channel, message string) error {
+	return a.cli.Publish(ctx, channel, message).Err()
+}
+
+func (a *redisCache) Subscribe(ctx context.Context, channel string, fn MessageHandler) (func(), error) {
+	sub := a.cli.Subscribe(ctx, channel)
+	// wait for the subscription is confirmed, so no message published after it is missed
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+
+	go func() {
+		for msg := range sub.Channel() {
+			fn(ctx, msg.Payload)
+		}
+	}()
+
+	return func() {
+		_ = sub.Close()
+	}, nil
+}
+
 func (a *redisCache) Close(ctx context.Context) error {
 	return a.cli.Close()
 }
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRedisCache(t *testing.T) {
@@ -52,3 +54,33 @@ func TestRedisCache(t *testing.T) {
 	err = cache.Close(ctx)
 	assert.Nil(err)
 }
+
+func TestRedisCachePubSub(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := NewRedisCache(RedisConfig{
+		Addr: "localhost:6379",
+		DB:   1,
+	})
+
+	ctx := context.Background()
+	received := make(chan string, 1)
+	unsubscribe, err := cache.Subscribe(ctx, "foo", func(ctx context.Context, message string) {
+		received <- message
+	})
+	require.NoError(t, err)
+
+	err = cache.Publish(ctx, "foo", "bar")
+	assert.Nil(err)
+
+	select {
+	case message := <-received:
+		assert.Equal("bar", message)
+	case <-time.After(time.Second):
+		assert.Fail("message not received")
+	}
+
+	unsubscribe()
+	err = cache.Close(ctx)
+	assert.Nil(err)
+}
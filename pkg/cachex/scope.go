@@ -0,0 +1,73 @@
+package cachex
+
+import (
+	"context"
+	"time"
+)
+
+// Cache keeping the data of each scope in its own namespaces, the scope is taken from the context, e.g. the tenant of the request.
+// The context without scope reads and writes the namespaces as they are, the channels of messages are never scoped.
+type scopedCache struct {
+	Cacher
+	scope func(ctx context.Context) string
+	opts  *options
+}
+
+func NewScopedCache(cache Cacher, scope func(ctx context.Context) string, opts ...Option) Cacher {
+	defaultOpts := &options{
+		Delimiter: defaultDelimiter,
+	}
+
+	for _, o := range opts {
+		o(defaultOpts)
+	}
+
+	return &scopedCache{
+		Cacher: cache,
+		scope:  scope,
+		opts:   defaultOpts,
+	}
+}
+
+func (a *scopedCache) ns(ctx context.Context, ns string) string {
+	if scope := a.scope(ctx); scope != "" {
+		return scope + a.opts.Delimiter + ns
+	}
+	return ns
+}
+
+func (a *scopedCache) Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error {
+	return a.Cacher.Set(ctx, a.ns(ctx, ns), key, value, expiration...)
+}
+
+func (a *scopedCache) Get(ctx context.Context, ns, key string) (string, error) {
+	return a.Cacher.Get(ctx, a.ns(ctx, ns), key)
+}
+
+func (a *scopedCache) GetAndDelete(ctx context.Context, ns, key string) (string, error) {
+	return a.Cacher.GetAndDelete(ctx, a.ns(ctx, ns), key)
+}
+
+func (a *scopedCache) SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error) {
+	return a.Cacher.SetNX(ctx, a.ns(ctx, ns), key, value, expiration...)
+}
+
+func (a *scopedCache) DeleteIfEqual(ctx context.Context, ns, key, value string) (bool, error) {
+	return a.Cacher.DeleteIfEqual(ctx, a.ns(ctx, ns), key, value)
+}
+
+func (a *scopedCache) Exists(ctx context.Context, ns, key string) (bool, error) {
+	return a.Cacher.Exists(ctx, a.ns(ctx, ns), key)
+}
+
+func (a *scopedCache) Delete(ctx context.Context, ns, key string) error {
+	return a.Cacher.Delete(ctx, a.ns(ctx, ns), key)
+}
+
+func (a *scopedCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return a.Cacher.Incr(ctx, a.ns(ctx, ns), key, expiration...)
+}
+
+func (a *scopedCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
+	return a.Cacher.Iterator(ctx, a.ns(ctx, ns), fn)
+}
@@ -0,0 +1,73 @@
+package cachex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type scopeCtx struct{}
+
+func TestScopedCache(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := NewScopedCache(NewMemoryCache(MemoryConfig{CleanupInterval: time.Second * 30}), func(ctx context.Context) string {
+		v, _ := ctx.Value(scopeCtx{}).(string)
+		return v
+	})
+
+	ctx := context.Background()
+	a := context.WithValue(ctx, scopeCtx{}, "a")
+	b := context.WithValue(ctx, scopeCtx{}, "b")
+
+	assert.Nil(cache.Set(a, "tt", "foo", "a"))
+	assert.Nil(cache.Set(b, "tt", "foo", "b"))
+	assert.Nil(cache.Set(ctx, "tt", "foo", "none"))
+
+	// each scope only sees its own data
+	for c, expected := range map[context.Context]string{a: "a", b: "b", ctx: "none"} {
+		val, err := cache.Get(c, "tt", "foo")
+		assert.Nil(err)
+		assert.Equal(expected, val)
+	}
+
+	assert.Nil(cache.Delete(a, "tt", "foo"))
+	exists, err := cache.Exists(a, "tt", "foo")
+	assert.Nil(err)
+	assert.False(exists)
+	exists, err = cache.Exists(b, "tt", "foo")
+	assert.Nil(err)
+	assert.True(exists)
+
+	var keys []string
+	err = cache.Iterator(b, "tt", func(ctx context.Context, key, value string) bool {
+		keys = append(keys, key+"="+value)
+		return true
+	})
+	assert.Nil(err)
+	assert.Equal([]string{"foo=b"}, keys)
+
+	val, err := cache.GetAndDelete(ctx, "tt", "foo")
+	assert.Nil(err)
+	assert.Equal("none", val)
+
+	// the messages are not scoped
+	received := make(chan string, 1)
+	unsubscribe, err := cache.Subscribe(a, "channel", func(ctx context.Context, message string) {
+		received <- message
+	})
+	assert.Nil(err)
+	defer unsubscribe()
+
+	assert.Nil(cache.Publish(b, "channel", "hello"))
+	select {
+	case message := <-received:
+		assert.Equal("hello", message)
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+
+	assert.Nil(cache.Close(ctx))
+}
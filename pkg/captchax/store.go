@@ -0,0 +1,77 @@
+package captchax
+
+import (
+	"context"
+	"time"
+
+	"github.com/LyricTian/captcha/store"
+)
+
+// Cacher is the subset of cachex.Cacher used by the captcha store.
+type Cacher interface {
+	Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error
+	Get(ctx context.Context, ns, key string) (string, error)
+	GetAndDelete(ctx context.Context, ns, key string) (string, error)
+}
+
+type storeOptions struct {
+	CacheNS    string        // default "captcha"
+	Expiration time.Duration // default 10 minutes
+}
+
+type StoreOption func(*storeOptions)
+
+func WithCacheNS(ns string) StoreOption {
+	return func(o *storeOptions) {
+		o.CacheNS = ns
+	}
+}
+
+func WithExpiration(expiration time.Duration) StoreOption {
+	return func(o *storeOptions) {
+		o.Expiration = expiration
+	}
+}
+
+// Create a captcha store on top of the cache, so that captchas can be shared between multiple instances.
+func NewStoreWithCache(cache Cacher, opts ...StoreOption) store.Store {
+	s := &storeImpl{
+		c: cache,
+		opts: &storeOptions{
+			CacheNS:    "captcha",
+			Expiration: 10 * time.Minute,
+		},
+	}
+	for _, opt := range opts {
+		opt(s.opts)
+	}
+	return s
+}
+
+type storeImpl struct {
+	opts *storeOptions
+	c    Cacher
+}
+
+func (s *storeImpl) Set(id string, digits []byte) {
+	_ = s.c.Set(context.Background(), s.opts.CacheNS, id, string(digits), s.opts.Expiration)
+}
+
+// Get returns the stored digits, when clear is true the captcha is consumed (one-time use).
+func (s *storeImpl) Get(id string, clear bool) []byte {
+	var (
+		val string
+		err error
+	)
+
+	ctx := context.Background()
+	if clear {
+		val, err = s.c.GetAndDelete(ctx, s.opts.CacheNS, id)
+	} else {
+		val, err = s.c.Get(ctx, s.opts.CacheNS, id)
+	}
+	if err != nil || val == "" {
+		return nil
+	}
+	return []byte(val)
+}
@@ -0,0 +1,39 @@
+package captchax
+
+import (
+	"testing"
+	"time"
+
+	"gin-admin/pkg/cachex"
+
+	"github.com/LyricTian/captcha"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	cache := cachex.NewMemoryCache(cachex.MemoryConfig{CleanupInterval: time.Second})
+	store := NewStoreWithCache(cache, WithExpiration(time.Minute))
+
+	store.Set("foo", []byte{1, 2, 3, 4})
+	assert.Equal(t, []byte{1, 2, 3, 4}, store.Get("foo", false))
+	assert.Equal(t, []byte{1, 2, 3, 4}, store.Get("foo", true))
+	assert.Nil(t, store.Get("foo", false))
+}
+
+func TestVerifyOnce(t *testing.T) {
+	cache := cachex.NewMemoryCache(cachex.MemoryConfig{CleanupInterval: time.Second})
+	store := NewStoreWithCache(cache)
+	captcha.SetCustomStore(store)
+
+	id := captcha.NewLen(4)
+	digits := store.Get(id, false)
+	assert.Len(t, digits, 4)
+
+	code := make([]byte, len(digits))
+	for i, d := range digits {
+		code[i] = '0' + d
+	}
+
+	assert.True(t, captcha.VerifyString(id, string(code)))
+	assert.False(t, captcha.VerifyString(id, string(code)))
+}
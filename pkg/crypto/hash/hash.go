@@ -3,6 +3,7 @@ package hash
 import (
 	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
 	"fmt"
 
 	"golang.org/x/crypto/bcrypt"
@@ -32,6 +33,18 @@ func SHA1String(s string) string {
 	return SHA1([]byte(s))
 }
 
+// sha256 hash
+func SHA256(b []byte) string {
+	h := sha256.New()
+	_, _ = h.Write(b)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// sha256 hash
+func SHA256String(s string) string {
+	return SHA256([]byte(s))
+}
+
 // Use bcrypt generate password hash
 func GeneratePassword(password string) (string, error) {
 	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -24,3 +24,11 @@ func TestMD5(t *testing.T) {
 		t.Error("Failed to generate MD5 hash: ", v)
 	}
 }
+
+func TestSHA256(t *testing.T) {
+	origin := "abc-123"
+	hashVal := "5942d94f524882e0f29bf0a1e5a6dcc952eea1c0c21dd3588a3fc7db9716db0c"
+	if v := SHA256String(origin); v != hashVal {
+		t.Error("Failed to generate SHA256 hash: ", v)
+	}
+}
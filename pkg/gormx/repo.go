@@ -3,6 +3,8 @@ package gormx
 import (
 	"context"
 
+	"gin-admin/pkg/helper"
+
 	"gorm.io/gorm"
 )
 
@@ -60,6 +62,15 @@ type Repository[T Entity] interface {
 	DB() *gorm.DB
 }
 
+// GetDB 获取数据库实例
+// 上下文中有事务(helper.WithTrans)时使用该事务
+func GetDB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := helper.GetTrans(ctx); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
 // GenericRepo 通用仓库实现
 type GenericRepo[T Entity] struct {
 	db *gorm.DB
@@ -74,12 +85,12 @@ func NewGenericRepo[T Entity](db *gorm.DB) *GenericRepo[T] {
 
 // Create 创建实体
 func (r *GenericRepo[T]) Create(ctx context.Context, entity *T, opts ...Option) error {
-	query := Apply(r.db.WithContext(ctx), opts...)
+	query := Apply(GetDB(ctx, r.db), opts...)
 	return query.Create(entity).Error
 }
 
 func (r *GenericRepo[T]) CreateBatch(ctx context.Context, entities []*T, batchSize int, opts ...Option) error {
-	query := Apply(r.db.WithContext(ctx), opts...)
+	query := Apply(GetDB(ctx, r.db), opts...)
 	return query.CreateInBatches(entities, batchSize).Error
 }
 
@@ -88,7 +99,7 @@ func (r *GenericRepo[T]) Get(ctx context.Context, id any, opts ...Option) (*T, e
 	var entity T
 
 	// 创建查询并应用选项
-	query := Apply(r.db.WithContext(ctx), opts...)
+	query := Apply(GetDB(ctx, r.db), opts...)
 
 	if id != nil {
 		query = query.Where("id = ?", id)
@@ -110,7 +121,7 @@ func (r *GenericRepo[T]) First(ctx context.Context, opts ...Option) (*T, error)
 	var entity T
 
 	// 创建查询并应用选项
-	query := Apply(r.db.WithContext(ctx), opts...)
+	query := Apply(GetDB(ctx, r.db), opts...)
 
 	if len(query.Statement.Clauses) == 0 {
 		// 没有查询条件，返回错误
@@ -124,21 +135,21 @@ func (r *GenericRepo[T]) First(ctx context.Context, opts ...Option) (*T, error)
 
 // Update 更新实体
 func (r *GenericRepo[T]) Update(ctx context.Context, entity *T, opts ...Option) error {
-	query := Apply(r.db.WithContext(ctx), opts...)
+	query := Apply(GetDB(ctx, r.db), opts...)
 	return query.Updates(entity).Error
 }
 
 // Delete 删除实体
 func (r *GenericRepo[T]) Delete(ctx context.Context, id any, opts ...Option) error {
 	var entity T
-	query := Apply(r.db.WithContext(ctx), opts...)
+	query := Apply(GetDB(ctx, r.db), opts...)
 	return query.Where("id = ?", id).Delete(&entity).Error
 }
 
 // DeleteBatch 删除实体
 func (r *GenericRepo[T]) DeleteBatch(ctx context.Context, opts ...Option) error {
 	var entity T
-	query := Apply(r.db.WithContext(ctx), opts...)
+	query := Apply(GetDB(ctx, r.db), opts...)
 
 	if len(query.Statement.Clauses) == 0 {
 		// 没有查询条件，返回错误
@@ -153,7 +164,7 @@ func (r *GenericRepo[T]) Find(ctx context.Context, opts ...Option) ([]*T, error)
 	var entities []*T
 
 	// 创建查询
-	query := r.db.WithContext(ctx)
+	query := GetDB(ctx, r.db)
 
 	// 应用查询选项
 	query = Apply(query, opts...)
@@ -169,7 +180,7 @@ func (r *GenericRepo[T]) Count(ctx context.Context, opts ...Option) (int64, erro
 	var entity T
 
 	// 创建查询
-	query := r.db.WithContext(ctx).Model(&entity)
+	query := GetDB(ctx, r.db).Model(&entity)
 
 	// 应用查询选项
 	query = Apply(query, opts...)
@@ -189,8 +200,9 @@ func (r *GenericRepo[T]) Exists(ctx context.Context, opts ...Option) (bool, erro
 }
 
 // Transaction 在事务中执行函数
+// 上下文中已有事务时，在该事务中嵌套执行
 func (r *GenericRepo[T]) Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
-	return r.db.WithContext(ctx).Transaction(fn)
+	return GetDB(ctx, r.db).Transaction(fn)
 }
 
 // WithTx 使用事务
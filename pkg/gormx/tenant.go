@@ -0,0 +1,104 @@
+package gormx
+
+import (
+	"context"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const defaultTenantField = "TenantID"
+
+// TenantPlugin scopes the models with a tenant field to the tenant of the statement context,
+// the field is filled on create and the queries, updates and deletes are limited to the rows of the tenant.
+type TenantPlugin struct {
+	Field       string                                   // Name of the tenant field, TenantID by default
+	GetTenantID func(ctx context.Context) (string, bool) // Get the tenant of the context, the statement is not scoped if false
+}
+
+var _ gorm.Plugin = (*TenantPlugin)(nil)
+
+func (p *TenantPlugin) Name() string {
+	return "gormx:tenant"
+}
+
+func (p *TenantPlugin) Initialize(db *gorm.DB) error {
+	if p.Field == "" {
+		p.Field = defaultTenantField
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("gormx:tenant_create", p.fill); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("gormx:tenant_query", p.scope); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("gormx:tenant_row", p.scope); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("gormx:tenant_update", p.scope); err != nil {
+		return err
+	}
+	return cb.Delete().Before("gorm:delete").Register("gormx:tenant_delete", p.scope)
+}
+
+// Get the tenant field of the statement model and the tenant of the context.
+func (p *TenantPlugin) lookup(db *gorm.DB) (*schema.Field, string, bool) {
+	if db.Error != nil || db.Statement.Schema == nil || p.GetTenantID == nil {
+		return nil, "", false
+	}
+
+	field := db.Statement.Schema.LookUpField(p.Field)
+	if field == nil || field.DBName == "" {
+		return nil, "", false
+	}
+
+	tenantID, ok := p.GetTenantID(db.Statement.Context)
+	return field, tenantID, ok
+}
+
+// Fill the tenant of the created rows which have no tenant yet.
+func (p *TenantPlugin) fill(db *gorm.DB) {
+	field, tenantID, ok := p.lookup(db)
+	if !ok {
+		return
+	}
+
+	ctx := db.Statement.Context
+	set := func(rv reflect.Value) {
+		rv = reflect.Indirect(rv)
+		if rv.Kind() != reflect.Struct {
+			return
+		}
+		if _, isZero := field.ValueOf(ctx, rv); isZero {
+			if err := field.Set(ctx, rv, tenantID); err != nil {
+				_ = db.AddError(err)
+			}
+		}
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			set(rv.Index(i))
+		}
+	case reflect.Struct:
+		set(rv)
+	}
+}
+
+// Limit the statement to the rows of the tenant.
+func (p *TenantPlugin) scope(db *gorm.DB) {
+	field, tenantID, ok := p.lookup(db)
+	if !ok {
+		return
+	}
+
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenantID},
+	}})
+}
@@ -0,0 +1,84 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type tenantCtx struct{}
+
+type tenantItem struct {
+	ID       int
+	Name     string
+	TenantID string
+}
+
+type plainItem struct {
+	ID   int
+	Name string
+}
+
+func withTenant(tenantID string) context.Context {
+	return context.WithValue(context.Background(), tenantCtx{}, tenantID)
+}
+
+func TestTenantPlugin(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, db.Use(&TenantPlugin{
+		GetTenantID: func(ctx context.Context) (string, bool) {
+			v, ok := ctx.Value(tenantCtx{}).(string)
+			return v, ok
+		},
+	}))
+	assert.Nil(t, db.AutoMigrate(new(tenantItem), new(plainItem)))
+
+	a, b := withTenant("a"), withTenant("b")
+
+	// the tenant is filled on create unless given
+	assert.Nil(t, db.WithContext(a).Create(&[]*tenantItem{{Name: "a1"}, {Name: "a2"}}).Error)
+	assert.Nil(t, db.WithContext(b).Create(&tenantItem{Name: "b1"}).Error)
+	assert.Nil(t, db.WithContext(b).Create(&tenantItem{Name: "c1", TenantID: "c"}).Error)
+	assert.Nil(t, db.Create(&tenantItem{Name: "platform"}).Error)
+
+	var items []*tenantItem
+	assert.Nil(t, db.Order("id").Find(&items).Error)
+	if assert.Len(t, items, 5) {
+		assert.Equal(t, []string{"a", "a", "b", "c", ""}, []string{items[0].TenantID, items[1].TenantID, items[2].TenantID, items[3].TenantID, items[4].TenantID})
+	}
+
+	// the queries are limited to the tenant, the context without tenant is not scoped
+	var count int64
+	assert.Nil(t, db.WithContext(a).Model(new(tenantItem)).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+	assert.Nil(t, db.WithContext(withTenant("")).Model(new(tenantItem)).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	var item tenantItem
+	assert.ErrorIs(t, db.WithContext(b).Where("name = ?", "a1").First(&item).Error, gorm.ErrRecordNotFound)
+	assert.Nil(t, db.WithContext(a).Table("tenant_items AS t").Where("t.name = ?", "a1").First(&item).Error)
+	assert.Equal(t, "a1", item.Name)
+
+	// the updates and deletes do not reach the rows of the other tenants
+	result := db.WithContext(b).Model(new(tenantItem)).Where("name LIKE ?", "a%").Update("name", "changed")
+	assert.Nil(t, result.Error)
+	assert.Equal(t, int64(0), result.RowsAffected)
+
+	result = db.WithContext(a).Where("name = ?", "b1").Delete(new(tenantItem))
+	assert.Nil(t, result.Error)
+	assert.Equal(t, int64(0), result.RowsAffected)
+
+	result = db.WithContext(a).Where("name = ?", "a1").Delete(new(tenantItem))
+	assert.Nil(t, result.Error)
+	assert.Equal(t, int64(1), result.RowsAffected)
+
+	// the models without tenant field are never scoped
+	assert.Nil(t, db.WithContext(a).Create(&plainItem{Name: "plain"}).Error)
+	assert.Nil(t, db.WithContext(b).Model(new(plainItem)).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
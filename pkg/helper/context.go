@@ -13,6 +13,13 @@ type (
 	userIDCtx     struct{}
 	userTokenCtx  struct{}
 	isRootUserCtx struct{}
+	clientIPCtx   struct{}
+	userAgentCtx  struct{}
+	sessionIDCtx  struct{}
+	apiKeyIDCtx   struct{}
+	apiKeyRoleCtx struct{}
+	mfaCtx        struct{}
+	tenantIDCtx   struct{}
 )
 
 func WithTraceID(ctx context.Context, traceID string) context.Context {
@@ -80,3 +87,94 @@ func GetIsRootUser(ctx context.Context) bool {
 	v := ctx.Value(isRootUserCtx{})
 	return v != nil && v.(bool)
 }
+
+func WithClientIP(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, clientIPCtx{}, clientIP)
+}
+
+func GetClientIP(ctx context.Context) string {
+	v := ctx.Value(clientIPCtx{})
+	if v != nil {
+		return v.(string)
+	}
+	return ""
+}
+
+func WithUserAgent(ctx context.Context, userAgent string) context.Context {
+	return context.WithValue(ctx, userAgentCtx{}, userAgent)
+}
+
+func GetUserAgent(ctx context.Context) string {
+	v := ctx.Value(userAgentCtx{})
+	if v != nil {
+		return v.(string)
+	}
+	return ""
+}
+
+func WithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionIDCtx{}, sessionID)
+}
+
+func GetSessionID(ctx context.Context) string {
+	v := ctx.Value(sessionIDCtx{})
+	if v != nil {
+		return v.(string)
+	}
+	return ""
+}
+
+func WithAPIKeyID(ctx context.Context, apiKeyID string) context.Context {
+	return context.WithValue(ctx, apiKeyIDCtx{}, apiKeyID)
+}
+
+// Get the ID of the API key which authenticated the request, empty if authenticated by token
+func GetAPIKeyID(ctx context.Context) string {
+	v := ctx.Value(apiKeyIDCtx{})
+	if v != nil {
+		return v.(string)
+	}
+	return ""
+}
+
+func WithAPIKeyRoleIDs(ctx context.Context, roleIDs []string) context.Context {
+	return context.WithValue(ctx, apiKeyRoleCtx{}, roleIDs)
+}
+
+// Get the roles the API key is scoped to, false if the request is not limited by roles of an API key
+func GetAPIKeyRoleIDs(ctx context.Context) ([]string, bool) {
+	v := ctx.Value(apiKeyRoleCtx{})
+	if v != nil {
+		return v.([]string), true
+	}
+	return nil, false
+}
+
+func WithMFAVerified(ctx context.Context) context.Context {
+	return context.WithValue(ctx, mfaCtx{}, true)
+}
+
+// Whether the user passed two-factor authentication at login
+func GetMFAVerified(ctx context.Context) bool {
+	v := ctx.Value(mfaCtx{})
+	return v != nil && v.(bool)
+}
+
+// Set the tenant of the request, the empty ID is the platform which owns the data out of any tenant.
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDCtx{}, tenantID)
+}
+
+// Drop the tenant of the context, the data of all the tenants are accessible with it.
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantIDCtx{}, nil)
+}
+
+// Get the tenant of the request, false if the tenant is not resolved and the data are not scoped by tenant
+func GetTenantID(ctx context.Context) (string, bool) {
+	v := ctx.Value(tenantIDCtx{})
+	if v != nil {
+		return v.(string), true
+	}
+	return "", false
+}
@@ -1,6 +1,7 @@
 package helper
 
 import (
+	"context"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -50,6 +51,17 @@ func GetRefreshToken(c *gin.Context) string {
 	return token
 }
 
+// Get API key from header
+func GetAPIKey(c *gin.Context) string {
+	return c.GetHeader("X-API-Key")
+}
+
+// Put client IP and user agent of the request into context
+func WithClient(c *gin.Context) context.Context {
+	ctx := WithClientIP(c.Request.Context(), c.ClientIP())
+	return WithUserAgent(ctx, c.Request.UserAgent())
+}
+
 // Get request body from context
 func GetRequestBody(c *gin.Context) []byte {
 	if v, ok := c.Get(keyRequestBody); ok {
@@ -5,3 +5,44 @@ import "github.com/golang-jwt/jwt/v5"
 type TokenClaims interface {
 	jwt.Claims
 }
+
+// Claims of both access and refresh token.
+type Claims struct {
+	jwt.RegisteredClaims
+	Family     string `json:"fam,omitempty"` // ID of the token family, all tokens rotated from the same login share it
+	Generation int64  `json:"gen,omitempty"` // Token generation of the subject at login, kept on rotation
+	MFA        bool   `json:"mfa,omitempty"` // Whether the subject passed two-factor authentication at login, kept on rotation
+	Tenant     string `json:"tid,omitempty"` // Tenant of the subject at login, kept on rotation
+}
+
+// Get the claim ID (jti) of the claims, which is also the session ID.
+func GetClaimID(claims TokenClaims) string {
+	if c, ok := claims.(*Claims); ok {
+		return c.ID
+	}
+	return ""
+}
+
+// Get the token generation of the claims.
+func GetClaimGeneration(claims TokenClaims) int64 {
+	if c, ok := claims.(*Claims); ok {
+		return c.Generation
+	}
+	return 0
+}
+
+// Whether the subject of the claims passed two-factor authentication at login.
+func GetClaimMFA(claims TokenClaims) bool {
+	if c, ok := claims.(*Claims); ok {
+		return c.MFA
+	}
+	return false
+}
+
+// Get the tenant of the subject of the claims, empty if the subject is not in a tenant.
+func GetClaimTenant(claims TokenClaims) string {
+	if c, ok := claims.(*Claims); ok {
+		return c.Tenant
+	}
+	return ""
+}
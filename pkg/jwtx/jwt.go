@@ -10,14 +10,25 @@ import (
 )
 
 type Auther interface {
-	// Generate a JWT (JSON Web Token) with the provided subject.
-	GenerateToken(ctx context.Context, subject string) (TokenInfo, error)
+	// Generate a JWT (JSON Web Token) with the provided subject, and register the session of it.
+	GenerateToken(ctx context.Context, subject string, opts ...TokenOption) (TokenInfo, error)
 	// Invalidate a token by removing it from the token store.
 	DestroyToken(ctx context.Context, accessToken string) error
+	// List the active sessions of the subject.
+	ListSessions(ctx context.Context, subject string) ([]*Session, error)
+	// Get the session by claim ID.
+	GetSession(ctx context.Context, id string) (*Session, error)
+	// Invalidate both the access and refresh token of the session.
+	RevokeSession(ctx context.Context, id string) error
 	// Parse from a given access token.
 	ParseToken(ctx context.Context, accessToken string) (TokenClaims, error)
 	// Parse from a given refresh token.
 	ParseRefreshToken(ctx context.Context, refreshToken string) (TokenClaims, error)
+	// Exchange a refresh token for a new token pair of the same family, the refresh token can only be used once.
+	// If an used refresh token is presented again, the whole family is revoked and ErrTokenReused is returned with the claims.
+	RefreshToken(ctx context.Context, refreshToken string, opts ...TokenOption) (TokenInfo, TokenClaims, error)
+	// Get the public verification keys as JSON Web Key Set, HMAC keys are excluded.
+	JWKS() *JWKS
 	// Release any resources held by the JWTAuth instance.
 	Release(ctx context.Context) error
 }
@@ -25,32 +36,36 @@ type Auther interface {
 const defaultSigningKey = "CG24SDVP8OHPK395GB5G"
 const defaultRefreshKey = "IOW3846N73946NLS0"
 
-var ErrInvalidToken = errors.New("invalid token")
+// The last seen time of session is updated at most once per interval.
+const sessionTouchInterval = time.Minute
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenReused  = errors.New("refresh token reused")
+)
 
 type options struct {
-	signingMethod jwt.SigningMethod
-	signingKey    []byte
-	signingKey2   []byte
-	refreshKey    []byte
-	keyFuncs      []func(*jwt.Token) (interface{}, error)
-	expired       int // second
-	tokenType     string
+	signingKey     *Key
+	verifyKeys     []*Key
+	refreshKey     []byte
+	expired        int // second
+	refreshExpired int // second
+	tokenType      string
 }
 
 type Option func(*options)
 
-func SetSigningMethod(method jwt.SigningMethod) Option {
+// Set the key to sign access tokens, it also verifies tokens.
+func SetSigningKey(key *Key) Option {
 	return func(o *options) {
-		o.signingMethod = method
+		o.signingKey = key
 	}
 }
 
-func SetSigningKey(key, oldKey string) Option {
+// Add keys which only verify access tokens, e.g. keys retired by rotation.
+func AddVerifyKeys(keys ...*Key) Option {
 	return func(o *options) {
-		o.signingKey = []byte(key)
-		if oldKey != "" && key != oldKey {
-			o.signingKey2 = []byte(oldKey)
-		}
+		o.verifyKeys = append(o.verifyKeys, keys...)
 	}
 }
 
@@ -66,35 +81,29 @@ func SetExpired(expired int) Option {
 	}
 }
 
+func SetRefreshExpired(expired int) Option {
+	return func(o *options) {
+		o.refreshExpired = expired
+	}
+}
+
 func New(store Storer, opts ...Option) Auther {
 	o := options{
-		tokenType:     "Bearer",
-		expired:       7200,
-		signingMethod: jwt.SigningMethodHS512,
-		signingKey:    []byte(defaultSigningKey),
-		refreshKey:    []byte(defaultRefreshKey),
+		tokenType:      "Bearer",
+		expired:        7200,
+		refreshExpired: 2592000,
+		signingKey: &Key{
+			Method: jwt.SigningMethodHS512,
+			Sign:   []byte(defaultSigningKey),
+			Verify: []byte(defaultSigningKey),
+		},
+		refreshKey: []byte(defaultRefreshKey),
 	}
 
 	for _, opt := range opts {
 		opt(&o)
 	}
 
-	o.keyFuncs = append(o.keyFuncs, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return o.signingKey, nil
-	})
-
-	if o.signingKey2 != nil {
-		o.keyFuncs = append(o.keyFuncs, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, ErrInvalidToken
-			}
-			return o.signingKey2, nil
-		})
-	}
-
 	return &JWTAuth{
 		opts:  &o,
 		store: store,
@@ -106,37 +115,83 @@ type JWTAuth struct {
 	store Storer
 }
 
-func (a *JWTAuth) GenerateToken(ctx context.Context, subject string) (TokenInfo, error) {
+// All keys can verify access tokens, the signing key first.
+func (a *JWTAuth) keys() []*Key {
+	return append([]*Key{a.opts.signingKey}, a.opts.verifyKeys...)
+}
+
+// The refresh token is only consumed by ourselves, so it is always signed with HMAC.
+func (a *JWTAuth) refreshMethod() jwt.SigningMethod {
+	if _, ok := a.opts.signingKey.Method.(*jwt.SigningMethodHMAC); ok {
+		return a.opts.signingKey.Method
+	}
+	return jwt.SigningMethodHS512
+}
+
+func (a *JWTAuth) GenerateToken(ctx context.Context, subject string, opts ...TokenOption) (TokenInfo, error) {
+	return a.generateToken(ctx, subject, "", opts...)
+}
+
+func (a *JWTAuth) generateToken(ctx context.Context, subject, family string, opts ...TokenOption) (TokenInfo, error) {
+	var to tokenOptions
+	for _, opt := range opts {
+		opt(&to)
+	}
+
 	now := time.Now()
 	r, _ := uuid.NewRandom()
 	claimID := r.String()
+	if family == "" {
+		family = claimID
+	}
 	expiresAt := now.Add(time.Duration(a.opts.expired) * time.Second)
-	refreshExpiresAt := now.Add(time.Duration(a.opts.expired*30) * time.Second)
+	refreshExpiresAt := now.Add(time.Duration(a.opts.refreshExpired) * time.Second)
 
-	accessClaims := jwt.RegisteredClaims{}
+	accessClaims := Claims{Family: family, Generation: to.generation, MFA: to.mfa, Tenant: to.tenant}
 	accessClaims.ID = claimID
 	accessClaims.IssuedAt = &jwt.NumericDate{Time: now}
 	accessClaims.ExpiresAt = &jwt.NumericDate{Time: expiresAt}
 	accessClaims.NotBefore = &jwt.NumericDate{Time: now}
 	accessClaims.Subject = subject
-	accessToken := jwt.NewWithClaims(a.opts.signingMethod, &accessClaims)
-	accessTokenStr, err := accessToken.SignedString(a.opts.signingKey)
+	accessToken := jwt.NewWithClaims(a.opts.signingKey.Method, &accessClaims)
+	if kid := a.opts.signingKey.ID; kid != "" {
+		accessToken.Header["kid"] = kid
+	}
+	accessTokenStr, err := accessToken.SignedString(a.opts.signingKey.Sign)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshClaims := jwt.RegisteredClaims{}
+	refreshClaims := Claims{Family: family, Generation: to.generation, MFA: to.mfa, Tenant: to.tenant}
 	refreshClaims.ID = claimID
 	refreshClaims.IssuedAt = &jwt.NumericDate{Time: now}
 	refreshClaims.ExpiresAt = &jwt.NumericDate{Time: refreshExpiresAt}
 	refreshClaims.NotBefore = &jwt.NumericDate{Time: now}
 	refreshClaims.Subject = subject
-	refreshToken := jwt.NewWithClaims(a.opts.signingMethod, &refreshClaims)
+	refreshToken := jwt.NewWithClaims(a.refreshMethod(), &refreshClaims)
 	refreshTokenStr, err := refreshToken.SignedString(a.opts.refreshKey)
 	if err != nil {
 		return nil, err
 	}
 
+	err = a.callStore(func(store Storer) error {
+		session := &Session{
+			ID:         claimID,
+			Family:     family,
+			Subject:    subject,
+			ClientIP:   to.clientIP,
+			UserAgent:  to.userAgent,
+			Location:   to.location,
+			IssuedAt:   now,
+			LastSeenAt: now,
+			ExpiresAt:  refreshExpiresAt,
+		}
+		return store.SetSession(ctx, session, time.Until(refreshExpiresAt))
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	tokenInfo := &tokenInfo{
 		Expires:      expiresAt.Unix(),
 		TokenType:    a.opts.tokenType,
@@ -146,34 +201,41 @@ func (a *JWTAuth) GenerateToken(ctx context.Context, subject string) (TokenInfo,
 	return tokenInfo, nil
 }
 
-func (a *JWTAuth) parseToken(tokenStr string) (*jwt.RegisteredClaims, error) {
-	var (
-		token *jwt.Token
-		err   error
-	)
+func (a *JWTAuth) parseToken(tokenStr string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
 
-	for _, keyFunc := range a.opts.keyFuncs {
-		token, err = jwt.ParseWithClaims(tokenStr, &jwt.RegisteredClaims{}, keyFunc)
-		if err != nil || token == nil || !token.Valid {
-			continue
+		var keys []jwt.VerificationKey
+		for _, key := range a.keys() {
+			if key.Verify == nil || key.Method.Alg() != t.Method.Alg() {
+				continue
+			}
+			if kid != "" && key.ID != kid {
+				continue
+			}
+			keys = append(keys, key.Verify)
 		}
-		break
-	}
+
+		if len(keys) == 0 {
+			return nil, ErrInvalidToken
+		}
+		return jwt.VerificationKeySet{Keys: keys}, nil
+	})
 
 	if err != nil || token == nil || !token.Valid {
 		return nil, ErrInvalidToken
 	}
 
-	return token.Claims.(*jwt.RegisteredClaims), nil
+	return token.Claims.(*Claims), nil
 }
 
-func (a *JWTAuth) parseRefreshToken(tokenStr string) (*jwt.RegisteredClaims, error) {
+func (a *JWTAuth) parseRefreshToken(tokenStr string) (*Claims, error) {
 	var (
 		token *jwt.Token
 		err   error
 	)
 
-	token, err = jwt.ParseWithClaims(tokenStr, &jwt.RegisteredClaims{}, func(t *jwt.Token) (interface{}, error) {
+	token, err = jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, ErrInvalidToken
 		}
@@ -184,7 +246,7 @@ func (a *JWTAuth) parseRefreshToken(tokenStr string) (*jwt.RegisteredClaims, err
 		return nil, ErrInvalidToken
 	}
 
-	return token.Claims.(*jwt.RegisteredClaims), nil
+	return token.Claims.(*Claims), nil
 }
 
 func (a *JWTAuth) callStore(fn func(Storer) error) error {
@@ -200,11 +262,63 @@ func (a *JWTAuth) DestroyToken(ctx context.Context, tokenStr string) error {
 		return err
 	}
 
-	expiresAt, _ := claims.GetExpirationTime()
+	return a.RevokeSession(ctx, claims.ID)
+}
+
+func (a *JWTAuth) ListSessions(ctx context.Context, subject string) ([]*Session, error) {
+	var sessions []*Session
+	err := a.callStore(func(store Storer) error {
+		list, err := store.ListSessions(ctx, subject)
+		sessions = list
+		return err
+	})
+	return sessions, err
+}
+
+func (a *JWTAuth) GetSession(ctx context.Context, id string) (*Session, error) {
+	var session *Session
+	err := a.callStore(func(store Storer) error {
+		s, err := store.GetSession(ctx, id)
+		session = s
+		return err
+	})
+	if err == nil && session == nil {
+		return nil, ErrSessionNotFound
+	}
+	return session, err
+}
 
+func (a *JWTAuth) RevokeSession(ctx context.Context, id string) error {
 	return a.callStore(func(store Storer) error {
-		expired := time.Until(expiresAt.Time)
-		return store.Set(ctx, claims.ID, expired)
+		// the access and refresh token share the claim ID, block it until the refresh token expires
+		expired := time.Duration(a.opts.refreshExpired) * time.Second
+		if session, err := store.GetSession(ctx, id); err == nil {
+			expired = time.Until(session.ExpiresAt)
+		}
+
+		if expired > 0 {
+			if err := store.Set(ctx, id, expired); err != nil {
+				return err
+			}
+		}
+		return store.DeleteSession(ctx, id)
+	})
+}
+
+// Update the last seen time of the session, the session itself is not written back so a concurrent revocation sticks.
+func (a *JWTAuth) touchSession(ctx context.Context, id string) {
+	_ = a.callStore(func(store Storer) error {
+		session, err := store.GetSession(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if now.Sub(session.LastSeenAt) < sessionTouchInterval {
+			return nil
+		}
+
+		return store.TouchSession(ctx, id, now, time.Until(session.ExpiresAt))
 	})
 }
 
@@ -230,6 +344,8 @@ func (a *JWTAuth) ParseToken(ctx context.Context, tokenStr string) (TokenClaims,
 		return nil, err
 	}
 
+	a.touchSession(ctx, claims.ID)
+
 	return claims, nil
 }
 
@@ -258,6 +374,87 @@ func (a *JWTAuth) ParseRefreshToken(ctx context.Context, tokenStr string) (Token
 	return claims, nil
 }
 
+func (a *JWTAuth) RefreshToken(ctx context.Context, tokenStr string, opts ...TokenOption) (TokenInfo, TokenClaims, error) {
+	if tokenStr == "" {
+		return nil, nil, ErrInvalidToken
+	}
+
+	claims, err := a.parseRefreshToken(tokenStr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = a.callStore(func(store Storer) error {
+		// the token is consumed atomically, any other use of it (concurrent or later) is treated as a reuse
+		if ok, err := store.SetRotated(ctx, claims.ID, max(time.Until(claims.ExpiresAt.Time), time.Second)); err != nil {
+			return err
+		} else if !ok {
+			if err := a.revokeFamily(ctx, store, claims.Subject, claims.Family); err != nil {
+				return err
+			}
+			return ErrTokenReused
+		}
+
+		if exists, err := store.Check(ctx, claims.ID); err != nil {
+			return err
+		} else if exists {
+			return ErrInvalidToken
+		}
+		return nil
+	})
+	if err == ErrTokenReused {
+		return nil, claims, err
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	family := claims.Family
+	if family == "" {
+		family = claims.ID
+	}
+
+	// the rotated tokens keep the generation, two-factor authentication and tenant of the login
+	opts = append([]TokenOption{WithGeneration(claims.Generation), WithMFA(claims.MFA), WithTenant(claims.Tenant)}, opts...)
+
+	token, err := a.generateToken(ctx, claims.Subject, family, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := a.RevokeSession(ctx, claims.ID); err != nil {
+		return nil, nil, err
+	}
+
+	return token, claims, nil
+}
+
+// Revoke all sessions of the token family.
+func (a *JWTAuth) revokeFamily(ctx context.Context, store Storer, subject, family string) error {
+	sessions, err := store.ListSessions(ctx, subject)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.Family == family || session.ID == family {
+			if err := a.RevokeSession(ctx, session.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *JWTAuth) JWKS() *JWKS {
+	set := &JWKS{Keys: []JWK{}}
+	for _, key := range a.keys() {
+		if jwk, ok := key.JWK(); ok {
+			set.Keys = append(set.Keys, *jwk)
+		}
+	}
+	return set
+}
+
 func (a *JWTAuth) Release(ctx context.Context) error {
 	return a.callStore(func(store Storer) error {
 		return store.Close(ctx)
@@ -3,6 +3,7 @@ package jwtx
 import (
 	"context"
 	"gin-admin/pkg/cachex"
+	"sync"
 	"testing"
 	"time"
 
@@ -34,6 +35,114 @@ func TestAuth(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.EqualError(t, err, ErrInvalidToken.Error())
 
+	// revoke session invalidates both access and refresh token
+	token, err = jwtAuth.GenerateToken(ctx, userID, WithClient("127.0.0.1", "test-agent", "localhost"))
+	assert.Nil(t, err)
+
+	sessions, err := jwtAuth.ListSessions(ctx, userID)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "127.0.0.1", sessions[0].ClientIP)
+	assert.Equal(t, "test-agent", sessions[0].UserAgent)
+
+	err = jwtAuth.RevokeSession(ctx, sessions[0].ID)
+	assert.Nil(t, err)
+
+	_, err = jwtAuth.ParseToken(ctx, token.GetAccessToken())
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+	_, err = jwtAuth.ParseRefreshToken(ctx, token.GetRefreshToken())
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+
+	sessions, err = jwtAuth.ListSessions(ctx, userID)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 0)
+
+	// the sessions are listed by subject, and touching a revoked session does not bring it back
+	_, err = jwtAuth.GenerateToken(ctx, "other")
+	assert.Nil(t, err)
+	_, err = jwtAuth.GenerateToken(ctx, userID)
+	assert.Nil(t, err)
+	sessions, err = jwtAuth.ListSessions(ctx, userID)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+
+	err = jwtAuth.RevokeSession(ctx, sessions[0].ID)
+	assert.Nil(t, err)
+	err = store.TouchSession(ctx, sessions[0].ID, time.Now(), time.Hour)
+	assert.Nil(t, err)
+	_, err = store.GetSession(ctx, sessions[0].ID)
+	assert.EqualError(t, err, ErrSessionNotFound.Error())
+	sessions, err = jwtAuth.ListSessions(ctx, userID)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 0)
+
 	err = jwtAuth.Release(ctx)
 	assert.Nil(t, err)
 }
+
+func TestRefreshTokenRotation(t *testing.T) {
+	cache := cachex.NewMemoryCache(cachex.MemoryConfig{CleanupInterval: time.Second})
+
+	ctx := context.Background()
+	jwtAuth := New(NewStoreWithCache(cache), SetRefreshExpired(3600))
+
+	userID := "test"
+	token, err := jwtAuth.GenerateToken(ctx, userID, WithGeneration(7), WithMFA(true), WithTenant("tenant"))
+	assert.Nil(t, err)
+
+	rotated, claims, err := jwtAuth.RefreshToken(ctx, token.GetRefreshToken())
+	assert.Nil(t, err)
+	subject, _ := claims.GetSubject()
+	assert.Equal(t, userID, subject)
+	assert.Equal(t, int64(7), GetClaimGeneration(claims))
+
+	// the generation, two-factor authentication and tenant are kept on rotation
+	rotatedClaims, err := jwtAuth.ParseToken(ctx, rotated.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), GetClaimGeneration(rotatedClaims))
+	assert.True(t, GetClaimMFA(rotatedClaims))
+	assert.Equal(t, "tenant", GetClaimTenant(rotatedClaims))
+
+	// the old pair is invalid after rotation
+	_, err = jwtAuth.ParseToken(ctx, token.GetAccessToken())
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+
+	_, err = jwtAuth.ParseToken(ctx, rotated.GetAccessToken())
+	assert.Nil(t, err)
+
+	// reusing the old refresh token revokes the whole family
+	_, _, err = jwtAuth.RefreshToken(ctx, token.GetRefreshToken())
+	assert.EqualError(t, err, ErrTokenReused.Error())
+
+	_, err = jwtAuth.ParseToken(ctx, rotated.GetAccessToken())
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+	_, _, err = jwtAuth.RefreshToken(ctx, rotated.GetRefreshToken())
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+
+	sessions, err := jwtAuth.ListSessions(ctx, userID)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 0)
+
+	// only one of the concurrent rotations of the same token wins
+	token, err = jwtAuth.GenerateToken(ctx, userID)
+	assert.Nil(t, err)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		success int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := jwtAuth.RefreshToken(ctx, token.GetRefreshToken()); err == nil {
+				mu.Lock()
+				success++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, success)
+}
@@ -0,0 +1,222 @@
+package jwtx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidKey = errors.New("invalid key")
+
+// Key is a signing or verification key of tokens.
+type Key struct {
+	ID     string            // Key ID, written to the `kid` header of tokens
+	Method jwt.SigningMethod // Signing method of the key
+	Sign   any               // Key to sign tokens, nil for verification only keys
+	Verify any               // Key to verify tokens
+}
+
+// Create a key of HMAC signing method with the secret, the secret must not be empty.
+func NewHMACKey(id string, method jwt.SigningMethod, secret []byte) (*Key, error) {
+	if _, ok := method.(*jwt.SigningMethodHMAC); !ok || len(secret) == 0 {
+		return nil, ErrInvalidKey
+	}
+	return &Key{
+		ID:     id,
+		Method: method,
+		Sign:   secret,
+		Verify: secret,
+	}, nil
+}
+
+// Parse a PEM encoded key of RSA/ECDSA/Ed25519 signing method, an ECDSA key must be on the curve of the method.
+// A private key can both sign and verify tokens, while a public key can only verify tokens.
+// If id is empty, a thumbprint of the public key is used.
+func ParseKeyPEM(id string, method jwt.SigningMethod, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	var (
+		private crypto.Signer
+		public  crypto.PublicKey
+	)
+
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if s, ok := k.(crypto.Signer); ok {
+			private = s
+		}
+	} else if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		private = k
+	} else if k, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		private = k
+	} else if k, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		public = k
+	} else if k, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		public = k
+	} else if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		public = cert.PublicKey
+	} else {
+		return nil, ErrInvalidKey
+	}
+
+	if private != nil {
+		public = private.Public()
+	}
+
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if _, ok := public.(*rsa.PublicKey); !ok {
+			return nil, ErrInvalidKey
+		}
+	case *jwt.SigningMethodECDSA:
+		// the curve must be the one of the method, e.g. P-256 for ES256
+		pub, ok := public.(*ecdsa.PublicKey)
+		if !ok || pub.Curve.Params().BitSize != m.CurveBits {
+			return nil, ErrInvalidKey
+		}
+	case *jwt.SigningMethodEd25519:
+		if _, ok := public.(ed25519.PublicKey); !ok {
+			return nil, ErrInvalidKey
+		}
+	default:
+		return nil, ErrInvalidKey
+	}
+
+	if id == "" {
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(der)
+		id = base64.RawURLEncoding.EncodeToString(sum[:12])
+	}
+
+	key := &Key{
+		ID:     id,
+		Method: method,
+		Verify: public,
+	}
+	if private != nil {
+		key.Sign = private
+	}
+	return key, nil
+}
+
+// JSON Web Key (RFC 7517) of public keys
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // EC/OKP curve
+	X   string `json:"x,omitempty"`   // EC/OKP x coordinate
+	Y   string `json:"y,omitempty"`   // EC y coordinate
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Get the JSON Web Key of the key, HMAC keys are never exposed.
+func (k *Key) JWK() (*JWK, bool) {
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	jwk := &JWK{
+		Use: "sig",
+		Alg: k.Method.Alg(),
+		Kid: k.ID,
+	}
+
+	switch pub := k.Verify.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return nil, false
+	}
+
+	return jwk, true
+}
+
+// Get the public key of the JSON Web Key, e.g. a key of the JWKS published by an identity provider.
+func (j JWK) PublicKey() (any, error) {
+	b64 := base64.RawURLEncoding.DecodeString
+
+	switch j.Kty {
+	case "RSA":
+		n, err := b64(j.N)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		e, err := b64(j.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, ErrInvalidKey
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrInvalidKey
+		}
+		x, err := b64(j.X)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		y, err := b64(j.Y)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, ErrInvalidKey
+		}
+		return pub, nil
+	case "OKP":
+		x, err := b64(j.X)
+		if err != nil || j.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, ErrInvalidKey
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, ErrInvalidKey
+}
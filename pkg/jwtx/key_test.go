@@ -0,0 +1,111 @@
+package jwtx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func encodePEM(t *testing.T, key any) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestAsymmetricKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+
+	cases := []struct {
+		method jwt.SigningMethod
+		key    any
+		kty    string
+	}{
+		{jwt.SigningMethodRS256, rsaKey, "RSA"},
+		{jwt.SigningMethodES256, ecKey, "EC"},
+		{jwt.SigningMethodEdDSA, edKey, "OKP"},
+	}
+
+	ctx := context.Background()
+	for _, c := range cases {
+		key, err := ParseKeyPEM("", c.method, encodePEM(t, c.key))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, key.ID)
+
+		auth := New(nil, SetSigningKey(key))
+		token, err := auth.GenerateToken(ctx, "test")
+		assert.Nil(t, err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(token.GetAccessToken(), &Claims{})
+		assert.Nil(t, err)
+		assert.Equal(t, key.ID, parsed.Header["kid"])
+		assert.Equal(t, c.method.Alg(), parsed.Header["alg"])
+
+		claims, err := auth.ParseToken(ctx, token.GetAccessToken())
+		assert.Nil(t, err)
+		subject, _ := claims.GetSubject()
+		assert.Equal(t, "test", subject)
+
+		jwks := auth.JWKS()
+		assert.Len(t, jwks.Keys, 1)
+		assert.Equal(t, c.kty, jwks.Keys[0].Kty)
+		assert.Equal(t, key.ID, jwks.Keys[0].Kid)
+
+		pub, err := jwks.Keys[0].PublicKey()
+		assert.Nil(t, err)
+		assert.Equal(t, key.Verify, pub)
+	}
+
+	// a wrong method for the key is rejected
+	_, err = ParseKeyPEM("", jwt.SigningMethodES256, encodePEM(t, rsaKey))
+	assert.Equal(t, ErrInvalidKey, err)
+
+	// the curve of the key must match the method
+	_, err = ParseKeyPEM("", jwt.SigningMethodES384, encodePEM(t, ecKey))
+	assert.Equal(t, ErrInvalidKey, err)
+
+	// an empty secret is rejected
+	_, err = NewHMACKey("", jwt.SigningMethodHS256, nil)
+	assert.Equal(t, ErrInvalidKey, err)
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+
+	oldKey, err := NewHMACKey("old", jwt.SigningMethodHS256, []byte("old-secret"))
+	assert.Nil(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	newKey, err := ParseKeyPEM("new", jwt.SigningMethodES256, encodePEM(t, ecKey))
+	assert.Nil(t, err)
+
+	oldAuth := New(nil, SetSigningKey(oldKey))
+	oldToken, err := oldAuth.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+
+	newAuth := New(nil, SetSigningKey(newKey), AddVerifyKeys(oldKey))
+	_, err = newAuth.ParseToken(ctx, oldToken.GetAccessToken())
+	assert.Nil(t, err)
+
+	// HMAC keys are never published
+	assert.Len(t, newAuth.JWKS().Keys, 1)
+
+	// the old key is dropped after rotation
+	rotatedAuth := New(nil, SetSigningKey(newKey))
+	_, err = rotatedAuth.ParseToken(ctx, oldToken.GetAccessToken())
+	assert.Equal(t, ErrInvalidToken, err)
+}
@@ -0,0 +1,57 @@
+package jwtx
+
+import "time"
+
+// Session is a login session, identified by the claim ID shared by the access and refresh token.
+type Session struct {
+	ID         string    `json:"id"`         // Claim ID
+	Family     string    `json:"family"`     // ID of the token family
+	Subject    string    `json:"subject"`    // Subject of token (user ID)
+	ClientIP   string    `json:"clientIp"`   // Client IP when issued
+	UserAgent  string    `json:"userAgent"`  // User agent when issued
+	Location   string    `json:"location"`   // Geo location of client IP
+	IssuedAt   time.Time `json:"issuedAt"`   // Issue time
+	LastSeenAt time.Time `json:"lastSeenAt"` // Last time the access token was used
+	ExpiresAt  time.Time `json:"expiresAt"`  // Expire time of refresh token
+}
+
+type tokenOptions struct {
+	clientIP   string
+	userAgent  string
+	location   string
+	generation int64
+	mfa        bool
+	tenant     string
+}
+
+type TokenOption func(*tokenOptions)
+
+// Record client information into the session of the token.
+func WithClient(clientIP, userAgent, location string) TokenOption {
+	return func(o *tokenOptions) {
+		o.clientIP = clientIP
+		o.userAgent = userAgent
+		o.location = location
+	}
+}
+
+// Set the token generation of the subject, the application rejects the tokens of an outdated generation.
+func WithGeneration(generation int64) TokenOption {
+	return func(o *tokenOptions) {
+		o.generation = generation
+	}
+}
+
+// Mark the subject passed two-factor authentication at login.
+func WithMFA(mfa bool) TokenOption {
+	return func(o *tokenOptions) {
+		o.mfa = mfa
+	}
+}
+
+// Set the tenant of the subject, the application scopes the requests with the token to the tenant.
+func WithTenant(tenant string) TokenOption {
+	return func(o *tokenOptions) {
+		o.tenant = tenant
+	}
+}
@@ -2,19 +2,40 @@ package jwtx
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
 	"time"
+
+	"gin-admin/pkg/cachex"
 )
 
+var ErrSessionNotFound = errors.New("session not found")
+
 // Storer is the interface that storage the token.
 type Storer interface {
 	Set(ctx context.Context, tokenStr string, expiration time.Duration) error
 	Delete(ctx context.Context, tokenStr string) error
 	Check(ctx context.Context, tokenStr string) (bool, error)
 	Close(ctx context.Context) error
+
+	SetSession(ctx context.Context, session *Session, expiration time.Duration) error
+	GetSession(ctx context.Context, id string) (*Session, error)
+	DeleteSession(ctx context.Context, id string) error
+	ListSessions(ctx context.Context, subject string) ([]*Session, error)
+	// Record the last seen time of the session apart from it, so a revoked session is never written back.
+	TouchSession(ctx context.Context, id string, lastSeenAt time.Time, expiration time.Duration) error
+
+	// Mark the refresh token as used by rotation if it is not yet, it returns false if it has been used.
+	SetRotated(ctx context.Context, id string, expiration time.Duration) (bool, error)
 }
 
 type storeOptions struct {
-	CacheNS string // default "jwt"
+	CacheNS        string // default "jwt"
+	SessionCacheNS string // default "jwt_session"
+	IndexCacheNS   string // default "jwt_session_index", prefix of the session index namespace of each subject
+	SeenCacheNS    string // default "jwt_session_seen"
+	RotatedCacheNS string // default "jwt_rotated"
 }
 
 type StoreOption func(*storeOptions)
@@ -25,11 +46,37 @@ func WithCacheNS(ns string) StoreOption {
 	}
 }
 
+func WithSessionCacheNS(ns string) StoreOption {
+	return func(o *storeOptions) {
+		o.SessionCacheNS = ns
+	}
+}
+
+func WithIndexCacheNS(ns string) StoreOption {
+	return func(o *storeOptions) {
+		o.IndexCacheNS = ns
+	}
+}
+
+func WithSeenCacheNS(ns string) StoreOption {
+	return func(o *storeOptions) {
+		o.SeenCacheNS = ns
+	}
+}
+
+func WithRotatedCacheNS(ns string) StoreOption {
+	return func(o *storeOptions) {
+		o.RotatedCacheNS = ns
+	}
+}
+
 type Cacher interface {
 	Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error
 	Get(ctx context.Context, ns, key string) (string, error)
+	SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error)
 	Exists(ctx context.Context, ns, key string) (bool, error)
 	Delete(ctx context.Context, ns, key string) error
+	Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error
 	Close(ctx context.Context) error
 }
 
@@ -37,7 +84,11 @@ func NewStoreWithCache(cache Cacher, opts ...StoreOption) Storer {
 	s := &storeImpl{
 		c: cache,
 		opts: &storeOptions{
-			CacheNS: "jwt",
+			CacheNS:        "jwt",
+			SessionCacheNS: "jwt_session",
+			IndexCacheNS:   "jwt_session_index",
+			SeenCacheNS:    "jwt_session_seen",
+			RotatedCacheNS: "jwt_rotated",
 		},
 	}
 	for _, opt := range opts {
@@ -66,3 +117,96 @@ func (s *storeImpl) Check(ctx context.Context, tokenStr string) (bool, error) {
 func (s *storeImpl) Close(ctx context.Context) error {
 	return s.c.Close(ctx)
 }
+
+// The session IDs of each subject are indexed in a namespace of its own, so listing them does not scan all sessions.
+func (s *storeImpl) indexNS(subject string) string {
+	return s.opts.IndexCacheNS + ":" + subject
+}
+
+func (s *storeImpl) SetSession(ctx context.Context, session *Session, expiration time.Duration) error {
+	byt, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	if err := s.c.Set(ctx, s.indexNS(session.Subject), session.ID, "", expiration); err != nil {
+		return err
+	}
+	return s.c.Set(ctx, s.opts.SessionCacheNS, session.ID, string(byt), expiration)
+}
+
+func (s *storeImpl) GetSession(ctx context.Context, id string) (*Session, error) {
+	val, err := s.c.Get(ctx, s.opts.SessionCacheNS, id)
+	if err != nil {
+		if errors.Is(err, cachex.ErrNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	session := &Session{}
+	if err := json.Unmarshal([]byte(val), session); err != nil {
+		return nil, err
+	}
+
+	if val, err := s.c.Get(ctx, s.opts.SeenCacheNS, id); err == nil {
+		if sec, err := strconv.ParseInt(val, 10, 64); err == nil && sec > session.LastSeenAt.Unix() {
+			session.LastSeenAt = time.Unix(sec, 0)
+		}
+	} else if !errors.Is(err, cachex.ErrNotFound) {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *storeImpl) DeleteSession(ctx context.Context, id string) error {
+	if session, err := s.GetSession(ctx, id); err == nil {
+		if err := s.c.Delete(ctx, s.indexNS(session.Subject), id); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+
+	if err := s.c.Delete(ctx, s.opts.SeenCacheNS, id); err != nil {
+		return err
+	}
+	return s.c.Delete(ctx, s.opts.SessionCacheNS, id)
+}
+
+func (s *storeImpl) ListSessions(ctx context.Context, subject string) ([]*Session, error) {
+	var ids []string
+	err := s.c.Iterator(ctx, s.indexNS(subject), func(ctx context.Context, key, value string) bool {
+		ids = append(ids, key)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*Session
+	for _, id := range ids {
+		session, err := s.GetSession(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrSessionNotFound) {
+				// drop the index of the session which has gone
+				if err := s.c.Delete(ctx, s.indexNS(subject), id); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (s *storeImpl) TouchSession(ctx context.Context, id string, lastSeenAt time.Time, expiration time.Duration) error {
+	return s.c.Set(ctx, s.opts.SeenCacheNS, id, strconv.FormatInt(lastSeenAt.Unix(), 10), expiration)
+}
+
+// Mark the refresh token as used by rotation, presenting it again means it was leaked.
+// The mark is set only if absent, so only one of the concurrent rotations of the same token wins.
+func (s *storeImpl) SetRotated(ctx context.Context, id string, expiration time.Duration) (bool, error) {
+	return s.c.SetNX(ctx, s.opts.RotatedCacheNS, id, "", expiration)
+}
@@ -17,10 +17,11 @@ const (
 )
 
 const (
-	key_traceID = "traceId"
-	key_userID  = "userId"
-	key_tag     = "tag"
-	key_stack   = "stack"
+	key_traceID  = "traceId"
+	key_userID   = "userId"
+	key_tenantID = "tenantId"
+	key_tag      = "tag"
+	key_stack    = "stack"
 )
 
 type (
@@ -96,6 +97,20 @@ func GetUserID(ctx context.Context) string {
 	return ""
 }
 
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return With(ctx, key_tenantID, tenantID)
+}
+
+func GetTenantID(ctx context.Context) string {
+	v := GetValues(ctx)
+	if v != nil {
+		if s, ok := v[key_tenantID].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
 func WithTag(ctx context.Context, tag string) context.Context {
 	return With(ctx, key_tag, tag)
 }
@@ -9,14 +9,15 @@ import (
 )
 
 type Logger struct {
-	ID        string    `json:"id" gorm:"size:20;primaryKey;"` // Unique ID
-	Level     string    `json:"level" gorm:"size:20;index;"`   // Log level
-	Message   string    `json:"message" gorm:"size:1024;"`     // Log message
-	CreatedAt time.Time `json:"createdAt" gorm:"index;"`       // Create time
-	TraceID   string    `json:"traceId" gorm:"size:64;index;"` // Trace ID
-	UserID    string    `json:"userId" gorm:"size:20;index;"`  // User ID
-	Tag       string    `json:"tag" gorm:"size:32;index;"`     // Log tag
-	Stack     string    `json:"stack" gorm:"type:text;"`       // Error stack
+	ID        string    `json:"id" gorm:"size:20;primaryKey;"`                     // Unique ID
+	Level     string    `json:"level" gorm:"size:20;index;"`                       // Log level
+	Message   string    `json:"message" gorm:"size:1024;"`                         // Log message
+	CreatedAt time.Time `json:"createdAt" gorm:"index;"`                           // Create time
+	TraceID   string    `json:"traceId" gorm:"size:64;index;"`                     // Trace ID
+	UserID    string    `json:"userId" gorm:"size:20;index;"`                      // User ID
+	TenantID  string    `json:"tenantId" gorm:"size:20;index;not null;default:''"` // Tenant ID
+	Tag       string    `json:"tag" gorm:"size:32;index;"`                         // Log tag
+	Stack     string    `json:"stack" gorm:"type:text;"`                           // Error stack
 
 	Meta map[string]any `json:"meta" gorm:"type:text;serializer:json;"` // Log data
 }
@@ -67,6 +68,10 @@ func (h *GormHook) Exec(extra map[string]string, b []byte) error {
 		msg.UserID = v.(string)
 		delete(data, key_userID)
 	}
+	if v, ok := data[key_tenantID]; ok { // tenantId in context
+		msg.TenantID = v.(string)
+		delete(data, key_tenantID)
+	}
 	if v, ok := data[key_tag]; ok { // tag in context
 		msg.Tag = v.(string)
 		delete(data, key_tag)
@@ -0,0 +1,35 @@
+package mail_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gin-admin/pkg/mail"
+	"gin-admin/pkg/mail/mailtest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmtpSender(t *testing.T) {
+	srv := mailtest.NewServer()
+	defer srv.Close()
+
+	sender := &mail.SmtpSender{
+		SmtpHost: srv.Host,
+		Port:     srv.Port,
+		FromName: "Admin",
+		FromMail: "admin@example.com",
+	}
+
+	err := sender.SendTo(context.Background(), []string{"alice@example.com"}, "重置密码", "<p>Hello</p>")
+	assert.Nil(t, err)
+
+	msg, err := srv.Receive(time.Second)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "admin@example.com", msg.From)
+		assert.Equal(t, []string{"alice@example.com"}, msg.To)
+		assert.Equal(t, "重置密码", msg.Subject)
+		assert.Equal(t, "<p>Hello</p>", msg.Body)
+	}
+}
@@ -0,0 +1,196 @@
+// Package mailtest provides a local SMTP server that captures the sent messages for tests.
+package mailtest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrTimeout = errors.New("mailtest: no message received")
+
+// A captured message, the subject and body are decoded.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string
+	Raw     []byte
+}
+
+// A plain SMTP server without TLS and authentication, listening on a random local port.
+type Server struct {
+	Host string
+	Port int
+
+	ln       net.Listener
+	messages chan *Message
+	wg       sync.WaitGroup
+}
+
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	addr := ln.Addr().(*net.TCPAddr)
+	s := &Server{
+		Host:     addr.IP.String(),
+		Port:     addr.Port,
+		ln:       ln,
+		messages: make(chan *Message, 100),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Address of the server in the form of host:port.
+func (s *Server) Addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+func (s *Server) Close() {
+	_ = s.ln.Close()
+	s.wg.Wait()
+}
+
+// Wait for the next message.
+func (s *Server) Receive(timeout time.Duration) (*Message, error) {
+	select {
+	case msg := <-s.messages:
+		return msg, nil
+	case <-time.After(timeout):
+		return nil, ErrTimeout
+	}
+}
+
+// Drop the received messages.
+func (s *Server) Reset() {
+	for {
+		select {
+		case <-s.messages:
+		default:
+			return
+		}
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(time.Minute))
+
+	tp := textproto.NewConn(conn)
+	reply := func(line string) bool {
+		return tp.PrintfLine("%s", line) == nil
+	}
+
+	if !reply("220 mailtest ESMTP") {
+		return
+	}
+
+	msg := &Message{}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO", "EHLO":
+			reply("250 mailtest")
+		case "MAIL":
+			msg = &Message{From: address(arg)}
+			reply("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, address(arg))
+			reply("250 OK")
+		case "DATA":
+			if !reply("354 End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			raw, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			msg.Raw = raw
+			parse(msg)
+			s.messages <- msg
+			msg = &Message{}
+			reply("250 OK")
+		case "RSET":
+			msg = &Message{}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// Extract the address of `FROM:<a@b.c> BODY=8BITMIME`
+func address(arg string) string {
+	start, end := strings.Index(arg, "<"), strings.Index(arg, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
+
+func parse(msg *Message) {
+	m, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(string(msg.Raw))))
+	if err != nil {
+		return
+	}
+
+	dec := new(mime.WordDecoder)
+	if subject, err := dec.DecodeHeader(m.Header.Get("Subject")); err == nil {
+		msg.Subject = subject
+	}
+
+	var body io.Reader = m.Body
+	switch strings.ToLower(m.Header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	if b, err := io.ReadAll(body); err == nil {
+		msg.Body = string(b)
+	}
+}
@@ -12,6 +12,8 @@ type AuthConfig struct {
 	RootID      string
 	Skipper     func(c *gin.Context) bool
 	ParseUserID func(c *gin.Context) (string, error)
+	// Limit the authenticated request, the request is stopped if false, which must be responded
+	Limit func(c *gin.Context) bool
 }
 
 func AuthWithConfig(config AuthConfig) gin.HandlerFunc {
@@ -28,11 +30,19 @@ func AuthWithConfig(config AuthConfig) gin.HandlerFunc {
 		}
 
 		ctx := helper.WithUserID(c.Request.Context(), userID)
+		// the changes made by the user are audited with the client IP
+		if helper.GetClientIP(ctx) == "" {
+			ctx = helper.WithClientIP(ctx, c.ClientIP())
+		}
 		ctx = logger.WithUserID(ctx, userID)
-		if userID == config.RootID {
+		// the request with a role-scoped API key is never the root user
+		if _, scoped := helper.GetAPIKeyRoleIDs(ctx); userID == config.RootID && !scoped {
 			ctx = helper.WithIsRootUser(ctx)
 		}
 		c.Request = c.Request.WithContext(ctx)
+		if config.Limit != nil && !config.Limit(c) {
+			return
+		}
 		c.Next()
 	}
 }
@@ -10,8 +10,10 @@ import (
 
 type CasbinConfig struct {
 	Skipper     func(c *gin.Context) bool
-	GetEnforcer func(c *gin.Context) *casbin.Enforcer
+	GetEnforcer func(c *gin.Context) *casbin.SyncedEnforcer
 	GetSubjects func(c *gin.Context) []string
+	// Attributes of the request passed as the last request field, for the models evaluating conditions
+	GetAttributes func(c *gin.Context) any
 }
 
 func CasbinWithConfig(config CasbinConfig) gin.HandlerFunc {
@@ -29,8 +31,20 @@ func CasbinWithConfig(config CasbinConfig) gin.HandlerFunc {
 			return
 		}
 
+		// the route template is enforced, the path is only used for the unmatched routes
+		obj := c.FullPath()
+		if obj == "" {
+			obj = c.Request.URL.Path
+		}
+
+		rvals := []any{nil, obj, c.Request.Method}
+		if config.GetAttributes != nil {
+			rvals = append(rvals, config.GetAttributes(c))
+		}
+
 		for _, sub := range config.GetSubjects(c) {
-			if b, err := enforcer.Enforce(sub, c.Request.URL.Path, c.Request.Method); err != nil {
+			rvals[0] = sub
+			if b, err := enforcer.Enforce(rvals...); err != nil {
 				response.Error(c, err)
 				return
 			} else if b {
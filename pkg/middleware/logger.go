@@ -44,6 +44,7 @@ func LoggerWithConfig(config LoggerConfig) gin.HandlerFunc {
 			"clientIP":      clientIP,
 			"method":        c.Request.Method,
 			"path":          c.Request.URL.Path,
+			"route":         c.FullPath(),
 			"userAgent":     userAgent,
 			"referer":       c.Request.Referer(),
 			"uri":           c.Request.RequestURI,
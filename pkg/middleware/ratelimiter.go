@@ -2,6 +2,12 @@ package middleware
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"gin-admin/internal/errorx"
@@ -9,6 +15,7 @@ import (
 	"gin-admin/pkg/logger"
 	"gin-admin/pkg/response"
 
+	"github.com/casbin/casbin/v2/util"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/go-redis/redis_rate/v9"
@@ -16,61 +23,205 @@ import (
 	"golang.org/x/time/rate"
 )
 
+const (
+	RateLimitByIP   = "ip"
+	RateLimitByUser = "user"
+)
+
 type RateLimiterConfig struct {
 	Enable             bool
-	Period             int
+	Period             int // seconds
 	MaxRequestsPerIP   int
 	MaxRequestsPerUser int
-	StoreType          string // memory/redis
-	MemoryStoreConfig  RateLimiterMemoryConfig
-	RedisStoreConfig   RateLimiterRedisConfig
+	// Rules matched in order, the first matching rule by ip and the first matching rule by user apply,
+	// the default limits per IP and per user apply if none matches
+	Rules []RateLimitRule
+	// Get the codes of the roles of the authenticated user, only called by the rules with roles
+	GetRoles          func(c *gin.Context) []string
+	StoreType         string // memory/redis
+	MemoryStoreConfig RateLimiterMemoryConfig
+	RedisStoreConfig  RateLimiterRedisConfig
+}
+
+type RateLimitRule struct {
+	Name    string        // Name of the rule, the requests are counted per rule
+	Path    string        // Route template, matched in the keyMatch2 way like /api/v1/users/:id or /api/v1/*
+	Methods []string      // Request methods, all methods if empty
+	Roles   []string      // Codes of the roles, the rule with roles is always by user
+	By      string        // Count the requests per ip or user, ip by default
+	Limit   int           // Maximum requests in the period, unlimited if not positive
+	Period  time.Duration // Period in which the limit is fully refilled
+}
+
+func (r *RateLimitRule) match(c *gin.Context, roles func() []string) bool {
+	if r.Path != "" && !util.KeyMatch2(routePath(c), r.Path) {
+		return false
+	}
+	if len(r.Methods) > 0 && !slices.ContainsFunc(r.Methods, func(method string) bool {
+		return strings.EqualFold(method, c.Request.Method)
+	}) {
+		return false
+	}
+	if len(r.Roles) > 0 {
+		return slices.ContainsFunc(roles(), func(role string) bool {
+			return slices.Contains(r.Roles, role)
+		})
+	}
+	return true
+}
+
+// The route template is matched, the path is only used for the unmatched routes.
+func routePath(c *gin.Context) string {
+	if p := c.FullPath(); p != "" {
+		return p
+	}
+	return c.Request.URL.Path
 }
 
-func RateLimiterWithConfig(config RateLimiterConfig) gin.HandlerFunc {
+// RateLimiter limits the requests by client IP before authentication and by user once authenticated.
+type RateLimiter struct {
+	store  RateLimiterStorer
+	byIP   []RateLimitRule
+	byUser []RateLimitRule
+	// Get the codes of the roles of the authenticated user
+	getRoles func(c *gin.Context) []string
+}
+
+func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
+	a := &RateLimiter{getRoles: config.GetRoles}
 	if !config.Enable {
-		return Empty()
+		return a
 	}
 
-	var store RateLimiterStorer
 	switch config.StoreType {
 	case "redis":
-		store = NewRateLimiterRedisStore(config.RedisStoreConfig)
+		a.store = NewRateLimiterRedisStore(config.RedisStoreConfig)
 	default:
-		store = NewRateLimiterMemoryStore(config.MemoryStoreConfig)
+		a.store = NewRateLimiterMemoryStore(config.MemoryStoreConfig)
 	}
 
-	return func(c *gin.Context) {
-
-		var (
-			allowed bool
-			err     error
-		)
+	for i, rule := range config.Rules {
+		if len(rule.Roles) > 0 {
+			rule.By = RateLimitByUser
+		}
+		if rule.Name == "" {
+			rule.Name = strconv.Itoa(i)
+		}
 
-		ctx := c.Request.Context()
-		if userID := helper.GetUserID(ctx); userID != "" {
-			allowed, err = store.Allow(ctx, userID, time.Second*time.Duration(config.Period), config.MaxRequestsPerUser)
+		if rule.By == RateLimitByUser {
+			a.byUser = append(a.byUser, rule)
 		} else {
-			allowed, err = store.Allow(ctx, c.ClientIP(), time.Second*time.Duration(config.Period), config.MaxRequestsPerIP)
+			rule.By = RateLimitByIP
+			a.byIP = append(a.byIP, rule)
 		}
+	}
 
-		if err != nil {
-			logger.Error(ctx, "Rate limiter middleware error", err)
-			response.Error(c, errorx.ErrInternal.New(ctx))
-		} else if allowed {
+	period := time.Second * time.Duration(config.Period)
+	a.byIP = append(a.byIP, RateLimitRule{Name: "default", By: RateLimitByIP, Limit: config.MaxRequestsPerIP, Period: period})
+	a.byUser = append(a.byUser, RateLimitRule{Name: "default", By: RateLimitByUser, Limit: config.MaxRequestsPerUser, Period: period})
+	return a
+}
+
+// Limit the requests by client IP, it runs before the authentication.
+func (a *RateLimiter) Handler() gin.HandlerFunc {
+	if a.store == nil {
+		return Empty()
+	}
+
+	return func(c *gin.Context) {
+		rule := a.lookup(c, a.byIP)
+		if a.allow(c, rule, c.ClientIP()) {
 			c.Next()
-		} else {
-			response.Error(c, errorx.ErrTooManyRequests.New(ctx))
 		}
 	}
 }
 
+// Limit the authenticated request by user, the request is responded if not allowed.
+func (a *RateLimiter) LimitUser(c *gin.Context) bool {
+	userID := helper.GetUserID(c.Request.Context())
+	if a.store == nil || userID == "" {
+		return true
+	}
+
+	rule := a.lookup(c, a.byUser)
+	return a.allow(c, rule, userID)
+}
+
+// Get the first rule matching the request, the roles are only fetched once if any rule has roles.
+func (a *RateLimiter) lookup(c *gin.Context, rules []RateLimitRule) *RateLimitRule {
+	var roles []string
+	fetched := false
+	getRoles := func() []string {
+		if !fetched && a.getRoles != nil {
+			roles, fetched = a.getRoles(c), true
+		}
+		return roles
+	}
+
+	for i := range rules {
+		if rules[i].match(c, getRoles) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+func (a *RateLimiter) allow(c *gin.Context, rule *RateLimitRule, key string) bool {
+	if rule == nil || rule.Limit <= 0 || rule.Period <= 0 {
+		return true
+	}
+
+	ctx := c.Request.Context()
+	result, err := a.store.Allow(ctx, rule.By+":"+rule.Name+":"+key, rule.Limit, rule.Period)
+	if err != nil {
+		logger.Error(ctx, "Rate limiter middleware error", err)
+		response.Error(c, errorx.ErrInternal.New(ctx))
+		return false
+	}
+
+	setRateLimitHeaders(c, rule, result)
+	if !result.Allowed {
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+		response.Error(c, errorx.ErrTooManyRequests.New(ctx))
+		return false
+	}
+	return true
+}
+
+// Set the RateLimit headers, the most restrictive limit is reported if the request is limited by ip and user.
+func setRateLimitHeaders(c *gin.Context, rule *RateLimitRule, result *RateLimitResult) {
+	if v := c.Writer.Header().Get("RateLimit-Remaining"); v != "" {
+		if remaining, err := strconv.Atoi(v); err == nil && remaining <= result.Remaining {
+			return
+		}
+	}
+
+	c.Header("RateLimit-Limit", strconv.Itoa(rule.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rule.Limit, ceilSeconds(rule.Period)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int           // Requests allowed right now
+	RetryAfter time.Duration // Time until the next request is allowed, zero if allowed
+	ResetAfter time.Duration // Time until the limit is fully available again
+}
+
 type RateLimiterStorer interface {
-	Allow(ctx context.Context, identifier string, period time.Duration, maxRequests int) (bool, error)
+	// Take a request of the identifier from the token bucket holding the limit, which is refilled in the period
+	Allow(ctx context.Context, identifier string, limit int, period time.Duration) (*RateLimitResult, error)
 }
 
 func NewRateLimiterMemoryStore(config RateLimiterMemoryConfig) RateLimiterStorer {
 	return &RateLimiterMemoryStore{
-		cache: cache.New(config.Expiration, config.CleanupInterval),
+		cache:      cache.New(config.Expiration, config.CleanupInterval),
+		expiration: config.Expiration,
 	}
 }
 
@@ -80,25 +231,50 @@ type RateLimiterMemoryConfig struct {
 }
 
 type RateLimiterMemoryStore struct {
-	cache *cache.Cache
+	mu         sync.Mutex
+	cache      *cache.Cache
+	expiration time.Duration
 }
 
-func (s *RateLimiterMemoryStore) Allow(ctx context.Context, identifier string, period time.Duration, maxRequests int) (bool, error) {
-	if period.Seconds() <= 0 || maxRequests <= 0 {
-		return true, nil
+func (s *RateLimiterMemoryStore) Allow(ctx context.Context, identifier string, limit int, period time.Duration) (*RateLimitResult, error) {
+	if period <= 0 || limit <= 0 {
+		return &RateLimitResult{Allowed: true, Remaining: limit}, nil
 	}
 
-	if limiter, exists := s.cache.Get(identifier); exists {
-		isAllow := limiter.(*rate.Limiter).Allow()
-		s.cache.SetDefault(identifier, limiter)
-		return isAllow, nil
+	every := rate.Limit(float64(limit) / period.Seconds())
+
+	s.mu.Lock()
+	limiter, ok := s.cache.Get(identifier)
+	if !ok {
+		limiter = rate.NewLimiter(every, limit)
 	}
+	// the bucket is full again after the period, so it is dropped no earlier than that
+	s.cache.Set(identifier, limiter, max(s.expiration, period))
+	s.mu.Unlock()
+
+	return take(limiter.(*rate.Limiter), every, limit, time.Now()), nil
+}
 
-	limiter := rate.NewLimiter(rate.Every(period), maxRequests)
-	limiter.Allow()
-	s.cache.SetDefault(identifier, limiter)
+// Take a token from the limiter at the time, the limit of the limiter is updated if it is changed.
+func take(limiter *rate.Limiter, every rate.Limit, limit int, now time.Time) *RateLimitResult {
+	if limiter.Limit() != every || limiter.Burst() != limit {
+		limiter.SetLimitAt(now, every)
+		limiter.SetBurstAt(now, limit)
+	}
+
+	result := new(RateLimitResult)
+	r := limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		result.RetryAfter = delay
+	} else {
+		result.Allowed = true
+	}
 
-	return true, nil
+	tokens := limiter.TokensAt(now)
+	result.Remaining = max(int(tokens), 0)
+	result.ResetAfter = time.Duration((float64(limit) - tokens) / float64(every) * float64(time.Second))
+	return result
 }
 
 type RateLimiterRedisConfig struct {
@@ -125,14 +301,20 @@ type RateLimiterRedisStore struct {
 	limiter *redis_rate.Limiter
 }
 
-func (s *RateLimiterRedisStore) Allow(ctx context.Context, identifier string, period time.Duration, maxRequests int) (bool, error) {
-	if period.Seconds() <= 0 || maxRequests <= 0 {
-		return true, nil
+func (s *RateLimiterRedisStore) Allow(ctx context.Context, identifier string, limit int, period time.Duration) (*RateLimitResult, error) {
+	if period <= 0 || limit <= 0 {
+		return &RateLimitResult{Allowed: true, Remaining: limit}, nil
 	}
 
-	result, err := s.limiter.Allow(ctx, identifier, redis_rate.PerSecond(maxRequests/int(period.Seconds())))
+	// GCRA emits a request every period/limit with a burst of the limit, which is the same as the token bucket
+	result, err := s.limiter.Allow(ctx, identifier, redis_rate.Limit{Rate: limit, Burst: limit, Period: period})
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	return result.Allowed > 0, nil
+	return &RateLimitResult{
+		Allowed:    result.Allowed > 0,
+		Remaining:  result.Remaining,
+		RetryAfter: max(result.RetryAfter, 0),
+		ResetAfter: result.ResetAfter,
+	}, nil
 }
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	// 5 requests per minute is a token every 12 seconds with a burst of 5
+	every := rate.Limit(5.0 / 60)
+	limiter := rate.NewLimiter(every, 5)
+	now := time.Now()
+
+	for i := 4; i >= 0; i-- {
+		result := take(limiter, every, 5, now)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+	}
+
+	result := take(limiter, every, 5, now)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 12*time.Second, result.RetryAfter.Round(time.Second))
+	assert.Equal(t, time.Minute, result.ResetAfter.Round(time.Second))
+
+	// a token is refilled after the emission interval
+	result = take(limiter, every, 5, now.Add(12*time.Second))
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	// the changed limit applies to the existing bucket
+	result = take(limiter, rate.Limit(10.0/60), 10, now.Add(24*time.Second))
+	assert.True(t, result.Allowed)
+}
+
+func TestRateLimiterMemoryStore(t *testing.T) {
+	store := NewRateLimiterMemoryStore(RateLimiterMemoryConfig{Expiration: time.Minute, CleanupInterval: time.Minute})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		result, err := store.Allow(ctx, "a", 2, time.Hour)
+		assert.Nil(t, err)
+		assert.True(t, result.Allowed)
+	}
+	result, err := store.Allow(ctx, "a", 2, time.Hour)
+	assert.Nil(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 30*time.Minute, result.RetryAfter.Round(time.Minute))
+
+	// the identifiers are counted separately and the limit under a request per second is not rounded to zero
+	result, err = store.Allow(ctx, "b", 2, time.Hour)
+	assert.Nil(t, err)
+	assert.True(t, result.Allowed)
+}
+
+func TestRateLimiterRules(t *testing.T) {
+	limiter := NewRateLimiter(RateLimiterConfig{
+		Enable:             true,
+		Period:             10,
+		MaxRequestsPerIP:   100,
+		MaxRequestsPerUser: 3,
+		Rules: []RateLimitRule{
+			{Name: "login", Path: "/api/v1/auth/login", Methods: []string{"post"}, Limit: 2, Period: time.Minute},
+			{Name: "export", Path: "/api/v1/*/export", Roles: []string{"guest"}, Limit: 1, Period: time.Hour},
+		},
+		GetRoles: func(c *gin.Context) []string {
+			return []string{c.GetHeader("X-Role")}
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(limiter.Handler())
+	e.POST("/api/v1/auth/login", func(c *gin.Context) { c.Status(http.StatusOK) })
+	auth := AuthWithConfig(AuthConfig{
+		ParseUserID: func(c *gin.Context) (string, error) { return c.GetHeader("X-User"), nil },
+		Limit:       limiter.LimitUser,
+	})
+	e.GET("/api/v1/users/export", auth, func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(method, path, user, role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-User", user)
+		req.Header.Set("X-Role", role)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	// the login rule is counted per IP
+	for i := 1; i >= 0; i-- {
+		w := do("POST", "/api/v1/auth/login", "", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, strconv.Itoa(i), w.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+	}
+	w := do("POST", "/api/v1/auth/login", "", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	// the rule with roles only applies to the users having any of them, the others have the default limit per user
+	assert.Equal(t, http.StatusOK, do("GET", "/api/v1/users/export", "u1", "guest").Code)
+	w = do("GET", "/api/v1/users/export", "u1", "guest")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+
+	for i := 0; i < 3; i++ {
+		w = do("GET", "/api/v1/users/export", "u2", "admin")
+		assert.Equal(t, http.StatusOK, w.Code)
+		// the most restrictive limit of the ip and the user is reported
+		assert.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, do("GET", "/api/v1/users/export", "u2", "admin").Code)
+}
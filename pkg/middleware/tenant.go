@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+
+	"gin-admin/pkg/helper"
+	"gin-admin/pkg/logger"
+	"gin-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TenantConfig struct {
+	Skipper func(c *gin.Context) bool
+	Header  string // Request header carrying the code of the tenant
+	Domain  string // Base domain of the tenant subdomains, the subdomain is the code of the tenant
+	// Resolve the ID of the tenant by its code
+	Resolve func(c *gin.Context, code string) (string, error)
+}
+
+// Scope the request to the tenant resolved from the request header or subdomain,
+// the request without tenant code is out of any tenant.
+func TenantWithConfig(config TenantConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if config.Skipper != nil && config.Skipper(c) {
+			c.Next()
+			return
+		}
+
+		code := strings.TrimSpace(c.GetHeader(config.Header))
+		if code == "" && config.Domain != "" {
+			code = subdomain(c.Request.Host, config.Domain)
+		}
+
+		var tenantID string
+		if code != "" {
+			var err error
+			if tenantID, err = config.Resolve(c, code); err != nil {
+				response.Error(c, err)
+				return
+			}
+		}
+
+		ctx := helper.WithTenantID(c.Request.Context(), tenantID)
+		if tenantID != "" {
+			ctx = logger.WithTenantID(ctx, tenantID)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// Get the direct subdomain of the host under the domain, empty if not any.
+func subdomain(host, domain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	sub, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(domain))
+	if !ok || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
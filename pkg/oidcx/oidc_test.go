@@ -0,0 +1,70 @@
+package oidcx_test
+
+import (
+	"context"
+	"testing"
+
+	"gin-admin/pkg/oidcx"
+	"gin-admin/pkg/oidcx/oidctest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := oidctest.NewServer("client", "secret")
+	defer idp.Close()
+
+	idp.SetClaims(map[string]any{
+		"sub":                "u-1",
+		"email":              "alice@example.com",
+		"preferred_username": "alice",
+	})
+
+	ctx := context.Background()
+	provider := oidcx.New(oidcx.Config{
+		Issuer:       idp.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/callback",
+		Scopes:       []string{"profile", "email"},
+	})
+
+	verifier, err := oidcx.GenerateVerifier()
+	assert.Nil(t, err)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	assert.Nil(t, err)
+	assert.Contains(t, authURL, "code_challenge="+oidcx.Challenge(verifier))
+
+	callback, err := idp.Authorize(authURL)
+	assert.Nil(t, err)
+	assert.Equal(t, "state-1", callback.Query().Get("state"))
+
+	code := callback.Query().Get("code")
+
+	// a wrong verifier is rejected by the provider
+	_, err = provider.Exchange(ctx, code, "wrong")
+	assert.NotNil(t, err)
+
+	callback, err = idp.Authorize(authURL)
+	assert.Nil(t, err)
+	code = callback.Query().Get("code")
+
+	token, err := provider.Exchange(ctx, code, verifier)
+	assert.Nil(t, err)
+
+	// the nonce must match the one of the authorization request
+	_, err = provider.VerifyIDToken(ctx, token.IDToken, "other")
+	assert.ErrorIs(t, err, oidcx.ErrNonceMismatch)
+
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, "nonce-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "u-1", claims.Subject)
+	assert.Equal(t, "alice@example.com", claims.Email)
+	assert.Equal(t, "alice", claims.String("preferred_username"))
+
+	// a token for another client is rejected
+	other := oidcx.New(oidcx.Config{Issuer: idp.URL, ClientID: "other"})
+	_, err = other.VerifyIDToken(ctx, token.IDToken, "nonce-1")
+	assert.ErrorIs(t, err, oidcx.ErrInvalidIDToken)
+}
@@ -0,0 +1,162 @@
+// Package totp implements the time-based one-time password algorithm (RFC 6238)
+// with HMAC-SHA1, as used by the common authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSecret = errors.New("totp: invalid secret")
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type options struct {
+	Digits int
+	Period int // seconds
+	Skew   int // number of periods allowed before and after the current one
+}
+
+type Option func(*options)
+
+func WithDigits(digits int) Option {
+	return func(o *options) {
+		o.Digits = digits
+	}
+}
+
+func WithPeriod(period int) Option {
+	return func(o *options) {
+		o.Period = period
+	}
+}
+
+func WithSkew(skew int) Option {
+	return func(o *options) {
+		o.Skew = skew
+	}
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		Digits: 6,
+		Period: 30,
+		Skew:   1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Generate a random base32 encoded secret (160 bits).
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(b), nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	key, err := b32.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+func hotp(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
+
+// Generate the code of the secret at the given time.
+func GenerateCode(secret string, t time.Time, opts ...Option) (string, error) {
+	o := newOptions(opts...)
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(t.Unix())/uint64(o.Period), o.Digits), nil
+}
+
+// Validate the code against the secret at the given time, allowing the configured clock skew.
+func Validate(secret, code string, t time.Time, opts ...Option) bool {
+	_, ok := Match(secret, code, t, opts...)
+	return ok
+}
+
+// Match the code against the secret at the given time like Validate, it also returns the time step
+// (counter) of the matched code, so the callers can reject the codes of the used steps.
+func Match(secret, code string, t time.Time, opts ...Option) (int64, bool) {
+	o := newOptions(opts...)
+
+	code = strings.TrimSpace(code)
+	if len(code) != o.Digits {
+		return 0, false
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	counter := int64(t.Unix()) / int64(o.Period)
+	for i := -o.Skew; i <= o.Skew; i++ {
+		step := counter + int64(i)
+		if step < 0 {
+			continue
+		}
+		expected := hotp(key, uint64(step), o.Digits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// Build the otpauth:// provisioning URI, which is usually rendered as a QR code for authenticator apps.
+func ProvisioningURI(secret, issuer, account string, opts ...Option) string {
+	o := newOptions(opts...)
+
+	label := url.PathEscape(account)
+	if issuer != "" {
+		label = url.PathEscape(issuer) + ":" + label
+	}
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	if issuer != "" {
+		params.Set("issuer", issuer)
+	}
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", o.Digits))
+	params.Set("period", fmt.Sprintf("%d", o.Period))
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
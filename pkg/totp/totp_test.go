@@ -0,0 +1,64 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test vectors from RFC 6238 Appendix B (SHA1)
+func TestGenerateCode(t *testing.T) {
+	assert := assert.New(t)
+
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+
+	for ts, expected := range vectors {
+		code, err := GenerateCode(secret, time.Unix(ts, 0), WithDigits(8))
+		assert.Nil(err)
+		assert.Equal(expected, code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	secret, err := GenerateSecret()
+	assert.Nil(err)
+
+	now := time.Now()
+	code, err := GenerateCode(secret, now)
+	assert.Nil(err)
+	assert.Len(code, 6)
+
+	assert.True(Validate(secret, code, now))
+	assert.True(Validate(secret, code, now.Add(30*time.Second)))
+	assert.False(Validate(secret, code, now.Add(90*time.Second)))
+	assert.False(Validate(secret, "abc", now))
+	assert.False(Validate("!!invalid", code, now))
+
+	// the step of the code is the same from the next period
+	step, ok := Match(secret, code, now)
+	assert.True(ok)
+	assert.Equal(now.Unix()/30, step)
+	next, ok := Match(secret, code, now.Add(30*time.Second))
+	assert.True(ok)
+	assert.Equal(step, next)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	assert := assert.New(t)
+
+	uri := ProvisioningURI("JBSWY3DPEHPK3PXP", "Gin Admin", "alice")
+	assert.Equal("otpauth://totp/Gin%20Admin:alice?algorithm=SHA1&digits=6&issuer=Gin+Admin&period=30&secret=JBSWY3DPEHPK3PXP", uri)
+}
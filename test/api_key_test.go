@@ -0,0 +1,106 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKey(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+
+	token := login.Data.AccessToken
+
+	// service account
+	var createUser dtos.Result[*models.User]
+	e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.UserCreateReq{
+		Username: "api_key_bot",
+		NickName: "Bot",
+		Password: "test",
+		Status:   models.UserStatus_Activated,
+		Type:     models.UserType_Service,
+		RoleIDs:  []string{},
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createUser)
+
+	bot := createUser.Data
+	assert.Equal(models.UserType_Service, bot.Type)
+
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: "api_key_bot",
+		Password: "test",
+	}).Expect().Status(http.StatusUnauthorized)
+
+	// the role is not owned by the service account
+	e.POST(baseAPI+"/users/"+bot.ID+"/api-keys").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.APIKeyCreateReq{
+		Name:    "scoped",
+		RoleIDs: []string{"not_owned"},
+	}).Expect().Status(http.StatusBadRequest)
+
+	var created dtos.Result[*dtos.APIKeyCreated]
+	e.POST(baseAPI+"/users/"+bot.ID+"/api-keys").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.APIKeyCreateReq{
+		Name: "ci",
+	}).Expect().Status(http.StatusOK).JSON().Decode(&created)
+
+	key := created.Data
+	assert.NotEmpty(key.Key)
+	assert.Contains(key.Key, key.Prefix)
+
+	var user dtos.Result[*models.User]
+	e.GET(baseAPI+"/auth/user").WithHeader("X-API-Key", key.Key).
+		Expect().Status(http.StatusOK).JSON().Decode(&user)
+	assert.Equal(bot.ID, user.Data.ID)
+
+	e.GET(baseAPI+"/auth/user").WithHeader("X-API-Key", key.Key+"x").Expect().Status(http.StatusUnauthorized)
+
+	// an API key can not issue new keys
+	e.POST(baseAPI+"/auth/api-keys").WithHeader("X-API-Key", key.Key).WithJSON(dtos.APIKeyCreateReq{
+		Name: "escalate",
+	}).Expect().Status(http.StatusForbidden)
+
+	var list dtos.Result[[]*models.APIKey]
+	e.GET(baseAPI+"/auth/api-keys").WithHeader("X-API-Key", key.Key).
+		Expect().Status(http.StatusOK).JSON().Decode(&list)
+	if assert.Len(list.Data, 1) {
+		assert.Equal(key.Prefix, list.Data[0].Prefix)
+		assert.NotNil(list.Data[0].LastUsedAt)
+	}
+
+	e.DELETE(baseAPI+"/users/"+bot.ID+"/api-keys/"+key.ID).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusOK)
+
+	e.GET(baseAPI+"/auth/user").WithHeader("X-API-Key", key.Key).Expect().Status(http.StatusUnauthorized)
+
+	// the renamed key is recorded by the audit trail
+	var own dtos.Result[*dtos.APIKeyCreated]
+	e.POST(baseAPI+"/auth/api-keys").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.APIKeyCreateReq{
+		Name: "own",
+	}).Expect().Status(http.StatusOK).JSON().Decode(&own)
+
+	name := "renamed"
+	e.PUT(baseAPI+"/auth/api-keys/"+own.Data.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.APIKeyUpdateReq{Name: &name}).Expect().Status(http.StatusOK)
+
+	var audits dtos.ResultList[*models.Audit]
+	e.GET(baseAPI+"/audits").WithHeader("Authorization", "Bearer "+token).
+		WithQuery("entityType", models.AuditEntity_APIKey).WithQuery("entityId", own.Data.ID).
+		WithQuery("action", models.AuditAction_Update).
+		Expect().Status(http.StatusOK).JSON().Decode(&audits)
+	if assert.Len(audits.Data.Items, 1) {
+		assert.Equal(models.AuditChanges{{Field: "name", Old: "own", New: "renamed"}}, audits.Data.Items[0].Changes)
+	}
+
+	e.DELETE(baseAPI+"/auth/api-keys/"+own.Data.ID).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusOK)
+}
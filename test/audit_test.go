@@ -0,0 +1,159 @@
+package test
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudit(t *testing.T) {
+	e := ApiTester(t)
+
+	t.Cleanup(func() {
+		os.RemoveAll("data")
+	})
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	auth := "Bearer " + login.Data.AccessToken
+
+	assert := assert.New(t)
+
+	menuIDs := make([]string, 2)
+	for i, name := range []string{"audit_a", "audit_b"} {
+		var menu dtos.Result[*models.Menu]
+		e.POST(baseAPI+"/menus").WithHeader("Authorization", auth).WithJSON(dtos.MenuCreateReq{
+			Name:   name,
+			Type:   models.MenuType_MENU,
+			Path:   "/" + name,
+			Status: models.MenuStatus_ENABLED,
+		}).Expect().Status(http.StatusOK).JSON().Decode(&menu)
+		menuIDs[i] = menu.Data.ID
+	}
+
+	var role dtos.Result[*models.Role]
+	e.POST(baseAPI+"/roles").WithHeader("Authorization", auth).WithJSON(dtos.RoleCreateReq{
+		Code:    "audit",
+		Name:    "Audit",
+		MenuIDs: []string{menuIDs[0]},
+		Status:  models.RoleStatus_Enabled,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&role)
+	roleID := role.Data.ID
+
+	name := "Audited"
+	e.PUT(baseAPI+"/roles/"+roleID).WithHeader("Authorization", auth).WithHeader("X-Request-Id", "TRACE-AUDIT").
+		WithTransformer(func(r *http.Request) { r.RemoteAddr = "10.0.0.8:1234" }).
+		WithJSON(dtos.RoleUpdateReq{Name: &name, MenuIDs: &[]string{menuIDs[1]}}).
+		Expect().Status(http.StatusOK)
+
+	// the update without any change is not recorded
+	e.PUT(baseAPI+"/roles/"+roleID).WithHeader("Authorization", auth).
+		WithJSON(dtos.RoleUpdateReq{Name: &name}).
+		Expect().Status(http.StatusOK)
+
+	var audits dtos.ResultList[*models.Audit]
+	e.GET(baseAPI+"/audits").WithHeader("Authorization", auth).
+		WithQuery("entityType", models.AuditEntity_Role).WithQuery("entityId", roleID).
+		Expect().Status(http.StatusOK).JSON().Decode(&audits)
+	if assert.Len(audits.Data.Items, 2) {
+		update, create := audits.Data.Items[0], audits.Data.Items[1]
+		assert.Equal(models.AuditAction_Create, create.Action)
+		assert.Equal(models.AuditAction_Update, update.Action)
+		assert.Equal(configs.C.Super.ID, update.UserID)
+		assert.Equal(configs.C.Super.Username, update.Username)
+		assert.Equal("TRACE-AUDIT", update.TraceID)
+		assert.Equal("10.0.0.8", update.ClientIP)
+
+		changes := make(map[string]*models.AuditChange)
+		for _, change := range update.Changes {
+			changes[change.Field] = change
+		}
+		assert.Len(changes, 2)
+		if assert.Contains(changes, "name") {
+			assert.Equal("Audit", changes["name"].Old)
+			assert.Equal("Audited", changes["name"].New)
+		}
+		if assert.Contains(changes, "menuIds") {
+			assert.Equal([]any{menuIDs[0]}, changes["menuIds"].Old)
+			assert.Equal([]any{menuIDs[1]}, changes["menuIds"].New)
+		}
+	}
+
+	// the password is never exposed in the trail
+	var user dtos.Result[*models.User]
+	e.POST(baseAPI+"/users").WithHeader("Authorization", auth).WithJSON(dtos.UserCreateReq{
+		Username: "audit_user",
+		NickName: "Audit User",
+		Password: "Audit@123456",
+		Status:   models.UserStatus_Activated,
+		RoleIDs:  []string{roleID},
+	}).Expect().Status(http.StatusOK).JSON().Decode(&user)
+	password := "Audit@654321"
+	e.PUT(baseAPI+"/users/"+user.Data.ID).WithHeader("Authorization", auth).
+		WithJSON(dtos.UserUpdateReq{Password: &password}).
+		Expect().Status(http.StatusOK)
+
+	e.GET(baseAPI+"/audits").WithHeader("Authorization", auth).
+		WithQuery("entityId", user.Data.ID).WithQuery("action", models.AuditAction_Update).
+		Expect().Status(http.StatusOK).JSON().Decode(&audits)
+	if assert.Len(audits.Data.Items, 1) && assert.Len(audits.Data.Items[0].Changes, 1) {
+		change := audits.Data.Items[0].Changes[0]
+		assert.Equal("password", change.Field)
+		assert.Equal("******", change.Old)
+		assert.Equal("******", change.New)
+	}
+
+	e.DELETE(baseAPI+"/users/"+user.Data.ID).WithHeader("Authorization", auth).Expect().Status(http.StatusOK)
+	e.GET(baseAPI+"/audits").WithHeader("Authorization", auth).
+		WithQuery("entityType", models.AuditEntity_User).WithQuery("action", models.AuditAction_Delete).
+		Expect().Status(http.StatusOK).JSON().Decode(&audits)
+	if assert.Len(audits.Data.Items, 1) {
+		assert.Equal(user.Data.ID, audits.Data.Items[0].EntityID)
+
+		// the deleted user is recorded with its old values
+		changes := make(map[string]*models.AuditChange)
+		for _, change := range audits.Data.Items[0].Changes {
+			changes[change.Field] = change
+		}
+		if assert.Contains(changes, "username") {
+			assert.Equal("audit_user", changes["username"].Old)
+			assert.Nil(changes["username"].New)
+		}
+		if assert.Contains(changes, "roleIds") {
+			assert.Equal([]any{roleID}, changes["roleIds"].Old)
+		}
+	}
+
+	// the token revocation of the deleted user is recorded as well
+	e.GET(baseAPI+"/audits").WithHeader("Authorization", auth).
+		WithQuery("entityId", user.Data.ID).WithQuery("action", models.AuditAction_RevokeTokens).
+		Expect().Status(http.StatusOK).JSON().Decode(&audits)
+	assert.NotEmpty(audits.Data.Items)
+
+	var dept dtos.Result[*models.Department]
+	e.POST(baseAPI+"/departments").WithHeader("Authorization", auth).WithJSON(dtos.DepartmentCreateReq{
+		Code:   "audit",
+		Name:   "Audit Dept",
+		Status: models.DepartmentStatus_Enabled,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&dept)
+	e.DELETE(baseAPI+"/departments/"+dept.Data.ID).WithHeader("Authorization", auth).Expect().Status(http.StatusOK)
+
+	e.GET(baseAPI+"/audits").WithHeader("Authorization", auth).
+		WithQuery("entityType", models.AuditEntity_Department).WithQuery("entityId", dept.Data.ID).
+		Expect().Status(http.StatusOK).JSON().Decode(&audits)
+	if assert.Len(audits.Data.Items, 2) {
+		deleted, created := audits.Data.Items[0], audits.Data.Items[1]
+		assert.Equal(models.AuditAction_Create, created.Action)
+		assert.Equal(models.AuditAction_Delete, deleted.Action)
+		assert.Contains(deleted.Changes, &models.AuditChange{Field: "name", Old: "Audit Dept"})
+	}
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptcha(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	enable := configs.C.Captcha.Enable
+	configs.C.Captcha.Enable = true
+	defer func() { configs.C.Captcha.Enable = enable }()
+
+	// the captchas are kept in the cache of the app, so every instance can verify them
+	newCaptcha := func() (string, string) {
+		var result dtos.Result[*dtos.Captcha]
+		e.GET(baseAPI + "/captcha/id").Expect().Status(http.StatusOK).JSON().Decode(&result)
+
+		digits, err := appCtx.Cacher().Get(context.Background(), "captcha", result.Data.CaptchaID)
+		assert.Nil(err)
+		code := make([]byte, len(digits))
+		for i := range digits {
+			code[i] = '0' + digits[i]
+		}
+		return result.Data.CaptchaID, string(code)
+	}
+
+	login := func(id, code string) *dtos.Login {
+		return &dtos.Login{
+			Username:    configs.C.Super.Username,
+			Password:    configs.C.Super.Password,
+			CaptchaID:   id,
+			CaptchaCode: code,
+		}
+	}
+
+	e.POST(baseAPI + "/auth/login").WithJSON(login("", "")).Expect().Status(http.StatusBadRequest).
+		JSON().Object().Value("code").IsEqual(2021)
+
+	// the captcha is consumed by the wrong code
+	id, code := newCaptcha()
+	wrong := []byte(code)
+	wrong[0] = '0' + (wrong[0]-'0'+1)%10
+	e.POST(baseAPI + "/auth/login").WithJSON(login(id, string(wrong))).Expect().Status(http.StatusBadRequest)
+	e.POST(baseAPI + "/auth/login").WithJSON(login(id, code)).Expect().Status(http.StatusBadRequest)
+
+	id, code = newCaptcha()
+	e.POST(baseAPI + "/auth/login").WithJSON(login(id, code)).Expect().Status(http.StatusOK)
+	e.POST(baseAPI + "/auth/login").WithJSON(login(id, code)).Expect().Status(http.StatusBadRequest)
+}
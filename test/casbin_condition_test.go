@@ -0,0 +1,113 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/pkg/crypto/hash"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCasbinCondition(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	token := login.Data.AccessToken
+
+	// the menus are enforced against the route templates
+	var createMenu dtos.Result[*models.Menu]
+	e.POST(baseAPI+"/menus").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.MenuCreateReq{
+		Type:   models.MenuType_BUTTON,
+		Method: http.MethodGet,
+		Path:   baseAPI + "/departments/{id}",
+		Status: models.MenuStatus_ENABLED,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createMenu)
+	menu := createMenu.Data
+
+	e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleCreateReq{
+		Code:           "condition",
+		Name:           "condition",
+		Status:         models.RoleStatus_Enabled,
+		MenuIDs:        []string{menu.ID},
+		MenuConditions: map[string]*models.GrantCondition{menu.ID: {CIDRs: []string{"10.0.0.0"}}},
+	}).Expect().Status(http.StatusBadRequest)
+
+	var createRole dtos.Result[*models.Role]
+	e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleCreateReq{
+		Code:           "condition",
+		Name:           "condition",
+		Status:         models.RoleStatus_Enabled,
+		MenuIDs:        []string{menu.ID},
+		MenuConditions: map[string]*models.GrantCondition{menu.ID: {CIDRs: []string{"10.0.0.0/8"}}},
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createRole)
+	role := createRole.Data
+
+	var createUser dtos.Result[*models.User]
+	e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.UserCreateReq{
+		Username: "condition_user",
+		NickName: "condition_user",
+		Password: "Condition-1234",
+		Status:   models.UserStatus_Activated,
+		RoleIDs:  []string{role.ID},
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createUser)
+	user := createUser.Data
+
+	var userLogin dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: "condition_user",
+		Password: hash.MD5String("Condition-1234"),
+	}).Expect().Status(http.StatusOK).JSON().Decode(&userLogin)
+	userToken := userLogin.Data.AccessToken
+
+	// passing the authorization ends with the missing department
+	request := func(clientIP string, status int) {
+		e.GET(baseAPI+"/departments/not-exists").WithHeader("Authorization", "Bearer "+userToken).
+			WithTransformer(func(r *http.Request) { r.RemoteAddr = clientIP + ":1234" }).
+			Expect().Status(status)
+	}
+	updateRole := func(req dtos.RoleUpdateReq) {
+		e.PUT(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).
+			WithJSON(req).Expect().Status(http.StatusOK)
+	}
+
+	request("10.1.2.3", http.StatusNotFound)
+	request("192.168.1.2", http.StatusForbidden)
+
+	// the forwarded client IP is ignored without trusted proxies
+	e.GET(baseAPI+"/departments/not-exists").WithHeader("Authorization", "Bearer "+userToken).
+		WithHeader("X-Forwarded-For", "10.1.2.3").
+		WithTransformer(func(r *http.Request) { r.RemoteAddr = "192.168.1.2:1234" }).
+		Expect().Status(http.StatusForbidden)
+
+	var getRole dtos.Result[*models.Role]
+	e.GET(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusOK).JSON().Decode(&getRole)
+	if assert.Contains(getRole.Data.MenuConditions, menu.ID) {
+		assert.Equal([]string{"10.0.0.0/8"}, getRole.Data.MenuConditions[menu.ID].CIDRs)
+	}
+
+	// the conditions are kept when the menus are granted again
+	updateRole(dtos.RoleUpdateReq{MenuIDs: &[]string{menu.ID}})
+	request("192.168.1.2", http.StatusForbidden)
+
+	// the user did not pass two-factor authentication
+	updateRole(dtos.RoleUpdateReq{MenuConditions: map[string]*models.GrantCondition{menu.ID: {MFA: true}}})
+	request("10.1.2.3", http.StatusForbidden)
+
+	// removing the conditions
+	updateRole(dtos.RoleUpdateReq{MenuConditions: map[string]*models.GrantCondition{}})
+	request("192.168.1.2", http.StatusNotFound)
+
+	e.DELETE(baseAPI+"/users/"+user.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	e.DELETE(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	e.DELETE(baseAPI+"/menus/"+menu.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+}
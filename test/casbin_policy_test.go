@@ -0,0 +1,116 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/pkg/crypto/hash"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCasbinPolicy(t *testing.T) {
+	e := ApiTester(t)
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	token := login.Data.AccessToken
+
+	var createMenu dtos.Result[*models.Menu]
+	e.POST(baseAPI+"/menus").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.MenuCreateReq{
+		Type:   models.MenuType_BUTTON,
+		Method: http.MethodGet,
+		Path:   baseAPI + "/departments",
+		Status: models.MenuStatus_ENABLED,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createMenu)
+	menu := createMenu.Data
+
+	var createParamMenu dtos.Result[*models.Menu]
+	e.POST(baseAPI+"/menus").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.MenuCreateReq{
+		Type:   models.MenuType_BUTTON,
+		Method: http.MethodGet,
+		Path:   baseAPI + "/loggers/{id}",
+		Status: models.MenuStatus_ENABLED,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createParamMenu)
+	paramMenu := createParamMenu.Data
+
+	var createRole dtos.Result[*models.Role]
+	e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleCreateReq{
+		Code:    "policy",
+		Name:    "policy",
+		Status:  models.RoleStatus_Enabled,
+		MenuIDs: []string{menu.ID, paramMenu.ID},
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createRole)
+	role := createRole.Data
+
+	// the grants are given their own IDs
+	var count int64
+	appCtx.DB().Model(new(models.MenuRole)).Where("role_id = ? AND id != ''", role.ID).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	var createUser dtos.Result[*models.User]
+	e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.UserCreateReq{
+		Username: "policy_user",
+		NickName: "policy_user",
+		Password: "Policy-1234",
+		Status:   models.UserStatus_Activated,
+		RoleIDs:  []string{role.ID},
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createUser)
+	user := createUser.Data
+
+	var userLogin dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: "policy_user",
+		Password: hash.MD5String("Policy-1234"),
+	}).Expect().Status(http.StatusOK).JSON().Decode(&userLogin)
+	userToken := userLogin.Data.AccessToken
+
+	request := func(method, path string, status int) {
+		e.Request(method, path).WithHeader("Authorization", "Bearer "+userToken).Expect().Status(status)
+	}
+
+	// the granted resources apply at once
+	request(http.MethodGet, baseAPI+"/departments", http.StatusOK)
+
+	// a grant on a route template does not cover the other routes it would match as a pattern
+	request(http.MethodGet, baseAPI+"/loggers/export", http.StatusForbidden)
+
+	// changes of the resources apply at once
+	method, path := http.MethodGet, baseAPI+"/roles"
+	e.PUT(baseAPI+"/menus/"+menu.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.MenuUpdateReq{Method: &method, Path: &path}).Expect().Status(http.StatusOK)
+	request(http.MethodGet, baseAPI+"/departments", http.StatusForbidden)
+	request(http.MethodGet, baseAPI+"/roles", http.StatusOK)
+
+	// disabling the resources
+	status := models.MenuStatus_DISABLED
+	e.PUT(baseAPI+"/menus/"+menu.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.MenuUpdateReq{Status: &status}).Expect().Status(http.StatusOK)
+	request(http.MethodGet, baseAPI+"/roles", http.StatusForbidden)
+	status = models.MenuStatus_ENABLED
+	e.PUT(baseAPI+"/menus/"+menu.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.MenuUpdateReq{Status: &status}).Expect().Status(http.StatusOK)
+	request(http.MethodGet, baseAPI+"/roles", http.StatusOK)
+
+	// revoking the resources
+	e.PUT(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.RoleUpdateReq{MenuIDs: &[]string{}}).Expect().Status(http.StatusOK)
+	request(http.MethodGet, baseAPI+"/roles", http.StatusForbidden)
+
+	// deleting the resources
+	e.PUT(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.RoleUpdateReq{MenuIDs: &[]string{menu.ID}}).Expect().Status(http.StatusOK)
+	request(http.MethodGet, baseAPI+"/roles", http.StatusOK)
+	e.DELETE(baseAPI+"/menus/"+menu.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	request(http.MethodGet, baseAPI+"/roles", http.StatusForbidden)
+
+	e.DELETE(baseAPI+"/users/"+user.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	e.DELETE(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	e.DELETE(baseAPI+"/menus/"+paramMenu.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+}
@@ -0,0 +1,161 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/pkg/crypto/hash"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataScope(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	// the permissions of the APIs are not under test
+	casbin := configs.C.Middleware.Casbin.Disable
+	configs.C.Middleware.Casbin.Disable = true
+	defer func() { configs.C.Middleware.Casbin.Disable = casbin }()
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	token := login.Data.AccessToken
+
+	createRole := func(code, scope string, deptIDs ...string) *models.Role {
+		var result dtos.Result[*models.Role]
+		e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleCreateReq{
+			Code:        code,
+			Name:        code,
+			Status:      models.RoleStatus_Enabled,
+			DataScope:   scope,
+			DataDeptIDs: deptIDs,
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		assert.Equal(scope, result.Data.DataScope)
+		return result.Data
+	}
+
+	createUser := func(username, deptID string, roleIDs ...string) *models.User {
+		var result dtos.Result[*models.User]
+		e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.UserCreateReq{
+			Username: username,
+			NickName: username,
+			Password: "Scope-1234",
+			Status:   models.UserStatus_Activated,
+			DeptID:   deptID,
+			RoleIDs:  append([]string{}, roleIDs...),
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		return result.Data
+	}
+
+	loginAs := func(username string) string {
+		var result dtos.Result[*dtos.LoginToken]
+		e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+			Username: username,
+			Password: hash.MD5String("Scope-1234"),
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		return result.Data.AccessToken
+	}
+
+	listUsers := func(token string) []string {
+		var result dtos.ResultList[*models.User]
+		e.GET(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithQuery("username", "scope_").
+			Expect().Status(http.StatusOK).JSON().Decode(&result)
+
+		var usernames []string
+		for _, item := range result.Data.Items {
+			usernames = append(usernames, item.Username)
+		}
+		return usernames
+	}
+
+	createDept := func(name, parentID string) *models.Department {
+		var result dtos.Result[*models.Department]
+		e.POST(baseAPI+"/departments").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.DepartmentCreateReq{
+			Name:     name,
+			Status:   models.DepartmentStatus_Enabled,
+			ParentID: parentID,
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		return result.Data
+	}
+
+	d1 := createDept("scope_d1", "")
+	d1a := createDept("scope_d1a", d1.ID)
+	d2 := createDept("scope_d2", "")
+
+	deptRole := createRole("scope_dept", models.RoleDataScope_Dept)
+	subRole := createRole("scope_sub", models.RoleDataScope_DeptAndBelow)
+	customRole := createRole("scope_custom", models.RoleDataScope_Custom, d2.ID)
+	selfRole := createRole("scope_self", models.RoleDataScope_Self)
+
+	users := []*models.User{
+		createUser("scope_manager", d1.ID, deptRole.ID),
+		createUser("scope_member", d1.ID),
+		createUser("scope_other", d2.ID),
+		createUser("scope_auditor", "", customRole.ID),
+		createUser("scope_self", d1.ID, selfRole.ID),
+		createUser("scope_director", d1.ID, subRole.ID),
+		createUser("scope_engineer", d1a.ID),
+	}
+	other := users[2]
+
+	// the own department
+	managerToken := loginAs("scope_manager")
+	assert.ElementsMatch([]string{"scope_manager", "scope_member", "scope_self", "scope_director"}, listUsers(managerToken))
+	e.GET(baseAPI+"/users/"+other.ID).WithHeader("Authorization", "Bearer "+managerToken).
+		Expect().Status(http.StatusNotFound)
+
+	// the users out of the scope can not be changed either
+	e.PUT(baseAPI+"/users/"+other.ID).WithHeader("Authorization", "Bearer "+managerToken).
+		WithJSON(map[string]any{"nickName": "scope_changed"}).Expect().Status(http.StatusNotFound)
+	e.PATCH(baseAPI+"/users/"+other.ID+"/reset-pwd").WithHeader("Authorization", "Bearer "+managerToken).
+		Expect().Status(http.StatusNotFound)
+	e.PATCH(baseAPI+"/users/"+other.ID+"/unlock").WithHeader("Authorization", "Bearer "+managerToken).
+		Expect().Status(http.StatusNotFound)
+	e.DELETE(baseAPI+"/users/"+other.ID).WithHeader("Authorization", "Bearer "+managerToken).
+		Expect().Status(http.StatusNotFound)
+	e.GET(baseAPI+"/users/"+other.ID).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusOK).JSON().Path("$.data.nickName").IsEqual("scope_other")
+
+	// the own department and its sub departments
+	directorToken := loginAs("scope_director")
+	assert.ElementsMatch([]string{"scope_manager", "scope_member", "scope_self", "scope_director", "scope_engineer"}, listUsers(directorToken))
+
+	// the selected departments
+	auditorToken := loginAs("scope_auditor")
+	assert.ElementsMatch([]string{"scope_other"}, listUsers(auditorToken))
+	e.GET(baseAPI+"/users/"+other.ID).WithHeader("Authorization", "Bearer "+auditorToken).
+		Expect().Status(http.StatusOK)
+
+	// the user itself and the users created by it
+	selfToken := loginAs("scope_self")
+	assert.ElementsMatch([]string{"scope_self"}, listUsers(selfToken))
+
+	// the logs are limited by the user who made them
+	e.GET(baseAPI+"/loggers").WithHeader("Authorization", "Bearer "+selfToken).Expect().Status(http.StatusOK)
+
+	// the scope of multiple roles are merged
+	e.PUT(baseAPI+"/users/"+users[4].ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(map[string]any{"roleIds": []string{selfRole.ID, customRole.ID}}).Expect().Status(http.StatusOK)
+	selfToken = loginAs("scope_self")
+	assert.ElementsMatch([]string{"scope_self", "scope_other"}, listUsers(selfToken))
+
+	// the root user is not limited
+	assert.Len(listUsers(token), len(users))
+
+	for _, user := range users {
+		e.DELETE(baseAPI+"/users/"+user.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	}
+	for _, role := range []*models.Role{deptRole, subRole, customRole, selfRole} {
+		e.DELETE(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	}
+	for _, dept := range []*models.Department{d1a, d1, d2} {
+		e.DELETE(baseAPI+"/departments/"+dept.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	}
+}
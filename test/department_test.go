@@ -0,0 +1,164 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDepartment(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	token := login.Data.AccessToken
+
+	createDept := func(name, parentID string) *models.Department {
+		var result dtos.Result[*models.Department]
+		e.POST(baseAPI+"/departments").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.DepartmentCreateReq{
+			Name:     name,
+			Status:   models.DepartmentStatus_Enabled,
+			ParentID: parentID,
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		assert.Equal(parentID, result.Data.ParentID)
+		return result.Data
+	}
+
+	getDept := func(id string) *models.Department {
+		var result dtos.Result[*models.Department]
+		e.GET(baseAPI+"/departments/"+id).WithHeader("Authorization", "Bearer "+token).
+			Expect().Status(http.StatusOK).JSON().Decode(&result)
+		return result.Data
+	}
+
+	moveDept := func(id string, req dtos.DepartmentMoveReq, status int) {
+		e.PUT(baseAPI+"/departments/"+id+"/move").WithHeader("Authorization", "Bearer "+token).WithJSON(req).
+			Expect().Status(status)
+	}
+
+	listUsers := func(deptID string) []string {
+		var result dtos.ResultList[*models.User]
+		e.GET(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithQuery("deptId", deptID).
+			Expect().Status(http.StatusOK).JSON().Decode(&result)
+
+		var usernames []string
+		for _, item := range result.Data.Items {
+			usernames = append(usernames, item.Username)
+		}
+		return usernames
+	}
+
+	// company
+	// ├── dev
+	// │   └── backend
+	// sales
+	company := createDept("dept_company", "")
+	dev := createDept("dept_dev", company.ID)
+	backend := createDept("dept_backend", dev.ID)
+	sales := createDept("dept_sales", "")
+
+	var list dtos.ResultList[*models.Department]
+	e.GET(baseAPI+"/departments").WithHeader("Authorization", "Bearer "+token).WithQuery("name", "dept_").
+		Expect().Status(http.StatusOK).JSON().Decode(&list)
+	assert.Len(list.Data.Items, 4)
+	assert.Len(models.Departments(list.Data.Items).ToTree(), 2)
+
+	// the primary department and the secondary ones
+	users := make([]*models.User, 0, 2)
+	for _, req := range []dtos.UserCreateReq{
+		{Username: "dept_alice", DeptID: dev.ID, DeptIDs: []string{sales.ID}},
+		{Username: "dept_bob", DeptID: backend.ID},
+	} {
+		req.NickName, req.Password, req.Status, req.RoleIDs = req.Username, "Dept-1234", models.UserStatus_Activated, []string{}
+		var result dtos.Result[*models.User]
+		e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(req).
+			Expect().Status(http.StatusOK).JSON().Decode(&result)
+		users = append(users, result.Data)
+	}
+	alice, bob := users[0], users[1]
+	assert.Len(alice.Departments, 2)
+
+	e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.UserCreateReq{
+		Username: "dept_nobody",
+		NickName: "dept_nobody",
+		Password: "Dept-1234",
+		Status:   models.UserStatus_Activated,
+		DeptID:   "not-exists",
+		RoleIDs:  []string{},
+	}).Expect().Status(http.StatusNotFound)
+
+	// users are filtered by the department subtree
+	assert.ElementsMatch([]string{"dept_alice", "dept_bob"}, listUsers(company.ID))
+	assert.ElementsMatch([]string{"dept_bob"}, listUsers(backend.ID))
+	assert.ElementsMatch([]string{"dept_alice"}, listUsers(sales.ID))
+
+	// leaders are added as members
+	e.PUT(baseAPI+"/departments/"+company.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.DepartmentUpdateReq{LeaderIDs: &[]string{bob.ID}}).Expect().Status(http.StatusOK)
+
+	dept := getDept(company.ID)
+	if assert.Len(dept.Leaders, 1) {
+		assert.Equal(bob.ID, dept.Leaders[0].ID)
+	}
+	if assert.NotNil(dept.Children) && assert.Len(*dept.Children, 1) {
+		assert.Equal(dev.ID, (*dept.Children)[0].ID)
+	}
+
+	var getUser dtos.Result[*models.User]
+	e.GET(baseAPI+"/users/"+bob.ID).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusOK).JSON().Decode(&getUser)
+	assert.Equal(backend.ID, getUser.Data.DeptID)
+	assert.Len(getUser.Data.Departments, 2)
+
+	// the leadership is kept while the memberships are changed
+	e.PUT(baseAPI+"/users/"+bob.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.UserUpdateReq{DeptIDs: &[]string{company.ID, sales.ID}}).Expect().Status(http.StatusOK)
+	assert.Len(getDept(company.ID).Leaders, 1)
+	assert.ElementsMatch([]string{"dept_alice", "dept_bob"}, listUsers(sales.ID))
+
+	// departments can not be moved into their own subtree
+	moveDept(company.ID, dtos.DepartmentMoveReq{ParentID: backend.ID}, http.StatusBadRequest)
+	moveDept(company.ID, dtos.DepartmentMoveReq{ParentID: company.ID}, http.StatusBadRequest)
+
+	// the sub departments are moved together
+	moveDept(dev.ID, dtos.DepartmentMoveReq{ParentID: sales.ID}, http.StatusOK)
+	assert.Equal(sales.ID, getDept(dev.ID).ParentID)
+	assert.ElementsMatch([]string{"dept_alice", "dept_bob"}, listUsers(sales.ID))
+	e.PUT(baseAPI+"/users/"+bob.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.UserUpdateReq{DeptIDs: &[]string{}}).Expect().Status(http.StatusOK)
+	assert.Empty(listUsers(company.ID))
+
+	// reorder among the siblings
+	rank := 10
+	moveDept(dev.ID, dtos.DepartmentMoveReq{ParentID: sales.ID, Rank: &rank}, http.StatusOK)
+	assert.Equal(rank, getDept(dev.ID).Rank)
+
+	// disabling a department disables the sub departments
+	disabled := models.DepartmentStatus_Disabled
+	e.PUT(baseAPI+"/departments/"+sales.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.DepartmentUpdateReq{Status: &disabled}).Expect().Status(http.StatusOK)
+	assert.Equal(disabled, getDept(backend.ID).Status)
+
+	// only empty departments can be deleted
+	e.DELETE(baseAPI+"/departments/"+dev.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusBadRequest)
+	e.DELETE(baseAPI+"/departments/"+backend.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusBadRequest)
+
+	for _, user := range users {
+		e.DELETE(baseAPI+"/users/"+user.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	}
+	for _, dept := range []*models.Department{backend, dev, sales} {
+		e.DELETE(baseAPI+"/departments/"+dept.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	}
+	// the leaderships are deleted with the users
+	e.DELETE(baseAPI+"/departments/"+company.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	e.GET(baseAPI+"/departments/"+company.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusNotFound)
+}
@@ -0,0 +1,141 @@
+package test
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/pkg/crypto/hash"
+	"gin-admin/pkg/logger"
+	"gin-admin/pkg/randx"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerExport(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	// the permissions of the APIs are not under test
+	casbin, export := configs.C.Middleware.Casbin.Disable, configs.C.LogExport
+	configs.C.Middleware.Casbin.Disable = true
+	// the logs are read across batches
+	configs.C.LogExport = configs.LogExport{MaxRows: 2, RoleMaxRows: map[string]int{"export_big": 10}, BatchSize: 2}
+	defer func() {
+		configs.C.Middleware.Casbin.Disable = casbin
+		configs.C.LogExport = export
+	}()
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	token := login.Data.AccessToken
+
+	now := time.Now()
+	for i, level := range []string{"info", "error", "info"} {
+		message := "export, \"quoted\""
+		if i == 2 {
+			message = "=HYPERLINK(\"http://example.com\")"
+		}
+		assert.Nil(appCtx.DB().Create(&models.Logger{Logger: logger.Logger{
+			ID:        randx.NewXID(),
+			Level:     level,
+			Message:   message,
+			CreatedAt: now.Add(time.Duration(i-10) * time.Minute),
+			UserID:    configs.C.Super.ID,
+			Tag:       "export_test",
+			Meta:      map[string]any{"index": i},
+		}}).Error)
+	}
+
+	// the logs are streamed in the order of creation with the download headers
+	resp := e.GET(baseAPI+"/loggers/export").WithHeader("Authorization", "Bearer "+token).
+		WithQuery("tag", "export_test").Expect().Status(http.StatusOK)
+	resp.Header("Content-Type").IsEqual("text/csv; charset=utf-8")
+	resp.Header("Content-Disposition").HasPrefix(`attachment; filename="logs-`).HasSuffix(`.csv"`)
+
+	records, err := csv.NewReader(strings.NewReader(resp.Body().Raw())).ReadAll()
+	assert.Nil(err)
+	if assert.Len(records, 4) {
+		assert.Equal("id", records[0][0])
+		assert.Equal("level", records[0][2])
+		assert.Equal([]string{"info", "error", "info"}, []string{records[1][2], records[2][2], records[3][2]})
+		assert.Equal(configs.C.Super.Username, records[1][6])
+		assert.Equal("export, \"quoted\"", records[1][9])
+		assert.Equal(`{"index":0}`, records[1][11])
+		// the formulas are not evaluated by the spreadsheets
+		assert.Equal("'=HYPERLINK(\"http://example.com\")", records[3][9])
+	}
+
+	// the filters of the list apply
+	resp = e.GET(baseAPI+"/loggers/export").WithHeader("Authorization", "Bearer "+token).
+		WithQuery("tag", "export_test").WithQuery("level", "error").WithQuery("format", "ndjson").
+		Expect().Status(http.StatusOK)
+	resp.Header("Content-Type").IsEqual("application/x-ndjson")
+	resp.Header("Content-Disposition").HasSuffix(`.ndjson"`)
+
+	var items []*models.Logger
+	scanner := bufio.NewScanner(strings.NewReader(resp.Body().Raw()))
+	for scanner.Scan() {
+		item := new(models.Logger)
+		assert.Nil(json.Unmarshal(scanner.Bytes(), item))
+		items = append(items, item)
+	}
+	if assert.Len(items, 1) {
+		assert.Equal("error", items[0].Level)
+		assert.Equal("export_test", items[0].Tag)
+	}
+
+	// an empty export still has the header of the columns
+	resp = e.GET(baseAPI+"/loggers/export").WithHeader("Authorization", "Bearer "+token).
+		WithQuery("tag", "export_none").Expect().Status(http.StatusOK)
+	assert.Equal(1, strings.Count(resp.Body().Raw(), "\n"))
+
+	e.GET(baseAPI+"/loggers/export").WithHeader("Authorization", "Bearer "+token).
+		WithQuery("format", "xml").Expect().Status(http.StatusUnprocessableEntity)
+
+	// the cap is the largest of the roles of the user, the default cap applies to the other users
+	createUser := func(username, roleCode string) string {
+		var role dtos.Result[*models.Role]
+		e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleCreateReq{
+			Code:   roleCode,
+			Name:   roleCode,
+			Status: models.RoleStatus_Enabled,
+		}).Expect().Status(http.StatusOK).JSON().Decode(&role)
+
+		e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.UserCreateReq{
+			Username: username,
+			NickName: username,
+			Password: "Export-1234",
+			Status:   models.UserStatus_Activated,
+			RoleIDs:  []string{role.Data.ID},
+		}).Expect().Status(http.StatusOK)
+
+		var result dtos.Result[*dtos.LoginToken]
+		e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+			Username: username,
+			Password: hash.MD5String("Export-1234"),
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		return result.Data.AccessToken
+	}
+
+	small := createUser("export_small", "export_small")
+	e.GET(baseAPI+"/loggers/export").WithHeader("Authorization", "Bearer "+small).
+		WithQuery("tag", "export_test").Expect().Status(http.StatusBadRequest).
+		JSON().Object().Value("code").IsEqual(2058)
+	e.GET(baseAPI+"/loggers/export").WithHeader("Authorization", "Bearer "+small).
+		WithQuery("tag", "export_test").WithQuery("level", "info").Expect().Status(http.StatusOK)
+
+	big := createUser("export_big", "export_big")
+	e.GET(baseAPI+"/loggers/export").WithHeader("Authorization", "Bearer "+big).
+		WithQuery("tag", "export_test").Expect().Status(http.StatusOK)
+}
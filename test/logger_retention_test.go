@@ -0,0 +1,123 @@
+package test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/models"
+	"gin-admin/internal/services"
+	"gin-admin/pkg/logger"
+	"gin-admin/pkg/randx"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerRetention(t *testing.T) {
+	assert := assert.New(t)
+	db := appCtx.DB()
+
+	retention := configs.C.LogRetention
+	archiveDir := t.TempDir()
+	t.Cleanup(func() {
+		configs.C.LogRetention = retention
+	})
+	configs.C.LogRetention.Days = 90
+	configs.C.LogRetention.Rules = []configs.LogRetentionRule{
+		{Tag: "retention_a", Days: 30},
+		{Tag: "retention_b", Days: 0},
+	}
+	configs.C.LogRetention.BatchSize = 2
+	configs.C.LogRetention.BatchPause = 0
+	configs.C.LogRetention.Rollup = true
+	configs.C.LogRetention.Archive.Enable = true
+	configs.C.LogRetention.Archive.Path = archiveDir
+
+	now := time.Now()
+	insert := func(tag, level, tenantID string, days int) {
+		item := &models.Logger{Logger: logger.Logger{
+			ID:        randx.NewXID(),
+			Level:     level,
+			Message:   "retention",
+			CreatedAt: now.AddDate(0, 0, -days),
+			TenantID:  tenantID,
+			Tag:       tag,
+			Meta:      map[string]any{"days": days},
+		}}
+		assert.Nil(db.Create(item).Error)
+	}
+	countOf := func(tag string) int64 {
+		var count int64
+		assert.Nil(db.Model(new(models.Logger)).Where("tag = ?", tag).Count(&count).Error)
+		return count
+	}
+
+	// the rule of tag a keeps 30 days, the logs of tag b are kept forever and the others are kept 90 days
+	for i := 0; i < 3; i++ {
+		insert("retention_a", "info", "", 40)
+	}
+	insert("retention_a", "error", "t1", 40)
+	insert("retention_a", "info", "", 10)
+	insert("retention_b", "info", "", 400)
+	insert("retention_b", "error", "", 400)
+	insert("retention_c", "info", "", 100)
+	insert("retention_c", "info", "", 60)
+
+	ctx := context.Background()
+	svc := services.NewLoggerRetention(appCtx)
+	count, err := svc.PurgeExpired(ctx)
+	assert.Nil(err)
+	assert.Equal(int64(5), count)
+	assert.Equal(int64(1), countOf("retention_a"))
+	assert.Equal(int64(2), countOf("retention_b"))
+	assert.Equal(int64(1), countOf("retention_c"))
+
+	// the purge by time is restricted to the tag and level
+	count, err = svc.Purge(ctx, now.AddDate(0, 0, -30), "retention_b", "error")
+	assert.Nil(err)
+	assert.Equal(int64(1), count)
+	assert.Equal(int64(1), countOf("retention_b"))
+
+	var rollups []*models.LoggerRollup
+	assert.Nil(db.Where("tag LIKE ?", "retention_%").Order("tag, level, tenant_id").Find(&rollups).Error)
+	if assert.Len(rollups, 4) {
+		assert.Equal(now.AddDate(0, 0, -40).Format(time.DateOnly), rollups[0].Day)
+		assert.Equal("error", rollups[0].Level)
+		assert.Equal("t1", rollups[0].TenantID)
+		assert.Equal(int64(1), rollups[0].Total)
+		// the batches of the same day are added up
+		assert.Equal("info", rollups[1].Level)
+		assert.Equal(int64(3), rollups[1].Total)
+		assert.Equal("retention_b", rollups[2].Tag)
+		assert.Equal("retention_c", rollups[3].Tag)
+	}
+
+	// the purged logs are archived before they are deleted
+	files, err := filepath.Glob(filepath.Join(archiveDir, "logger-*.ndjson.gz"))
+	assert.Nil(err)
+	var archived []logger.Logger
+	for _, name := range files {
+		f, err := os.Open(name)
+		assert.Nil(err)
+		gz, err := gzip.NewReader(f)
+		assert.Nil(err)
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			var item logger.Logger
+			assert.Nil(json.Unmarshal(scanner.Bytes(), &item))
+			archived = append(archived, item)
+		}
+		assert.Nil(scanner.Err())
+		f.Close()
+	}
+	if assert.Len(archived, 6) {
+		assert.Equal("retention", archived[0].Message)
+		assert.NotNil(archived[0].Meta["days"])
+	}
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/pkg/logger"
+	"gin-admin/pkg/randx"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerStats(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	auth := "Bearer " + login.Data.AccessToken
+
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.Local)
+	insert := func(minutes int, level, userID string, meta map[string]any) {
+		assert.Nil(appCtx.DB().Create(&models.Logger{Logger: logger.Logger{
+			ID:        randx.NewXID(),
+			Level:     level,
+			Message:   "stats",
+			CreatedAt: base.Add(time.Duration(minutes) * time.Minute),
+			UserID:    userID,
+			Tag:       "stats_test",
+			Meta:      meta,
+		}}).Error)
+	}
+	// the slow paths are grouped by the routes of the paths
+	request := func(method, route string, status, cost int) map[string]any {
+		return map[string]any{"method": method, "path": route + "/" + randx.NewXID(), "route": route, "status": status, "cost": cost}
+	}
+	superID := configs.C.Super.ID
+	insert(5, "info", superID, request("GET", "/a", 200, 10))
+	insert(30, "info", superID, request("POST", "/b", 500, 30))
+	insert(70, "error", superID, request("GET", "/a", 200, 40))
+	insert(120, "warn", "", nil)
+	// the requests of no route are not grouped
+	insert(121, "info", "", request("GET", "", 404, 1000))
+	// out of the range
+	insert(-1, "info", superID, request("GET", "/c", 200, 100))
+
+	var result dtos.Result[*dtos.LoggerStats]
+	e.GET(baseAPI+"/loggers/stats").WithHeader("Authorization", auth).WithQuery("tag", "stats_test").
+		WithQuery("startTime", "2026-01-01 10:00:00").WithQuery("endTime", "2026-01-01 12:30:00").
+		Expect().Status(http.StatusOK).JSON().Decode(&result)
+	stats := result.Data
+
+	assert.Equal("hour", stats.Bucket)
+	assert.Equal(int64(5), stats.Total)
+	assert.Equal([]*dtos.LoggerStatsCount{{Key: "info", Count: 3}, {Key: "error", Count: 1}, {Key: "warn", Count: 1}}, stats.Levels)
+	assert.Equal([]*dtos.LoggerStatsCount{{Key: "stats_test", Count: 5}}, stats.Tags)
+	assert.Equal([]*dtos.LoggerStatsCount{{Key: "200", Count: 2}, {Key: "404", Count: 1}, {Key: "500", Count: 1}}, stats.Statuses)
+
+	if assert.Len(stats.Users, 2) {
+		assert.Equal(superID, stats.Users[0].UserID)
+		assert.Equal(configs.C.Super.Username, stats.Users[0].Username)
+		assert.Equal(int64(3), stats.Users[0].Count)
+		assert.Equal("", stats.Users[1].UserID)
+		assert.Equal(int64(2), stats.Users[1].Count)
+	}
+
+	if assert.Len(stats.Histogram, 3) {
+		assert.True(base.Equal(stats.Histogram[0].Time))
+		assert.Equal(int64(2), stats.Histogram[0].Count)
+		assert.Equal(int64(1), stats.Histogram[1].Count)
+		assert.Equal(int64(1), stats.Histogram[1].Errors)
+		assert.Equal(int64(2), stats.Histogram[2].Count)
+	}
+
+	if assert.Len(stats.SlowPaths, 2) {
+		assert.Equal(dtos.LoggerStatsPath{Method: "POST", Path: "/b", Count: 1, AvgCost: 30, MaxCost: 30}, *stats.SlowPaths[0])
+		assert.Equal(dtos.LoggerStatsPath{Method: "GET", Path: "/a", Count: 2, AvgCost: 25, MaxCost: 40}, *stats.SlowPaths[1])
+	}
+
+	// the top limits the users and slow paths
+	e.GET(baseAPI+"/loggers/stats").WithHeader("Authorization", auth).WithQuery("tag", "stats_test").
+		WithQuery("startTime", "2026-01-01 10:00:00").WithQuery("endTime", "2026-01-01 12:30:00").
+		WithQuery("bucket", "minute").WithQuery("top", 1).
+		Expect().Status(http.StatusOK).JSON().Decode(&result)
+	assert.Len(result.Data.Histogram, 151)
+	assert.Len(result.Data.Users, 1)
+	assert.Len(result.Data.SlowPaths, 1)
+
+	// the range is limited
+	e.GET(baseAPI+"/loggers/stats").WithHeader("Authorization", auth).WithQuery("bucket", "day").
+		WithQuery("startTime", "2025-11-01 10:00:00").WithQuery("endTime", "2026-01-01 12:30:00").
+		Expect().Status(http.StatusBadRequest)
+
+	// the histogram of too many buckets is refused
+	e.GET(baseAPI+"/loggers/stats").WithHeader("Authorization", auth).
+		WithQuery("startTime", "2025-12-30 10:00:00").WithQuery("endTime", "2026-01-01 12:30:00").
+		WithQuery("bucket", "minute").Expect().Status(http.StatusBadRequest)
+	e.GET(baseAPI+"/loggers/stats").WithHeader("Authorization", auth).
+		WithQuery("bucket", "week").Expect().Status(http.StatusUnprocessableEntity)
+}
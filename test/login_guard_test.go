@@ -0,0 +1,55 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginGuard(t *testing.T) {
+	e := ApiTester(t)
+
+	cfg := configs.C.LoginGuard
+	t.Cleanup(func() {
+		configs.C.LoginGuard = cfg
+	})
+
+	configs.C.LoginGuard.Enable = true
+	configs.C.LoginGuard.MaxFailuresPerUser = 3
+	configs.C.LoginGuard.DelayBase = 0
+
+	login := dtos.Login{
+		Username: "brute-force",
+		Password: "wrong-password",
+	}
+
+	for i := 0; i < 3; i++ {
+		e.POST(baseAPI + "/auth/login").WithJSON(login).Expect().Status(http.StatusUnauthorized)
+	}
+
+	e.POST(baseAPI + "/auth/login").WithJSON(login).Expect().Status(http.StatusLocked)
+
+	// the concurrent failures are all counted
+	guard := services.NewLoginGuard(appCtx)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, guard.Fail(ctx, "brute-force-parallel", ""))
+		}()
+	}
+	wg.Wait()
+	assert.NotNil(t, guard.Check(ctx, "brute-force-parallel", ""))
+
+	assert.Nil(t, guard.Unlock(ctx, "brute-force-parallel"))
+	assert.Nil(t, guard.Check(ctx, "brute-force-parallel", ""))
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/services"
+	"gin-admin/pkg/totp"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMFA(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	loginReq := dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}
+
+	var login dtos.Result[*dtos.LoginResult]
+	e.POST(baseAPI + "/auth/login").WithJSON(loginReq).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	assert.Nil(login.Data.MFA)
+
+	token := login.Data.AccessToken
+
+	var setup dtos.Result[*dtos.MFASetup]
+	e.POST(baseAPI+"/auth/mfa/enroll").WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusOK).JSON().Decode(&setup)
+	assert.NotEmpty(setup.Data.Secret)
+	assert.NotEmpty(setup.Data.URI)
+	assert.Len(setup.Data.RecoveryCodes, configs.C.MFA.RecoveryCodes)
+
+	code, err := totp.GenerateCode(setup.Data.Secret, time.Now())
+	assert.Nil(err)
+
+	e.POST(baseAPI+"/auth/mfa/activate").WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.MFACodeReq{Code: code}).Expect().Status(http.StatusOK)
+
+	// login again, a challenge is returned instead of tokens
+	var challenge dtos.Result[*dtos.LoginResult]
+	e.POST(baseAPI + "/auth/login").WithJSON(loginReq).Expect().Status(http.StatusOK).JSON().Decode(&challenge)
+	assert.Nil(challenge.Data.LoginToken)
+	assert.NotNil(challenge.Data.MFA)
+	assert.NotEmpty(challenge.Data.MFA.Token)
+	assert.Nil(challenge.Data.MFA.Setup)
+
+	e.POST(baseAPI + "/auth/mfa/verify").WithJSON(dtos.MFAVerifyReq{
+		Token: challenge.Data.MFA.Token,
+		Code:  "000000x",
+	}).Expect().Status(http.StatusBadRequest)
+
+	// the code of the activation can not be replayed
+	e.POST(baseAPI + "/auth/mfa/verify").WithJSON(dtos.MFAVerifyReq{
+		Token: challenge.Data.MFA.Token,
+		Code:  code,
+	}).Expect().Status(http.StatusBadRequest)
+
+	var verified dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/mfa/verify").WithJSON(dtos.MFAVerifyReq{
+		Token: challenge.Data.MFA.Token,
+		Code:  setup.Data.RecoveryCodes[0],
+	}).Expect().Status(http.StatusOK).JSON().Decode(&verified)
+	assert.NotEmpty(verified.Data.AccessToken)
+
+	// the challenge can only be used once
+	e.POST(baseAPI + "/auth/mfa/verify").WithJSON(dtos.MFAVerifyReq{
+		Token: challenge.Data.MFA.Token,
+		Code:  setup.Data.RecoveryCodes[1],
+	}).Expect().Status(http.StatusUnauthorized)
+
+	// the concurrent failures are all counted and reach the login guard
+	cfg, mfaCfg := configs.C.LoginGuard, configs.C.MFA
+	t.Cleanup(func() {
+		configs.C.LoginGuard, configs.C.MFA = cfg, mfaCfg
+	})
+	configs.C.LoginGuard.Enable = true
+	configs.C.LoginGuard.MaxFailuresPerUser = 3
+	configs.C.LoginGuard.DelayBase = 0
+	configs.C.MFA.ChallengeAttempts = 3
+
+	e.POST(baseAPI + "/auth/login").WithJSON(loginReq).Expect().Status(http.StatusOK).JSON().Decode(&challenge)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.POST(baseAPI + "/auth/mfa/verify").WithJSON(dtos.MFAVerifyReq{
+				Token: challenge.Data.MFA.Token,
+				Code:  "000000",
+			}).Expect()
+		}()
+	}
+	wg.Wait()
+
+	e.POST(baseAPI + "/auth/login").WithJSON(loginReq).Expect().Status(http.StatusLocked)
+	assert.Nil(services.NewLoginGuard(appCtx).Unlock(context.Background(), loginReq.Username))
+
+	// the password alone does not clear the failed codes, so logging in again does not allow more guesses
+	var retry dtos.Result[*dtos.LoginResult]
+	for _, failures := range []int{2, 1} {
+		e.POST(baseAPI + "/auth/login").WithJSON(loginReq).Expect().Status(http.StatusOK).JSON().Decode(&retry)
+		for i := 0; i < failures; i++ {
+			e.POST(baseAPI + "/auth/mfa/verify").WithJSON(dtos.MFAVerifyReq{
+				Token: retry.Data.MFA.Token,
+				Code:  "000000",
+			}).Expect().Status(http.StatusBadRequest)
+		}
+	}
+	e.POST(baseAPI + "/auth/login").WithJSON(loginReq).Expect().Status(http.StatusLocked)
+	assert.Nil(services.NewLoginGuard(appCtx).Unlock(context.Background(), loginReq.Username))
+	configs.C.LoginGuard, configs.C.MFA = cfg, mfaCfg
+
+	// the challenge is dropped after too many attempts
+	e.POST(baseAPI + "/auth/mfa/verify").WithJSON(dtos.MFAVerifyReq{
+		Token: challenge.Data.MFA.Token,
+		Code:  setup.Data.RecoveryCodes[1],
+	}).Expect().Status(http.StatusUnauthorized)
+
+	// the code of the next time step has not been used yet
+	code, err = totp.GenerateCode(setup.Data.Secret, time.Now().Add(30*time.Second))
+	assert.Nil(err)
+
+	e.DELETE(baseAPI+"/auth/mfa").WithHeader("Authorization", "Bearer "+verified.Data.AccessToken).
+		WithJSON(dtos.MFACodeReq{Code: code}).Expect().Status(http.StatusOK)
+}
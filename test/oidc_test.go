@@ -0,0 +1,165 @@
+package test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/pkg/oidcx/oidctest"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOIDC(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	idp := oidctest.NewServer("gin-admin", "secret")
+	defer idp.Close()
+
+	provider := configs.OIDCProvider{
+		Issuer:       idp.URL,
+		ClientID:     "gin-admin",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/callback",
+		DefaultRoles: []string{"oidc_user"},
+	}
+	autoCreate, manual := provider, provider
+	autoCreate.Name, autoCreate.AutoCreate = "idp", true
+	manual.Name = "idp_manual"
+
+	providers := configs.C.OIDC.Providers
+	configs.C.OIDC.Providers = append(providers, autoCreate, manual)
+	defer func() { configs.C.OIDC.Providers = providers }()
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	rootToken := login.Data.AccessToken
+
+	var createRole dtos.Result[*models.Role]
+	e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+rootToken).WithJSON(dtos.RoleCreateReq{
+		Code:   "oidc_user",
+		Name:   "OIDC user",
+		Status: models.RoleStatus_Enabled,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createRole)
+
+	var providerList dtos.Result[[]*dtos.OIDCProvider]
+	e.GET(baseAPI + "/auth/oidc/providers").Expect().Status(http.StatusOK).JSON().Decode(&providerList)
+	assert.Len(providerList.Data, len(providers)+2)
+
+	// sign in at the provider and return the callback query
+	// the state is bound to the user agent by the cookie, which is sent back with the callback
+	signIn := func(start *httpexpect.Request) url.Values {
+		resp := start.Expect().Status(http.StatusFound)
+		callback, err := idp.Authorize(resp.Header("Location").Raw())
+		assert.Nil(err)
+		assert.Equal(resp.Cookie("oidc_state").Value().Raw(), callback.Query().Get("state"))
+		return callback.Query()
+	}
+
+	idp.SetClaims(map[string]any{
+		"sub":                "alice-1",
+		"email":              "alice@example.com",
+		"name":               "Alice",
+		"preferred_username": "oidc_alice",
+	})
+
+	// just-in-time provisioning on the first sign-on
+	query := signIn(e.GET(baseAPI + "/auth/oidc/idp/login").WithRedirectPolicy(httpexpect.DontFollowRedirects))
+
+	var result dtos.Result[*dtos.LoginResult]
+	e.GET(baseAPI+"/auth/oidc/idp/callback").WithQueryString(query.Encode()).WithCookie("oidc_state", query.Get("state")).
+		Expect().Status(http.StatusOK).JSON().Decode(&result)
+	assert.NotEmpty(result.Data.AccessToken)
+	aliceToken := result.Data.AccessToken
+
+	var user dtos.Result[*models.User]
+	e.GET(baseAPI+"/auth/user").WithHeader("Authorization", "Bearer "+aliceToken).
+		Expect().Status(http.StatusOK).JSON().Decode(&user)
+	assert.Equal("oidc_alice", user.Data.Username)
+	assert.Equal("alice@example.com", user.Data.Email)
+	if assert.Len(user.Data.Roles, 1) {
+		assert.Equal(createRole.Data.ID, user.Data.Roles[0].ID)
+	}
+
+	// the provisioned user is audited
+	var audits dtos.ResultList[*models.Audit]
+	e.GET(baseAPI+"/audits").WithHeader("Authorization", "Bearer "+rootToken).
+		WithQuery("entityId", user.Data.ID).WithQuery("action", models.AuditAction_Create).
+		Expect().Status(http.StatusOK).JSON().Decode(&audits)
+	if assert.Len(audits.Data.Items, 1) {
+		assert.Contains(audits.Data.Items[0].Changes, &models.AuditChange{Field: "username", New: "oidc_alice"})
+	}
+
+	// the state can only be used once
+	e.GET(baseAPI+"/auth/oidc/idp/callback").WithQueryString(query.Encode()).WithCookie("oidc_state", query.Get("state")).
+		Expect().Status(http.StatusBadRequest)
+
+	// the sign-on can not be completed by another user agent
+	query = signIn(e.GET(baseAPI + "/auth/oidc/idp/login").WithRedirectPolicy(httpexpect.DontFollowRedirects))
+	ApiTester(t).GET(baseAPI + "/auth/oidc/idp/callback").WithQueryString(query.Encode()).
+		Expect().Status(http.StatusBadRequest)
+
+	// the second sign-on logs into the same user
+	e.GET(baseAPI+"/auth/oidc/idp/callback").WithQueryString(query.Encode()).WithCookie("oidc_state", query.Get("state")).
+		Expect().Status(http.StatusOK).JSON().Decode(&result)
+	e.GET(baseAPI+"/auth/user").WithHeader("Authorization", "Bearer "+result.Data.AccessToken).
+		Expect().Status(http.StatusOK).JSON().Decode(&user)
+	assert.Equal("oidc_alice", user.Data.Username)
+
+	// identities are not provisioned without auto creation
+	idp.SetClaims(map[string]any{"sub": "alice-2"})
+	query = signIn(e.GET(baseAPI + "/auth/oidc/idp_manual/login").WithRedirectPolicy(httpexpect.DontFollowRedirects))
+	e.GET(baseAPI+"/auth/oidc/idp_manual/callback").WithQueryString(query.Encode()).WithCookie("oidc_state", query.Get("state")).
+		Expect().Status(http.StatusForbidden)
+
+	// link the identity to the current user
+	var link dtos.Result[*dtos.OIDCAuthURL]
+	e.POST(baseAPI+"/auth/oidc/idp_manual/link").WithHeader("Authorization", "Bearer "+aliceToken).
+		Expect().Status(http.StatusOK).JSON().Decode(&link)
+	callback, err := idp.Authorize(link.Data.URL)
+	assert.Nil(err)
+
+	linkReq := dtos.OIDCCallbackReq{
+		Code:  callback.Query().Get("code"),
+		State: callback.Query().Get("state"),
+	}
+
+	// it can not be completed by another user
+	e.POST(baseAPI+"/auth/oidc/idp_manual/link/callback").WithHeader("Authorization", "Bearer "+rootToken).
+		WithJSON(linkReq).Expect().Status(http.StatusBadRequest)
+
+	e.POST(baseAPI+"/auth/oidc/idp_manual/link").WithHeader("Authorization", "Bearer "+aliceToken).
+		Expect().Status(http.StatusOK).JSON().Decode(&link)
+	callback, err = idp.Authorize(link.Data.URL)
+	assert.Nil(err)
+
+	linkReq = dtos.OIDCCallbackReq{
+		Code:  callback.Query().Get("code"),
+		State: callback.Query().Get("state"),
+	}
+	e.POST(baseAPI+"/auth/oidc/idp_manual/link/callback").WithHeader("Authorization", "Bearer "+aliceToken).
+		WithJSON(linkReq).Expect().Status(http.StatusOK)
+
+	query = signIn(e.GET(baseAPI + "/auth/oidc/idp_manual/login").WithRedirectPolicy(httpexpect.DontFollowRedirects))
+	e.GET(baseAPI+"/auth/oidc/idp_manual/callback").WithQueryString(query.Encode()).WithCookie("oidc_state", query.Get("state")).
+		Expect().Status(http.StatusOK).JSON().Decode(&result)
+	assert.NotEmpty(result.Data.AccessToken)
+
+	var identities dtos.Result[[]*models.UserIdentity]
+	e.GET(baseAPI+"/auth/identities").WithHeader("Authorization", "Bearer "+aliceToken).
+		Expect().Status(http.StatusOK).JSON().Decode(&identities)
+	assert.Len(identities.Data, 2)
+
+	for _, identity := range identities.Data {
+		e.DELETE(baseAPI+"/auth/identities/"+identity.ID).WithHeader("Authorization", "Bearer "+aliceToken).
+			Expect().Status(http.StatusOK)
+	}
+}
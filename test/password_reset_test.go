@@ -0,0 +1,110 @@
+package test
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/pkg/crypto/hash"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordReset(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	mailbox.Reset()
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	token := login.Data.AccessToken
+
+	var createUser dtos.Result[*models.User]
+	e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.UserCreateReq{
+		Username: "forgetful",
+		NickName: "Forgetful",
+		Password: "Start-1234",
+		Email:    "forgetful@example.com",
+		Status:   models.UserStatus_Activated,
+		RoleIDs:  []string{},
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createUser)
+	user := createUser.Data
+
+	var before dtos.Result[*dtos.LoginResult]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: user.Username,
+		Password: hash.MD5String("Start-1234"),
+	}).Expect().Status(http.StatusOK).JSON().Decode(&before)
+	if !assert.NotNil(before.Data.LoginToken) {
+		return
+	}
+
+	// the response does not tell whether the account exists
+	e.POST(baseAPI + "/auth/password/forgot").WithJSON(dtos.PasswordForgotReq{Email: "nobody@example.com"}).
+		Expect().Status(http.StatusOK)
+	_, err := mailbox.Receive(300 * time.Millisecond)
+	assert.NotNil(err)
+
+	e.POST(baseAPI + "/auth/password/forgot").WithJSON(dtos.PasswordForgotReq{Email: user.Email}).
+		Expect().Status(http.StatusOK)
+	msg, err := mailbox.Receive(5 * time.Second)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal([]string{user.Email}, msg.To)
+	assert.NotEmpty(msg.Subject)
+
+	match := regexp.MustCompile(`<strong>([0-9A-Za-z]+)</strong>`).FindStringSubmatch(msg.Body)
+	if !assert.Len(match, 2) {
+		return
+	}
+	code := match[1]
+
+	// further requests are throttled
+	e.POST(baseAPI + "/auth/password/forgot").WithJSON(dtos.PasswordForgotReq{Email: user.Email}).
+		Expect().Status(http.StatusOK)
+	_, err = mailbox.Receive(300 * time.Millisecond)
+	assert.NotNil(err)
+
+	e.POST(baseAPI + "/auth/password/reset").WithJSON(dtos.PasswordResetReq{Token: "wrong", NewPassword: "Reset-1234"}).
+		Expect().Status(http.StatusBadRequest)
+
+	// the code is kept until the password satisfies the policy
+	e.POST(baseAPI + "/auth/password/reset").WithJSON(dtos.PasswordResetReq{Token: code, NewPassword: "weak"}).
+		Expect().Status(http.StatusBadRequest)
+
+	e.POST(baseAPI + "/auth/password/reset").WithJSON(dtos.PasswordResetReq{Token: code, NewPassword: "Reset-1234"}).
+		Expect().Status(http.StatusOK)
+
+	// the code can only be used once
+	e.POST(baseAPI + "/auth/password/reset").WithJSON(dtos.PasswordResetReq{Token: code, NewPassword: "Other-1234"}).
+		Expect().Status(http.StatusBadRequest)
+
+	var result dtos.Result[*dtos.LoginResult]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: user.Username,
+		Password: hash.MD5String("Reset-1234"),
+	}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+	assert.NotNil(result.Data.LoginToken)
+
+	// the tokens issued before the reset are revoked
+	e.GET(baseAPI+"/auth/user").WithHeader("Authorization", "Bearer "+before.Data.LoginToken.AccessToken).
+		Expect().Status(http.StatusUnauthorized)
+
+	var audits dtos.ResultList[*models.Audit]
+	e.GET(baseAPI+"/audits").WithHeader("Authorization", "Bearer "+token).
+		WithQuery("entityType", models.AuditEntity_User).WithQuery("entityId", user.ID).
+		WithQuery("action", models.AuditAction_RevokeTokens).
+		Expect().Status(http.StatusOK).JSON().Decode(&audits)
+	assert.Len(audits.Data.Items, 1)
+
+	e.DELETE(baseAPI+"/users/"+user.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/pkg/crypto/hash"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	policy := configs.C.Password
+	configs.C.Password.RejectUsername = true
+	configs.C.Password.ChangeOnFirstLogin = true
+	configs.C.Password.ChangeOnReset = true
+	defer func() { configs.C.Password = policy }()
+
+	var login dtos.Result[*dtos.LoginResult]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	token := login.Data.AccessToken
+
+	userReq := dtos.UserCreateReq{
+		Username: "policy",
+		NickName: "Policy",
+		Status:   models.UserStatus_Activated,
+		RoleIDs:  []string{},
+	}
+
+	// length, complexity and username are checked on the plaintext
+	for _, password := range []string{"Ab-1", "abcdefghij", "my-policy-1"} {
+		userReq.Password = password
+		e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(userReq).
+			Expect().Status(http.StatusBadRequest)
+	}
+
+	userReq.Password = "Start-1234"
+	var createUser dtos.Result[*models.User]
+	e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(userReq).
+		Expect().Status(http.StatusOK).JSON().Decode(&createUser)
+	user := createUser.Data
+	assert.True(user.MustChangePassword)
+
+	loginAs := func(password string) *dtos.LoginResult {
+		var result dtos.Result[*dtos.LoginResult]
+		e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+			Username: userReq.Username,
+			Password: password,
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		return result.Data
+	}
+
+	// the password set by an administrator must be changed on first login
+	login.Data = loginAs(hash.MD5String("Start-1234"))
+	assert.Nil(login.Data.LoginToken)
+	if assert.NotNil(login.Data.PasswordChange) {
+		assert.Equal(dtos.PasswordChangeReason_Required, login.Data.PasswordChange.Reason)
+	}
+
+	changeReq := dtos.PasswordChangeReq{Token: login.Data.PasswordChange.Token, NewPassword: "Start-1234"}
+	e.POST(baseAPI + "/auth/password/change").WithJSON(changeReq).Expect().Status(http.StatusBadRequest)
+
+	changeReq.NewPassword = "Second-1234"
+	login = dtos.Result[*dtos.LoginResult]{}
+	e.POST(baseAPI + "/auth/password/change").WithJSON(changeReq).
+		Expect().Status(http.StatusOK).JSON().Decode(&login)
+	assert.Nil(login.Data.PasswordChange)
+	if assert.NotNil(login.Data.LoginToken) {
+		assert.NotEmpty(login.Data.AccessToken)
+	}
+	userToken := login.Data.AccessToken
+	refreshToken := login.Data.RefreshToken
+
+	// the challenge can not be used again
+	e.POST(baseAPI + "/auth/password/change").WithJSON(changeReq).Expect().Status(http.StatusUnauthorized)
+
+	// recent passwords can not be reused
+	e.PUT(baseAPI+"/auth/password").WithHeader("Authorization", "Bearer "+userToken).WithJSON(dtos.AuthUpdatePasswordReq{
+		OldPassword: hash.MD5String("Second-1234"),
+		NewPassword: "Start-1234",
+	}).Expect().Status(http.StatusBadRequest)
+
+	e.PUT(baseAPI+"/auth/password").WithHeader("Authorization", "Bearer "+userToken).WithJSON(dtos.AuthUpdatePasswordReq{
+		OldPassword: hash.MD5String("Second-1234"),
+		NewPassword: "Third-1234",
+	}).Expect().Status(http.StatusOK)
+
+	login.Data = loginAs(hash.MD5String("Third-1234"))
+	assert.Nil(login.Data.PasswordChange)
+	assert.NotNil(login.Data.LoginToken)
+
+	// the password must be changed again after an administrator reset it
+	e.PATCH(baseAPI+"/users/"+user.ID+"/reset-pwd").WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusOK)
+
+	e.POST(baseAPI+"/auth/refresh-token").WithHeader("Authorization", "Bearer "+refreshToken).
+		Expect().Status(http.StatusForbidden)
+
+	login.Data = loginAs(configs.C.DefaultLoginPwd)
+	assert.Nil(login.Data.LoginToken)
+	assert.NotNil(login.Data.PasswordChange)
+
+	e.DELETE(baseAPI+"/users/"+user.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+}
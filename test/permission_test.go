@@ -0,0 +1,160 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/pkg/crypto/hash"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermission(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	token := login.Data.AccessToken
+
+	createMenu := func(method, path, code string) *models.Menu {
+		var result dtos.Result[*models.Menu]
+		e.POST(baseAPI+"/menus").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.MenuCreateReq{
+			Type:   models.MenuType_BUTTON,
+			Method: method,
+			Path:   path,
+			Code:   code,
+			Status: models.MenuStatus_ENABLED,
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		assert.Equal(code, result.Data.Code)
+		return result.Data
+	}
+
+	getMenu := createMenu(http.MethodGet, baseAPI+"/departments/{id}", "perm:department:get")
+	exportMenu := createMenu("", "", "perm:department:export")
+	createDeptMenu := createMenu(http.MethodPost, baseAPI+"/departments", "perm:department:create")
+
+	var createRole dtos.Result[*models.Role]
+	e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleCreateReq{
+		Code:    "permission",
+		Name:    "permission",
+		Status:  models.RoleStatus_Enabled,
+		MenuIDs: []string{getMenu.ID, exportMenu.ID},
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createRole)
+	role := createRole.Data
+
+	var createUser dtos.Result[*models.User]
+	e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.UserCreateReq{
+		Username: "permission_user",
+		NickName: "permission_user",
+		Password: "Permission-1234",
+		Status:   models.UserStatus_Activated,
+		RoleIDs:  []string{role.ID},
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createUser)
+	user := createUser.Data
+
+	var userLogin dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: "permission_user",
+		Password: hash.MD5String("Permission-1234"),
+	}).Expect().Status(http.StatusOK).JSON().Decode(&userLogin)
+	userToken := userLogin.Data.AccessToken
+
+	var codes dtos.Result[[]string]
+	e.GET(baseAPI+"/auth/codes").WithHeader("Authorization", "Bearer "+userToken).
+		Expect().Status(http.StatusOK).JSON().Decode(&codes)
+	assert.Equal([]string{"perm:department:export", "perm:department:get"}, codes.Data)
+
+	e.GET(baseAPI+"/auth/codes").WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusOK).JSON().Decode(&codes)
+	assert.Contains(codes.Data, "perm:department:create")
+
+	items := []*dtos.PermissionCheckItem{
+		{Method: http.MethodGet, Path: baseAPI + "/departments/123"},
+		{Method: http.MethodGet, Path: baseAPI + "/departments/{id}"},
+		{Method: http.MethodPost, Path: baseAPI + "/departments"},
+		{Code: "perm:department:get"},
+		{Code: "perm:department:export"},
+		{Code: "perm:department:create"},
+		{Code: "perm:not-exists"},
+	}
+	check := func(token string) []bool {
+		var result dtos.Result[[]*dtos.PermissionCheckResult]
+		e.POST(baseAPI+"/auth/permissions/check").WithHeader("Authorization", "Bearer "+token).
+			WithJSON(dtos.PermissionCheckReq{Items: items}).
+			Expect().Status(http.StatusOK).JSON().Decode(&result)
+
+		allowed := make([]bool, len(result.Data))
+		for i, item := range result.Data {
+			assert.Equal(*items[i], item.PermissionCheckItem)
+			allowed[i] = item.Allowed
+		}
+		return allowed
+	}
+	assert.Equal([]bool{true, true, false, true, true, false, false}, check(userToken))
+	assert.Equal([]bool{true, true, true, true, true, true, false}, check(token))
+
+	// either the API or the code is required
+	e.POST(baseAPI+"/auth/permissions/check").WithHeader("Authorization", "Bearer "+userToken).
+		WithJSON(dtos.PermissionCheckReq{Items: []*dtos.PermissionCheckItem{{Path: baseAPI + "/departments"}}}).
+		Expect().Status(http.StatusUnprocessableEntity)
+
+	queryCodes := func(req *httpexpect.Request) []string {
+		var result dtos.Result[[]string]
+		req.Expect().Status(http.StatusOK).JSON().Decode(&result)
+		return result.Data
+	}
+	userCodes := func() []string {
+		return queryCodes(e.GET(baseAPI+"/auth/codes").WithHeader("Authorization", "Bearer "+userToken))
+	}
+
+	// the codes follow the roles granted to the API key
+	var createExtra dtos.Result[*models.Role]
+	e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleCreateReq{
+		Code:   "permission_extra",
+		Name:   "permission_extra",
+		Status: models.RoleStatus_Enabled,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createExtra)
+	extra := createExtra.Data
+	e.PUT(baseAPI+"/users/"+user.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(map[string]any{"roleIds": []string{role.ID, extra.ID}}).Expect().Status(http.StatusOK)
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: "permission_user",
+		Password: hash.MD5String("Permission-1234"),
+	}).Expect().Status(http.StatusOK).JSON().Decode(&userLogin)
+	userToken = userLogin.Data.AccessToken
+
+	var createKey dtos.Result[*dtos.APIKeyCreated]
+	e.POST(baseAPI+"/auth/api-keys").WithHeader("Authorization", "Bearer "+userToken).WithJSON(dtos.APIKeyCreateReq{
+		Name:    "permission",
+		RoleIDs: []string{extra.ID},
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createKey)
+	assert.Empty(queryCodes(e.GET(baseAPI+"/auth/codes").WithHeader("X-API-Key", createKey.Data.Key)))
+	assert.Equal([]string{"perm:department:export", "perm:department:get"}, userCodes())
+
+	// the grants with unmet conditions are left out
+	e.PUT(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleUpdateReq{
+		MenuConditions: map[string]*models.GrantCondition{exportMenu.ID: {MFA: true}},
+	}).Expect().Status(http.StatusOK)
+	assert.Equal([]string{"perm:department:get"}, userCodes())
+
+	// the disabled roles grant nothing
+	disabled := models.RoleStatus_Disabled
+	e.PUT(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.RoleUpdateReq{Status: &disabled}).Expect().Status(http.StatusOK)
+	assert.Empty(userCodes())
+
+	e.DELETE(baseAPI+"/users/"+user.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	e.DELETE(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	e.DELETE(baseAPI+"/roles/"+extra.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	for _, menu := range []*models.Menu{getMenu, exportMenu, createDeptMenu} {
+		e.DELETE(baseAPI+"/menus/"+menu.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	}
+}
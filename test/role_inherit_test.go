@@ -0,0 +1,127 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/pkg/crypto/hash"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleInherit(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	token := login.Data.AccessToken
+
+	var createMenu dtos.Result[*models.Menu]
+	e.POST(baseAPI+"/menus").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.MenuCreateReq{
+		Type:   models.MenuType_BUTTON,
+		Method: http.MethodGet,
+		Path:   baseAPI + "/departments",
+		Status: models.MenuStatus_ENABLED,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createMenu)
+	menu := createMenu.Data
+
+	createRole := func(code, parentID string, menuIDs ...string) *models.Role {
+		var result dtos.Result[*models.Role]
+		e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleCreateReq{
+			Code:     code,
+			Name:     code,
+			Status:   models.RoleStatus_Enabled,
+			ParentID: parentID,
+			MenuIDs:  menuIDs,
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		assert.Equal(parentID, result.Data.ParentID)
+		return result.Data
+	}
+
+	getRole := func(id string) *models.Role {
+		var result dtos.Result[*models.Role]
+		e.GET(baseAPI+"/roles/"+id).WithHeader("Authorization", "Bearer "+token).
+			Expect().Status(http.StatusOK).JSON().Decode(&result)
+		return result.Data
+	}
+
+	setParent := func(id, parentID string, status int) {
+		e.PUT(baseAPI+"/roles/"+id).WithHeader("Authorization", "Bearer "+token).
+			WithJSON(dtos.RoleUpdateReq{ParentID: &parentID}).Expect().Status(status)
+	}
+
+	// grandparent <- parent <- child
+	grandparent := createRole("inherit_grandparent", "", menu.ID)
+	parent := createRole("inherit_parent", grandparent.ID)
+	child := createRole("inherit_child", parent.ID)
+
+	e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleCreateReq{
+		Code:     "inherit_orphan",
+		Name:     "inherit_orphan",
+		Status:   models.RoleStatus_Enabled,
+		ParentID: "not-exists",
+	}).Expect().Status(http.StatusNotFound)
+
+	// the effective permissions include the inherited ones
+	role := getRole(child.ID)
+	assert.Empty(role.Menus)
+	if assert.Len(role.EffectiveMenus, 1) {
+		assert.Equal(menu.ID, role.EffectiveMenus[0].ID)
+	}
+
+	// the inheritance can not be cyclic
+	setParent(grandparent.ID, child.ID, http.StatusBadRequest)
+	setParent(parent.ID, parent.ID, http.StatusBadRequest)
+
+	// the users of the sub roles are granted the inherited APIs
+	var createUser dtos.Result[*models.User]
+	e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.UserCreateReq{
+		Username: "inherit_user",
+		NickName: "inherit_user",
+		Password: "Inherit-1234",
+		Status:   models.UserStatus_Activated,
+		RoleIDs:  []string{child.ID},
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createUser)
+	user := createUser.Data
+
+	var userLogin dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: "inherit_user",
+		Password: hash.MD5String("Inherit-1234"),
+	}).Expect().Status(http.StatusOK).JSON().Decode(&userLogin)
+	userToken := userLogin.Data.AccessToken
+
+	// the policies of the changed roles are applied at once
+	e.GET(baseAPI+"/departments").WithHeader("Authorization", "Bearer "+userToken).Expect().Status(http.StatusOK)
+
+	// the inheritance stops at a disabled role
+	disabled := models.RoleStatus_Disabled
+	e.PUT(baseAPI+"/roles/"+parent.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.RoleUpdateReq{Status: &disabled}).Expect().Status(http.StatusOK)
+	assert.Empty(getRole(child.ID).EffectiveMenus)
+	e.GET(baseAPI+"/departments").WithHeader("Authorization", "Bearer "+userToken).Expect().Status(http.StatusForbidden)
+
+	// a disabled role grants nothing by itself either
+	e.PUT(baseAPI+"/roles/"+grandparent.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.RoleUpdateReq{Status: &disabled}).Expect().Status(http.StatusOK)
+	role = getRole(grandparent.ID)
+	assert.Len(role.Menus, 1)
+	assert.Empty(role.EffectiveMenus)
+
+	// the sub roles stop inheriting from the deleted role
+	e.DELETE(baseAPI+"/roles/"+parent.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	assert.Empty(getRole(child.ID).ParentID)
+
+	e.DELETE(baseAPI+"/users/"+user.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	for _, role := range []*models.Role{child, grandparent} {
+		e.DELETE(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	}
+	e.DELETE(baseAPI+"/menus/"+menu.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/internal/repositories"
+	"gin-admin/internal/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteSync(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	token := login.Data.AccessToken
+
+	createMenu := func(path string) *models.Menu {
+		var result dtos.Result[*models.Menu]
+		e.POST(baseAPI+"/menus").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.MenuCreateReq{
+			Type:   models.MenuType_BUTTON,
+			Method: http.MethodGet,
+			Path:   path,
+			Status: models.MenuStatus_ENABLED,
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		return result.Data
+	}
+	matched := createMenu(baseAPI + "/menus/{id}")
+	stale := createMenu(baseAPI + "/not-exists")
+
+	var createRole dtos.Result[*models.Role]
+	e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleCreateReq{
+		Code:    "route_sync",
+		Name:    "route_sync",
+		Status:  models.RoleStatus_Enabled,
+		MenuIDs: []string{stale.ID},
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createRole)
+	role := createRole.Data
+	assert.NotEmpty(appCtx.Casbin().GetEnforcer().GetFilteredPolicy(0, role.ID))
+
+	cfg := &configs.C.Menu.RouteSync
+	cfg.CreateMissing, cfg.DisableStale = true, true
+	defer func() {
+		cfg.CreateMissing, cfg.DisableStale = false, false
+	}()
+
+	hasRoute := func(routes []*dtos.Route, method, path string) bool {
+		return slices.ContainsFunc(routes, func(route *dtos.Route) bool {
+			return route.Method == method && route.Path == path
+		})
+	}
+
+	syncSvc := services.NewRouteSync(appCtx)
+
+	// another instance is syncing
+	locked, err := appCtx.Cacher().SetNX(ctx, "route_sync", "lock", "other", time.Minute)
+	assert.True(locked)
+	assert.NoError(err)
+	result, err := syncSvc.Sync(ctx, engine.Routes())
+	if assert.NoError(err) {
+		assert.Empty(result.Missing)
+		assert.Zero(result.Created)
+	}
+	assert.NoError(appCtx.Cacher().Delete(ctx, "route_sync", "lock"))
+
+	result, err = syncSvc.Sync(ctx, engine.Routes())
+	if assert.NoError(err) {
+		assert.True(hasRoute(result.Missing, http.MethodGet, baseAPI+"/roles"))
+		assert.True(hasRoute(result.Missing, http.MethodPatch, baseAPI+"/users/:id/unlock"))
+		assert.False(hasRoute(result.Missing, http.MethodGet, baseAPI+"/menus/:id"))
+		// the routes not authorized by casbin are excluded
+		assert.False(hasRoute(result.Missing, http.MethodGet, baseAPI+"/auth/user"))
+		assert.Equal(len(result.Missing), result.Created)
+
+		if assert.Len(result.Stale, 1) {
+			assert.Equal(stale.ID, result.Stale[0].ID)
+		}
+		assert.Equal(1, result.Disabled)
+	}
+
+	// the policies of the disabled resources are removed
+	assert.Empty(appCtx.Casbin().GetEnforcer().GetFilteredPolicy(0, role.ID))
+
+	// the created resources cover the routes
+	result, err = syncSvc.Sync(ctx, engine.Routes())
+	if assert.NoError(err) {
+		assert.Empty(result.Missing)
+		assert.Len(result.Stale, 1)
+		assert.Zero(result.Created)
+		assert.Zero(result.Disabled)
+	}
+
+	var getMenu dtos.Result[*models.Menu]
+	e.GET(baseAPI+"/menus/"+stale.ID).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusOK).JSON().Decode(&getMenu)
+	assert.Equal(models.MenuStatus_DISABLED, getMenu.Data.Status)
+
+	catalog, err := repositories.NewMenu(appCtx.DB()).GetChildByName(ctx, "", cfg.Catalog)
+	if assert.NoError(err) {
+		assert.Equal(models.MenuType_CATALOG, catalog.Type)
+		e.DELETE(baseAPI+"/menus/"+catalog.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	}
+	e.DELETE(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	for _, menu := range []*models.Menu{matched, stale} {
+		e.DELETE(baseAPI+"/menus/"+menu.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	}
+}
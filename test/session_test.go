@@ -0,0 +1,65 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	loginReq := dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}
+
+	var login1, login2 dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(loginReq).Expect().Status(http.StatusOK).JSON().Decode(&login1)
+	e.POST(baseAPI + "/auth/login").WithJSON(loginReq).Expect().Status(http.StatusOK).JSON().Decode(&login2)
+
+	token1 := login1.Data.AccessToken
+	token2 := login2.Data.AccessToken
+
+	// find the session ID of the second login
+	var sessions dtos.Result[[]*dtos.Session]
+	e.GET(baseAPI+"/auth/sessions").WithHeader("Authorization", "Bearer "+token2).
+		Expect().Status(http.StatusOK).JSON().Decode(&sessions)
+	assert.GreaterOrEqual(len(sessions.Data), 2)
+
+	var sessionID string
+	for _, s := range sessions.Data {
+		if s.Current {
+			sessionID = s.ID
+		}
+	}
+	assert.NotEmpty(sessionID)
+
+	// revoke the second session from the first one
+	e.DELETE(baseAPI+"/auth/sessions/"+sessionID).WithHeader("Authorization", "Bearer "+token1).
+		Expect().Status(http.StatusOK)
+
+	e.GET(baseAPI+"/auth/user").WithHeader("Authorization", "Bearer "+token2).Expect().Status(http.StatusUnauthorized)
+	e.POST(baseAPI+"/auth/refresh-token").WithHeader("Authorization", "Bearer "+login2.Data.RefreshToken).
+		Expect().Status(http.StatusUnauthorized)
+	e.DELETE(baseAPI+"/auth/sessions/"+sessionID).WithHeader("Authorization", "Bearer "+token1).
+		Expect().Status(http.StatusNotFound)
+
+	e.GET(baseAPI+"/auth/user").WithHeader("Authorization", "Bearer "+token1).Expect().Status(http.StatusOK)
+
+	// refresh token can only be used once, reusing it revokes the rotated tokens too
+	var refreshed dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI+"/auth/refresh-token").WithHeader("Authorization", "Bearer "+login1.Data.RefreshToken).
+		Expect().Status(http.StatusOK).JSON().Decode(&refreshed)
+	e.GET(baseAPI+"/auth/user").WithHeader("Authorization", "Bearer "+token1).Expect().Status(http.StatusUnauthorized)
+	e.GET(baseAPI+"/auth/user").WithHeader("Authorization", "Bearer "+refreshed.Data.AccessToken).Expect().Status(http.StatusOK)
+
+	e.POST(baseAPI+"/auth/refresh-token").WithHeader("Authorization", "Bearer "+login1.Data.RefreshToken).
+		Expect().Status(http.StatusUnauthorized)
+	e.GET(baseAPI+"/auth/user").WithHeader("Authorization", "Bearer "+refreshed.Data.AccessToken).Expect().Status(http.StatusUnauthorized)
+}
@@ -0,0 +1,175 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/internal/services"
+	"gin-admin/pkg/crypto/hash"
+	"gin-admin/pkg/helper"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenant(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	token := login.Data.AccessToken
+
+	// the tenant is created with its super admin
+	var createTenant dtos.Result[*models.Tenant]
+	e.POST(baseAPI+"/tenants").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.TenantCreateReq{
+		Code:          "acme",
+		Name:          "Acme",
+		Status:        models.TenantStatus_Enabled,
+		AdminUsername: "tenant_admin",
+		AdminPassword: "Tenant-1234",
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createTenant)
+	tenant := createTenant.Data
+	assert.NotEmpty(tenant.AdminID)
+
+	e.POST(baseAPI+"/tenants").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.TenantCreateReq{
+		Code:          "acme",
+		Name:          "Acme",
+		Status:        models.TenantStatus_Enabled,
+		AdminUsername: "tenant_admin",
+	}).Expect().Status(http.StatusBadRequest)
+
+	tenantLogin := func(req *httpexpect.Request) string {
+		var result dtos.Result[*dtos.LoginToken]
+		req.WithJSON(dtos.Login{
+			Username: "tenant_admin",
+			Password: hash.MD5String("Tenant-1234"),
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		return result.Data.AccessToken
+	}
+
+	// the tenant is resolved from the header or the subdomain, the users of a tenant are unknown out of it
+	adminToken := tenantLogin(e.POST(baseAPI+"/auth/login").WithHeader("X-Tenant", "acme"))
+	tenantLogin(e.POST(baseAPI + "/auth/login").WithHost("acme.admin.example.com"))
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: "tenant_admin",
+		Password: hash.MD5String("Tenant-1234"),
+	}).Expect().Status(http.StatusUnauthorized)
+	e.POST(baseAPI+"/auth/login").WithHeader("X-Tenant", "unknown").WithJSON(dtos.Login{
+		Username: "tenant_admin",
+		Password: hash.MD5String("Tenant-1234"),
+	}).Expect().Status(http.StatusNotFound)
+
+	// the requests with the token are scoped to the tenant of the token
+	createRole := func(token, code string) *models.Role {
+		var result dtos.Result[*models.Role]
+		e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleCreateReq{
+			Code:   code,
+			Name:   code,
+			Status: models.RoleStatus_Enabled,
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		return result.Data
+	}
+	tenantRole := createRole(adminToken, "tenant_role")
+	assert.Equal(tenant.ID, tenantRole.TenantID)
+	platformRole := createRole(token, "tenant_role")
+	assert.Empty(platformRole.TenantID)
+
+	createUser := func(token, roleID string) *models.User {
+		var result dtos.Result[*models.User]
+		e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.UserCreateReq{
+			Username: "tenant_user",
+			NickName: "tenant_user",
+			Password: "Tenant-1234",
+			Status:   models.UserStatus_Activated,
+			RoleIDs:  []string{roleID},
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		return result.Data
+	}
+	tenantUser := createUser(adminToken, tenantRole.ID)
+	platformUser := createUser(token, platformRole.ID)
+
+	// the tenant admin with a role-scoped API key only has the roles of the key
+	tenantCtx := helper.WithTenantID(context.Background(), tenant.ID)
+	assert.Nil(appCtx.DB().Table(new(models.UserRole).TableName()).Create(map[string]any{
+		"user_id": tenant.AdminID,
+		"role_id": tenantRole.ID,
+	}).Error)
+	scopedKey, err := services.NewAPIKey(appCtx).Create(tenantCtx, tenant.AdminID, &dtos.APIKeyCreateReq{
+		Name:    "scoped",
+		RoleIDs: []string{tenantRole.ID},
+	})
+	if assert.Nil(err) {
+		e.GET(baseAPI+"/users").WithHeader("X-API-Key", scopedKey.Key).Expect().Status(http.StatusForbidden)
+	}
+
+	listUsers := func(token string) []string {
+		var result dtos.ResultList[*models.User]
+		e.GET(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithQuery("username", "tenant_").
+			Expect().Status(http.StatusOK).JSON().Decode(&result)
+
+		var ids []string
+		for _, item := range result.Data.Items {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+	assert.ElementsMatch([]string{tenant.AdminID, tenantUser.ID}, listUsers(adminToken))
+	assert.Equal([]string{platformUser.ID}, listUsers(token))
+
+	e.GET(baseAPI+"/roles/"+platformRole.ID).WithHeader("Authorization", "Bearer "+adminToken).Expect().Status(http.StatusNotFound)
+	e.GET(baseAPI+"/users/"+tenantUser.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusNotFound)
+
+	// the token can not be used in another tenant
+	e.GET(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithHeader("X-Tenant", "acme").
+		Expect().Status(http.StatusUnauthorized)
+
+	// the shared data are only changed out of any tenant
+	e.GET(baseAPI+"/tenants").WithHeader("Authorization", "Bearer "+adminToken).Expect().Status(http.StatusForbidden)
+	e.POST(baseAPI+"/menus").WithHeader("Authorization", "Bearer "+adminToken).WithJSON(dtos.MenuCreateReq{
+		Type:   models.MenuType_BUTTON,
+		Method: http.MethodGet,
+		Path:   baseAPI + "/tenant",
+		Status: models.MenuStatus_ENABLED,
+	}).Expect().Status(http.StatusForbidden)
+
+	// the rotated tokens stay in the tenant
+	var refresh dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI+"/auth/login").WithHeader("X-Tenant", "acme").WithJSON(dtos.Login{
+		Username: "tenant_admin",
+		Password: hash.MD5String("Tenant-1234"),
+	}).Expect().Status(http.StatusOK).JSON().Decode(&refresh)
+	var rotated dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI+"/auth/refresh-token").WithHeader("Authorization", "Bearer "+refresh.Data.RefreshToken).
+		Expect().Status(http.StatusOK).JSON().Decode(&rotated)
+	assert.ElementsMatch([]string{tenant.AdminID, tenantUser.ID}, listUsers(rotated.Data.AccessToken))
+
+	// the requests of a disabled tenant are rejected
+	disabled := models.TenantStatus_Disabled
+	e.PUT(baseAPI+"/tenants/"+tenant.ID).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dtos.TenantUpdateReq{Status: &disabled}).Expect().Status(http.StatusOK)
+	e.GET(baseAPI+"/users").WithHeader("Authorization", "Bearer "+adminToken).Expect().Status(http.StatusForbidden)
+	e.POST(baseAPI+"/auth/login").WithHeader("X-Tenant", "acme").WithJSON(dtos.Login{
+		Username: "tenant_admin",
+		Password: hash.MD5String("Tenant-1234"),
+	}).Expect().Status(http.StatusForbidden)
+
+	// the data of the tenant are deleted with it
+	e.DELETE(baseAPI+"/tenants/"+tenant.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	e.GET(baseAPI+"/tenants/"+tenant.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusNotFound)
+	exists, err := appCtx.DB().Model(new(models.User)).Where("id = ?", tenantUser.ID).Rows()
+	if assert.Nil(err) {
+		assert.False(exists.Next())
+		_ = exists.Close()
+	}
+
+	e.DELETE(baseAPI+"/users/"+platformUser.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	e.DELETE(baseAPI+"/roles/"+platformRole.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+}
@@ -9,6 +9,8 @@ import (
 	"gin-admin/internal/apis"
 	"gin-admin/internal/app"
 	"gin-admin/internal/configs"
+	"gin-admin/internal/types"
+	"gin-admin/pkg/mail/mailtest"
 
 	"github.com/gavv/httpexpect/v2"
 	"github.com/gin-gonic/gin"
@@ -19,7 +21,9 @@ const (
 )
 
 var (
-	engine *gin.Engine
+	engine  *gin.Engine
+	appCtx  types.AppContext // for the services run outside of requests
+	mailbox *mailtest.Server // receives the mails sent by the app
 )
 
 func init() {
@@ -28,6 +32,11 @@ func init() {
 
 	configs.C.DB.AutoMigrate = true
 
+	mailbox = mailtest.NewServer()
+	configs.C.Mail.SmtpHost = mailbox.Host
+	configs.C.Mail.Port = mailbox.Port
+	configs.C.Mail.FromMail = "admin@example.com"
+
 	_ = os.RemoveAll(configs.C.DB.DSN)
 	ctx := context.Background()
 	app := app.New(ctx, configs.C)
@@ -35,8 +44,12 @@ func init() {
 	if err := app.Init(ctx); err != nil {
 		panic(err)
 	}
+	appCtx = app
 
 	engine = gin.New()
+	if err := engine.SetTrustedProxies(configs.C.HTTP.TrustedProxies); err != nil {
+		panic(err)
+	}
 	err := apis.RegisterRouters(app, engine)
 	if err != nil {
 		panic(err)
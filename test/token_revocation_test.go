@@ -0,0 +1,105 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"gin-admin/internal/configs"
+	"gin-admin/internal/dtos"
+	"gin-admin/internal/models"
+	"gin-admin/pkg/crypto/hash"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenRevocation(t *testing.T) {
+	e := ApiTester(t)
+	assert := assert.New(t)
+
+	var login dtos.Result[*dtos.LoginToken]
+	e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+		Username: configs.C.Super.Username,
+		Password: configs.C.Super.Password,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&login)
+	token := login.Data.AccessToken
+
+	var createRole dtos.Result[*models.Role]
+	e.POST(baseAPI+"/roles").WithHeader("Authorization", "Bearer "+token).WithJSON(dtos.RoleCreateReq{
+		Code:   "revocation",
+		Name:   "Revocation",
+		Status: models.RoleStatus_Enabled,
+	}).Expect().Status(http.StatusOK).JSON().Decode(&createRole)
+	role := createRole.Data
+
+	userReq := dtos.UserCreateReq{
+		Username: "revocation",
+		NickName: "Revocation",
+		Password: "Revoke-1234",
+		Status:   models.UserStatus_Activated,
+		RoleIDs:  []string{role.ID},
+	}
+
+	var createUser dtos.Result[*models.User]
+	e.POST(baseAPI+"/users").WithHeader("Authorization", "Bearer "+token).WithJSON(userReq).
+		Expect().Status(http.StatusOK).JSON().Decode(&createUser)
+	user := createUser.Data
+
+	loginAs := func(password string) *dtos.LoginToken {
+		var result dtos.Result[*dtos.LoginToken]
+		e.POST(baseAPI + "/auth/login").WithJSON(dtos.Login{
+			Username: userReq.Username,
+			Password: password,
+		}).Expect().Status(http.StatusOK).JSON().Decode(&result)
+		assert.NotEmpty(result.Data.AccessToken)
+		return result.Data
+	}
+
+	getUser := func(accessToken string) int {
+		return e.GET(baseAPI+"/auth/user").WithHeader("Authorization", "Bearer "+accessToken).Expect().Raw().StatusCode
+	}
+
+	updateUser := func(req map[string]any) {
+		e.PUT(baseAPI+"/users/"+user.ID).WithHeader("Authorization", "Bearer "+token).WithJSON(req).
+			Expect().Status(http.StatusOK)
+	}
+
+	// changes of the profile keep the tokens
+	userToken := loginAs(hash.MD5String(userReq.Password))
+	updateUser(map[string]any{"nickName": "Revocation 1", "roleIds": []string{role.ID}})
+	assert.Equal(http.StatusOK, getUser(userToken.AccessToken))
+
+	// freezing the user
+	updateUser(map[string]any{"status": models.UserStatus_Freezed})
+	assert.Equal(http.StatusUnauthorized, getUser(userToken.AccessToken))
+	updateUser(map[string]any{"status": models.UserStatus_Activated})
+	assert.Equal(http.StatusUnauthorized, getUser(userToken.AccessToken))
+
+	// changing the roles
+	userToken = loginAs(hash.MD5String(userReq.Password))
+	updateUser(map[string]any{"roleIds": []string{}})
+	assert.Equal(http.StatusUnauthorized, getUser(userToken.AccessToken))
+
+	// resetting the password, the refresh token is rejected as well
+	userToken = loginAs(hash.MD5String(userReq.Password))
+	e.PATCH(baseAPI+"/users/"+user.ID+"/reset-pwd").WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(http.StatusOK)
+	assert.Equal(http.StatusUnauthorized, getUser(userToken.AccessToken))
+	e.POST(baseAPI+"/auth/refresh-token").WithHeader("Authorization", "Bearer "+userToken.RefreshToken).
+		Expect().Status(http.StatusUnauthorized)
+
+	// changing the own password
+	userToken = loginAs(configs.C.DefaultLoginPwd)
+	e.PUT(baseAPI+"/auth/password").WithHeader("Authorization", "Bearer "+userToken.AccessToken).WithJSON(dtos.AuthUpdatePasswordReq{
+		OldPassword: configs.C.DefaultLoginPwd,
+		NewPassword: "Changed-1234",
+	}).Expect().Status(http.StatusOK)
+	assert.Equal(http.StatusUnauthorized, getUser(userToken.AccessToken))
+
+	// deleting the user
+	userToken = loginAs(hash.MD5String("Changed-1234"))
+	assert.Equal(http.StatusOK, getUser(userToken.AccessToken))
+	e.DELETE(baseAPI+"/users/"+user.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+	assert.Equal(http.StatusUnauthorized, getUser(userToken.AccessToken))
+
+	e.DELETE(baseAPI+"/roles/"+role.ID).WithHeader("Authorization", "Bearer "+token).Expect().Status(http.StatusOK)
+}